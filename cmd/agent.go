@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"shard/internal/attack"
+)
+
+// agentTokenEnv holds the secret agents and their controller share. It is
+// read from the environment only, so it never shows up in a process list.
+const agentTokenEnv = "SHARD_AGENT_TOKEN"
+
+func runAgent(args []string) error {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:7777", "Address to accept runs from a controller on")
+	certFile := fs.String("tls-cert", "", "Serve HTTPS with this PEM certificate (needs -tls-key)")
+	keyFile := fs.String("tls-key", "", "PEM key for -tls-cert")
+	insecure := fs.Bool("insecure-listen", false, "Serve plain HTTP on a non-loopback -listen address; the bearer token and results cross the network in cleartext")
+	fs.Parse(args)
+
+	token := os.Getenv(agentTokenEnv)
+	if token == "" {
+		return usageErrorf("agent needs %s set to a shared secret; the controller sends the same", agentTokenEnv)
+	}
+	if (*certFile == "") != (*keyFile == "") {
+		return usageErrorf("-tls-cert and -tls-key must be set together")
+	}
+	if *certFile == "" && !loopback(*listen) && !*insecure {
+		return usageErrorf("-listen %s is reachable from other hosts and would take the bearer token over plain HTTP; "+
+			"set -tls-cert and -tls-key, or -insecure-listen on a network you trust", *listen)
+	}
+
+	handler := attack.AgentHandler(os.Stdout, token)
+	var err error
+	if *certFile != "" {
+		fmt.Printf("🛰  Shard agent listening on https://%s\n", *listen)
+		err = http.ListenAndServeTLS(*listen, *certFile, *keyFile, handler)
+	} else {
+		fmt.Printf("🛰  Shard agent listening on %s\n", *listen)
+		if !loopback(*listen) {
+			fmt.Println("⚠️  plain HTTP: the bearer token and results cross the network in cleartext")
+		}
+		err = http.ListenAndServe(*listen, handler)
+	}
+	return fmt.Errorf("agent: %w", err)
+}
+
+// loopback reports whether a listen address only accepts local connections.
+// An empty host listens on every interface.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
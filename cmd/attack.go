@@ -2,42 +2,199 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	"shard/internal/attack"
 	"shard/internal/config"
+	"shard/pkg/shard"
 )
 
 func runAttack(args []string) error {
 	fs := flag.NewFlagSet("attack", flag.ExitOnError)
 	cfgPath := fs.String("cfg", "shard.json", "Path to config file")
 	outPath := fs.String("out", "", "Output JSONL file path (overrides config.output.jsonl_path)")
+	agents := fs.String("agents", "", "Comma-separated agent addresses (host:port) to split the load across")
+	ui := fs.Bool("ui", false, "Show a live full-terminal dashboard (falls back to the progress line without a TTY)")
+	control := fs.String("control", "", "Address to serve live run control on, e.g. 127.0.0.1:7070 (POST /extend)")
+	dryRun := fs.Bool("dry-run", false, "Validate config and file dependencies, then exit without sending requests")
+	url := fs.String("url", "", "Target URL (overrides target.url)")
+	method := fs.String("method", "", "HTTP method (overrides target.method)")
+	rate := fs.Int("rate", 0, "Requests per second (overrides load.rate)")
+	duration := fs.String("duration", "", "Test duration, e.g. 30s (overrides load.duration)")
+	var concurrency config.Workers
+	fs.TextVar(&concurrency, "concurrency", config.Workers(0), `Worker count or "auto" (overrides load.concurrency)`)
+	timeout := fs.String("timeout", "", "Request timeout (overrides load.timeout)")
+	mode := fs.String("mode", "", `"fixed" to run the plan or "search" to find the highest sustainable rate (overrides load.mode)`)
+	var headers multiFlag
+	fs.Var(&headers, "header", `Extra request header "K: V" (repeatable, merged over target.headers)`)
+	labels := labelFlag{}
+	fs.Var(labels, "label", `Label the run "key=value" in its results' meta record (repeatable), e.g. env=staging`)
 	fs.Parse(args)
 
-	// Load config
-	cfg, err := config.ReadConfig(*cfgPath)
+	setFlags := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	// loadConfig layers defaults < file < SHARD_* env < flags; it is reused on SIGHUP.
+	loadConfig := func() (*config.Config, error) {
+		cfg, err := config.ReadConfig(*cfgPath)
+		if errors.Is(err, os.ErrNotExist) && !setFlags["cfg"] {
+			// no config file is fine when everything comes from flags/env
+			def := config.DefaultConfig()
+			cfg, err = &def, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", config.ErrInvalid, err)
+		}
+		if err := cfg.ApplyEnv(os.Getenv); err != nil {
+			return nil, fmt.Errorf("env override: %w: %w", config.ErrInvalid, err)
+		}
+		if setFlags["url"] {
+			cfg.Target.URL = *url
+		}
+		if setFlags["method"] {
+			cfg.Target.Method = *method
+		}
+		if setFlags["rate"] {
+			cfg.Load.Rate = *rate
+		}
+		if setFlags["duration"] {
+			cfg.Load.Duration = *duration
+		}
+		if setFlags["concurrency"] {
+			cfg.Load.Concurrency = concurrency
+		}
+		if setFlags["timeout"] {
+			cfg.Load.Timeout = *timeout
+		}
+		if setFlags["mode"] {
+			cfg.Load.Mode = *mode
+		}
+		if len(headers) > 0 {
+			merged := make(map[string]string, len(cfg.Target.Headers)+len(headers))
+			for k, v := range cfg.Target.Headers {
+				merged[k] = v
+			}
+			for _, h := range headers {
+				k, v, ok := strings.Cut(h, ":")
+				if !ok {
+					return nil, usageErrorf("bad -header %q, want \"K: V\"", h)
+				}
+				merged[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+			cfg.Target.Headers = merged
+		}
+		if *outPath != "" {
+			cfg.Output.JSONLPath = *outPath
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %w", config.ErrInvalid, err)
+		}
+		return cfg, nil
+	}
+
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("load config: %w", err)
+		return err
+	}
+	// {ts} and a progress directory resolve once, so SIGHUP reloads still
+	// compare against the paths as configured
+	output, progress := cfg.Output.Paths(time.Now())
+
+	// Human-readable output moves to stderr when results stream to stdout
+	console := io.Writer(os.Stdout)
+	if output == "-" {
+		console = os.Stderr
+	}
+	for _, n := range cfg.Notices() {
+		fmt.Fprintf(console, "⚠️  %s\n", n)
+	}
+
+	// Check file dependencies before any output is touched
+	if err := prepare(console, cfg); err != nil {
+		return err
 	}
-	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("invalid config: %w", err)
+	if *dryRun {
+		fmt.Fprintf(console, "🗓  %s\n", config.FormatPlan(cfg.Plan(), 50))
+		if s := cfg.Load.Search; cfg.Load.Searching() {
+			fmt.Fprintf(console, "✅ Dry run OK: rate search with %s steps from %d/s concurrency=%s -> %s\n",
+				s.StageDuration, s.StartRate, cfg.Load.Concurrency, output)
+			return nil
+		}
+		if w := cfg.Schedule(); len(w) > 0 {
+			fmt.Fprintf(console, "✅ Dry run OK: replay of %d windows from %s, peak %d/s concurrency=%s -> %s\n",
+				len(w), cfg.Load.ScheduleFile, cfg.Load.Rate, cfg.Load.Concurrency, output)
+			return nil
+		}
+		fmt.Fprintf(console, "✅ Dry run OK: rate=%d/s duration=%s concurrency=%s -> %s\n",
+			cfg.Load.Rate, cfg.Load.Duration, cfg.Load.Concurrency, output)
+		return nil
 	}
 
-	// Determine output path
-	output := cfg.Output.JSONLPath
-	if *outPath != "" {
-		output = *outPath
+	if *agents != "" {
+		if *control != "" {
+			return usageErrorf("-control is not available with -agents")
+		}
+		if cfg.Load.Searching() {
+			return usageErrorf("load.mode search is not available with -agents")
+		}
+		if cfg.Load.ScheduleFile != "" {
+			return usageErrorf("load.schedule_file is not available with -agents")
+		}
+		if os.Getenv(agentTokenEnv) == "" {
+			return usageErrorf("-agents needs %s set to the agents' shared token", agentTokenEnv)
+		}
+		return runDistributed(console, cfg, strings.Split(*agents, ","), output, progress, labels)
 	}
 
-	// Prepare runner
-	runner, err := attack.NewRunner(cfg)
+	dashboard := false
+	if *ui {
+		if f, ok := console.(*os.File); ok && isTerminal(f) {
+			dashboard = true
+		} else {
+			fmt.Fprintln(console, "⚠️  -ui needs a terminal; using the plain progress line")
+		}
+	}
+
+	var ln net.Listener
+	if *control != "" {
+		if ln, err = net.Listen("tcp", *control); err != nil {
+			return fmt.Errorf("control endpoint: %w", err)
+		}
+		defer ln.Close()
+	}
+
+	// Prepare the run; outputs are only created once nothing else can fail
+	sink, progressLog, err := openOutputs(cfg.Output, output, progress)
 	if err != nil {
-		return fmt.Errorf("runner init: %w", err)
+		return err
+	}
+	defer progressLog.Close()
+	atk, err := shard.New(*cfg, shard.Options{Sink: sink, Terminal: console, Log: progressLog,
+		Dashboard: dashboard, Meta: shard.Meta{Labels: labels}})
+	if err != nil {
+		sink.Close()
+		return err
+	}
+	runner := atk.Runner()
+
+	if ln != nil {
+		go http.Serve(ln, attack.ControlHandler(runner, os.Stderr))
+		fmt.Fprintf(console, "🎛  Control endpoint on http://%s (POST /extend)\n", ln.Addr())
+	}
+
+	if pinned := runner.PinnedAddrs(); len(pinned) > 0 {
+		fmt.Fprintf(console, "📌 Target pinned to %s\n", strings.Join(pinned, ", "))
 	}
 
 	// Context with cancel on Ctrl+C
@@ -52,15 +209,129 @@ func runAttack(args []string) error {
 		cancel()
 	}()
 
+	// SIGHUP re-reads the config file and applies live-changeable settings
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+	go func() {
+		for range hupCh {
+			newCfg, err := loadConfig()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\n⚠️  Config reload rejected: %v\n", err)
+				continue
+			}
+			applied, err := runner.Reload(newCfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\n⚠️  %v\n", err)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "\n🔄 Config reloaded (%d change(s)): %v\n", len(applied), applied)
+		}
+	}()
+
 	start := time.Now()
-	fmt.Printf("🚀 Starting attack: rate=%d/s duration=%s concurrency=%d\n",
-		cfg.Load.Rate, cfg.Load.Duration, cfg.Load.Concurrency)
+	if s := cfg.Load.Search; cfg.Load.Searching() {
+		fmt.Fprintf(console, "🔎 Searching for the highest sustainable rate: %s steps from %d/s, x%g each, concurrency=%s\n",
+			s.StageDuration, s.StartRate, s.Factor, cfg.Load.Concurrency)
+	} else {
+		fmt.Fprintf(console, "🚀 Starting attack: rate=%d/s duration=%s concurrency=%s\n",
+			cfg.Load.Rate, cfg.Load.Duration, cfg.Load.Concurrency)
+	}
+	if n := cfg.Load.TotalRequests; n > 0 {
+		fmt.Fprintf(console, "🔢 Stopping after %d requests (load.total_requests)\n", n)
+	}
+	if plan := cfg.Plan(); len(plan) > 1 {
+		fmt.Fprintf(console, "🗓  %s\n", config.FormatPlan(plan, 50))
+	}
 
-	if err := runner.Run(ctx, output); err != nil {
+	if _, err := atk.Run(ctx); err != nil {
+		var te *attack.ThresholdError
+		if errors.As(err, &te) {
+			fmt.Fprintf(console, "\n🛑 Attack stopped after %v, partial results written to %s\n",
+				time.Since(start).Round(time.Millisecond), output)
+		}
 		return fmt.Errorf("attack run: %w", err)
 	}
 
 	elapsed := time.Since(start)
-	fmt.Printf("✅ Attack complete in %v, results written to %s\n", elapsed, output)
+	if n := runner.Discarded(); n > 0 {
+		fmt.Fprintf(console, "\n🗑  %d scheduled requests discarded at stop (stop_policy=%s)\n", n, cfg.Load.StopPolicy)
+	}
+	fmt.Fprintf(console, "✅ Attack complete in %v, results written to %s\n", elapsed, output)
+	return nil
+}
+
+// runDistributed splits the load across agents and merges their results;
+// live reload and the dashboard are not available in this mode.
+func runDistributed(console io.Writer, cfg *config.Config, agents []string, output, progress string, labels labelFlag) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		fmt.Fprintln(os.Stderr, "\n🛑 Interrupt received, stopping agents...")
+		cancel()
+	}()
+
+	start := time.Now()
+	fmt.Fprintf(console, "🚀 Starting distributed attack: rate=%d/s duration=%s across %d agents (%s)\n",
+		cfg.Load.Rate, cfg.Load.Duration, len(agents), strings.Join(agents, ", "))
+	if plan := cfg.Plan(); len(plan) > 1 {
+		fmt.Fprintf(console, "🗓  %s\n", config.FormatPlan(plan, 50))
+	}
+	if err := attack.RunAgents(ctx, cfg, agents, os.Getenv(agentTokenEnv), output, progress, console, attack.MetaRecord{Labels: labels}); err != nil {
+		return fmt.Errorf("distributed run: %w", err)
+	}
+	fmt.Fprintf(console, "\n✅ Attack complete in %v, results written to %s\n", time.Since(start), output)
+	return nil
+}
+
+// prepare runs the config's file-dependency checks and prints what was found.
+func prepare(w io.Writer, cfg *config.Config) error {
+	files, err := cfg.Prepare()
+	for _, f := range files {
+		fmt.Fprintf(w, "📄 %s: %s (%d bytes, %d rows)\n", f.Field, f.Path, f.Size, f.Rows)
+	}
+	if err != nil {
+		return fmt.Errorf("prepare: %w: %w", config.ErrInvalid, err)
+	}
+	return nil
+}
+
+// openOutputs opens the results sink and progress log at the resolved
+// paths, see config.Output.Paths. An empty progress path discards the log.
+func openOutputs(out config.Output, output, progress string) (attack.ResultSink, io.WriteCloser, error) {
+	progressLog, err := attack.OpenProgress(progress, out.ProgressAppend)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open progress log: %w", err)
+	}
+	sink, err := attack.OpenSink(output, out, out.SegmentSize())
+	if err != nil {
+		progressLog.Close()
+		return nil, nil, fmt.Errorf("open output: %w", err)
+	}
+	return sink, progressLog, nil
+}
+
+// labelFlag collects repeatable -label key=value flags; a repeated key
+// keeps its last value.
+type labelFlag map[string]string
+
+func (l labelFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlag) Set(v string) error {
+	k, val, ok := strings.Cut(v, "=")
+	if k = strings.TrimSpace(k); !ok || k == "" {
+		return fmt.Errorf("want key=value, got %q", v)
+	}
+	l[k] = strings.TrimSpace(val)
 	return nil
 }
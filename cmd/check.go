@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"shard/internal/config"
+	"shard/internal/stats"
+)
+
+// runCheck evaluates results against SLOs for CI: it prints every rule's
+// verdict and fails with exitThresholds when one is violated.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	var inPaths multiFlag
+	fs.Var(&inPaths, "in", "JSONL results file or glob, .gz allowed, - for stdin (repeatable; default logs.jsonl)")
+	cfgPath := fs.String("cfg", "shard.json", "Config file whose slo section to check")
+	sloPath := fs.String("slo", "", `SLO file, {"slo": [...]}, checked instead of the config's slo section`)
+	warnOnly := fs.Bool("warn-only", false, "Print violated SLOs but exit 0")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Parse(args)
+
+	inPaths = append(inPaths, fs.Args()...)
+	if len(inPaths) == 0 {
+		inPaths = multiFlag{"logs.jsonl"}
+	}
+	if *format != "text" && *format != "json" {
+		return usageErrorf("unknown format %q (want text or json)", *format)
+	}
+	rules, err := checkRules(*cfgPath, *sloPath)
+	if err != nil {
+		return err
+	}
+
+	agg := stats.New()
+	agg.SetSLO(rules)
+	if err := loadInputs(agg, inPaths); err != nil {
+		return fmt.Errorf("load results: %w", err)
+	}
+	summary := agg.Summary()
+	failed := summary.SLOFailed()
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		out := struct {
+			Requests int               `json:"requests"`
+			Failed   int               `json:"failed"`
+			SLO      []stats.SLOResult `json:"slo"`
+		}{summary.Requests, failed, summary.SLO}
+		if err := enc.Encode(out); err != nil {
+			return fmt.Errorf("encode verdicts: %w", err)
+		}
+	} else {
+		fmt.Printf("🎯 %d SLOs against %d requests\n", len(rules), summary.Requests)
+		stats.WriteSLO(os.Stdout, summary.SLO)
+		if failed == 0 {
+			fmt.Printf("\n✅ All %d SLOs met\n", len(rules))
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	if *warnOnly {
+		fmt.Fprintf(os.Stderr, "⚠️  %d of %d SLOs violated (-warn-only: not failing)\n", failed, len(rules))
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d", stats.ErrSLO, failed, len(rules))
+}
+
+// checkRules reads the rules from the SLO file if one is given, else from
+// the config's slo section.
+func checkRules(cfgPath, sloPath string) ([]config.SLORule, error) {
+	if sloPath != "" {
+		rules, err := config.ReadSLOFile(sloPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", config.ErrInvalid, err)
+		}
+		return rules, nil
+	}
+	cfg, err := config.ReadConfig(cfgPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, usageErrorf("no SLOs to check: %s not found; pass -cfg or -slo", cfgPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	rules, err := cfg.SLORules()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	if len(rules) == 0 {
+		return nil, usageErrorf("no SLOs to check: %s has no slo section; add one or pass -slo", cfgPath)
+	}
+	return rules, nil
+}
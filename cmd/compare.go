@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"shard/internal/stats"
+)
+
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorReset = "\033[0m"
+)
+
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	aPath := fs.String("a", "", "Baseline JSONL results file or glob")
+	bPath := fs.String("b", "", "Candidate JSONL results file or glob")
+	threshold := fs.String("threshold", "5%", "Change above which a metric is marked as a regression")
+	failOn := fs.String("fail-on-regression", "", "Exit non-zero if any metric regresses by more than this (e.g. 10%)")
+	noColor := fs.Bool("no-color", false, "Disable colored output")
+	force := fs.Bool("force", false, "Compare even when the runs' meta records show different target URLs")
+	fs.Parse(args)
+
+	if *aPath == "" || *bPath == "" {
+		return usageErrorf("both -a and -b are required")
+	}
+	mark, err := parsePercent(*threshold)
+	if err != nil {
+		return usageErrorf("invalid -threshold: %v", err)
+	}
+	fail := math.Inf(1)
+	if *failOn != "" {
+		if fail, err = parsePercent(*failOn); err != nil {
+			return usageErrorf("invalid -fail-on-regression: %v", err)
+		}
+	}
+
+	sa, err := loadSummary(*aPath)
+	if err != nil {
+		return err
+	}
+	sb, err := loadSummary(*bPath)
+	if err != nil {
+		return err
+	}
+	if !*force && !stats.SameTarget(sa.Run, sb.Run) {
+		return usageErrorf("A targets %s but B targets %s; pass -force to compare them anyway",
+			strings.Join(sa.Run.URLs, ", "), strings.Join(sb.Run.URLs, ", "))
+	}
+
+	color := !*noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	regressions := printCompare(os.Stdout, *aPath, *bPath, sa, sb, mark, fail, color)
+	if *failOn != "" && regressions > 0 {
+		return fmt.Errorf("%w: %d metric(s) regressed by more than %s", stats.ErrRegression, regressions, *failOn)
+	}
+	return nil
+}
+
+func loadSummary(path string) (stats.Summary, error) {
+	agg := stats.New()
+	if err := loadInputs(agg, []string{path}); err != nil {
+		return stats.Summary{}, err
+	}
+	return agg.Summary(), nil
+}
+
+// printCompare renders the diff and returns how many metrics crossed the fail threshold.
+func printCompare(w io.Writer, aPath, bPath string, a, b stats.Summary, mark, fail float64, color bool) int {
+	paint := func(s, c string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	fmt.Fprintf(w, "\n=== Compare ===\n  A: %s\n  B: %s\n\n", aPath, bPath)
+	fmt.Fprintf(w, "  %-14s %-12s %-12s %-12s %-10s\n", "Metric", "A", "B", "Delta", "Delta%")
+
+	failed := 0
+	for _, d := range stats.Compare(a, b) {
+		line := fmt.Sprintf("  %-14s %-12s %-12s %-12s %-10s",
+			d.Metric, formatMetric(d.Metric, d.A), formatMetric(d.Metric, d.B),
+			signed(formatMetric(d.Metric, d.Abs), d.Abs), formatPct(d.Pct))
+		switch {
+		case d.Regression(mark):
+			line = paint(line+"  - regression", colorRed)
+		case d.Improvement(mark):
+			line = paint(line+"  + improvement", colorGreen)
+		}
+		if d.Regression(fail) {
+			failed++
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintln(w, "\nStatus families (share of requests):")
+	for _, fam := range []string{"2xx", "3xx", "4xx", "5xx"} {
+		fa, fb := a.FamilyShare(fam), b.FamilyShare(fam)
+		if fa == 0 && fb == 0 {
+			continue
+		}
+		shift := (fb - fa) * 100
+		fmt.Fprintf(w, "  %-3s : %6.2f%% -> %6.2f%% (%+.2fpp)\n", fam, fa*100, fb*100, shift)
+	}
+	return failed
+}
+
+func formatMetric(metric string, v float64) string {
+	switch metric {
+	case "requests":
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	case "error_rate":
+		return fmt.Sprintf("%.2f%%", v*100)
+	default:
+		return fmt.Sprintf("%.2fms", v)
+	}
+}
+
+func signed(s string, v float64) string {
+	if v > 0 {
+		return "+" + s
+	}
+	return s
+}
+
+func formatPct(p float64) string {
+	if math.IsNaN(p) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", p)
+}
+
+// parsePercent accepts "10%", "10" or "2.5%" and returns the number of percent.
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("must be >= 0, got %s", s)
+	}
+	return v, nil
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
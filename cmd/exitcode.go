@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+	"shard/internal/stats"
+)
+
+// Exit codes are part of the CLI contract; automation relies on them, so
+// existing values must never change meaning.
+const (
+	exitOK          = 0
+	exitInternal    = 1 // unexpected error
+	exitUsage       = 2 // bad flags or arguments (also used by flag parsing itself)
+	exitConfig      = 3 // config unreadable or invalid, file dependencies missing
+	exitUnreachable = 4 // target or agents unreachable before the run started
+	exitThresholds  = 5 // run completed but failed thresholds (compare -fail-on-regression, report thresholds, check SLOs)
+	exitAborted     = 6 // run stopped early by abort thresholds or the output size limit
+	exitProbe       = 7 // validate probe got an error status or failed an extraction
+)
+
+// errUsage marks errors in how shard was invoked.
+var errUsage = errors.New("usage")
+
+type usageError struct{ msg string }
+
+func (e usageError) Error() string        { return e.msg }
+func (e usageError) Is(target error) bool { return target == errUsage }
+
+func usageErrorf(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// exitCode maps an error returned by a command to its exit code.
+func exitCode(err error) int {
+	var threshold *attack.ThresholdError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, attack.ErrUnreachable):
+		// before ErrInvalid: a failed resolve.once lookup is not a config error
+		return exitUnreachable
+	case errors.Is(err, config.ErrInvalid):
+		return exitConfig
+	case errors.Is(err, stats.ErrRegression), errors.Is(err, stats.ErrThresholds), errors.Is(err, stats.ErrSLO):
+		return exitThresholds
+	case errors.As(err, &threshold), errors.Is(err, attack.ErrOutputSizeLimit):
+		return exitAborted
+	case errors.Is(err, errProbe):
+		return exitProbe
+	}
+	return exitInternal
+}
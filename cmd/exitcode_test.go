@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shard/internal/config"
+)
+
+// writeConfig writes cfg as JSON into dir and returns its path. Outputs
+// left empty go to dir as well.
+func writeConfig(t *testing.T, dir string, cfg config.Config) string {
+	t.Helper()
+	if cfg.Output.JSONLPath == "" {
+		cfg.Output.JSONLPath = filepath.Join(dir, "results.jsonl")
+	}
+	if cfg.Output.ProgressPath == "" {
+		cfg.Output.ProgressPath = filepath.Join(dir, "progress.log")
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "shard.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func shortConfig(url string) config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Target.URL = url
+	cfg.Load.Rate = 20
+	cfg.Load.Duration = "2s"
+	cfg.Load.Concurrency = 4
+	cfg.Load.Timeout = "2s"
+	cfg.Output.JSONLPath, cfg.Output.ProgressPath = "", "" // see writeConfig
+	return cfg
+}
+
+// closedURL returns the URL of a listener that no longer accepts connections.
+func closedURL() string {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+func TestExitCodes(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		name string
+		want int
+		run  func(t *testing.T, dir string) error
+	}{
+		{"internal", exitInternal, func(t *testing.T, dir string) error {
+			// an I/O failure mid-read: the results "file" is a directory
+			return runReport([]string{dir})
+		}},
+		{"usage", exitUsage, func(t *testing.T, dir string) error {
+			return runCompare(nil)
+		}},
+		{"config", exitConfig, func(t *testing.T, dir string) error {
+			return runValidate([]string{"-cfg", filepath.Join(dir, "missing.json")})
+		}},
+		{"config missing body file", exitConfig, func(t *testing.T, dir string) error {
+			cfg := shortConfig(failing.URL)
+			cfg.Target.Method = http.MethodPost
+			cfg.Target.BodyFile = filepath.Join(dir, "missing.json")
+			return runValidate([]string{"-cfg", writeConfig(t, dir, cfg)})
+		}},
+		{"config unwritable output", exitConfig, func(t *testing.T, dir string) error {
+			cfg := shortConfig(failing.URL)
+			cfg.Output.JSONLPath = filepath.Join(dir, "no-such-dir", "results.jsonl")
+			return runValidate([]string{"-cfg", writeConfig(t, dir, cfg)})
+		}},
+		{"config output clashes", exitConfig, func(t *testing.T, dir string) error {
+			cfg := shortConfig(failing.URL)
+			cfg.Output.ProgressPath = filepath.Join(dir, "results.jsonl")
+			return runValidate([]string{"-cfg", writeConfig(t, dir, cfg)})
+		}},
+		{"unreachable", exitUnreachable, func(t *testing.T, dir string) error {
+			return runValidate([]string{"-cfg", writeConfig(t, dir, shortConfig(closedURL()))})
+		}},
+		{"unreachable resolve once", exitUnreachable, func(t *testing.T, dir string) error {
+			cfg := shortConfig("http://shard-test.invalid/")
+			cfg.Target.Resolve.Once = true
+			return runValidate([]string{"-cfg", writeConfig(t, dir, cfg)})
+		}},
+		{"thresholds", exitThresholds, func(t *testing.T, dir string) error {
+			var rows strings.Builder
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(&rows, `{"v":2,"ts":"2026-01-02T03:04:%02dZ","code":200,"reused":true,"sched_lag":0,"phases":{"total":50}}`+"\n", i)
+			}
+			results := filepath.Join(dir, "in.jsonl")
+			if err := os.WriteFile(results, []byte(rows.String()), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg := shortConfig(failing.URL)
+			cfg.Thresholds = []config.Threshold{{Percentile: 50, MaxMs: 10}}
+			return runReport([]string{"-cfg", writeConfig(t, dir, cfg), "-v", "0", results})
+		}},
+		{"aborted", exitAborted, func(t *testing.T, dir string) error {
+			// a 500 is a response, the abort thresholds count transport failures
+			cfg := shortConfig(closedURL())
+			cfg.Abort.ConsecutiveFailures = 3
+			return runAttack([]string{"-cfg", writeConfig(t, dir, cfg)})
+		}},
+		{"probe", exitProbe, func(t *testing.T, dir string) error {
+			return runValidate([]string{"-cfg", writeConfig(t, dir, shortConfig(failing.URL))})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(t, t.TempDir())
+			if got := exitCode(err); got != tt.want {
+				t.Fatalf("exit code %d (error %v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -11,16 +11,64 @@ import (
 func runInit(args []string) error {
 	fs := flag.NewFlagSet("init", flag.ExitOnError)
 	cfgPath := fs.String("cfg", "shard.json", "Path to config file")
+	fromHAR := fs.String("from-har", "", "Take the target from a HAR export")
+	fromCurl := fs.String("from-curl", "", "Take the target from a curl command line (e.g. devtools \"Copy as cURL\")")
+	entry := fs.Int("entry", 1, "HAR entry to use (1-based)")
+	keepCookies := fs.Bool("keep-cookies", false, "Keep Cookie headers from the imported request")
 	_ = fs.Parse(args)
 
+	if *fromHAR != "" && *fromCurl != "" {
+		return usageErrorf("-from-har and -from-curl are mutually exclusive")
+	}
+
+	// parse the import fully before touching any file
+	var req *config.ImportedRequest
+	switch {
+	case *fromHAR != "":
+		data, err := os.ReadFile(*fromHAR)
+		if err != nil {
+			return fmt.Errorf("read HAR: %w", err)
+		}
+		var n int
+		req, n, err = config.FromHAR(data, *entry-1, *keepCookies)
+		if err != nil {
+			return err
+		}
+		if n > 1 {
+			fmt.Printf("ℹ️  %s has %d entries; using entry %d (pick another with -entry)\n", *fromHAR, n, *entry)
+		}
+	case *fromCurl != "":
+		var err error
+		if req, err = config.FromCurl(*fromCurl, *keepCookies); err != nil {
+			return err
+		}
+	}
+
 	if _, err := os.Stat(*cfgPath); err == nil {
 		fmt.Fprintf(os.Stderr, "Warning: %s already exists and will be overwritten.\n", *cfgPath)
 	}
 
-	if err := config.WriteDefaultConfig(*cfgPath); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+	if req == nil {
+		if err := config.WriteDefaultConfig(*cfgPath); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+		fmt.Printf("Default configuration written to %s\n", *cfgPath)
+		return nil
 	}
 
-	fmt.Printf("Default configuration written to %s\n", *cfgPath)
+	cfg := config.DefaultConfig()
+	base, _ := config.SplitExt(*cfgPath)
+	bodyFile := base + ".body"
+	req.Apply(&cfg, bodyFile)
+	if len(req.Body) > 0 {
+		if err := os.WriteFile(bodyFile, req.Body, 0644); err != nil {
+			return fmt.Errorf("failed to write body: %w", err)
+		}
+		fmt.Printf("📄 Request body (%d bytes) written to %s\n", len(req.Body), bodyFile)
+	}
+	if err := config.WriteConfig(*cfgPath, cfg); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	fmt.Printf("Configuration for %s %s written to %s\n", req.Method, req.URL, *cfgPath)
 	return nil
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"shard/internal/stats"
+)
+
+// multiFlag collects a repeatable string flag.
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ",") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
+
+// expandInputs expands glob patterns so rotated runs can be passed as one argument.
+// Plain paths are kept as-is so a missing file still produces a clear error.
+func expandInputs(patterns []string) ([]string, error) {
+	var paths []string
+	for _, p := range patterns {
+		if !strings.ContainsAny(p, "*?[") {
+			paths = append(paths, p)
+			continue
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", p, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", p)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// loadInputs feeds every input file into agg.
+func loadInputs(agg *stats.Aggregator, patterns []string) error {
+	paths, err := expandInputs(patterns)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := agg.LoadJSONL(p); err != nil {
+			return fmt.Errorf("load %s: %w", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"shard/internal/config"
+	"shard/internal/stats"
+)
+
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	slow := fs.Duration("slow", time.Second, "Keep successful requests at least this slow")
+	keep := fs.Float64("keep", 0.01, "Fraction of the other successful requests to keep")
+	fs.Parse(args)
+
+	if *keep < 0 || *keep > 1 {
+		return usageErrorf("-keep must be between 0 and 1")
+	}
+	paths, err := expandInputs(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return usageErrorf("usage: shard prune [-slow 1s] [-keep 0.01] <runs/dir | results.jsonl>...")
+	}
+	opt := stats.PruneOptions{Slow: *slow, SampleRate: *keep}
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			if err := pruneRun([]resultsFile{{p, fi.Size()}}, opt); err != nil {
+				return err
+			}
+			continue
+		}
+		files, err := resultsFiles(p)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("prune %s: no results files in the directory", p)
+		}
+		for _, run := range groupRuns(files) {
+			err := pruneRun(run, opt)
+			if errors.Is(err, stats.ErrPruned) {
+				fmt.Printf("⏭️  %s: already pruned, skipped\n", runBase(run[0].path))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pruneRun prunes the files of one run under a single snapshot and records
+// the pruning in the run's summary file, when it has one.
+func pruneRun(files []resultsFile, opt stats.PruneOptions) error {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	recs, err := stats.PruneFiles(paths, opt)
+	if err != nil {
+		return fmt.Errorf("prune %s: %w", strings.Join(paths, ", "), err)
+	}
+	for i, f := range files {
+		after, err := os.Stat(f.path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✂️  %s: kept %d of %d results (%d → %d bytes)\n", f.path, recs[i].Kept, recs[i].Total, f.size, after.Size())
+	}
+	return updateRunSummary(runBase(paths[0])+".summary.json", stats.PruneSummary(recs))
+}
+
+// updateRunSummary adds the pruning to a summary file such as the ones
+// `shard suite` writes next to each run's results. A missing file is fine.
+func updateRunSummary(path string, info stats.PruneInfo) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var summary stats.Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return fmt.Errorf("update %s: %w", path, err)
+	}
+	summary.Pruned = &info
+	data, err = json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("📝 %s: recorded the pruning\n", path)
+	return nil
+}
+
+type resultsFile struct {
+	path string
+	size int64
+}
+
+// resultsFiles walks a run directory for its results files: .jsonl, plain or
+// compressed, including rotation segments. Progress logs and summaries are
+// left alone.
+func resultsFiles(dir string) ([]resultsFile, error) {
+	var files []resultsFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := strings.TrimSuffix(strings.TrimSuffix(d.Name(), ".gz"), ".zst")
+		if filepath.Ext(name) != ".jsonl" || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, resultsFile{path, fi.Size()})
+		return nil
+	})
+	return files, err
+}
+
+var segmentSuffix = regexp.MustCompile(`-[0-9]{4}$`)
+
+// runBase returns a results file's path without its extensions and rotation
+// segment number: "runs/soak-0002.jsonl.gz" belongs to "runs/soak".
+func runBase(path string) string {
+	base, _ := config.SplitExt(path)
+	return segmentSuffix.ReplaceAllString(base, "")
+}
+
+// groupRuns splits results files into runs, the rotation segments of a run
+// together and in order.
+func groupRuns(files []resultsFile) [][]resultsFile {
+	byBase := make(map[string][]resultsFile)
+	var bases []string
+	for _, f := range files {
+		b := runBase(f.path)
+		if _, ok := byBase[b]; !ok {
+			bases = append(bases, b)
+		}
+		byBase[b] = append(byBase[b], f)
+	}
+	sort.Strings(bases)
+	runs := make([][]resultsFile, len(bases))
+	for i, b := range bases {
+		run := byBase[b]
+		sort.Slice(run, func(i, j int) bool { return run[i].path < run[j].path })
+		runs[i] = run
+	}
+	return runs
+}
@@ -1,23 +1,149 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"shard/internal/config"
 	"shard/internal/stats"
 )
 
 func runReport(args []string) error {
 	fs := flag.NewFlagSet("report", flag.ExitOnError)
-	inPath := fs.String("in", "logs.jsonl", "Path to JSONL results file")
+	var inPaths multiFlag
+	fs.Var(&inPaths, "in", "JSONL results file or glob, .gz allowed, - for stdin (repeatable; default logs.jsonl)")
+	format := fs.String("format", "text", "Output format: text, json or benchfmt")
+	metrics := fs.String("metrics", strings.Join(stats.DefaultBenchMetrics, ","), "Comma-separated metrics for -format benchfmt")
+	bucket := fs.Duration("bucket", 0, "Timeline bucket width (0 = automatic)")
+	buckets := fs.String("buckets", "", "Latency histogram bucket bounds, e.g. 5ms,20ms,100ms,1s (default 1ms to 10s, log-scaled)")
+	cfgPath := fs.String("cfg", "shard.json", "Config file with cost profiles and latency thresholds for the run (skipped if the default is missing)")
+	verbosity := fs.Int("v", 1, "Text verbosity: 0 headline, 1 tables, 2 everything (JSON always has everything)")
+	rawNumbers := fs.Bool("raw-numbers", false, "Print plain numbers in the text report instead of 12.8M, 3m03s, 1.20 GB (JSON is always raw)")
+	strict := fs.Bool("strict", false, "Fail on the first malformed input line instead of skipping and counting it")
+	since := fs.String("since", "", `Only results from this time on: RFC 3339, or "last 2m" before the latest result`)
+	until := fs.String("until", "", `Only results up to this time: RFC 3339, or "last 30s" before the latest result`)
+	status := fs.String("status", "", "Only results with these statuses or families, e.g. 500,503 or 5xx")
+	errorsOnly := fs.Bool("errors-only", false, "Only failed results and statuses >= 400")
+	errorClass := fs.String("error-class", "", "Only results failing with these error classes, e.g. connect,tls")
 	fs.Parse(args)
 
+	inPaths = append(inPaths, fs.Args()...)
+	if len(inPaths) == 0 {
+		inPaths = multiFlag{"logs.jsonl"}
+	}
+
 	agg := stats.New()
-	if err := agg.LoadJSONL(*inPath); err != nil {
+	agg.SetBucket(*bucket)
+	agg.SetRawNumbers(*rawNumbers)
+	agg.SetStrict(*strict)
+	if *buckets != "" {
+		bounds, err := stats.ParseBuckets(*buckets)
+		if err != nil {
+			return usageErrorf("-buckets: %v", err)
+		}
+		if err := agg.SetLatencyBuckets(bounds); err != nil {
+			return usageErrorf("-buckets: %v", err)
+		}
+	}
+	explicitCfg := false
+	fs.Visit(func(f *flag.Flag) { explicitCfg = explicitCfg || f.Name == "cfg" })
+	if err := loadReportConfig(agg, *cfgPath, explicitCfg); err != nil {
+		return err
+	}
+	filter, err := stats.ParseFilter(*since, *until, *status, *errorClass, *errorsOnly)
+	if err != nil {
+		return usageErrorf("%v", err)
+	}
+	if filter != nil && filter.Relative() {
+		if err := anchorFilter(filter, inPaths); err != nil {
+			return err
+		}
+	}
+	agg.SetFilter(filter)
+	if err := loadInputs(agg, inPaths); err != nil {
 		return fmt.Errorf("load results: %w", err)
 	}
 
-	agg.Report(os.Stdout)
+	summary := agg.Summary()
+	switch *format {
+	case "text":
+		agg.ReportLevel(os.Stdout, *verbosity)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(summary); err != nil {
+			return fmt.Errorf("encode summary: %w", err)
+		}
+	case "benchfmt":
+		if err := stats.WriteBenchfmt(os.Stdout, summary, strings.Split(*metrics, ",")); err != nil {
+			return fmt.Errorf("benchfmt: %w", err)
+		}
+	default:
+		return usageErrorf("unknown format %q (want text, json or benchfmt)", *format)
+	}
+	if n := summary.ThresholdsFailed(); n > 0 {
+		return fmt.Errorf("%w: %d of %d", stats.ErrThresholds, n, len(summary.Thresholds))
+	}
+	if n := summary.SLOFailed(); n > 0 {
+		return fmt.Errorf("%w: %d of %d", stats.ErrSLO, n, len(summary.SLO))
+	}
+	return nil
+}
+
+// loadReportConfig reads the cost profiles, latency thresholds and SLOs from
+// the config at path. A missing file is only an error when it was asked for
+// explicitly.
+func loadReportConfig(agg *stats.Aggregator, path string, explicit bool) error {
+	cfg, err := config.ReadConfig(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	profiles, err := cfg.CostProfiles()
+	if err != nil {
+		return fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	agg.SetPricing(profiles)
+	thresholds, err := cfg.SLAThresholds()
+	if err != nil {
+		return fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	agg.SetThresholds(thresholds)
+	rules, err := cfg.SLORules()
+	if err != nil {
+		return fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	agg.SetSLO(rules)
+	return nil
+}
+
+// anchorFilter resolves "last" filter bounds against the latest timestamp
+// of all inputs, which takes a first pass over them.
+func anchorFilter(filter *stats.Filter, patterns []string) error {
+	paths, err := expandInputs(patterns)
+	if err != nil {
+		return fmt.Errorf("load results: %w", err)
+	}
+	var latest time.Time
+	for _, p := range paths {
+		if p == "-" {
+			return usageErrorf(`"last" filters need file inputs: standard input can only be read once`)
+		}
+		t, err := stats.LatestTimestamp(p)
+		if err != nil {
+			return fmt.Errorf("load results: %s: %w", p, err)
+		}
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	filter.Anchor(latest)
 	return nil
 }
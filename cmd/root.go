@@ -8,7 +8,7 @@ import (
 func main() {
 	if len(os.Args) < 2 {
 		fmt.Fprintln(os.Stderr, "Usage: shard <command> [options]")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	cmd := os.Args[1]
@@ -22,13 +22,31 @@ func main() {
 		err = runAttack(args)
 	case "report":
 		err = runReport(args)
+	case "compare":
+		err = runCompare(args)
+	case "check":
+		err = runCheck(args)
+	case "agent":
+		err = runAgent(args)
+	case "prune":
+		err = runPrune(args)
+	case "validate":
+		err = runValidate(args)
+	case "suite":
+		err = runSuite(args)
+	case "schedule":
+		err = runSchedule(args)
+	case "schema":
+		err = runSchema(args)
+	case "selftest":
+		err = runSelftest(args)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", cmd)
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"shard/internal/stats"
+)
+
+// runSchedule turns the results of earlier runs back into a
+// load.schedule_file, so the same traffic shape can be replayed.
+func runSchedule(args []string) error {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	var from multiFlag
+	fs.Var(&from, "from", "Results file to read dispatch times from (repeatable, globs allowed)")
+	window := fs.Duration("window", time.Second, "Length of each schedule window")
+	out := fs.String("out", "", "Write the schedule to this file instead of stdout")
+	fs.Parse(args)
+
+	if len(from) == 0 || fs.NArg() > 0 {
+		return usageErrorf("usage: shard schedule -from <results.jsonl> [-window 1s] [-out schedule.csv]")
+	}
+	if *window < time.Millisecond {
+		return usageErrorf("-window must be at least 1ms")
+	}
+	paths, err := expandInputs(from)
+	if err != nil {
+		return err
+	}
+	rows, err := stats.ScheduleFrom(paths, *window)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	note := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	} else {
+		note = os.Stderr
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "offset,count")
+	total := 0
+	for _, r := range rows {
+		fmt.Fprintf(bw, "%s,%d\n", strconv.FormatFloat(r.Offset.Seconds(), 'f', -1, 64), r.Count)
+		total += r.Count
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write schedule: %w", err)
+	}
+	dest := *out
+	if dest == "" {
+		dest = "stdout"
+	}
+	last := rows[len(rows)-1].Offset
+	fmt.Fprintf(note, "🗓  %d requests in %d rows over %s written to %s\n", total, len(rows), last, dest)
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"shard/internal/schema"
+)
+
+// runSchema prints the JSON Schema of one machine output, or of all of them
+// keyed by kind.
+func runSchema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shard schema [%s]\n", strings.Join(schema.Kinds(), "|"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		return usageErrorf("schema takes at most one kind, got %d", fs.NArg())
+	}
+
+	var out any
+	if kind := fs.Arg(0); kind != "" {
+		doc, err := schema.Document(kind)
+		if err != nil {
+			return usageErrorf("%v", err)
+		}
+		out = doc
+	} else {
+		all := make(map[string]schema.Schema)
+		for _, kind := range schema.Kinds() {
+			doc, err := schema.Document(kind)
+			if err != nil {
+				return err
+			}
+			all[kind] = doc
+		}
+		out = all
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode schema: %w", err)
+	}
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+	"shard/internal/schema"
+	"shard/internal/stats"
+)
+
+// errSelftest marks a self-test with failed checks.
+var errSelftest = errors.New("self-test failed")
+
+// runSelftest attacks an in-process mock target with a short multi-stage
+// plan, retries, body capture, thresholds, SLOs and OAuth2 tokens, then
+// checks the results file, the summary and every report format against each
+// other.
+func runSelftest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	keep := fs.Bool("keep", false, "Keep the work directory (results, progress log, reports) for inspection")
+	fs.Parse(args)
+
+	dir, err := os.MkdirTemp("", "shard-selftest-")
+	if err != nil {
+		return fmt.Errorf("work dir: %w", err)
+	}
+	if *keep {
+		defer fmt.Printf("📁 Work directory kept: %s\n", dir)
+	} else {
+		defer os.RemoveAll(dir)
+	}
+
+	mock, err := startMock()
+	if err != nil {
+		return fmt.Errorf("mock target: %w", err)
+	}
+	defer mock.Close()
+
+	cfg := selftestConfig(mock.url, dir)
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("self-test config: %w", err)
+	}
+	thresholds, err := cfg.SLAThresholds()
+	if err != nil {
+		return fmt.Errorf("self-test config: %w", err)
+	}
+	slo, err := cfg.SLORules()
+	if err != nil {
+		return fmt.Errorf("self-test config: %w", err)
+	}
+	fmt.Printf("🧪 Self-test against the mock target at %s\n", mock.url)
+	fmt.Printf("🗓  %s\n", config.FormatPlan(cfg.Plan(), 50))
+
+	var c selfChecks
+	runner, err := attack.NewRunner(&cfg)
+	if err != nil {
+		return fmt.Errorf("runner init: %w", err)
+	}
+	if !c.check("attack runs to completion", runAgainstMock(runner, cfg)) {
+		return c.result()
+	}
+
+	rows, err := readSelftestRows(cfg.Output.JSONLPath)
+	if !c.check("results file parses", err) {
+		return c.result()
+	}
+	c.check("rows written == sent counter", expectEqual("rows", len(rows), int(runner.Sent())))
+	c.check("every plan stage sent requests", checkStages(rows, cfg.Plan()))
+	c.check("retries recorded", checkAny(rows, "no result needed a retry", func(r attack.Result) bool { return r.Attempts > 1 }))
+	c.check("error bodies captured", checkAny(rows, "no error body was captured", func(r attack.Result) bool {
+		return r.Code >= 500 && strings.Contains(r.BodySample, "mock failure")
+	}))
+	c.check("auth token refreshed mid-run", checkAuth(cfg.Output.JSONLPath, rows))
+
+	agg := stats.New()
+	agg.SetThresholds(thresholds)
+	agg.SetSLO(slo)
+	if !c.check("aggregator loads the results", agg.LoadJSONL(cfg.Output.JSONLPath)) {
+		return c.result()
+	}
+	summary := agg.Summary()
+	c.check("summary totals match the JSONL", checkTotals(rows, summary))
+	c.check("thresholds evaluate correctly", checkThresholds(rows, thresholds, summary.Thresholds))
+	c.check("SLOs evaluate correctly", checkSLO(rows, slo, summary.SLO))
+	c.check("text report", writeTextReport(agg, filepath.Join(dir, "report.txt")))
+	c.check("json report round-trips", writeJSONReport(summary, filepath.Join(dir, "report.json")))
+	c.check("outputs match their JSON schemas", checkSchemas(cfg.Output.JSONLPath, filepath.Join(dir, "report.json")))
+	c.check("benchfmt report", writeBenchReport(summary, filepath.Join(dir, "report.bench.txt")))
+	c.check("progress log completed", checkProgressLog(cfg.Output.ProgressPath))
+	return c.result()
+}
+
+// selftestConfig is a few seconds of every stage at a rate any host manages.
+func selftestConfig(url, dir string) config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Target.URL = url
+	cfg.Load.Rate = 200
+	cfg.Load.Duration = "4s"
+	cfg.Load.Warmup = "1s"
+	cfg.Load.Ramp = "1s"
+	cfg.Load.Cooldown = config.Cooldown{Duration: "1s", Rate: 20}
+	cfg.Load.Concurrency = 32
+	cfg.Load.QueueSize = 0
+	cfg.Load.Timeout = "5s"
+	cfg.Load.Retries = config.Retries{MaxAttempts: 3, RetryOn: []string{"503"}, Backoff: "fixed", Delay: "5ms"}
+	cfg.Output.JSONLPath = filepath.Join(dir, "results.jsonl")
+	cfg.Output.ProgressPath = filepath.Join(dir, "progress.log")
+	cfg.Output.Capture = config.Capture{OnError: true}
+	// the mock's tokens last 5s, so one is refreshed while the run goes
+	cfg.Auth.OAuth2 = config.ClientCredentials{TokenURL: url + "token", ClientID: "selftest", ClientSecret: "selftest"}
+	// one threshold that must pass in each mode and one that must fail:
+	// every successful mock response takes at least 2ms
+	cfg.Thresholds = []config.Threshold{
+		{Percentile: 50, MaxMs: 10000},
+		{Percentile: 100, MaxMs: 10000, Mode: "per-bucket"},
+		{Percentile: 50, MaxMs: 1},
+	}
+	// likewise SLOs of each kind, the last failing
+	cfg.SLO = []string{"availability >= 90", "error_rate <= 0.5", "p99.9_ms <= 10000", "p50_ms < 1"}
+	return cfg
+}
+
+func runAgainstMock(runner *attack.Runner, cfg config.Config) error {
+	sink, err := attack.OpenSink(cfg.Output.JSONLPath, cfg.Output, 0)
+	if err != nil {
+		return fmt.Errorf("open output: %w", err)
+	}
+	progress, err := attack.OpenProgress(cfg.Output.ProgressPath, false)
+	if err != nil {
+		sink.Close()
+		return fmt.Errorf("open progress log: %w", err)
+	}
+	defer progress.Close()
+	return runner.RunSink(context.Background(), sink, nil, progress)
+}
+
+// mockTarget is the in-process target the self-test attacks. Most requests
+// get a 200 after 2ms; every 10th a 503, which the self-test retries, and
+// every 50th a 500 whose body it captures. Requests need a bearer token
+// from /token that has not expired; tokens last 5s.
+type mockTarget struct {
+	*http.Server
+	url string
+}
+
+func startMock() (*mockTarget, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	var n atomic.Int64
+	var mu sync.Mutex
+	tokens := make(map[string]time.Time) // Authorization value -> expiry
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if id, secret, _ := r.BasicAuth(); id != "selftest" || secret != "selftest" || r.FormValue("grant_type") != "client_credentials" {
+				http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			mu.Lock()
+			tok := fmt.Sprintf("mock-%d", len(tokens)+1)
+			tokens["Bearer "+tok] = time.Now().Add(5 * time.Second)
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":5}`, tok)
+			return
+		}
+		mu.Lock()
+		expires, ok := tokens[r.Header.Get("Authorization")]
+		mu.Unlock()
+		if !ok || time.Now().After(expires) {
+			http.Error(w, "mock token missing or expired", http.StatusUnauthorized)
+			return
+		}
+		i := n.Add(1)
+		switch {
+		case i%50 == 0:
+			http.Error(w, "mock failure", http.StatusInternalServerError)
+		case i%10 == 0:
+			http.Error(w, "mock overloaded", http.StatusServiceUnavailable)
+		default:
+			time.Sleep(2 * time.Millisecond)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"ok":true,"n":%d}`, i)
+		}
+	})}
+	go srv.Serve(ln)
+	return &mockTarget{Server: srv, url: "http://" + ln.Addr().String() + "/"}, nil
+}
+
+// selfChecks prints each check's verdict as it runs and tallies them.
+type selfChecks struct {
+	passed, failed int
+}
+
+func (c *selfChecks) check(name string, err error) bool {
+	if err != nil {
+		c.failed++
+		fmt.Printf("  ❌ FAIL  %s: %v\n", name, err)
+		return false
+	}
+	c.passed++
+	fmt.Printf("  ✅ PASS  %s\n", name)
+	return true
+}
+
+func (c *selfChecks) result() error {
+	if c.failed > 0 {
+		return fmt.Errorf("%w: %d of %d checks failed", errSelftest, c.failed, c.passed+c.failed)
+	}
+	fmt.Printf("✅ Self-test passed (%d checks)\n", c.passed)
+	return nil
+}
+
+func expectEqual[T comparable](what string, got, want T) error {
+	if got != want {
+		return fmt.Errorf("%s: got %v, want %v", what, got, want)
+	}
+	return nil
+}
+
+// readSelftestRows decodes every result in the file, skipping typed records.
+func readSelftestRows(path string) ([]attack.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rows []attack.Result
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if bytes.HasPrefix(sc.Bytes(), []byte(`{"type":`)) {
+			continue
+		}
+		var res attack.Result
+		if err := json.Unmarshal(sc.Bytes(), &res); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, res)
+	}
+	return rows, sc.Err()
+}
+
+func checkAny(rows []attack.Result, none string, ok func(attack.Result) bool) error {
+	for _, r := range rows {
+		if ok(r) {
+			return nil
+		}
+	}
+	return errors.New(none)
+}
+
+// checkAuth wants the closing auth record to show a refresh during the run
+// and no request turned away for its token.
+func checkAuth(path string, rows []attack.Result) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rec *attack.AuthRecord
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte(`{"type":"auth"`)) {
+			rec = new(attack.AuthRecord)
+			if err := json.Unmarshal(line, rec); err != nil {
+				return fmt.Errorf("auth record: %w", err)
+			}
+		}
+	}
+	switch {
+	case rec == nil:
+		return errors.New("no auth record")
+	case rec.Refreshed < 1:
+		return fmt.Errorf("%d tokens fetched, none by a refresh", rec.Fetched)
+	case rec.Failed > 0:
+		return fmt.Errorf("%d token requests failed", rec.Failed)
+	}
+	for _, r := range rows {
+		if r.Code == http.StatusUnauthorized {
+			return fmt.Errorf("%s got a 401 (%s)", r.Timestamp.Format(time.RFC3339Nano), r.Error)
+		}
+	}
+	return nil
+}
+
+func checkStages(rows []attack.Result, plan []config.Stage) error {
+	seen := map[string]bool{}
+	for _, r := range rows {
+		stage := r.Stage
+		if stage == "" {
+			stage = "steady"
+		}
+		seen[stage] = true
+	}
+	var missing []string
+	for _, s := range plan {
+		if !seen[s.Name] {
+			missing = append(missing, s.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no requests in %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// reportMs is d in milliseconds at the microsecond resolution the report's
+// percentiles keep.
+func reportMs(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*1000) / 1000
+}
+
+// measured reports whether the summary counts r as part of the measurement.
+func measured(r attack.Result) bool {
+	return r.Stage != "warmup" && r.Stage != "cooldown" && r.Error != "client_abort"
+}
+
+// checkTotals recounts the summary's totals straight from the rows.
+func checkTotals(rows []attack.Result, s stats.Summary) error {
+	var requests, warmup, failed int
+	var bytes int64
+	codes := map[int]int{}
+	for _, r := range rows {
+		if r.Stage == "warmup" {
+			warmup++
+		}
+		if !measured(r) {
+			continue
+		}
+		requests++
+		bytes += r.Bytes
+		if r.Error != "" {
+			failed++
+		}
+		if r.Code > 0 {
+			codes[r.Code]++
+		}
+	}
+	errs := []error{
+		expectEqual("requests", s.Requests, requests),
+		expectEqual("warmup", s.Warmup, warmup),
+		expectEqual("failed", s.Failed, failed),
+		expectEqual("bytes received", s.BytesReceived, bytes),
+		expectEqual("status codes", len(s.StatusCodes), len(codes)),
+	}
+	for code, n := range codes {
+		errs = append(errs, expectEqual(fmt.Sprintf("status %d", code), s.StatusCodes[code], n))
+	}
+	return errors.Join(errs...)
+}
+
+// checkThresholds recomputes each verdict from the rows: overall thresholds
+// by nearest rank, and the 100th-percentile per-bucket one from the slowest
+// request, as every bucket must hold.
+func checkThresholds(rows []attack.Result, want []config.Threshold, got []stats.ThresholdResult) error {
+	if err := expectEqual("thresholds", len(got), len(want)); err != nil {
+		return err
+	}
+	var samples []float64
+	for _, r := range rows {
+		if measured(r) && !r.AfterIdle {
+			samples = append(samples, reportMs(r.Phases.Total))
+		}
+	}
+	if len(samples) == 0 {
+		return errors.New("no measured requests")
+	}
+	sort.Float64s(samples)
+	var errs []error
+	for i, t := range want {
+		observed := samples[len(samples)-1]
+		if t.Mode == "overall" {
+			rank := max(int(math.Ceil(t.Percentile/100*float64(len(samples))))-1, 0)
+			observed = samples[min(rank, len(samples)-1)]
+			errs = append(errs, expectEqual(got[i].Rule+" observed", got[i].ObservedMs, observed))
+		}
+		errs = append(errs, expectEqual(got[i].Rule+" pass", got[i].Pass, observed <= t.MaxMs))
+	}
+	// the config has a threshold of each verdict; both must show up
+	passed := 0
+	for _, t := range got {
+		if t.Pass {
+			passed++
+		}
+	}
+	if passed == 0 || passed == len(got) {
+		errs = append(errs, fmt.Errorf("expected both passing and failing thresholds, %d of %d passed", passed, len(got)))
+	}
+	return errors.Join(errs...)
+}
+
+// checkSLO recomputes each SLO from the rows: availability and error rate
+// by counting, latency percentiles by nearest rank as in checkThresholds.
+func checkSLO(rows []attack.Result, want []config.SLORule, got []stats.SLOResult) error {
+	if err := expectEqual("SLOs", len(got), len(want)); err != nil {
+		return err
+	}
+	var samples []float64
+	var requests, failed, unavailable int
+	for _, r := range rows {
+		if !measured(r) {
+			continue
+		}
+		requests++
+		if r.Error != "" {
+			failed++
+		}
+		if r.Error != "" || r.Code >= 500 {
+			unavailable++
+		}
+		if !r.AfterIdle {
+			samples = append(samples, reportMs(r.Phases.Total))
+		}
+	}
+	if len(samples) == 0 {
+		return errors.New("no measured requests")
+	}
+	sort.Float64s(samples)
+	var errs []error
+	for i, rule := range want {
+		var observed float64
+		switch rule.Metric {
+		case "availability":
+			observed = 100 * float64(requests-unavailable) / float64(requests)
+		case "error_rate":
+			observed = float64(failed) / float64(requests)
+		case "latency":
+			rank := max(int(math.Ceil(rule.Percentile/100*float64(len(samples))))-1, 0)
+			observed = samples[min(rank, len(samples)-1)]
+		}
+		errs = append(errs, expectEqual(rule.Rule+" observed", got[i].Observed, observed))
+		// selftestConfig's last SLO is the one that must fail
+		errs = append(errs, expectEqual(rule.Rule+" pass", got[i].Pass, i < len(want)-1))
+	}
+	return errors.Join(errs...)
+}
+
+func writeTextReport(agg *stats.Aggregator, path string) error {
+	var buf bytes.Buffer
+	agg.ReportLevel(&buf, 2)
+	for _, want := range []string{"=== Summary", "Phase timings", "Thresholds:"} {
+		if !strings.Contains(buf.String(), want) {
+			return fmt.Errorf("report has no %q section", want)
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func writeJSONReport(s stats.Summary, path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	var back stats.Summary
+	if err := json.Unmarshal(data, &back); err != nil {
+		return err
+	}
+	if err := errors.Join(
+		expectEqual("requests", back.Requests, s.Requests),
+		expectEqual("failed", back.Failed, s.Failed),
+		expectEqual("thresholds failed", back.ThresholdsFailed(), s.ThresholdsFailed()),
+		expectEqual("SLOs failed", back.SLOFailed(), s.SLOFailed()),
+	); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func writeBenchReport(s stats.Summary, path string) error {
+	var buf bytes.Buffer
+	if err := stats.WriteBenchfmt(&buf, s, stats.DefaultBenchMetrics); err != nil {
+		return err
+	}
+	lines := 0
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.HasPrefix(line, "Benchmark") {
+			lines++
+		}
+	}
+	if err := expectEqual("benchmark lines", lines, len(stats.DefaultBenchMetrics)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func checkProgressLog(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(data)), "---- Test completed ----") {
+		return errors.New("no completion line")
+	}
+	return nil
+}
+
+// checkSchemas validates every line of the results file, by its record
+// type, and the JSON report against the schemas `shard schema` prints.
+func checkSchemas(resultsPath, reportPath string) error {
+	docs := make(map[string]schema.Schema)
+	for _, kind := range schema.Kinds() {
+		doc, err := schema.Document(kind)
+		if err != nil {
+			return err
+		}
+		docs[kind] = doc
+	}
+	data, err := os.ReadFile(resultsPath)
+	if err != nil {
+		return err
+	}
+	for i, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var rec struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("results line %d: %w", i+1, err)
+		}
+		kind := rec.Type
+		if kind == "" {
+			kind = "result"
+		}
+		doc, ok := docs[kind]
+		if !ok {
+			return fmt.Errorf("results line %d: no schema for record type %q", i+1, kind)
+		}
+		if err := schema.Validate(doc, line); err != nil {
+			return fmt.Errorf("results line %d (%s): %w", i+1, kind, err)
+		}
+	}
+	report, err := os.ReadFile(reportPath)
+	if err != nil {
+		return err
+	}
+	if err := schema.Validate(docs["summary"], report); err != nil {
+		return fmt.Errorf("json report: %w", err)
+	}
+	return nil
+}
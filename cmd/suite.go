@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+	"shard/internal/stats"
+	"shard/pkg/shard"
+)
+
+func runSuite(args []string) error {
+	fs := flag.NewFlagSet("suite", flag.ExitOnError)
+	suitePath := fs.String("cfg", "suite.json", "Path to the suite file")
+	dryRun := fs.Bool("dry-run", false, "Validate the suite, every run's config and rate references, then exit")
+	fs.Parse(args)
+
+	suite, err := config.ReadSuite(*suitePath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	if err := os.MkdirAll(suite.Dir, 0o755); err != nil {
+		return fmt.Errorf("suite dir: %w", err)
+	}
+	// every run is checked before the first one starts; derived rates are
+	// only known later, so their configs are checked at a stand-in rate
+	for _, run := range suite.Runs {
+		if ref := run.Rate; ref != nil && ref.Derived() {
+			if err := stats.CheckMetricPath(ref.Metric); err != nil {
+				return fmt.Errorf("%w: suite run %q: rate %q: %w", config.ErrInvalid, run.Name, ref, err)
+			}
+		}
+		cfg, err := suiteRunConfig(suite, run, 1)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📋 %s: %s\n", run.Name, run.Config)
+		if err := prepare(os.Stdout, cfg); err != nil {
+			return fmt.Errorf("suite run %q: %w", run.Name, err)
+		}
+	}
+	if *dryRun {
+		fmt.Printf("✅ Dry run OK: %d runs, results in %s\n", len(suite.Runs), suite.Dir)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		<-sigCh
+		fmt.Fprintln(os.Stderr, "\n🛑 Interrupt received, stopping the current run and the suite...")
+		cancel()
+	}()
+
+	failed := 0
+	for i, run := range suite.Runs {
+		meta := attack.MetaRecord{Suite: *suitePath, Run: run.Name}
+		rate := 0
+		if ref := run.Rate; ref != nil && ref.Derived() {
+			d, err := deriveRate(suite, *ref)
+			if err != nil {
+				return fmt.Errorf("suite run %q: %w", run.Name, err)
+			}
+			rate = int(d.Value)
+			meta.Derived = append(meta.Derived, d)
+		}
+		cfg, err := suiteRunConfig(suite, run, rate)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n▶️  Suite run %d/%d: %s (rate=%d/s duration=%s)\n", i+1, len(suite.Runs), run.Name, cfg.Load.Rate, cfg.Load.Duration)
+		for _, d := range meta.Derived {
+			fmt.Printf("🧮 %s = %g, from %s (%g)\n", d.Field, d.Value, d.Expr, d.Source)
+		}
+		ok, err := executeSuiteRun(ctx, suite, run, cfg, meta)
+		if err != nil {
+			return fmt.Errorf("suite run %q: %w", run.Name, err)
+		}
+		if !ok {
+			failed++
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("suite interrupted during run %q", run.Name)
+		}
+	}
+	fmt.Printf("\n✅ Suite complete: %d runs, results in %s\n", len(suite.Runs), suite.Dir)
+	if failed > 0 {
+		return fmt.Errorf("%w (or SLOs): in %d of %d suite runs", stats.ErrThresholds, failed, len(suite.Runs))
+	}
+	return nil
+}
+
+func suiteFile(suite *config.Suite, run, suffix string) string {
+	return filepath.Join(suite.Dir, run+suffix)
+}
+
+// suiteRunConfig loads a run's config like attack does (file, then SHARD_*
+// env), applies the suite's overrides and output paths, and validates it.
+// A rate of 0 keeps the run's own.
+func suiteRunConfig(suite *config.Suite, run config.SuiteRun, rate int) (*config.Config, error) {
+	cfg, err := config.ReadConfig(run.Config)
+	if err != nil {
+		return nil, fmt.Errorf("%w: suite run %q: %w", config.ErrInvalid, run.Name, err)
+	}
+	if err := cfg.ApplyEnv(os.Getenv); err != nil {
+		return nil, fmt.Errorf("%w: suite run %q: env override: %w", config.ErrInvalid, run.Name, err)
+	}
+	if run.Rate != nil && !run.Rate.Derived() {
+		cfg.Load.Rate = run.Rate.Fixed
+	}
+	if rate > 0 {
+		cfg.Load.Rate = rate
+	}
+	if run.Duration != "" {
+		cfg.Load.Duration = run.Duration
+	}
+	cfg.Output.JSONLPath = suiteFile(suite, run.Name, ".jsonl")
+	cfg.Output.ProgressPath = suiteFile(suite, run.Name, ".progress.log")
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: suite run %q: %w", config.ErrInvalid, run.Name, err)
+	}
+	return cfg, nil
+}
+
+// deriveRate resolves a rate reference from the referenced run's summary.
+func deriveRate(suite *config.Suite, ref config.RateRef) (attack.Derivation, error) {
+	data, err := os.ReadFile(suiteFile(suite, ref.Run, ".summary.json"))
+	if err != nil {
+		return attack.Derivation{}, fmt.Errorf("rate %q: %w", ref, err)
+	}
+	v, err := stats.LookupMetric(data, ref.Metric)
+	if err != nil {
+		return attack.Derivation{}, fmt.Errorf("rate %q: run %s: %w", ref, ref.Run, err)
+	}
+	rate := math.Round(v * ref.Percent / 100)
+	if rate < 1 {
+		return attack.Derivation{}, fmt.Errorf("rate %q: resolves to %g/s from %g", ref, rate, v)
+	}
+	return attack.Derivation{Field: "load.rate", Expr: ref.String(), Source: v, Value: rate}, nil
+}
+
+// executeSuiteRun runs one attack and writes its summary JSON for later
+// runs to refer to. ok is false when the run failed its thresholds or SLOs.
+func executeSuiteRun(ctx context.Context, suite *config.Suite, run config.SuiteRun, cfg *config.Config, meta attack.MetaRecord) (ok bool, err error) {
+	sink, progressLog, err := openOutputs(cfg.Output, cfg.Output.JSONLPath, cfg.Output.ProgressPath)
+	if err != nil {
+		return false, err
+	}
+	defer progressLog.Close()
+	atk, err := shard.New(*cfg, shard.Options{Sink: sink, Terminal: os.Stdout, Log: progressLog, Meta: meta})
+	if err != nil {
+		sink.Close()
+		return false, err
+	}
+	start := time.Now()
+	summary, err := atk.Run(ctx)
+	if err != nil {
+		return false, fmt.Errorf("attack run: %w", err)
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("encode summary: %w", err)
+	}
+	if err := os.WriteFile(suiteFile(suite, run.Name, ".summary.json"), append(data, '\n'), 0o644); err != nil {
+		return false, fmt.Errorf("write summary: %w", err)
+	}
+	atk.Aggregator().ReportLevel(os.Stdout, 0)
+	fmt.Printf("✅ %s complete in %v\n", run.Name, time.Since(start).Round(time.Millisecond))
+	return summary.ThresholdsFailed() == 0 && summary.SLOFailed() == 0, nil
+}
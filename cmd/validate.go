@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+)
+
+// errProbe marks a validate probe that got an answer, but not a good one.
+var errProbe = errors.New("probe failed")
+
+// maxPlannedResults is where validate starts warning about result volume.
+const maxPlannedResults = 10_000_000
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	cfgPath := fs.String("cfg", "shard.json", "Path to config file")
+	fs.Parse(args)
+
+	cfg, err := config.ReadConfig(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	if err := cfg.ApplyEnv(os.Getenv); err != nil {
+		return fmt.Errorf("env override: %w: %w", config.ErrInvalid, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", config.ErrInvalid, err)
+	}
+	if err := prepare(os.Stdout, cfg); err != nil {
+		return err
+	}
+	for _, n := range cfg.Notices() {
+		fmt.Printf("⚠️  %s\n", n)
+	}
+	plan := cfg.Plan()
+	fmt.Printf("✅ Config OK: rate=%d/s duration=%s concurrency=%s\n", cfg.Load.Rate, cfg.Load.Duration, cfg.Load.Concurrency)
+	fmt.Printf("🗓  %s\n", config.FormatPlan(plan, 50))
+
+	n := config.PlanRequests(plan)
+	if cfg.Load.TotalRequests > 0 {
+		n = min(n, float64(cfg.Load.TotalRequests))
+	}
+	if math.IsInf(n, 1) {
+		fmt.Printf("♾️  the run has no end: stop it with Ctrl+C; a summary checkpoint goes to the progress log every %s\n", cfg.Output.CheckpointInterval)
+	} else if n > maxPlannedResults {
+		fmt.Printf("⚠️  the plan schedules ~%.0fM requests; expect a results file of several GB (see output.max_file_size)\n", n/1e6)
+	}
+
+	runner, err := attack.NewRunner(cfg)
+	if err != nil {
+		return fmt.Errorf("runner init: %w", err)
+	}
+	results, proto, err := runner.Probe()
+	if err != nil {
+		return fmt.Errorf("probe: %w", err)
+	}
+
+	var busy time.Duration // what one scheduled request keeps a worker busy for
+	var failed error
+	for _, res := range results {
+		printProbe(res, proto)
+		phases := res.Phases
+		busy += phases.Total
+		if !cfg.Load.DisableKeepAlive {
+			// a real run reuses connections; only the probe pays for setting one up
+			busy -= phases.DNS + phases.Connect + phases.TLS
+		}
+		switch {
+		case res.Error != "" && res.Code == 0 && res.Error != "template":
+			failed = fmt.Errorf("%w: %s", attack.ErrUnreachable, res.Error)
+		case res.Error != "":
+			failed = fmt.Errorf("%w: %s", errProbe, res.Error)
+		case res.Code >= 400:
+			failed = fmt.Errorf("%w: status %d", errProbe, res.Code)
+		}
+		if failed != nil {
+			break
+		}
+	}
+
+	timeout, _ := time.ParseDuration(cfg.Load.Timeout)
+	for _, res := range results {
+		if timeout > 0 && res.Error == "" && res.Phases.Total > timeout*8/10 {
+			fmt.Printf("⚠️  the probe took %v, close to load.timeout %v; expect timeouts under load\n",
+				res.Phases.Total.Round(time.Millisecond), timeout)
+		}
+	}
+	if failed == nil && busy > 0 {
+		// Little's law: requests in flight = arrival rate × time in the system
+		need := int(math.Ceil(float64(cfg.Load.Rate) * busy.Seconds()))
+		switch {
+		case cfg.Load.Concurrency.Auto() && need > config.AutoWorkersMax:
+			fmt.Printf("⚠️  ~%d workers are needed for %d/s at the probe's %v per request, over the auto concurrency ceiling of %d; the run will fall behind\n",
+				need, cfg.Load.Rate, busy.Round(time.Millisecond), config.AutoWorkersMax)
+		case !cfg.Load.Concurrency.Auto() && int(cfg.Load.Concurrency) < need:
+			fmt.Printf("⚠️  load.concurrency %d is below the ~%d workers needed for %d/s at the probe's %v per request; the run will fall behind\n",
+				cfg.Load.Concurrency, need, cfg.Load.Rate, busy.Round(time.Millisecond))
+		}
+	}
+	if failed != nil {
+		return failed
+	}
+	fmt.Println("✅ Probe OK")
+	return nil
+}
+
+func printProbe(res attack.Result, proto string) {
+	name := res.Method
+	if res.Step != "" {
+		name = "step " + res.Step
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	p := res.Phases
+	switch {
+	case res.Code == 0:
+		fmt.Printf("❌ %s: %s (failed in %s after %.1fms)\n", name, res.Error, res.FailPhase, ms(p.Total))
+	default:
+		icon := "🎯"
+		if res.Error != "" || res.Code >= 400 {
+			icon = "❌"
+		}
+		fmt.Printf("%s %s: %d %s via %s from %s\n", icon, name, res.Code, http.StatusText(res.Code), proto, res.RemoteAddr)
+		if res.Error != "" {
+			fmt.Printf("   check failed: %s\n", res.Error)
+		}
+	}
+	if res.Code > 0 || res.RemoteAddr != "" {
+		fmt.Printf("   dns=%.1fms connect=%.1fms tls=%.1fms ttfb=%.1fms total=%.1fms\n",
+			ms(p.DNS), ms(p.Connect), ms(p.TLS), ms(p.TTFB), ms(p.Total))
+	}
+}
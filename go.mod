@@ -1,2 +1,8 @@
 module shard
-go 1.22
\ No newline at end of file
+
+go 1.22
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.18.0
+)
@@ -0,0 +1,113 @@
+package attack
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"shard/internal/config"
+)
+
+// ThresholdError reports which abort threshold stopped the run.
+type ThresholdError struct {
+	Threshold string  `json:"threshold"` // config field, e.g. "abort.error_rate"
+	Observed  float64 `json:"observed"`  // value that crossed the limit
+	Limit     float64 `json:"limit"`
+}
+
+func (e *ThresholdError) Error() string {
+	return fmt.Sprintf("aborted: %s exceeded (observed %.4g, limit %.4g)", e.Threshold, e.Observed, e.Limit)
+}
+
+// abortCounts is the measured traffic one set of thresholds is evaluated against.
+type abortCounts struct {
+	samples     int64
+	failed      int64
+	consecutive int
+	lat         latencyHistogram
+}
+
+func (c *abortCounts) observe(res Result) {
+	c.samples++
+	if res.Error == "" {
+		c.consecutive = 0
+		c.lat.Add(res.Phases.Total)
+		return
+	}
+	c.failed++
+	c.consecutive++
+}
+
+// abortGuard evaluates the abort thresholds against measured results, for all
+// traffic and per method. Warmup and cooldown results are ignored. It runs on
+// the writer goroutine only; the settings may be swapped by Reload.
+type abortGuard struct {
+	settings atomic.Pointer[config.Abort]
+
+	all      abortCounts
+	byMethod map[string]*abortCounts
+	tripped  *ThresholdError
+}
+
+func newAbortGuard(a config.Abort) *abortGuard {
+	g := &abortGuard{byMethod: make(map[string]*abortCounts)}
+	g.settings.Store(&a)
+	return g
+}
+
+// observe records a result and trips immediately on a failure streak.
+func (g *abortGuard) observe(res Result) *ThresholdError {
+	if res.Stage == "warmup" || res.Stage == "cooldown" || g.tripped != nil {
+		return nil
+	}
+	a := g.settings.Load()
+	if res.Error == "client_abort" && !a.CountClientAborts {
+		return nil
+	}
+	g.all.observe(res)
+	if limit := a.ConsecutiveFailures; limit > 0 && g.all.consecutive >= limit {
+		g.tripped = &ThresholdError{"abort.consecutive_failures", float64(g.all.consecutive), float64(limit)}
+		return g.tripped
+	}
+
+	mc := g.byMethod[res.Method]
+	if mc == nil {
+		mc = &abortCounts{}
+		g.byMethod[res.Method] = mc
+	}
+	mc.observe(res)
+	if limit := a.Methods[res.Method].ConsecutiveFailures; limit > 0 && mc.consecutive >= limit {
+		g.tripped = &ThresholdError{"abort.methods." + res.Method + ".consecutive_failures", float64(mc.consecutive), float64(limit)}
+	}
+	return g.tripped
+}
+
+// check evaluates the rate-based thresholds; called once per progress tick.
+func (g *abortGuard) check() *ThresholdError {
+	if g.tripped != nil {
+		return g.tripped
+	}
+	a := g.settings.Load()
+	g.tripped = checkRates("abort", &g.all, a.ErrorRate, a.P99Ms, a.MinSamples)
+	for method, m := range a.Methods {
+		if g.tripped != nil {
+			break
+		}
+		if mc := g.byMethod[method]; mc != nil {
+			g.tripped = checkRates("abort.methods."+method, mc, m.ErrorRate, m.P99Ms, a.MinSamples)
+		}
+	}
+	return g.tripped
+}
+
+func checkRates(scope string, c *abortCounts, errorRate, p99Ms float64, minSamples int) *ThresholdError {
+	if c.samples < int64(minSamples) || c.samples == 0 {
+		return nil
+	}
+	if rate := float64(c.failed) / float64(c.samples); errorRate > 0 && rate > errorRate {
+		return &ThresholdError{scope + ".error_rate", rate, errorRate}
+	}
+	if p99 := c.lat.Quantile(0.99); p99Ms > 0 && p99 > p99Ms {
+		return &ThresholdError{scope + ".p99_ms", p99, p99Ms}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package attack
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/config"
+)
+
+// agentFlushEvery bounds how long a streamed record may sit in the response buffer.
+const agentFlushEvery = 200 * time.Millisecond
+
+// AgentHandler serves distributed runs. POST /run with the controller's
+// agentRun as JSON executes the attack and streams every JSONL record back
+// as it is written; the run stops when the controller disconnects. Requests
+// must carry "Authorization: Bearer <token>", and the config may only name
+// files it carries inline, so a caller can neither read the agent's files
+// nor reach its unix sockets. Only one run is served at a time, a
+// concurrent request gets 409. Run events go to logw.
+func AgentHandler(logw io.Writer, token string) http.Handler {
+	return &agent{log: logw, token: []byte("Bearer " + token)}
+}
+
+type agent struct {
+	log   io.Writer
+	token []byte // the whole Authorization value
+	busy  atomic.Bool
+}
+
+func (a *agent) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/run" {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		http.Error(w, "POST a config to /run", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), a.token) != 1 {
+		fmt.Fprintf(a.log, "⛔ rejected run from %s: bad or missing token\n", req.RemoteAddr)
+		http.Error(w, "agent token required", http.StatusUnauthorized)
+		return
+	}
+
+	var run agentRun
+	if err := json.NewDecoder(req.Body).Decode(&run); err != nil {
+		http.Error(w, "decode run: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg := run.Config
+	if cfg.Target.UnixSocket != "" {
+		http.Error(w, "invalid config: target.unix_socket is not available on agents", http.StatusBadRequest)
+		return
+	}
+	dir, err := os.MkdirTemp("", "shard-agent-")
+	if err != nil {
+		http.Error(w, "files: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+	if err := writeRunFiles(&cfg, run.Files, dir); err != nil {
+		http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !a.busy.CompareAndSwap(false, true) {
+		http.Error(w, "agent is already running an attack", http.StatusConflict)
+		return
+	}
+	defer a.busy.Store(false)
+
+	runner, err := NewRunner(&cfg)
+	if err != nil {
+		http.Error(w, "runner init: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(a.log, "🚀 run from %s: rate=%d/s duration=%s concurrency=%s\n",
+		req.RemoteAddr, cfg.Load.Rate, cfg.Load.Duration, cfg.Load.Concurrency)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	sink := &streamSink{w: w}
+	sink.flusher, _ = w.(http.Flusher)
+	start := time.Now()
+	if err := runner.RunSink(req.Context(), sink, nil, nil); err != nil {
+		// the sink only flushes on Close, so the controller still gets the reason
+		json.NewEncoder(sink).Encode(newAgentEnd(err))
+		sink.Close()
+		fmt.Fprintf(a.log, "⚠️  run from %s ended: %v\n", req.RemoteAddr, err)
+		return
+	}
+	fmt.Fprintf(a.log, "✅ run from %s complete in %v\n", req.RemoteAddr, time.Since(start).Round(time.Millisecond))
+}
+
+// writeRunFiles writes the inline files of a run to dir and points cfg at
+// them. A file the run does not carry is an error, never a path on this host.
+func writeRunFiles(cfg *config.Config, files map[string][]byte, dir string) error {
+	n := 0
+	return cfg.RunFiles(func(field, key string) (string, error) {
+		data, ok := files[key]
+		if !ok || key != field {
+			return "", fmt.Errorf("%s: agents take files inline from the controller, not paths on their host", field)
+		}
+		n++
+		path := filepath.Join(dir, strconv.Itoa(n))
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return "", err
+		}
+		return path, nil
+	})
+}
+
+// streamSink writes records to an HTTP response, flushing at most every
+// agentFlushEvery so the controller sees results while the run progresses.
+type streamSink struct {
+	w         io.Writer
+	flusher   http.Flusher
+	lastFlush time.Time
+}
+
+func (s *streamSink) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	if s.flusher != nil && time.Since(s.lastFlush) >= agentFlushEvery {
+		s.flusher.Flush()
+		s.lastFlush = time.Now()
+	}
+	return n, err
+}
+
+func (s *streamSink) Close() error {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
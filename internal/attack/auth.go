@@ -0,0 +1,261 @@
+package attack
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/config"
+)
+
+// authBoundary is how close to a token change a 401 must come to be the
+// auth error rather than a plain 401: the token was replaced while the
+// request ran, was this close to expiring, or had been fetched this
+// recently by a refresh.
+const authBoundary = time.Second
+
+// authRetryDelay spaces token requests after a failed refresh.
+const authRetryDelay = time.Second
+
+// AuthRecord closes the results of a run with OAuth2 client credentials:
+// how many tokens it fetched, at the start and by background refreshes,
+// and how many token requests failed.
+type AuthRecord struct {
+	Type      string    `json:"type"` // always "auth"
+	Timestamp time.Time `json:"ts"`
+	Scheme    string    `json:"scheme"`
+	Fetched   int64     `json:"fetched"`   // tokens obtained, the first included
+	Refreshed int64     `json:"refreshed"` // of which by a refresh during the run
+	Failed    int64     `json:"failed"`    // token requests that failed
+	Lifetime  float64   `json:"lifetime"`  // seconds the last token was issued for, 0 without expiry
+}
+
+// authToken is one access token and the Authorization value it makes.
+type authToken struct {
+	header  string
+	gen     int64 // 1 for the first token of a run
+	fetched time.Time
+	expires time.Time // zero when the token does not expire
+}
+
+// authenticator sets every request's Authorization from the auth section.
+// Basic and bearer values are fixed; an OAuth2 token is fetched by start
+// and replaced by a background refresh, so requests always read the
+// current one without waiting.
+type authenticator struct {
+	scheme string
+	fixed  string // Authorization for basic and bearer
+	oauth  config.ClientCredentials
+	client *http.Client
+	token  atomic.Pointer[authToken]
+
+	fetched, refreshed, failed atomic.Int64
+	lifetime                   atomic.Int64 // ns
+}
+
+// newAuthenticator returns nil without an auth section. client sends the
+// token requests.
+func newAuthenticator(a config.Auth, client *http.Client) *authenticator {
+	au := &authenticator{scheme: a.Scheme()}
+	switch au.scheme {
+	case "":
+		return nil
+	case "basic":
+		au.fixed = "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Basic.Username+":"+a.Basic.Password))
+	case "bearer":
+		au.fixed = "Bearer " + a.Bearer.Token
+	default:
+		au.oauth = a.OAuth2
+		au.client = client
+	}
+	return au
+}
+
+// start fetches the first OAuth2 token and keeps it fresh until ctx ends.
+// Basic and bearer have nothing to start.
+func (a *authenticator) start(ctx context.Context, annotate func(string)) error {
+	if a == nil || a.fixed != "" {
+		return nil
+	}
+	a.fetched.Store(0)
+	a.refreshed.Store(0)
+	a.failed.Store(0)
+	tok, err := a.fetch(ctx, 1)
+	if err != nil {
+		a.failed.Add(1)
+		return fmt.Errorf("fetch token from %s: %w", a.oauth.TokenURL, err)
+	}
+	a.token.Store(tok)
+	go a.refresh(ctx, annotate)
+	return nil
+}
+
+// refresh replaces the token ahead of its expiry, retrying failed requests
+// every authRetryDelay; the old token stays in use meanwhile.
+func (a *authenticator) refresh(ctx context.Context, annotate func(string)) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	retrying := false
+	for {
+		cur := a.token.Load()
+		if cur.expires.IsZero() {
+			return
+		}
+		wait := authRetryDelay
+		if !retrying {
+			wait = time.Until(a.refreshAt(cur))
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		next, err := a.fetch(ctx, cur.gen+1)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			a.failed.Add(1)
+			annotate(fmt.Sprintf("auth: token refresh failed, retrying in %s: %v", authRetryDelay, err))
+			retrying = true
+			continue
+		}
+		retrying = false
+		a.refreshed.Add(1)
+		a.token.Store(next)
+	}
+}
+
+// refreshAt is when tok is replaced: refresh_before ahead of its expiry, or
+// with a fifth of its lifetime left, and never before half of it is over.
+func (a *authenticator) refreshAt(tok *authToken) time.Time {
+	life := tok.expires.Sub(tok.fetched)
+	lead := a.oauth.RefreshLead()
+	if lead == 0 {
+		lead = life / 5
+	}
+	return tok.expires.Add(-min(lead, life/2))
+}
+
+// fetch requests a token with the client credentials grant.
+func (a *authenticator) fetch(ctx context.Context, gen int64) (*authToken, error) {
+	o := a.oauth
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	if o.Audience != "" {
+		form.Set("audience", o.Audience)
+	}
+	if o.AuthStyle == "body" {
+		form.Set("client_id", o.ClientID)
+		form.Set("client_secret", o.ClientSecret)
+	}
+	ctx, cancel := context.WithTimeout(ctx, o.TokenTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.AuthStyle != "body" {
+		req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	}
+	sent := time.Now()
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var tr struct {
+		AccessToken string          `json:"access_token"`
+		TokenType   string          `json:"token_type"`
+		ExpiresIn   json.RawMessage `json:"expires_in"`
+		Error       string          `json:"error"`
+		Description string          `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tr); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.AccessToken == "" {
+		msg := strings.TrimSpace(tr.Error + " " + tr.Description)
+		if msg == "" {
+			msg = "no access_token"
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return nil, fmt.Errorf("unsupported token_type %q", tr.TokenType)
+	}
+	tok := &authToken{header: "Bearer " + tr.AccessToken, gen: gen, fetched: sent}
+	// expires_in is a number, though some servers send it as a string
+	var life time.Duration
+	if secs, err := strconv.ParseFloat(strings.Trim(string(tr.ExpiresIn), `"`), 64); err == nil && secs > 0 {
+		life = time.Duration(secs * float64(time.Second))
+		tok.expires = sent.Add(life)
+	}
+	a.fetched.Add(1)
+	a.lifetime.Store(int64(life))
+	return tok, nil
+}
+
+// apply sets h's Authorization and returns the OAuth2 token it used.
+func (a *authenticator) apply(h http.Header) *authToken {
+	if a == nil {
+		return nil
+	}
+	if a.fixed != "" {
+		h.Set("Authorization", a.fixed)
+		return nil
+	}
+	tok := a.token.Load()
+	if tok != nil {
+		h.Set("Authorization", tok.header)
+	}
+	return tok
+}
+
+// aroundRefresh reports whether a request that sent tok from start until
+// end ran into a token change, see authBoundary.
+func (a *authenticator) aroundRefresh(tok *authToken, start, end time.Time) bool {
+	if a == nil || tok == nil {
+		return false
+	}
+	if cur := a.token.Load(); cur != nil && cur.gen != tok.gen {
+		return true
+	}
+	if !tok.expires.IsZero() && end.After(tok.expires.Add(-authBoundary)) {
+		return true
+	}
+	return tok.gen > 1 && start.Sub(tok.fetched) < authBoundary
+}
+
+// record returns the closing auth record, nil unless tokens were fetched.
+func (a *authenticator) record() *AuthRecord {
+	if a == nil || a.fixed != "" {
+		return nil
+	}
+	return &AuthRecord{
+		Type:      "auth",
+		Timestamp: time.Now(),
+		Scheme:    a.scheme,
+		Fetched:   a.fetched.Load(),
+		Refreshed: a.refreshed.Load(),
+		Failed:    a.failed.Load(),
+		Lifetime:  time.Duration(a.lifetime.Load()).Seconds(),
+	}
+}
@@ -0,0 +1,118 @@
+package attack
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"shard/internal/config"
+)
+
+// tokenServer is an OAuth2 client credentials endpoint issuing tokens that
+// expire after life, and a resource that accepts only unexpired ones.
+type tokenServer struct {
+	life time.Duration
+
+	mu      sync.Mutex
+	expires map[string]time.Time // token -> expiry
+	used    map[string]int       // token -> requests accepted with it
+	issued  int
+	denied  int
+}
+
+func (s *tokenServer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != "shard" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	s.mu.Lock()
+	s.issued++
+	tok := fmt.Sprintf("tok-%d", s.issued)
+	s.expires[tok] = time.Now().Add(s.life)
+	s.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": tok,
+		"token_type":   "Bearer",
+		"expires_in":   s.life.Seconds(),
+	})
+}
+
+func (s *tokenServer) resource(w http.ResponseWriter, r *http.Request) {
+	tok, _ := bytes.CutPrefix([]byte(r.Header.Get("Authorization")), []byte("Bearer "))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if exp, ok := s.expires[string(tok)]; !ok || time.Now().After(exp) {
+		s.denied++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.used[string(tok)]++
+}
+
+func TestOAuth2BackgroundRefresh(t *testing.T) {
+	srv := &tokenServer{life: 5 * time.Second, expires: map[string]time.Time{}, used: map[string]int{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", srv.token)
+	mux.HandleFunc("/", srv.resource)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	// refreshed with a fifth of the lifetime left, at 4s, so the run outlives
+	// the first token
+	cfg := testConfig(ts.URL + "/api")
+	cfg.Load.Rate = 20
+	cfg.Load.Duration = "6500ms"
+	cfg.Auth.OAuth2 = config.ClientCredentials{TokenURL: ts.URL + "/token", ClientID: "shard", ClientSecret: "s3cret"}
+	r := newTestRunner(t, cfg)
+	var sink memSink
+	if err := r.RunSink(context.Background(), &sink, nil, nil); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	var rec *AuthRecord
+	var requests, failed int
+	sc := bufio.NewScanner(&sink)
+	for sc.Scan() {
+		line := sc.Bytes()
+		switch {
+		case bytes.HasPrefix(line, []byte(`{"type":"auth"`)):
+			rec = new(AuthRecord)
+			if err := json.Unmarshal(line, rec); err != nil {
+				t.Fatal(err)
+			}
+		case !bytes.HasPrefix(line, []byte(`{"type":`)):
+			var res Result
+			if err := json.Unmarshal(line, &res); err != nil {
+				t.Fatal(err)
+			}
+			requests++
+			if res.Code != http.StatusOK {
+				failed++
+			}
+		}
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.denied > 0 || failed > 0 {
+		t.Fatalf("%d requests denied by the resource, %d of %d failed: a token expired in use", srv.denied, failed, requests)
+	}
+	if srv.issued < 2 || srv.used["tok-2"] == 0 {
+		t.Fatalf("%d tokens issued, used %v: the refreshed token was never sent", srv.issued, srv.used)
+	}
+	if rec == nil {
+		t.Fatal("no auth record closes the results")
+	}
+	if rec.Fetched != int64(srv.issued) || rec.Refreshed != rec.Fetched-1 || rec.Failed != 0 || rec.Lifetime != 5 {
+		t.Fatalf("auth record %+v, server issued %d tokens", *rec, srv.issued)
+	}
+}
@@ -0,0 +1,156 @@
+package attack
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/config"
+)
+
+// Auto concurrency checks the work queue every autoTick and grows the pool
+// when the queue was non-empty at two checks in a row. Every autoIdle
+// without growth, workers beyond the most that were busy at once (plus
+// autoHeadroom, never below the floor) are retired.
+const (
+	autoTick     = 250 * time.Millisecond
+	autoIdle     = 10 * time.Second
+	autoHeadroom = 0.25
+)
+
+// WorkersRecord is written at the end of a run with load.concurrency "auto",
+// so the report can state the pool size the load needed.
+type WorkersRecord struct {
+	Type      string    `json:"type"` // always "workers"
+	Timestamp time.Time `json:"ts"`
+	Peak      int       `json:"peak"`
+	PeakBusy  int       `json:"peak_busy"` // most workers serving at once
+	Ceiling   int       `json:"ceiling"`
+}
+
+// autoPool runs the workers of a load.concurrency "auto" run. Only its own
+// goroutine starts workers, and halt stops it before the runner closes the
+// work queue, so the pool never grows while shutdown waits for workers.
+type autoPool struct {
+	work   chan token
+	wg     *sync.WaitGroup
+	serve  func(*worker, token)
+	build  func() *worker
+	active *atomic.Int64 // StatsCollector.workers, for the live stats
+	busy   atomic.Int64  // workers serving a token right now
+	retire chan struct{} // an idle worker receiving from it exits
+	onCeil func()        // called when growth reaches the ceiling
+
+	peak, peakBusy int // owned by loop until halt returns
+	stop           chan struct{}
+	done           chan struct{}
+}
+
+func newAutoPool(r *Runner, work chan token, wg *sync.WaitGroup, stats *StatsCollector, serve func(*worker, token)) *autoPool {
+	p := &autoPool{
+		work:   work,
+		wg:     wg,
+		serve:  serve,
+		build:  r.workerFactory(),
+		active: &stats.workers,
+		retire: make(chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	p.onCeil = func() {
+		r.annotate(fmt.Sprintf("auto concurrency reached its ceiling of %d workers", config.AutoWorkersMax))
+	}
+	p.spawn(config.AutoWorkersMin)
+	go p.loop()
+	return p
+}
+
+func (p *autoPool) spawn(n int) {
+	for range n {
+		w := p.build()
+		p.active.Add(1)
+		p.wg.Add(1)
+		go p.run(w)
+	}
+	p.peak = max(p.peak, int(p.active.Load()))
+}
+
+func (p *autoPool) run(w *worker) {
+	defer p.wg.Done()
+	defer w.close()
+	for {
+		select {
+		case t, ok := <-p.work:
+			if !ok {
+				return
+			}
+			p.busy.Add(1)
+			p.serve(w, t)
+			p.busy.Add(-1)
+		case <-p.retire:
+			p.active.Add(-1)
+			return
+		}
+	}
+}
+
+func (p *autoPool) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(autoTick)
+	defer ticker.Stop()
+	backlog := false
+	var peakBusy int
+	quiet := time.Now() // start of the current window without growth
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		n := int(p.active.Load())
+		busy := int(p.busy.Load())
+		peakBusy = max(peakBusy, busy)
+		p.peakBusy = max(p.peakBusy, busy)
+		queued := len(p.work)
+		if queued > 0 && backlog {
+			backlog = false // give the new workers a tick to catch up
+			// a full queue doubles the pool; otherwise add a worker per queued token
+			add := queued
+			if queued == cap(p.work) {
+				add = n
+			}
+			add = min(add, n, config.AutoWorkersMax-n)
+			if add > 0 {
+				p.spawn(add)
+				if n+add == config.AutoWorkersMax {
+					p.onCeil()
+				}
+			}
+			peakBusy, quiet = 0, time.Now()
+			continue
+		}
+		backlog = queued > 0
+		if time.Since(quiet) < autoIdle {
+			continue
+		}
+		keep := max(config.AutoWorkersMin, int(float64(peakBusy)*(1+autoHeadroom))+1)
+	shrink:
+		for range n - keep {
+			select {
+			case p.retire <- struct{}{}:
+			default:
+				break shrink // the rest are busy
+			}
+		}
+		peakBusy, quiet = 0, time.Now()
+	}
+}
+
+// halt stops growth and returns the run's record. Workers keep serving
+// until the work queue is closed.
+func (p *autoPool) halt() WorkersRecord {
+	close(p.stop)
+	<-p.done
+	return WorkersRecord{Type: "workers", Peak: p.peak, PeakBusy: p.peakBusy, Ceiling: config.AutoWorkersMax}
+}
@@ -0,0 +1,108 @@
+package attack
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// baselineGap spaces baseline probes so they measure an idle target.
+const baselineGap = 100 * time.Millisecond
+
+// Baseline is the network-only cost of a request, measured by
+// load.baseline before the run and recorded in the meta record.
+type Baseline struct {
+	Method   string `json:"method"`
+	Requests int    `json:"requests"` // probes sent
+	Failed   int    `json:"failed,omitempty"`
+	// Medians are per phase, in ms. ttfb and total are counted from the
+	// moment the connection was ready, so they compare with requests on a
+	// reused connection; dns, connect and tls with those on a new one.
+	Medians map[string]float64 `json:"medians"`
+	// TotalP95 is the p95 of total, counted the same way, in ms: what the
+	// report measures cooldown recovery against.
+	TotalP95 float64 `json:"total_p95,omitempty"`
+}
+
+// calibrate sends the load.baseline probes one after another, each on a
+// fresh connection, and returns their medians. It returns nil, with the
+// reason, when no probe succeeded; the run goes ahead without a baseline.
+func (r *Runner) calibrate(ctx context.Context) (*Baseline, error) {
+	cfg := r.cfg.Load.Baseline
+	url, headers := r.cfg.Target.URL, r.cfg.Target.Headers
+	if r.cfg.Scenario != nil {
+		first := r.cfg.Scenario.Steps[0]
+		url, headers = first.URL, first.Headers
+	}
+	req, err := newRequest(cfg.Method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("baseline request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	transport, _ := r.client.Transport.(*http.Transport)
+	closeIdle := func() {
+		if transport != nil {
+			transport.CloseIdleConnections()
+		}
+	}
+	// the run starts on fresh connections, as it would without a baseline
+	defer closeIdle()
+
+	w := r.newWorkers(1)[0]
+	b := &Baseline{Method: cfg.Method, Requests: cfg.Requests}
+	phases := map[string][]time.Duration{}
+	var lastErr string
+	for i := range cfg.Requests {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(baselineGap):
+			}
+		}
+		closeIdle()
+		res := r.doRequest(w, req, nil)
+		if res.Error != "" {
+			b.Failed++
+			lastErr = res.Error
+			continue
+		}
+		p := res.Phases
+		setup := p.DNS + p.Connect + p.TLS
+		phases["dns"] = append(phases["dns"], p.DNS)
+		phases["connect"] = append(phases["connect"], p.Connect)
+		phases["tls"] = append(phases["tls"], p.TLS)
+		phases["ttfb"] = append(phases["ttfb"], max(0, p.TTFB-setup))
+		phases["total"] = append(phases["total"], max(0, p.Total-setup))
+	}
+	if b.Failed == b.Requests {
+		return nil, fmt.Errorf("all %d baseline probes failed, last with %s", b.Requests, lastErr)
+	}
+	b.Medians = make(map[string]float64, len(phases))
+	for name, ds := range phases {
+		slices.Sort(ds)
+		b.Medians[name] = toMillis(ds[(len(ds)-1)/2])
+	}
+	total := phases["total"]
+	b.TotalP95 = toMillis(total[max(0, int(math.Ceil(0.95*float64(len(total))))-1)])
+	return b, nil
+}
+
+// String is the one-line console form, e.g. "10 HEAD probes: dns=0.4ms ...".
+func (b *Baseline) String() string {
+	s := fmt.Sprintf("%d %s probes", b.Requests, b.Method)
+	if b.Failed > 0 {
+		s += fmt.Sprintf(" (%d failed)", b.Failed)
+	}
+	s += ":"
+	for _, name := range []string{"dns", "connect", "tls", "ttfb", "total"} {
+		s += fmt.Sprintf(" %s=%.2fms", name, b.Medians[name])
+	}
+	return s
+}
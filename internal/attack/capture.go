@@ -0,0 +1,91 @@
+package attack
+
+import (
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/config"
+)
+
+// bodyCapture decides which response bodies to keep and enforces the per-run cap.
+// Settings can be swapped while running (see Reload).
+type bodyCapture struct {
+	settings atomic.Pointer[config.Capture]
+	taken    atomic.Int64
+}
+
+func newBodyCapture(c config.Capture) *bodyCapture {
+	bc := &bodyCapture{}
+	bc.settings.Store(&c)
+	return bc
+}
+
+// read drains body and returns a truncated sample when the response should be
+// captured. A read error (e.g. the overall timeout firing mid-body) is returned.
+func (bc *bodyCapture) read(code int, body io.Reader) (string, error) {
+	c := bc.settings.Load()
+	success := code >= 200 && code < 300
+	want := (!success && c.OnError) || (success && c.SampleRate > 0 && rand.Float64() < c.SampleRate)
+	if !want || bc.taken.Load() >= int64(c.MaxCaptures) || bc.taken.Add(1) > int64(c.MaxCaptures) {
+		_, err := io.Copy(io.Discard, body)
+		return "", err
+	}
+
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, io.LimitReader(body, int64(c.MaxBytes))); err != nil {
+		return sb.String(), err
+	}
+	_, err := io.Copy(io.Discard, body)
+	return sb.String(), err
+}
+
+// dateSkew returns how far the server's Date header is ahead of the client
+// clock at completion, or ok=false when the header is absent or unparseable.
+// Date has one-second resolution, so the client time is truncated to match.
+// Returns nil, true when header capture is off.
+// numericHeaders parses the configured numeric headers, skipping absent or
+// non-numeric values. A Retry-After date is converted to seconds from end.
+func (bc *bodyCapture) numericHeaders(h http.Header, end time.Time) map[string]float64 {
+	names := bc.settings.Load().NumericHeaders
+	if len(names) == 0 {
+		return nil
+	}
+	var out map[string]float64
+	for _, name := range names {
+		raw := strings.TrimSpace(h.Get(name))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			date, derr := http.ParseTime(raw)
+			if derr != nil || !strings.EqualFold(name, "Retry-After") {
+				continue
+			}
+			v = date.Sub(end).Seconds()
+		}
+		if out == nil {
+			out = make(map[string]float64, len(names))
+		}
+		out[name] = v
+	}
+	return out
+}
+
+func (bc *bodyCapture) dateSkew(h http.Header, end time.Time) (skew *time.Duration, ok bool) {
+	if !bc.settings.Load().Headers {
+		return nil, true
+	}
+	date, err := http.ParseTime(h.Get("Date"))
+	if err != nil {
+		return nil, false
+	}
+	d := date.Sub(end.Truncate(time.Second))
+	return &d, true
+}
@@ -0,0 +1,42 @@
+package attack
+
+import (
+	"bytes"
+	"io"
+)
+
+// Checkpointer takes every result of a run as it is written, and the typed
+// records (meta, annotations, ...) as JSONL lines, and summarises those so
+// far on demand; the stats Aggregator is one. Long runs use it to put a full
+// summary into the progress log every output.checkpoint_interval, so trends
+// show before the run ends.
+type Checkpointer interface {
+	Add(Result)
+	AddRecord(line []byte) error
+	ReportLevel(w io.Writer, level int)
+}
+
+// recordTap passes records on to w and the typed ones to the checkpointer
+// too. Every write is one or more whole lines.
+type recordTap struct {
+	w  io.Writer
+	to Checkpointer
+}
+
+func (t recordTap) Write(p []byte) (int, error) {
+	for rest := p; len(rest) > 0; {
+		line, more, _ := bytes.Cut(rest, []byte("\n"))
+		if bytes.HasPrefix(line, []byte(`{"type":`)) {
+			t.to.AddRecord(line)
+		}
+		rest = more
+	}
+	return t.w.Write(p)
+}
+
+// SetCheckpoints makes the next runs feed c and write checkpoints from it.
+// It is only touched by the results writer, and runs without a progress log
+// write no checkpoints.
+func (r *Runner) SetCheckpoints(c Checkpointer) {
+	r.checkpoints = c
+}
@@ -0,0 +1,157 @@
+package attack
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// failCategories is the fixed set of failure phases the live stats count:
+// classifyError's taxonomy plus the phases set outside it. Anything else
+// (e.g. a phase from a newer agent) is counted as "other".
+var failCategories = [...]string{
+	"dns", "connect", "proxy", "proxy_auth", "auth", "tls", "tls_client_auth", "timeout", "ttfb", "body",
+	"redirect_limit", "template", "extract", "client_abort", "ws_upgrade", "ws_closed", "other",
+}
+
+var failIndex = func() map[string]int {
+	m := make(map[string]int, len(failCategories))
+	for i, c := range failCategories {
+		m[c] = i
+	}
+	return m
+}()
+
+// StatsCollector maintains real-time metrics. Every counter is a plain
+// atomic, so Add is safe for concurrent use and does not allocate.
+type StatsCollector struct {
+	sent       atomic.Int64
+	delivered  atomic.Int64 // sent requests that reached the server, see Result.Undelivered
+	success    atomic.Int64
+	fail       atomic.Int64
+	successLat atomic.Int64 // summed total latency in ns
+	failLat    atomic.Int64
+	fails      [len(failCategories)]atomic.Int64
+	families   [4]atomic.Int64 // 2xx..5xx of successful responses
+	dispatched atomic.Int64    // requests workers started, see Dispatch
+	workers    atomic.Int64    // live pool size under auto concurrency, 0 otherwise
+
+	// last DispatchRate reading; only the progress goroutine touches these
+	rateCount int64
+	rateAt    time.Time
+}
+
+// Dispatch counts a request a worker is starting, ahead of its result.
+func (s *StatsCollector) Dispatch() {
+	s.dispatched.Add(1)
+}
+
+// Workers returns the live worker pool size under load.concurrency "auto",
+// or 0 with a fixed pool.
+func (s *StatsCollector) Workers() int64 {
+	return s.workers.Load()
+}
+
+// DispatchRate returns the requests started per second since the previous
+// call (since start for the first one). Without Dispatch calls, e.g. when
+// merging agents' results, completed requests stand in. It must only be
+// called from one goroutine.
+func (s *StatsCollector) DispatchRate(start time.Time) float64 {
+	n := s.dispatched.Load()
+	if n == 0 {
+		n = s.sent.Load()
+	}
+	now := time.Now()
+	since := s.rateAt
+	if since.IsZero() {
+		since = start
+	}
+	rate := 0.0
+	if d := now.Sub(since).Seconds(); d > 0 {
+		rate = float64(n-s.rateCount) / d
+	}
+	s.rateCount, s.rateAt = n, now
+	return rate
+}
+
+// Add updates stats with a result.
+func (s *StatsCollector) Add(r Result) {
+	s.sent.Add(1)
+	if !r.Undelivered {
+		s.delivered.Add(1)
+	}
+	if r.Error != "" {
+		s.fail.Add(1)
+		s.failLat.Add(int64(r.Phases.Total))
+		i, ok := failIndex[r.FailPhase]
+		if !ok {
+			i = len(failCategories) - 1
+		}
+		s.fails[i].Add(1)
+		return
+	}
+	s.success.Add(1)
+	s.successLat.Add(int64(r.Phases.Total))
+	if f := r.Code/100 - 2; f >= 0 && f < len(s.families) {
+		s.families[f].Add(1)
+	}
+}
+
+// Delivered returns how many sent requests were actually written to the server.
+func (s *StatsCollector) Delivered() int64 {
+	return s.delivered.Load()
+}
+
+// FailLatency returns the mean time failed requests took, in ms.
+func (s *StatsCollector) FailLatency() float64 {
+	return meanMs(s.failLat.Load(), s.fail.Load())
+}
+
+// Snapshot returns a snapshot of current stats safely. avgLat is the mean
+// latency of successful requests in ms; fails holds only the categories seen.
+func (s *StatsCollector) Snapshot() (sent, success, fail int64, avgLat float64, fails map[string]int64, families map[string]int64) {
+	sent = s.sent.Load()
+	success = s.success.Load()
+	fail = s.fail.Load()
+	avgLat = meanMs(s.successLat.Load(), success)
+	fails = make(map[string]int64)
+	for i := range s.fails {
+		if n := s.fails[i].Load(); n > 0 {
+			fails[failCategories[i]] = n
+		}
+	}
+	families = map[string]int64{
+		"2xx": s.families[0].Load(),
+		"3xx": s.families[1].Load(),
+		"4xx": s.families[2].Load(),
+		"5xx": s.families[3].Load(),
+	}
+	return
+}
+
+// Progress returns the current readings as a progress record, for a run
+// that started at start. Like DispatchRate, which it calls, it must only be
+// called from one goroutine.
+func (s *StatsCollector) Progress(start time.Time) ProgressRecord {
+	now := time.Now()
+	p := ProgressRecord{
+		Type:      "progress",
+		Timestamp: now,
+		Elapsed:   now.Sub(start).Seconds(),
+		Delivered: s.Delivered(),
+		Rate:      s.DispatchRate(start),
+		FailAvgMs: s.FailLatency(),
+		Workers:   s.Workers(),
+	}
+	p.Sent, p.OK, p.Failed, p.AvgMs, p.Fails, p.Families = s.Snapshot()
+	if len(p.Fails) == 0 {
+		p.Fails = nil
+	}
+	return p
+}
+
+func meanMs(sumNs, n int64) float64 {
+	if n == 0 {
+		return 0
+	}
+	return float64(sumNs) / float64(n) / 1e6
+}
@@ -0,0 +1,44 @@
+package attack
+
+import (
+	"testing"
+	"time"
+)
+
+// collectorResults mixes the shapes Add sees: successes of every family and
+// failures of a known and an unknown phase.
+var collectorResults = []Result{
+	{Code: 200, Phases: PhaseTimings{Total: 12 * time.Millisecond}},
+	{Code: 302, Phases: PhaseTimings{Total: 3 * time.Millisecond}},
+	{Code: 503, Phases: PhaseTimings{Total: 40 * time.Millisecond}},
+	{Error: "connection refused", FailPhase: "connect", Undelivered: true},
+	{Error: "boom", FailPhase: "from-a-newer-agent"},
+}
+
+func TestCollectorAddDoesNotAllocate(t *testing.T) {
+	var s StatsCollector
+	i := 0
+	allocs := testing.AllocsPerRun(1000, func() {
+		s.Add(collectorResults[i%len(collectorResults)])
+		i++
+	})
+	if allocs != 0 {
+		t.Fatalf("Add allocates %.1f times per call", allocs)
+	}
+	_, success, fail, _, fails, families := s.Snapshot()
+	if fails["connect"] == 0 || fails["other"] == 0 || families["5xx"] == 0 || success+fail != s.sent.Load() {
+		t.Fatalf("success=%d fail=%d fails=%v families=%v", success, fail, fails, families)
+	}
+}
+
+func BenchmarkCollectorAdd(b *testing.B) {
+	var s StatsCollector
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			s.Add(collectorResults[i%len(collectorResults)])
+			i++
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package attack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"shard/internal/config"
+)
+
+// ErrNotRunning is returned by Extend when no run is scheduling requests.
+var ErrNotRunning = errors.New("no run in progress")
+
+// Extend pushes the running plan's end out by by: the steady stage runs
+// longer (see config.ExtendPlan), bounded by load.max_run_time. It returns
+// the new planned length and records the extension as an annotation.
+func (r *Runner) Extend(by time.Duration) (time.Duration, error) {
+	if by <= 0 {
+		return 0, fmt.Errorf("extension must be positive, got %s", by)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.planned == 0 {
+		return 0, ErrNotRunning
+	}
+	if config.IsUnbounded(r.planned) {
+		return 0, errors.New("the run has no planned end to extend")
+	}
+	if r.cfg.Load.ScheduleFile != "" {
+		return 0, errors.New("a replay runs for the length of load.schedule_file")
+	}
+	total := r.planned + by
+	if maxRun, _ := time.ParseDuration(r.cfg.Load.MaxRunTime); maxRun > 0 && total > maxRun {
+		return 0, fmt.Errorf("extending by %s would plan %s, over load.max_run_time %s", by, total, maxRun)
+	}
+	select {
+	case r.extendCh <- by:
+	default:
+		return 0, errors.New("too many extensions pending, retry shortly")
+	}
+	r.planned = total
+	r.annotate(fmt.Sprintf("run extended by %s, planned duration now %s", by, total))
+	return total, nil
+}
+
+// ControlHandler serves live control of a running attack. POST /extend with
+// {"by": "20m"} extends the run, see Extend. Accepted changes are logged to
+// logw.
+func ControlHandler(r *Runner, logw io.Writer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/extend", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, `POST {"by": "20m"} to /extend`, http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			By string `json:"by"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, "decode request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		by, err := time.ParseDuration(body.By)
+		if err != nil || by <= 0 {
+			http.Error(w, fmt.Sprintf("invalid \"by\" %q, want a positive duration such as \"20m\"", body.By), http.StatusBadRequest)
+			return
+		}
+		total, err := r.Extend(by)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		fmt.Fprintf(logw, "\n⏩ Run extended by %s via %s, planned duration now %s\n", by, req.RemoteAddr, total)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"extended_by": by.String(), "planned": total.String()})
+	})
+	return mux
+}
@@ -0,0 +1,74 @@
+package attack
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+
+	"shard/internal/config"
+)
+
+// worker is one worker goroutine's view of the runner: its id and the HTTP
+// client it sends with. Clients share the transport (and so the connection
+// pool); in per_worker cookie mode each has its own jar.
+type worker struct {
+	id     int
+	client *http.Client
+	proto  string  // protocol of the last response, e.g. "HTTP/2.0"
+	ws     *wsConn // the worker's connection under target.protocol websocket
+}
+
+// newWorkers builds the clients for n workers according to load.cookies.
+func (r *Runner) newWorkers(n int) []*worker {
+	next := r.workerFactory()
+	workers := make([]*worker, n)
+	for i := range workers {
+		workers[i] = next()
+	}
+	return workers
+}
+
+// workerFactory returns a function building one worker at a time, for pools
+// that grow during the run: ids are consecutive and a "shared" jar is shared
+// by every worker the function builds. It is not safe for concurrent use.
+func (r *Runner) workerFactory() func() *worker {
+	var shared http.CookieJar
+	if r.cfg.Load.Cookies == "shared" {
+		shared = r.newJar()
+	}
+	id := 0
+	return func() *worker {
+		client := *r.client
+		switch r.cfg.Load.Cookies {
+		case "shared":
+			client.Jar = shared
+		case "per_worker":
+			client.Jar = r.newJar()
+		}
+		w := &worker{id: id, client: &client}
+		id++
+		return w
+	}
+}
+
+// newJar returns a cookie jar seeded with target.cookies for the target URL.
+func (r *Runner) newJar() http.CookieJar {
+	jar, _ := cookiejar.New(nil) // only fails on a bad options value
+	if len(r.cfg.Target.Cookies) == 0 {
+		return jar
+	}
+	u, err := url.Parse(r.cfg.Target.URL)
+	if err != nil {
+		return jar
+	}
+	jar.SetCookies(u, seedCookies(r.cfg.Target))
+	return jar
+}
+
+func seedCookies(t config.Target) []*http.Cookie {
+	cookies := make([]*http.Cookie, 0, len(t.Cookies))
+	for name, value := range t.Cookies {
+		cookies = append(cookies, &http.Cookie{Name: name, Value: value, Path: "/"})
+	}
+	return cookies
+}
@@ -0,0 +1,79 @@
+package attack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// sessionServer issues a "session" cookie to requests that arrive without
+// one and counts what it saw.
+type sessionServer struct {
+	issued, returned, seeded atomic.Int64
+}
+
+func (s *sessionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie("tenant"); err == nil && c.Value == "acme" {
+		s.seeded.Add(1)
+	}
+	if _, err := r.Cookie("session"); err == nil {
+		s.returned.Add(1)
+		return
+	}
+	n := s.issued.Add(1)
+	http.SetCookie(w, &http.Cookie{Name: "session", Value: strconv.FormatInt(n, 10), Path: "/"})
+}
+
+func TestCookieJar(t *testing.T) {
+	tests := []struct {
+		mode      string
+		maxIssued int64 // sessions the server may hand out, 0 for one per request
+	}{
+		{"off", 0},
+		{"shared", 4},     // at most the workers racing for the first response
+		{"per_worker", 4}, // one per worker
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			var srv sessionServer
+			ts := httptest.NewServer(&srv)
+			defer ts.Close()
+
+			cfg := testConfig(ts.URL + "/login")
+			cfg.Load.Cookies = tt.mode
+			results := runTest(t, cfg)
+			n := int64(len(results))
+
+			issued, returned := srv.issued.Load(), srv.returned.Load()
+			if issued+returned != n {
+				t.Fatalf("server saw %d requests, the run recorded %d", issued+returned, n)
+			}
+			if tt.maxIssued == 0 {
+				if returned != 0 {
+					t.Fatalf("%d requests sent a cookie back with cookies off", returned)
+				}
+				return
+			}
+			if issued > tt.maxIssued || returned < n-tt.maxIssued {
+				t.Fatalf("%d sessions issued and %d sent back over %d requests, want the cookie on every later request",
+					issued, returned, n)
+			}
+		})
+	}
+}
+
+func TestCookieJarSeeded(t *testing.T) {
+	var srv sessionServer
+	ts := httptest.NewServer(&srv)
+	defer ts.Close()
+
+	cfg := testConfig(ts.URL)
+	cfg.Load.Cookies = "per_worker"
+	cfg.Target.Cookies = map[string]string{"tenant": "acme"}
+	results := runTest(t, cfg)
+	if seeded := srv.seeded.Load(); seeded != int64(len(results)) {
+		t.Fatalf("target.cookies arrived on %d of %d requests", seeded, len(results))
+	}
+}
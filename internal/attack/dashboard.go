@@ -0,0 +1,206 @@
+package attack
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"shard/internal/config"
+)
+
+// dashWindow is how many seconds of history the dashboard keeps.
+const dashWindow = 60
+
+// dashWidth bounds every dashboard line so narrow terminals don't wrap.
+const dashWidth = 78
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// dashSecond aggregates the results that completed in one wall-clock second.
+type dashSecond struct {
+	sec    int64 // seconds since start; identifies the ring slot's owner
+	count  int
+	ok     int
+	latSum time.Duration
+}
+
+// dashboard renders a full-terminal view of the run with raw ANSI escapes.
+// It is fed and drawn from the writer goroutine only.
+type dashboard struct {
+	w        io.Writer
+	target   string
+	start    time.Time
+	duration time.Duration
+	ring     [dashWindow]dashSecond
+	stage    string
+	event    string
+	drawn    bool
+}
+
+func newDashboard(w io.Writer, target string, duration time.Duration) *dashboard {
+	return &dashboard{w: w, target: target, start: time.Now(), duration: duration, stage: "steady"}
+}
+
+func (d *dashboard) observe(res Result) {
+	sec := int64(time.Since(d.start) / time.Second)
+	slot := &d.ring[sec%dashWindow]
+	if slot.sec != sec || slot.count == 0 {
+		*slot = dashSecond{sec: sec}
+	}
+	slot.count++
+	if res.Error == "" {
+		slot.ok++
+		slot.latSum += res.Phases.Total
+	}
+	d.stage = res.Stage
+	if d.stage == "" {
+		d.stage = "steady"
+	}
+}
+
+// history returns the last dashWindow complete seconds, oldest first.
+func (d *dashboard) history() []dashSecond {
+	now := int64(time.Since(d.start) / time.Second)
+	out := make([]dashSecond, 0, dashWindow)
+	for sec := now - dashWindow; sec < now; sec++ {
+		if sec < 0 {
+			continue
+		}
+		if s := d.ring[sec%dashWindow]; s.sec == sec {
+			out = append(out, s)
+		} else {
+			out = append(out, dashSecond{sec: sec})
+		}
+	}
+	return out
+}
+
+func (d *dashboard) render(stats *StatsCollector, targetRate int) {
+	sent, success, fail, avg, fails, fam := stats.Snapshot()
+	elapsed := time.Since(d.start)
+	hist := d.history()
+
+	var now float64
+	var lastLat float64
+	if n := len(hist); n > 0 {
+		now = float64(hist[n-1].count)
+		if s := hist[n-1]; s.ok > 0 {
+			lastLat = float64(s.latSum) / float64(time.Millisecond) / float64(s.ok)
+		}
+	}
+	avgRate := 0.0
+	if elapsed > time.Second {
+		avgRate = float64(sent) / elapsed.Seconds()
+	}
+
+	var lines []string
+	add := func(format string, args ...any) { lines = append(lines, fmt.Sprintf(format, args...)) }
+
+	add("shard ▸ %s", d.target)
+	if config.IsUnbounded(d.duration) {
+		add("elapsed %v / until stopped   stage %s", elapsed.Round(time.Second), d.stage)
+	} else {
+		add("elapsed %v / %v   stage %s", elapsed.Round(time.Second), d.duration, d.stage)
+		add("%s", progressBar(elapsed, d.duration, 50))
+	}
+	add("")
+	add("rate     now %.0f/s   avg %.1f/s   target %d/s", now, avgRate, targetRate)
+	add("latency  avg %.1fms   last 1s %.1fms", avg, lastLat)
+	add("         %s  (avg per second, last %ds)", sparkline(hist), dashWindow)
+	add("")
+	if delivered := stats.Delivered(); delivered != sent {
+		add("requests sent=%d delivered=%d ok=%d fail=%d", sent, delivered, success, fail)
+	} else {
+		add("requests sent=%d ok=%d fail=%d", sent, success, fail)
+	}
+	add("status   2xx=%d 3xx=%d 4xx=%d 5xx=%d", fam["2xx"], fam["3xx"], fam["4xx"], fam["5xx"])
+	add("errors   %s", failBreakdown(fails))
+	if n := stats.Workers(); n > 0 {
+		add("workers  %d (auto)", n)
+	}
+	if d.event != "" {
+		add("")
+		add("last event: %s", d.event)
+	}
+
+	var b strings.Builder
+	if !d.drawn {
+		b.WriteString("\x1b[?25l\x1b[2J") // hide cursor, clear screen
+		d.drawn = true
+	}
+	b.WriteString("\x1b[H")
+	for _, l := range lines {
+		b.WriteString(truncate(l, dashWidth))
+		b.WriteString("\x1b[K\n")
+	}
+	b.WriteString("\x1b[J")
+	io.WriteString(d.w, b.String())
+}
+
+// close restores the cursor after the final frame.
+func (d *dashboard) close() {
+	if d.drawn {
+		io.WriteString(d.w, "\x1b[?25h")
+	}
+}
+
+func progressBar(elapsed, total time.Duration, width int) string {
+	frac := 1.0
+	if total > 0 && elapsed < total {
+		frac = float64(elapsed) / float64(total)
+	}
+	filled := int(frac * float64(width))
+	return fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("█", filled), strings.Repeat("░", width-filled), frac*100)
+}
+
+// sparkline scales the per-second average latency onto the spark glyphs;
+// seconds without successes are blank.
+func sparkline(hist []dashSecond) string {
+	var max float64
+	lat := make([]float64, len(hist))
+	for i, s := range hist {
+		if s.ok > 0 {
+			lat[i] = float64(s.latSum) / float64(s.ok)
+			if lat[i] > max {
+				max = lat[i]
+			}
+		}
+	}
+	var b strings.Builder
+	for i, s := range hist {
+		if s.ok == 0 || max == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		idx := int(lat[i] / max * float64(len(sparks)-1))
+		b.WriteRune(sparks[idx])
+	}
+	return b.String()
+}
+
+func failBreakdown(fails map[string]int64) string {
+	if len(fails) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(fails))
+	for k := range fails {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fails[keys[i]] > fails[keys[j]] })
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, fails[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
@@ -0,0 +1,155 @@
+package attack
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/config"
+)
+
+// dialer wraps net.Dialer with the target.resolve and target.unix_socket
+// policies. Pinning only redirects connections to the target host, a unix
+// socket takes every connection; either way the URL's host still drives the
+// Host header and the TLS ServerName, which http.Transport derives from the
+// request, so certificates validate even when dialing a pinned IP.
+type dialer struct {
+	net.Dialer
+	targetHost string   // "host:port" of the target URL
+	pinned     []string // addresses to dial instead of resolving, round-robin
+	next       atomic.Uint64
+	conns      atomic.Uint64 // connections dialed so far
+	unixSocket string        // dial this socket for every connection, see target.unix_socket
+	lastTTL    atomic.Int64  // TTL+1 of the custom resolver's latest answer, 0 before any
+	noDelay    bool          // load.tcp_no_delay, set on each connection after the dial
+}
+
+func newDialer(cfg *config.Config) (*dialer, error) {
+	d := &dialer{Dialer: net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}}
+	if t, _ := time.ParseDuration(cfg.Load.ConnectTimeout); t > 0 {
+		d.Timeout = t
+	}
+	d.noDelay = cfg.Load.NoDelay()
+
+	res := cfg.Target.Resolve
+	if res.Resolver != "" {
+		server := res.Resolver
+		d.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var nd net.Dialer
+				c, err := nd.DialContext(ctx, network, server)
+				if err != nil {
+					return nil, err
+				}
+				return watchTTL(c, d.observeTTL), nil
+			},
+		}
+	}
+
+	u, err := url.Parse(cfg.Target.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse target url: %w", err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" || u.Scheme == "wss" {
+			port = "443"
+		}
+	}
+	d.targetHost = net.JoinHostPort(u.Hostname(), port)
+	d.unixSocket = cfg.Target.UnixSocket
+
+	switch {
+	case res.IPOverride != "":
+		d.pinned = []string{res.IPOverride}
+	case res.Once:
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		ips, err := d.resolver().LookupIPAddr(ctx, u.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("%w: resolve %s: %w", ErrUnreachable, u.Hostname(), err)
+		}
+		for _, ip := range ips {
+			d.pinned = append(d.pinned, net.JoinHostPort(ip.IP.String(), port))
+		}
+	}
+	return d, nil
+}
+
+func (d *dialer) resolver() *net.Resolver {
+	if d.Resolver != nil {
+		return d.Resolver
+	}
+	return net.DefaultResolver
+}
+
+// DialContext dials pinned addresses for the target host, round-robin across
+// all of them so multi-A targets keep spreading load without re-resolving.
+// Every connection is numbered, see connID.
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch {
+	case d.unixSocket != "":
+		network, addr = "unix", d.unixSocket
+	case len(d.pinned) > 0 && addr == d.targetHost:
+		addr = d.pinned[d.next.Add(1)%uint64(len(d.pinned))]
+	}
+	conn, err := d.Dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	// net enables TCP_NODELAY on every TCP connection once it connects, so
+	// turning it off can only happen here, not on the socket beforehand
+	if tc, ok := conn.(*net.TCPConn); ok && !d.noDelay {
+		if err := tc.SetNoDelay(false); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("tcp_no_delay: %w", err)
+		}
+	}
+	return &numberedConn{Conn: conn, id: d.conns.Add(1)}, nil
+}
+
+// numberedConn tags a connection with a run-unique id (starting at 1).
+type numberedConn struct {
+	net.Conn
+	id uint64
+}
+
+// connID returns the id of a connection handed out by the dialer, looking
+// through TLS (twice for a tunnel through an https proxy); 0 if it didn't
+// come from the dialer.
+func connID(c net.Conn) uint64 {
+	for {
+		tc, ok := c.(*tls.Conn)
+		if !ok {
+			break
+		}
+		c = tc.NetConn()
+	}
+	if nc, ok := c.(*numberedConn); ok {
+		return nc.id
+	}
+	return 0
+}
+
+func (d *dialer) observeTTL(ttl uint32) {
+	d.lastTTL.Store(int64(ttl) + 1)
+}
+
+// TTL returns the TTL of the custom resolver's latest answer, the one the
+// most recent lookup got; ok is false without target.resolve.resolver or
+// before the first lookup.
+func (d *dialer) TTL() (ttl uint32, ok bool) {
+	v := d.lastTTL.Load()
+	return uint32(v - 1), v > 0
+}
+
+// Pinned returns the addresses the target host is pinned to, if any.
+func (d *dialer) Pinned() []string {
+	return d.pinned
+}
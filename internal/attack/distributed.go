@@ -0,0 +1,387 @@
+package attack
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"shard/internal/config"
+)
+
+// SplitConfig returns agent i's share of cfg when the load is spread over n
+// agents: rates, concurrency and queue size are divided evenly, remainders
+// going to the first agents, and each agent sizes an "auto" pool itself.
+// The result is ready to send as-is.
+func SplitConfig(cfg config.Config, i, n int) config.Config {
+	share := func(total, min int) int {
+		v := total / n
+		if i < total%n {
+			v++
+		}
+		if v < min {
+			v = min
+		}
+		return v
+	}
+	part := cfg
+	part.Load.Rate = share(cfg.Load.Rate, 1)
+	if !cfg.Load.Concurrency.Auto() {
+		part.Load.Concurrency = config.Workers(share(int(cfg.Load.Concurrency), 1))
+	}
+	part.Load.QueueSize = share(cfg.Load.QueueSize, 1)
+	if cfg.Load.WarmupRate > 0 {
+		part.Load.WarmupRate = share(cfg.Load.WarmupRate, 1)
+	}
+	if cfg.Load.Cooldown.Rate > 0 {
+		part.Load.Cooldown.Rate = share(cfg.Load.Cooldown.Rate, 1)
+	}
+	if cfg.Load.TotalRequests > 0 {
+		part.Load.TotalRequests = share(cfg.Load.TotalRequests, 1)
+	}
+	// the controller already extended the duration and owns the output
+	part.Load.AutoExtend = false
+	part.Output.MaxFileSize = ""
+	part.Output.MaxFileSizeMB = 0
+	return part
+}
+
+// agentRun is what the controller posts to an agent's /run: its share of
+// the config, with every file the run reads (see config.RunFiles) carried
+// in Files instead of as a path on the agent's host. The config names each
+// file by its setting, which is also its key in Files.
+type agentRun struct {
+	Config config.Config     `json:"config"`
+	Files  map[string][]byte `json:"files,omitempty"`
+}
+
+// inlineFiles reads the files cfg refers to into an agentRun. cfg itself is
+// left alone: the run gets a deep copy.
+func inlineFiles(cfg *config.Config) (agentRun, error) {
+	var run agentRun
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return run, err
+	}
+	if err := json.Unmarshal(data, &run.Config); err != nil {
+		return run, err
+	}
+	err = run.Config.RunFiles(func(field, path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %w", config.ErrInvalid, field, err)
+		}
+		if run.Files == nil {
+			run.Files = make(map[string][]byte)
+		}
+		run.Files[field] = data
+		return field, nil
+	})
+	return run, err
+}
+
+// agentRecord is one record streamed back by an agent: a decoded result, or
+// a typed record passed through as raw JSONL. The controller's own alerts
+// about an agent travel the same way, so only the merge loop writes output.
+type agentRecord struct {
+	res   *Result
+	raw   []byte
+	alert string // shown on the terminal and in the progress log
+}
+
+// agentEnd is the last record an agent streams when its run stopped with an
+// error, so the controller can tell an abort from a broken stream.
+type agentEnd struct {
+	Type      string          `json:"type"` // "agent_end"
+	Timestamp time.Time       `json:"ts"`
+	Class     string          `json:"class"` // "threshold", "size_limit" or "error"
+	Message   string          `json:"message"`
+	Threshold *ThresholdError `json:"threshold,omitempty"`
+}
+
+func newAgentEnd(err error) agentEnd {
+	end := agentEnd{Type: "agent_end", Timestamp: time.Now(), Class: "error", Message: err.Error()}
+	var threshold *ThresholdError
+	switch {
+	case errors.As(err, &threshold):
+		end.Class, end.Threshold = "threshold", threshold
+	case errors.Is(err, ErrOutputSizeLimit):
+		end.Class = "size_limit"
+	}
+	return end
+}
+
+// err rebuilds the error the agent's run ended with.
+func (e agentEnd) err() error {
+	switch {
+	case e.Class == "threshold" && e.Threshold != nil:
+		return e.Threshold
+	case e.Class == "size_limit":
+		return ErrOutputSizeLimit
+	}
+	return fmt.Errorf("run ended: %s", e.Message)
+}
+
+// RunAgents drives a distributed run. Each agent gets an even share of the
+// load and streams its records back; they are merged into outPath with the
+// agent recorded on every result, and live progress covers all agents.
+// Files the config reads go to the agents inline, and token authenticates
+// the controller to them. An agent failing mid-run is reported and annotated
+// while the others carry on; RunAgents fails only when every agent does. An
+// agent stopped by abort thresholds or the output size limit stops the whole
+// run, and its ThresholdError or ErrOutputSizeLimit is returned. outPath and
+// progressPath are resolved, see config.Output.Paths. The merged results start with meta, completed
+// like Runner.SetMeta's, ahead of the agents' own meta records.
+func RunAgents(ctx context.Context, cfg *config.Config, agents []string, token, outPath, progressPath string, term io.Writer, meta MetaRecord) error {
+	if cfg.Load.Rate < len(agents) {
+		return fmt.Errorf("%w: load.rate %d is lower than the number of agents (%d)", config.ErrInvalid, cfg.Load.Rate, len(agents))
+	}
+	if cfg.Target.UnixSocket != "" {
+		return fmt.Errorf("%w: target.unix_socket is not available with agents", config.ErrInvalid)
+	}
+	inline, err := inlineFiles(cfg)
+	if err != nil {
+		return err
+	}
+	duration := config.PlanDuration(cfg.Plan())
+	maxSize, _ := config.ParseSize(cfg.Output.MaxFileSize)
+
+	progress, err := OpenProgress(progressPath, cfg.Output.ProgressAppend)
+	if err != nil {
+		return fmt.Errorf("open progress log: %w", err)
+	}
+	defer progress.Close()
+
+	sink, err := OpenSink(outPath, cfg.Output, cfg.Output.SegmentSize())
+	if err != nil {
+		return fmt.Errorf("open output: %w", err)
+	}
+	guard := newSizeGuard(sink, maxSize, cfg.Output.SizePolicy)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	records := make(chan agentRecord, 256)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+	var aborted error
+	for i, addr := range agents {
+		part := agentRun{Config: SplitConfig(inline.Config, i, len(agents)), Files: inline.Files}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := streamAgent(ctx, addr, token, &part, records)
+			var threshold *ThresholdError
+			if errors.As(err, &threshold) || errors.Is(err, ErrOutputSizeLimit) {
+				// the agent's own run already annotated the reason
+				records <- agentRecord{alert: fmt.Sprintf("🛑 agent %s stopped the run: %v", addr, err)}
+				mu.Lock()
+				if aborted == nil {
+					aborted = fmt.Errorf("agent %s: %w", addr, err)
+				}
+				mu.Unlock()
+				cancel()
+				return
+			}
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+			msg := fmt.Sprintf("agent %s failed after %d results: %v", addr, n, err)
+			records <- agentRecord{alert: "⚠️  " + msg, raw: annotationLine(msg)}
+			mu.Lock()
+			failed = append(failed, addr)
+			mu.Unlock()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(records)
+	}()
+
+	enc := json.NewEncoder(guard)
+	stats := &StatsCollector{}
+	start := time.Now()
+	meta.complete(cfg)
+	meta.Timestamp = start
+	_ = enc.Encode(meta)
+	ticker := time.NewTicker(progressInterval(cfg))
+	defer ticker.Stop()
+	alert := func(msg string) {
+		if term != nil {
+			fmt.Fprintf(term, "\n%s\n", msg)
+		}
+		fmt.Fprintf(progress, "[%v] %s\n", time.Since(start).Round(time.Second), msg)
+	}
+	for done := false; !done; {
+		select {
+		case rec, ok := <-records:
+			if !ok {
+				done = true
+				break
+			}
+			if rec.alert != "" {
+				alert(rec.alert)
+			}
+			if rec.res == nil {
+				if rec.raw != nil {
+					guard.Write(rec.raw)
+				}
+				continue
+			}
+			stats.Add(*rec.res)
+			if guard.keep(*rec.res) {
+				_ = enc.Encode(rec.res)
+			}
+		case <-ticker.C:
+			_ = enc.Encode(printStats(stats, start, term, progress))
+			elapsed := time.Since(start)
+			if msg := guard.check(elapsed, remaining(duration, elapsed), cfg.Load.Rate); msg != "" {
+				alert("⚠️  " + msg)
+				guard.Write(annotationLine(msg))
+				if guard.aborted {
+					cancel()
+				}
+			}
+		}
+	}
+	_ = enc.Encode(printStats(stats, start, term, progress))
+	if msg := guard.summary(); msg != "" {
+		guard.Write(annotationLine(msg))
+	}
+	fmt.Fprintln(progress, "---- Test completed ----")
+
+	if err := guard.Close(); err != nil {
+		return fmt.Errorf("close output: %w", err)
+	}
+	if guard.aborted {
+		return ErrOutputSizeLimit
+	}
+	if aborted != nil {
+		return aborted
+	}
+	if len(failed) == len(agents) {
+		return fmt.Errorf("%w: all agents failed: %s", ErrUnreachable, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// agentClient posts runs to agents. It has no overall timeout, as the
+// response streams for the whole run; streamAgent watches for a stalled
+// stream instead.
+var agentClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second, // the agent prepares the run first
+	},
+}
+
+// agentIdleTimeout is how long an agent's stream may go without a record
+// before it counts as failed: agents write progress records every
+// output.progress_interval, so a live one is never quiet for long.
+const agentIdleTimeout = 30 * time.Second
+
+// streamAgent posts run to the agent at addr and forwards its records until
+// the stream ends. It returns how many results were received, and the
+// agent's error when it sent an agentEnd record.
+func streamAgent(ctx context.Context, addr, token string, run *agentRun, out chan<- agentRecord) (int, error) {
+	body, err := json.Marshal(run)
+	if err != nil {
+		return 0, err
+	}
+	idle := max(agentIdleTimeout, 3*progressInterval(&run.Config))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stalled := time.AfterFunc(idle, cancel)
+	defer stalled.Stop()
+	url := addr
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(url, "/")+"/run", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := agentClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	n := 0
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		stalled.Reset(idle)
+		line := sc.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if bytes.HasPrefix(line, []byte(`{"type":"agent_end"`)) {
+			var end agentEnd
+			if err := json.Unmarshal(line, &end); err != nil {
+				return n, fmt.Errorf("decode end record: %w", err)
+			}
+			return n, end.err()
+		}
+		if bytes.HasPrefix(line, []byte(`{"type":"progress"`)) {
+			continue // the controller writes progress across all agents
+		}
+		if bytes.HasPrefix(line, []byte(`{"type":`)) {
+			out <- agentRecord{raw: agentTyped(addr, line)}
+			continue
+		}
+		var res Result
+		if err := json.Unmarshal(line, &res); err != nil {
+			return n, fmt.Errorf("decode result: %w", err)
+		}
+		res.Agent = addr
+		out <- agentRecord{res: &res}
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		if !stalled.Stop() && ctx.Err() != nil {
+			return n, fmt.Errorf("no records for %v", idle)
+		}
+		return n, err
+	}
+	if ctx.Err() == nil && n == 0 {
+		return 0, errors.New("stream ended without results")
+	}
+	return n, nil
+}
+
+// agentTyped prefixes an agent's annotation with its address so merged notes
+// stay attributable; other typed records pass through unchanged.
+func agentTyped(addr string, line []byte) []byte {
+	var ann Annotation
+	if json.Unmarshal(line, &ann) == nil && ann.Type == "annotation" {
+		ann.Message = "agent " + addr + ": " + ann.Message
+		if b, err := json.Marshal(ann); err == nil {
+			return append(b, '\n')
+		}
+	}
+	return append(append([]byte(nil), line...), '\n')
+}
+
+func annotationLine(msg string) []byte {
+	b, _ := json.Marshal(Annotation{Type: "annotation", Timestamp: time.Now(), Message: msg})
+	return append(b, '\n')
+}
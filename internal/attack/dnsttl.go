@@ -0,0 +1,100 @@
+package attack
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// watchTTL wraps a connection to the custom resolver so that the TTL of
+// every DNS answer read through it is reported to observe. The Go resolver
+// tells UDP from TCP by whether the connection is a net.PacketConn, so UDP
+// connections keep that type.
+func watchTTL(c net.Conn, observe func(ttl uint32)) net.Conn {
+	if uc, ok := c.(*net.UDPConn); ok {
+		return &ttlPacketConn{UDPConn: uc, observe: observe}
+	}
+	return &ttlStreamConn{Conn: c, observe: observe}
+}
+
+// ttlPacketConn reads one whole DNS message per Read.
+type ttlPacketConn struct {
+	*net.UDPConn
+	observe func(ttl uint32)
+}
+
+func (c *ttlPacketConn) Read(p []byte) (int, error) {
+	n, err := c.UDPConn.Read(p)
+	if ttl, ok := answerTTL(p[:n]); ok {
+		c.observe(ttl)
+	}
+	return n, err
+}
+
+// ttlStreamConn reassembles DNS messages over TCP, each carrying a two-byte
+// length prefix and possibly arriving in pieces.
+type ttlStreamConn struct {
+	net.Conn
+	buf     []byte
+	observe func(ttl uint32)
+}
+
+func (c *ttlStreamConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.buf = append(c.buf, p[:n]...)
+	for len(c.buf) >= 2 {
+		size := int(binary.BigEndian.Uint16(c.buf)) + 2
+		if len(c.buf) < size {
+			break
+		}
+		if ttl, ok := answerTTL(c.buf[2:size]); ok {
+			c.observe(ttl)
+		}
+		c.buf = c.buf[size:]
+	}
+	return n, err
+}
+
+// answerTTL returns the lowest TTL among the answer records of a DNS
+// response (the address records and any CNAMEs leading to them), or
+// ok=false when msg is not a response with answers.
+func answerTTL(msg []byte) (ttl uint32, ok bool) {
+	if len(msg) < 12 || msg[2]&0x80 == 0 {
+		return 0, false
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:]))
+	off := 12
+	for i := 0; i < questions; i++ {
+		if off = skipName(msg, off); off < 0 || off+4 > len(msg) {
+			return 0, false
+		}
+		off += 4 // type, class
+	}
+	for i := 0; i < answers; i++ {
+		if off = skipName(msg, off); off < 0 || off+10 > len(msg) {
+			return ttl, ok
+		}
+		t := binary.BigEndian.Uint32(msg[off+4:])
+		if !ok || t < ttl {
+			ttl, ok = t, true
+		}
+		off += 10 + int(binary.BigEndian.Uint16(msg[off+8:]))
+	}
+	return ttl, ok
+}
+
+// skipName returns the offset just past the (possibly compressed) domain
+// name at off, or -1 if it runs off the message.
+func skipName(msg []byte, off int) int {
+	for off < len(msg) {
+		switch l := int(msg[off]); {
+		case l == 0:
+			return off + 1
+		case l&0xc0 == 0xc0:
+			return off + 2
+		default:
+			off += 1 + l
+		}
+	}
+	return -1
+}
@@ -0,0 +1,89 @@
+package attack
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdDecoders recycles response decoders; each one carries sizeable window
+// buffers that would otherwise be allocated per request.
+var zstdDecoders = sync.Pool{
+	New: func() any {
+		d, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		return d
+	},
+}
+
+// acceptEncoding returns the Accept-Encoding to offer for load.compression,
+// or "" to leave the request alone: the user set the header, compression is
+// disabled, or (in auto mode) Go's transport would not have offered gzip
+// either. The forced modes send their encoding on every request.
+func acceptEncoding(mode string, req *http.Request) string {
+	switch {
+	case req.Header.Get("Accept-Encoding") != "" || mode == "disable":
+		return ""
+	case mode == "zstd":
+		return "zstd, gzip"
+	case mode == "gzip" || mode == "br" || mode == "identity":
+		return mode
+	case req.Header.Get("Range") != "" || req.Method == http.MethodHead:
+		return ""
+	}
+	return "gzip"
+}
+
+// decodeBody wraps body according to the response's Content-Encoding when
+// Shard negotiated the encoding itself, so the transport left decoding to
+// us. The encoding headers are dropped as the transport does for gzip.
+// release must be called once the body has been read, also on error.
+func decodeBody(h http.Header, body io.Reader) (io.Reader, func(), error) {
+	switch h.Get("Content-Encoding") {
+	case "zstd":
+		d := zstdDecoders.Get().(*zstd.Decoder)
+		release := func() {
+			d.Reset(nil)
+			zstdDecoders.Put(d)
+		}
+		dropEncoding(h)
+		return d, release, d.Reset(body)
+	case "gzip":
+		dropEncoding(h)
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return body, func() {}, err
+		}
+		return zr, func() { zr.Close() }, nil
+	case "br":
+		dropEncoding(h)
+		return brotli.NewReader(body), func() {}, nil
+	}
+	return body, func() {}, nil
+}
+
+func dropEncoding(h http.Header) {
+	h.Del("Content-Encoding")
+	h.Del("Content-Length")
+}
+
+// timedReader counts the bytes read through it and the time spent reading.
+// Wrapped around both the wire and the decoder, the difference is the time
+// spent decoding.
+type timedReader struct {
+	r     io.Reader
+	n     int64
+	spent time.Duration
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(p)
+	t.spent += time.Since(start)
+	t.n += int64(n)
+	return n, err
+}
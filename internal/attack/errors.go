@@ -0,0 +1,14 @@
+package attack
+
+import "errors"
+
+// The sentinels a run returns for the CLI's exit codes. An abort threshold
+// stops a run with a *ThresholdError instead, see abort.go.
+var (
+	// ErrOutputSizeLimit is returned by Run when the abort size policy stopped the attack.
+	ErrOutputSizeLimit = errors.New("results file size limit reached")
+
+	// ErrUnreachable is returned when the run could not start because the target
+	// (or, in a distributed run, every agent) could not be reached.
+	ErrUnreachable = errors.New("unreachable")
+)
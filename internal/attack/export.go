@@ -0,0 +1,365 @@
+package attack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/config"
+)
+
+// The exporter never slows the attack down: results wait in a queue of
+// exportQueue and are dropped when it is full, statsd lines are batched into
+// datagrams of at most statsdPacket bytes, and OTLP pushes a delta every
+// exportEvery with at most one push in flight.
+const (
+	exportQueue   = 4096
+	exportEvery   = 5 * time.Second
+	statsdEvery   = time.Second
+	statsdPacket  = 1432
+	otlpTimeout   = 5 * time.Second
+	statsdPrefix  = "shard."
+	otlpScopeName = "shard"
+)
+
+// otlpBounds are the explicit histogram bounds for request latency, in ms.
+var otlpBounds = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+type exportSample struct {
+	lat   time.Duration
+	class string // status family ("2xx") or, for failures, "error"
+	phase string // fail phase for failures
+}
+
+// exporter streams request metrics to statsd and/or an OTLP/HTTP collector.
+// observe is called from the results writer; everything else runs on the
+// exporter's own goroutines.
+type exporter struct {
+	ch      chan exportSample
+	dropped atomic.Int64
+	done    chan struct{}
+
+	statsd net.Conn
+	packet []byte
+
+	otlpURL string
+	client  *http.Client
+	pushes  chan []byte
+	pushed  chan struct{}
+	win     otlpWindow
+}
+
+// newExporter returns nil when the config exports nothing.
+func newExporter(out config.Output) (*exporter, error) {
+	if out.OTLPEndpoint == "" && out.StatsdAddr == "" {
+		return nil, nil
+	}
+	e := &exporter{ch: make(chan exportSample, exportQueue), done: make(chan struct{})}
+	if out.StatsdAddr != "" {
+		conn, err := net.Dial("udp", out.StatsdAddr)
+		if err != nil {
+			return nil, fmt.Errorf("statsd: %w", err)
+		}
+		e.statsd = conn
+	}
+	if out.OTLPEndpoint != "" {
+		e.otlpURL = strings.TrimSuffix(out.OTLPEndpoint, "/") + "/v1/metrics"
+		e.client = &http.Client{Timeout: otlpTimeout}
+		e.pushes = make(chan []byte, 1)
+		e.pushed = make(chan struct{})
+		e.win.reset(time.Now())
+		go e.pushLoop()
+	}
+	go e.loop()
+	return e, nil
+}
+
+// observe queues res for export, dropping it when the queue is full.
+func (e *exporter) observe(res Result) {
+	s := exportSample{lat: res.Phases.Total, class: statusClass(res.Code)}
+	if res.Error != "" {
+		s.class, s.phase = "error", res.FailPhase
+		if s.phase == "" {
+			s.phase = "other"
+		}
+	}
+	select {
+	case e.ch <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// close flushes what is queued and waits for the last OTLP push, which is
+// bounded by otlpTimeout. It returns how many results were dropped.
+func (e *exporter) close() int64 {
+	close(e.ch)
+	<-e.done
+	if e.pushes != nil {
+		close(e.pushes)
+		<-e.pushed
+	}
+	if e.statsd != nil {
+		e.statsd.Close()
+	}
+	return e.dropped.Load()
+}
+
+func (e *exporter) loop() {
+	defer close(e.done)
+	statsdTick := time.NewTicker(statsdEvery)
+	defer statsdTick.Stop()
+	otlpTick := time.NewTicker(exportEvery)
+	defer otlpTick.Stop()
+	for {
+		select {
+		case s, ok := <-e.ch:
+			if !ok {
+				e.flushStatsd()
+				e.flushOTLP(true)
+				return
+			}
+			if e.statsd != nil {
+				e.statsdSample(s)
+			}
+			if e.pushes != nil {
+				e.win.add(s)
+			}
+		case <-statsdTick.C:
+			e.flushStatsd()
+		case <-otlpTick.C:
+			e.flushOTLP(false)
+		}
+	}
+}
+
+func (e *exporter) statsdSample(s exportSample) {
+	if s.class == "error" {
+		e.statsdLine(statsdPrefix + "errors." + s.phase + ":1|c")
+		return
+	}
+	e.statsdLine(statsdPrefix + "requests." + s.class + ":1|c")
+	e.statsdLine(statsdPrefix + "latency:" + strconv.FormatFloat(float64(s.lat)/float64(time.Millisecond), 'f', 3, 64) + "|ms")
+}
+
+func (e *exporter) statsdLine(line string) {
+	if len(e.packet) > 0 && len(e.packet)+1+len(line) > statsdPacket {
+		e.flushStatsd()
+	}
+	if len(e.packet) > 0 {
+		e.packet = append(e.packet, '\n')
+	}
+	e.packet = append(e.packet, line...)
+}
+
+func (e *exporter) flushStatsd() {
+	if e.statsd == nil || len(e.packet) == 0 {
+		return
+	}
+	// fire and forget: an absent or slow agent loses datagrams, nothing more
+	e.statsd.Write(e.packet)
+	e.packet = e.packet[:0]
+}
+
+// flushOTLP hands the current window to the pusher. Unless final, a window
+// that finds the previous push still in flight stays open and is sent with
+// the next one.
+func (e *exporter) flushOTLP(final bool) {
+	if e.pushes == nil || e.win.empty() {
+		return
+	}
+	body, err := json.Marshal(e.win.export(time.Now()))
+	if err != nil {
+		return
+	}
+	if final {
+		e.pushes <- body
+		return
+	}
+	select {
+	case e.pushes <- body:
+		e.win.reset(time.Now())
+	default:
+	}
+}
+
+func (e *exporter) pushLoop() {
+	defer close(e.pushed)
+	for body := range e.pushes {
+		resp, err := e.client.Post(e.otlpURL, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+// otlpWindow accumulates one delta interval of OTLP metrics.
+type otlpWindow struct {
+	start    time.Time
+	counts   map[[2]string]int64 // (attribute key, value) -> requests
+	buckets  []uint64
+	n        uint64
+	sum      float64
+	min, max float64
+}
+
+func (w *otlpWindow) reset(now time.Time) {
+	*w = otlpWindow{start: now, counts: make(map[[2]string]int64), buckets: make([]uint64, len(otlpBounds)+1)}
+}
+
+func (w *otlpWindow) empty() bool { return len(w.counts) == 0 }
+
+func (w *otlpWindow) add(s exportSample) {
+	if s.class == "error" {
+		w.counts[[2]string{"error", s.phase}]++
+		return
+	}
+	w.counts[[2]string{"status_class", s.class}]++
+	ms := float64(s.lat) / float64(time.Millisecond)
+	i := 0
+	for i < len(otlpBounds) && ms > otlpBounds[i] {
+		i++
+	}
+	w.buckets[i]++
+	if w.n == 0 || ms < w.min {
+		w.min = ms
+	}
+	if ms > w.max {
+		w.max = ms
+	}
+	w.n++
+	w.sum += ms
+}
+
+// OTLP/HTTP JSON encoding of ExportMetricsServiceRequest, limited to what
+// the exporter sends. 64-bit integers are strings, as the JSON mapping wants.
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpKeyValue struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	}
+	otlpMetric struct {
+		Name      string         `json:"name"`
+		Unit      string         `json:"unit"`
+		Sum       *otlpSum       `json:"sum,omitempty"`
+		Histogram *otlpHistogram `json:"histogram,omitempty"`
+	}
+	otlpSum struct {
+		Temporality int             `json:"aggregationTemporality"`
+		Monotonic   bool            `json:"isMonotonic"`
+		DataPoints  []otlpNumberDPt `json:"dataPoints"`
+	}
+	otlpNumberDPt struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+		Start      string         `json:"startTimeUnixNano"`
+		Time       string         `json:"timeUnixNano"`
+		AsInt      string         `json:"asInt"`
+	}
+	otlpHistogram struct {
+		Temporality int           `json:"aggregationTemporality"`
+		DataPoints  []otlpHistDPt `json:"dataPoints"`
+	}
+	otlpHistDPt struct {
+		Start          string    `json:"startTimeUnixNano"`
+		Time           string    `json:"timeUnixNano"`
+		Count          string    `json:"count"`
+		Sum            float64   `json:"sum"`
+		Min            float64   `json:"min"`
+		Max            float64   `json:"max"`
+		BucketCounts   []string  `json:"bucketCounts"`
+		ExplicitBounds []float64 `json:"explicitBounds"`
+	}
+)
+
+// otlpDelta is AGGREGATION_TEMPORALITY_DELTA.
+const otlpDelta = 1
+
+func otlpAttr(key, value string) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	kv.Value.StringValue = value
+	return kv
+}
+
+func (w *otlpWindow) export(now time.Time) otlpRequest {
+	start := strconv.FormatInt(w.start.UnixNano(), 10)
+	end := strconv.FormatInt(now.UnixNano(), 10)
+	counter := &otlpSum{Temporality: otlpDelta, Monotonic: true}
+	for attr, n := range w.counts {
+		counter.DataPoints = append(counter.DataPoints, otlpNumberDPt{
+			Attributes: []otlpKeyValue{otlpAttr(attr[0], attr[1])},
+			Start:      start, Time: end, AsInt: strconv.FormatInt(n, 10),
+		})
+	}
+	metrics := []otlpMetric{{Name: "shard.requests", Unit: "1", Sum: counter}}
+	if w.n > 0 {
+		counts := make([]string, len(w.buckets))
+		for i, c := range w.buckets {
+			counts[i] = strconv.FormatUint(c, 10)
+		}
+		metrics = append(metrics, otlpMetric{Name: "shard.request.duration", Unit: "ms", Histogram: &otlpHistogram{
+			Temporality: otlpDelta,
+			DataPoints: []otlpHistDPt{{
+				Start: start, Time: end, Count: strconv.FormatUint(w.n, 10),
+				Sum: w.sum, Min: w.min, Max: w.max,
+				BucketCounts: counts, ExplicitBounds: otlpBounds,
+			}},
+		}})
+	}
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: []otlpKeyValue{otlpAttr("service.name", "shard")}},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: otlpScopeName}, Metrics: metrics}},
+	}}}
+}
+
+// setTraceparent starts a fresh sampled trace on h and returns its trace ID.
+func setTraceparent(h http.Header) string {
+	var id [16]byte
+	var span [8]byte
+	for id == [16]byte{} { // all-zero IDs are invalid
+		binary.LittleEndian.PutUint64(id[:8], rand.Uint64())
+		binary.LittleEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	for span == [8]byte{} {
+		binary.LittleEndian.PutUint64(span[:], rand.Uint64())
+	}
+	traceID := hex.EncodeToString(id[:])
+	h.Set("traceparent", "00-"+traceID+"-"+hex.EncodeToString(span[:])+"-01")
+	return traceID
+}
+
+// statusClass returns the status family of code ("2xx"), or "none" without
+// a response.
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "none"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
@@ -0,0 +1,70 @@
+package attack
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// latencyHistogram is a fixed-size, lock-free log-linear histogram in
+// milliseconds: 1ms buckets below 1s, 10ms buckets below 10s, 100ms buckets
+// below 60s, and one overflow bucket. Quantiles are accurate to the bucket width.
+type latencyHistogram struct {
+	buckets [histBuckets]atomic.Int64
+	count   atomic.Int64
+}
+
+const histBuckets = 1000 + 900 + 500 + 1
+
+func histIndex(ms int64) int {
+	switch {
+	case ms < 0:
+		return 0
+	case ms < 1000:
+		return int(ms)
+	case ms < 10000:
+		return 1000 + int((ms-1000)/10)
+	case ms < 60000:
+		return 1900 + int((ms-10000)/100)
+	default:
+		return histBuckets - 1
+	}
+}
+
+// histUpper returns the upper bound in ms of bucket i.
+func histUpper(i int) float64 {
+	switch {
+	case i < 1000:
+		return float64(i + 1)
+	case i < 1900:
+		return float64(1000 + (i-1000+1)*10)
+	case i < 2400:
+		return float64(10000 + (i-1900+1)*100)
+	default:
+		return 60000
+	}
+}
+
+func (h *latencyHistogram) Add(d time.Duration) {
+	h.buckets[histIndex(d.Milliseconds())].Add(1)
+	h.count.Add(1)
+}
+
+// Quantile returns the upper bound of the bucket holding quantile q (0..1).
+func (h *latencyHistogram) Quantile(q float64) float64 {
+	n := h.count.Load()
+	if n == 0 {
+		return 0
+	}
+	rank := int64(q*float64(n) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i := range h.buckets {
+		seen += h.buckets[i].Load()
+		if seen >= rank {
+			return histUpper(i)
+		}
+	}
+	return histUpper(histBuckets - 1)
+}
@@ -0,0 +1,32 @@
+package attack
+
+import (
+	"sync"
+	"time"
+)
+
+// idleTracker remembers when each host last had traffic so that a fresh
+// connection after a quiet period longer than the transport's idle timeout can
+// be told apart from a genuine reconnect caused by the server.
+type idleTracker struct {
+	timeout time.Duration
+	mu      sync.Mutex
+	last    map[string]time.Time
+}
+
+func newIdleTracker(timeout time.Duration) *idleTracker {
+	return &idleTracker{timeout: timeout, last: make(map[string]time.Time)}
+}
+
+// observe records a request to host that ran from start to end and reports
+// whether it followed an idle gap: the pool had been quiet for longer than
+// the idle timeout, so its connections were expected to have been closed.
+func (t *idleTracker) observe(host string, start, end time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prev, seen := t.last[host]
+	if end.After(prev) {
+		t.last[host] = end
+	}
+	return seen && start.Sub(prev) > t.timeout
+}
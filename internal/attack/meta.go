@@ -0,0 +1,88 @@
+package attack
+
+import (
+	"os"
+	"runtime/debug"
+	"time"
+
+	"shard/internal/config"
+)
+
+// Version is the Shard version recorded in results files. Release builds
+// set it with -ldflags "-X shard/internal/attack.Version=v1.2.3"; otherwise
+// it comes from the build info, see version.
+var Version string
+
+// version returns Version, or the module version or VCS revision the binary
+// was built from, or "dev".
+func version() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+			return "dev-" + s.Value[:12]
+		}
+	}
+	return "dev"
+}
+
+// MetaRecord describes the run a results file came from. It is the file's
+// first record.
+type MetaRecord struct {
+	Type      string    `json:"type"` // always "meta"
+	Timestamp time.Time `json:"ts"`
+	Version   string    `json:"version,omitempty"` // of Shard
+	// Schema is the SchemaVersion of the file's records, which `shard
+	// schema` describes.
+	Schema   int    `json:"schema,omitempty"`
+	Hostname string `json:"hostname,omitempty"` // of the machine sending the load
+	// Labels are the user's own tags for the run, from attack -label.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Config is the run's validated config with credentials redacted, see
+	// config.Config.Redacted.
+	Config *config.Config `json:"config,omitempty"`
+	// Baseline holds the load.baseline calibration, when one succeeded.
+	Baseline *Baseline `json:"baseline,omitempty"`
+	Suite    string    `json:"suite,omitempty"` // suite file, for runs of `shard suite`
+	Run      string    `json:"run,omitempty"`   // the run's name in the suite
+	// Derived lists settings resolved from earlier runs' summaries.
+	Derived []Derivation `json:"derived,omitempty"`
+	// TCPNoDelay is load.tcp_no_delay as the connections were dialed; files
+	// from before it was recorded leave it out.
+	TCPNoDelay *bool `json:"tcp_no_delay,omitempty"`
+}
+
+// Derivation is one setting resolved from an earlier run's summary.
+type Derivation struct {
+	Field  string  `json:"field"`  // e.g. "load.rate"
+	Expr   string  `json:"expr"`   // as written, e.g. "80% of runs.capacity.throughput"
+	Source float64 `json:"source"` // the referenced metric's value
+	Value  float64 `json:"value"`  // what the setting was set to
+}
+
+// complete fills in what every meta record says about cfg's run and the
+// machine running it.
+func (m *MetaRecord) complete(cfg *config.Config) {
+	m.Type = "meta"
+	m.Version = version()
+	m.Schema = SchemaVersion
+	m.Hostname, _ = os.Hostname()
+	snapshot := cfg.Redacted()
+	m.Config = &snapshot
+	noDelay := cfg.Load.NoDelay()
+	m.TCPNoDelay = &noDelay
+}
+
+// SetMeta has the next RunSink start its results with m.
+func (r *Runner) SetMeta(m MetaRecord) {
+	m.Type = "meta"
+	r.meta = &m
+}
@@ -0,0 +1,40 @@
+package attack
+
+import (
+	"context"
+	"fmt"
+)
+
+// Probe sends the configured request (or WebSocket message) once, or runs
+// the scenario chain once, through the same transport, TLS settings and
+// headers as a real run. It returns a Result per request sent and the
+// protocol the last response was received over. Errors are about building
+// the request or fetching its token, not sending it.
+func (r *Runner) Probe() ([]Result, string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := r.auth.start(ctx, func(string) {}); err != nil {
+		return nil, "", fmt.Errorf("auth: %w", err)
+	}
+	w := r.newWorkers(1)[0]
+	if r.cfg.Target.UsesWebSocket() {
+		ws, err := r.loadWebSocket()
+		if err != nil {
+			return nil, "", fmt.Errorf("load messages: %w", err)
+		}
+		defer w.close()
+		return []Result{r.sendWebSocket(w, ws)}, w.proto, nil
+	}
+	if r.cfg.Scenario != nil {
+		steps, err := r.loadScenario()
+		if err != nil {
+			return nil, "", fmt.Errorf("load scenario: %w", err)
+		}
+		return r.runScenario(ctx, w, steps, 1), w.proto, nil
+	}
+	req, err := r.makeRequest("")
+	if err != nil {
+		return nil, "", fmt.Errorf("make request: %w", err)
+	}
+	return []Result{r.doRequest(w, req, nil)}, w.proto, nil
+}
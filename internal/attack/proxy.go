@@ -0,0 +1,93 @@
+package attack
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/config"
+)
+
+// proxyTunnelKey carries a request's *proxyTunnel into the transport's
+// dial, where onProxyConnect fills it in.
+type proxyTunnelKey struct{}
+
+// proxyTunnel follows a request's hop through the proxy: whether it used
+// one, and the time the tunnel took to open. dialed is set by the trace's
+// ConnectDone on the dial goroutine, which then runs onProxyConnect;
+// doRequest reads the rest once the request is over, possibly while that
+// goroutine is still dialing.
+type proxyTunnel struct {
+	dialed time.Time    // connection to the proxy established
+	took   atomic.Int64 // CONNECT (or SOCKS handshake) in ns, 0 before it completed
+	status atomic.Int32 // CONNECT response status
+	used   atomic.Bool  // the proxy function routed the request through a proxy
+	socks  atomic.Bool  // ... a SOCKS one
+	open   atomic.Bool  // the hop through the proxy is done
+}
+
+// failed reports whether the request used a proxy and never got past it.
+func (t *proxyTunnel) failed() bool {
+	return t.used.Load() && !t.open.Load()
+}
+
+// opening reports whether a TLS handshake starting now is the one with an
+// https proxy rather than the origin; it counts towards proxy_connect.
+func (t *proxyTunnel) opening() bool {
+	return t.used.Load() && !t.open.Load() && !t.socks.Load()
+}
+
+// reached marks the origin reached: GotConn, or the origin's TLS handshake
+// starting. SOCKS has no hook of its own, so this ends its handshake.
+func (t *proxyTunnel) reached() {
+	if t.socks.Load() && !t.open.Load() && !t.dialed.IsZero() {
+		t.took.Store(int64(time.Since(t.dialed)))
+	}
+	t.open.Store(true)
+}
+
+// onProxyConnect is the transport's OnProxyConnectResponse hook: it records
+// the CONNECT round trip and status for the request that dialed. A 407 is
+// then reported as the proxy_auth error, not as whatever the failed dial
+// looks like.
+func onProxyConnect(ctx context.Context, _ *url.URL, _ *http.Request, resp *http.Response) error {
+	if t, ok := ctx.Value(proxyTunnelKey{}).(*proxyTunnel); ok && !t.dialed.IsZero() {
+		t.took.Store(int64(time.Since(t.dialed)))
+		t.status.Store(int32(resp.StatusCode))
+		t.open.Store(resp.StatusCode == http.StatusOK)
+	}
+	return nil
+}
+
+// newProxyFunc returns the transport's Proxy for the proxy section, nil
+// without one. Configured credentials fill in proxies whose URL has none;
+// the transport sends them as Proxy-Authorization or SOCKS5 login.
+func newProxyFunc(p config.Proxy) func(*http.Request) (*url.URL, error) {
+	if !p.Enabled() {
+		return nil
+	}
+	creds := p.Credentials()
+	find := http.ProxyFromEnvironment
+	if !p.FromEnv {
+		fixed, _ := url.Parse(p.URL) // checked by Validate
+		find = http.ProxyURL(fixed)
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := find(req)
+		if u == nil || err != nil {
+			return u, err
+		}
+		if u.User == nil && creds != nil {
+			with := *u
+			with.User = creds
+			u = &with
+		}
+		if t, ok := req.Context().Value(proxyTunnelKey{}).(*proxyTunnel); ok {
+			t.used.Store(true)
+			t.socks.Store(u.Scheme == "socks5" || u.Scheme == "socks5h")
+		}
+		return u, nil
+	}
+}
@@ -0,0 +1,30 @@
+package attack
+
+import (
+	"errors"
+	"net/http"
+)
+
+// errRedirectLimit is returned by the client when a redirect chain exceeds load.max_redirects.
+var errRedirectLimit = errors.New("stopped after max redirects")
+
+// redirectCountKey carries a per-request *int hop counter through the request context.
+type redirectCountKey struct{}
+
+// checkRedirect builds the client's CheckRedirect policy. When following is
+// disabled the 3xx response itself is returned as the final result; otherwise
+// every hop is counted into the request's counter until max is reached.
+func checkRedirect(follow bool, max int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if !follow {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > max {
+			return errRedirectLimit
+		}
+		if n, ok := req.Context().Value(redirectCountKey{}).(*int); ok {
+			*n = len(via)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,191 @@
+package attack
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"time"
+
+	"shard/internal/config"
+)
+
+// Reload applies the live-changeable subset of cfg to a running attack and
+// returns a description of what changed. Structural changes (target, protocol,
+// pool sizing, outputs) require a restart, so a config containing any of them
+// is rejected as a whole. Both outcomes are recorded as annotations.
+func (r *Runner) Reload(cfg *config.Config) ([]string, error) {
+	if err := cfg.Validate(); err != nil {
+		r.annotate("config reload rejected: " + err.Error())
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if changed := structuralChanges(r.cfg, cfg); len(changed) > 0 {
+		msg := fmt.Sprintf("config reload rejected: %s cannot change while running, restart required",
+			strings.Join(changed, ", "))
+		r.annotate(msg)
+		return nil, errors.New(msg)
+	}
+
+	var applied []string
+	if cfg.Load.Rate != r.cfg.Load.Rate {
+		applied = append(applied, fmt.Sprintf("load.rate %d -> %d", r.cfg.Load.Rate, cfg.Load.Rate))
+		r.cfg.Load.Rate = cfg.Load.Rate
+		// past the structural check these differ only as defaults derived
+		// from the rate, which follow it for the next reload to compare
+		r.cfg.Load.WarmupRate = cfg.Load.WarmupRate
+		r.cfg.Load.Search = cfg.Load.Search
+		offer(r.rateCh, cfg.Load.Rate)
+	}
+	if cfg.Output.ProgressInterval != r.cfg.Output.ProgressInterval {
+		applied = append(applied, fmt.Sprintf("output.progress_interval %q -> %q",
+			r.cfg.Output.ProgressInterval, cfg.Output.ProgressInterval))
+		r.cfg.Output.ProgressInterval = cfg.Output.ProgressInterval
+		offer(r.progressCh, progressInterval(cfg))
+	}
+
+	if !reflect.DeepEqual(cfg.Output.Capture, r.cfg.Output.Capture) {
+		applied = append(applied, fmt.Sprintf("output.capture %+v -> %+v", r.cfg.Output.Capture, cfg.Output.Capture))
+		r.cfg.Output.Capture = cfg.Output.Capture
+		capture := cfg.Output.Capture
+		r.capture.settings.Store(&capture)
+	}
+
+	if !reflect.DeepEqual(cfg.Abort, r.cfg.Abort) {
+		applied = append(applied, fmt.Sprintf("abort %+v -> %+v", r.cfg.Abort, cfg.Abort))
+		r.cfg.Abort = cfg.Abort
+		abort := cfg.Abort
+		r.abort.settings.Store(&abort)
+	}
+
+	if len(applied) == 0 {
+		r.annotate("config reloaded: no changes")
+	} else {
+		r.annotate("config reloaded: " + strings.Join(applied, ", "))
+	}
+	return applied, nil
+}
+
+// structuralChanges lists the fields that differ between old and cfg but
+// cannot be applied to a running attack.
+func structuralChanges(old, cfg *config.Config) []string {
+	checks := []struct {
+		name    string
+		changed bool
+	}{
+		{"target.url", old.Target.URL != cfg.Target.URL},
+		{"target.method", old.Target.Method != cfg.Target.Method},
+		{"target.headers", !maps.Equal(old.Target.Headers, cfg.Target.Headers)},
+		{"target.request_id", old.Target.RequestIDHeader != cfg.Target.RequestIDHeader},
+		{"target.echo_request_id_header", old.Target.EchoRequestIDHeader != cfg.Target.EchoRequestIDHeader},
+		{"target.body_file", old.Target.BodyFile != cfg.Target.BodyFile},
+		{"target.stages", !reflect.DeepEqual(old.Target.Stages, cfg.Target.Stages)},
+		{"target.protocol", old.Target.Protocol != cfg.Target.Protocol},
+		{"target.websocket", !reflect.DeepEqual(old.Target.WebSocket, cfg.Target.WebSocket)},
+		{"target.resolve", old.Target.Resolve != cfg.Target.Resolve},
+		{"target.cookies", !maps.Equal(old.Target.Cookies, cfg.Target.Cookies)},
+		{"target.unix_socket", old.Target.UnixSocket != cfg.Target.UnixSocket || old.Target.UnixTLS != cfg.Target.UnixTLS},
+		{"load.cookies", old.Load.Cookies != cfg.Load.Cookies},
+		{"load.client_abort", old.Load.ClientAbort != cfg.Load.ClientAbort},
+		{"load.retries", !reflect.DeepEqual(old.Load.Retries, cfg.Load.Retries)},
+		{"scenario", !reflect.DeepEqual(old.Scenario, cfg.Scenario)},
+		{"load.duration", old.Load.Duration != cfg.Load.Duration},
+		{"load.total_requests", old.Load.TotalRequests != cfg.Load.TotalRequests},
+		{"load.run_forever", old.Load.RunForever != cfg.Load.RunForever},
+		{"load.warmup", old.Load.Warmup != cfg.Load.Warmup},
+		{"load.warmup_rate", setWarmupRate(old.Load) != setWarmupRate(cfg.Load)},
+		{"load.ramp", old.Load.Ramp != cfg.Load.Ramp},
+		{"load.max_run_time", old.Load.MaxRunTime != cfg.Load.MaxRunTime},
+		{"load.cooldown", old.Load.Cooldown != cfg.Load.Cooldown},
+		{"load.concurrency", old.Load.Concurrency != cfg.Load.Concurrency},
+		{"load.queue_size", old.Load.QueueSize != cfg.Load.QueueSize},
+		{"load.timeout", old.Load.Timeout != cfg.Load.Timeout},
+		{"load.connect_timeout", old.Load.ConnectTimeout != cfg.Load.ConnectTimeout},
+		{"load.tls_timeout", old.Load.TLSTimeout != cfg.Load.TLSTimeout},
+		{"load.response_header_timeout", old.Load.ResponseHeaderTimeout != cfg.Load.ResponseHeaderTimeout},
+		{"load.disable_keepalive", old.Load.DisableKeepAlive != cfg.Load.DisableKeepAlive},
+		{"load.tcp_no_delay", old.Load.NoDelay() != cfg.Load.NoDelay()},
+		{"load.insecure_tls", old.Load.InsecureTLS != cfg.Load.InsecureTLS},
+		{"load.http2", old.Load.HTTP2 != cfg.Load.HTTP2},
+		{"load.compression", old.Load.Compression != cfg.Load.Compression},
+		{"load.identity_fraction", old.Load.IdentityFraction != cfg.Load.IdentityFraction},
+		{"tls", old.TLS != cfg.TLS},
+		{"proxy", old.Proxy != cfg.Proxy},
+		{"auth", !reflect.DeepEqual(old.Auth, cfg.Auth)},
+		{"load.stop_policy", old.Load.StopPolicy != cfg.Load.StopPolicy},
+		{"load.stop_grace", old.Load.StopGrace != cfg.Load.StopGrace},
+		{"load.baseline", !reflect.DeepEqual(old.Load.Baseline, cfg.Load.Baseline)},
+		{"load.mode", old.Load.Mode != cfg.Load.Mode},
+		{"load.search", !reflect.DeepEqual(setSearch(old.Load), setSearch(cfg.Load))},
+		{"load.schedule_file", old.Load.ScheduleFile != cfg.Load.ScheduleFile || !reflect.DeepEqual(old.Schedule(), cfg.Schedule())},
+		{"load.burst", old.Load.Burst != cfg.Load.Burst},
+		{"output.jsonl_path", old.Output.JSONLPath != cfg.Output.JSONLPath},
+		{"output.compression", old.Output.Codec(old.Output.JSONLPath) != cfg.Output.Codec(cfg.Output.JSONLPath)},
+		{"output.progress_path", old.Output.ProgressPath != cfg.Output.ProgressPath},
+		{"output.progress_append", old.Output.ProgressAppend != cfg.Output.ProgressAppend},
+		{"output.checkpoint_interval", old.Output.CheckpointInterval != cfg.Output.CheckpointInterval},
+		{"output.otlp_endpoint", old.Output.OTLPEndpoint != cfg.Output.OTLPEndpoint},
+		{"output.statsd_addr", old.Output.StatsdAddr != cfg.Output.StatsdAddr},
+		{"output.traceparent", old.Output.Traceparent != cfg.Output.Traceparent},
+	}
+	var changed []string
+	for _, c := range checks {
+		if c.changed {
+			changed = append(changed, c.name)
+		}
+	}
+	return changed
+}
+
+// setWarmupRate returns load.warmup_rate as the config file sets it: 0 when
+// it is the default Validate derives from load.rate, so a rate reload does
+// not count as a warmup change.
+func setWarmupRate(l config.LoadConfig) int {
+	if l.WarmupRate == l.DefaultWarmupRate() {
+		return 0
+	}
+	return l.WarmupRate
+}
+
+// setSearch is load.search with a start_rate defaulted from load.rate
+// cleared, as setWarmupRate.
+func setSearch(l config.LoadConfig) *config.Search {
+	if l.Search == nil || l.Search.StartRate != l.Rate {
+		return l.Search
+	}
+	s := *l.Search
+	s.StartRate = 0
+	return &s
+}
+
+// annotate queues an annotation for the results writer. It never blocks the
+// caller: up to 16 events wait for a run to write them, and any arriving
+// while the queue is full, as when no run is reading it, are dropped.
+func (r *Runner) annotate(msg string) {
+	select {
+	case r.annotations <- Annotation{Type: "annotation", Timestamp: time.Now(), Message: msg}:
+	default:
+	}
+}
+
+// offer replaces any pending value in a 1-buffered channel with v.
+func offer[T any](ch chan T, v T) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- v
+}
+
+// progressInterval returns the configured progress tick, defaulting to one second.
+func progressInterval(cfg *config.Config) time.Duration {
+	d, _ := time.ParseDuration(cfg.Output.ProgressInterval)
+	if d <= 0 {
+		return time.Second
+	}
+	return d
+}
@@ -0,0 +1,146 @@
+package attack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shard/internal/config"
+)
+
+// newTestRunner returns a runner for cfg that has not started.
+func newTestRunner(t *testing.T, cfg config.Config) *Runner {
+	t.Helper()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	r, err := NewRunner(&cfg)
+	if err != nil {
+		t.Fatalf("runner: %v", err)
+	}
+	return r
+}
+
+// lastAnnotation drains the runner's queued annotations and returns the last.
+func lastAnnotation(r *Runner) string {
+	var msg string
+	for len(r.annotations) > 0 {
+		msg = (<-r.annotations).Message
+	}
+	return msg
+}
+
+// The rate reload also covers the warmup rate Validate derives from it,
+// which must not read as a structural change.
+func TestReloadAppliesRateAndAbort(t *testing.T) {
+	cfg := testConfig("http://127.0.0.1:1/")
+	r := newTestRunner(t, cfg)
+
+	next := cfg
+	next.Load.Rate = 80
+	next.Abort.ConsecutiveFailures = 5
+	next.Abort.ErrorRate = 0.5
+	applied, err := r.Reload(&next)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(applied) != 2 || applied[0] != "load.rate 50 -> 80" || !strings.HasPrefix(applied[1], "abort ") {
+		t.Fatalf("applied %q", applied)
+	}
+	if rate := <-r.rateCh; rate != 80 {
+		t.Fatalf("scheduler offered rate %d, want 80", rate)
+	}
+	if a := r.abort.settings.Load(); a.ConsecutiveFailures != 5 || a.ErrorRate != 0.5 {
+		t.Fatalf("abort guard has %+v", *a)
+	}
+	if msg := lastAnnotation(r); !strings.HasPrefix(msg, "config reloaded: load.rate 50 -> 80") {
+		t.Fatalf("annotation %q", msg)
+	}
+
+	if applied, err := r.Reload(&next); err != nil || len(applied) != 0 {
+		t.Fatalf("reloading the same config: applied %q, err %v", applied, err)
+	}
+	if msg := lastAnnotation(r); msg != "config reloaded: no changes" {
+		t.Fatalf("annotation %q", msg)
+	}
+}
+
+func TestReloadRejectsStructuralChanges(t *testing.T) {
+	tests := []struct {
+		field  string
+		change func(*config.Config)
+	}{
+		{"target.url", func(c *config.Config) { c.Target.URL = "http://127.0.0.1:2/" }},
+		{"target.method", func(c *config.Config) { c.Target.Method = http.MethodPost }},
+		{"load.concurrency", func(c *config.Config) { c.Load.Concurrency = 8 }},
+		{"load.duration", func(c *config.Config) { c.Load.Duration = "5s" }},
+		{"load.warmup_rate", func(c *config.Config) { c.Load.WarmupRate = 20 }},
+		{"output.jsonl_path", func(c *config.Config) { c.Output.JSONLPath = "elsewhere.jsonl" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			cfg := testConfig("http://127.0.0.1:1/")
+			r := newTestRunner(t, cfg)
+
+			next := cfg
+			next.Load.Rate = 80 // allowed on its own, but the whole reload is rejected
+			tt.change(&next)
+			applied, err := r.Reload(&next)
+			if err == nil || !strings.Contains(err.Error(), tt.field+" cannot change") {
+				t.Fatalf("applied %q, err %v; want %s rejected", applied, err, tt.field)
+			}
+			if r.cfg.Load.Rate != 50 || len(r.rateCh) != 0 {
+				t.Fatalf("a rejected reload changed the rate to %d", r.cfg.Load.Rate)
+			}
+			if msg := lastAnnotation(r); !strings.HasPrefix(msg, "config reload rejected: "+tt.field) {
+				t.Fatalf("annotation %q", msg)
+			}
+		})
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	cfg := testConfig("http://127.0.0.1:1/")
+	r := newTestRunner(t, cfg)
+	next := cfg
+	next.Load.Rate = -1
+	if _, err := r.Reload(&next); err == nil || !strings.Contains(err.Error(), "invalid config") {
+		t.Fatalf("err %v, want invalid config", err)
+	}
+	if r.cfg.Load.Rate != 50 {
+		t.Fatalf("rate %d after an invalid reload", r.cfg.Load.Rate)
+	}
+}
+
+// A reload lands in a running attack: tightening the abort thresholds of a
+// run against a failing target stops it.
+func TestReloadAbortDuringRun(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close() // connections are refused from here on
+
+	cfg := testConfig(srv.URL)
+	cfg.Load.Duration = "10s"
+	r := newTestRunner(t, cfg)
+	done := make(chan error, 1)
+	go func() { done <- r.RunSink(context.Background(), &memSink{}, nil, nil) }()
+
+	time.Sleep(200 * time.Millisecond)
+	next := cfg
+	next.Abort.ConsecutiveFailures = 3
+	if _, err := r.Reload(&next); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	select {
+	case err := <-done:
+		var te *ThresholdError
+		if !errors.As(err, &te) {
+			t.Fatalf("run ended with %v, want the reloaded abort threshold", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run kept going after abort.consecutive_failures was reloaded")
+	}
+}
@@ -0,0 +1,83 @@
+package attack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"shard/internal/config"
+)
+
+// ReplayRecord opens the results of a load.schedule_file replay, right
+// after the meta record: the schedule the run followed, so the report can
+// set what each window called for against what was sent in it. Offsets
+// and lengths are in seconds, as in the schedule file.
+type ReplayRecord struct {
+	Type      string         `json:"type"` // always "replay"
+	Timestamp time.Time      `json:"ts"`   // when the schedule started: offset 0
+	File      string         `json:"file"`
+	Burst     bool           `json:"burst,omitempty"`
+	Length    float64        `json:"length"`
+	Windows   []ReplayWindow `json:"windows"`
+}
+
+// ReplayWindow is one window of the schedule.
+type ReplayWindow struct {
+	Offset float64 `json:"offset"`
+	Count  int     `json:"count"`
+}
+
+func newReplayRecord(cfg *config.Config, start time.Time) *ReplayRecord {
+	windows := cfg.Schedule()
+	rec := &ReplayRecord{
+		Type:      "replay",
+		Timestamp: start,
+		File:      cfg.Load.ScheduleFile,
+		Burst:     cfg.Load.Burst,
+		Length:    config.ScheduleLength(windows).Seconds(),
+		Windows:   make([]ReplayWindow, len(windows)),
+	}
+	for i, w := range windows {
+		rec.Windows[i] = ReplayWindow{Offset: w.Offset.Seconds(), Count: w.Count}
+	}
+	return rec
+}
+
+// replay dispatches tokens following load.schedule_file instead of a rate:
+// each window's count, spread evenly over the window or, with burst, all at
+// its start. Planned times depend on the schedule and start alone, so every
+// replay plans the same requests at the same offsets; as in schedule,
+// overdue tokens go out at once. A limit above 0 (load.total_requests)
+// ends the replay after that many tokens. It returns how many it sent.
+func (r *Runner) replay(ctx context.Context, workCh chan<- token, windows []config.ScheduleWindow, burst bool, start time.Time, limit int) int {
+	wait := time.NewTimer(time.Hour)
+	defer wait.Stop()
+	sent := 0
+	for _, win := range windows {
+		for k := range win.Count {
+			at := win.Offset
+			if !burst {
+				at += time.Duration(float64(win.Length) * float64(k) / float64(win.Count))
+			}
+			planned := start.Add(at)
+			if d := time.Until(planned); d > 0 {
+				wait.Reset(d)
+				select {
+				case <-ctx.Done():
+					return sent
+				case <-wait.C:
+				}
+			}
+			select {
+			case workCh <- token{planned: planned, stage: "replay"}:
+				if sent++; sent == limit {
+					r.annotate(fmt.Sprintf("load.total_requests reached: %d requests scheduled in %s", sent, time.Since(start).Round(time.Second)))
+					return sent
+				}
+			case <-ctx.Done():
+				return sent
+			}
+		}
+	}
+	return sent
+}
@@ -0,0 +1,45 @@
+package attack
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// crockford is the ULID alphabet: base32 without I, L, O and U.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID for target.request_id: 48 bits of millisecond
+// time, so IDs sort by send time in server logs, then 80 random bits. The
+// randomness comes from math/rand/v2's per-thread generator, which is
+// seeded once and takes no lock, so IDs cost no syscall on the hot path.
+// They only need to be unique, not unpredictable.
+func newULID(t time.Time) string {
+	ms := uint64(t.UnixMilli())
+	hi, lo := rand.Uint64(), rand.Uint32()
+	// bytes 0-5 are the time, 6-15 the randomness
+	var b [16]byte
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	b[6], b[7] = byte(lo>>8), byte(lo)
+	for i := 0; i < 8; i++ {
+		b[8+i] = byte(hi >> (56 - 8*i))
+	}
+	// 26 characters of 5 bits; the first carries only the top 3 bits
+	var out [26]byte
+	var acc uint32
+	var bits uint
+	j := 25
+	for i := 15; i >= 0; i-- {
+		acc |= uint32(b[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[j] = crockford[acc&31]
+			acc >>= 5
+			bits -= 5
+			j--
+		}
+	}
+	out[0] = crockford[acc&31]
+	return string(out[:])
+}
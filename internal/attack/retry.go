@@ -0,0 +1,108 @@
+package attack
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"shard/internal/config"
+)
+
+// retryPolicy is load.retries, parsed once per run.
+type retryPolicy struct {
+	attempts    int
+	on          map[string]bool // failure classes, status families ("5xx") and statuses ("503")
+	exponential bool
+	delay       time.Duration
+	maxDelay    time.Duration
+	anyMethod   bool
+}
+
+// newRetryPolicy returns nil when requests are sent only once.
+func newRetryPolicy(c config.Retries) *retryPolicy {
+	if c.MaxAttempts <= 1 {
+		return nil
+	}
+	p := &retryPolicy{
+		attempts:    c.MaxAttempts,
+		on:          make(map[string]bool, len(c.RetryOn)),
+		exponential: c.Backoff == "exponential",
+		anyMethod:   c.RetryNonIdempotent,
+	}
+	p.delay, _ = time.ParseDuration(c.Delay)
+	p.maxDelay, _ = time.ParseDuration(c.MaxDelay)
+	for _, on := range c.RetryOn {
+		p.on[on] = true
+	}
+	return p
+}
+
+// idempotent lists the methods RFC 9110 defines as idempotent.
+var idempotent = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true,
+	http.MethodTrace: true, http.MethodPut: true, http.MethodDelete: true,
+}
+
+// retryable reports whether res failed in a way the policy retries.
+func (p *retryPolicy) retryable(method string, res Result) bool {
+	if !p.anyMethod && !idempotent[method] {
+		return false
+	}
+	if res.Error != "" {
+		// a connect timeout counts as connect as well as timeout
+		return p.on[res.Error] || p.on[res.FailPhase]
+	}
+	code := strconv.Itoa(res.Code)
+	return p.on[code] || p.on[code[:1]+"xx"]
+}
+
+// backoff returns the pause before retry n (1-based). Exponential backoff
+// doubles per retry up to maxDelay and is jittered over its upper half, so
+// workers that failed together don't retry in lockstep.
+func (p *retryPolicy) backoff(n int) time.Duration {
+	if !p.exponential {
+		return p.delay
+	}
+	d := p.delay << (n - 1)
+	if d > p.maxDelay || d <= 0 {
+		d = p.maxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// send runs doRequest under the retry policy. The Result is the final
+// attempt's, but timed from the first attempt's start, so failed attempts
+// and backoff count towards the latency instead of hiding in it. When ctx
+// ends during a backoff, the attempt that failed is the final one.
+func (r *Runner) send(ctx context.Context, w *worker, base *http.Request, keep *kept) Result {
+	if r.retry == nil {
+		return r.doRequest(w, base, keep)
+	}
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		res := r.doRequest(w, base, keep)
+		if attempt < r.retry.attempts && r.retry.retryable(base.Method, res) {
+			if err := sleepCtx(ctx, r.retry.backoff(attempt)); err == nil {
+				continue
+			}
+		}
+		res.Attempts = attempt
+		res.Phases.Total += res.Timestamp.Sub(start)
+		res.Timestamp = start
+		return res
+	}
+}
+
+// sleepCtx pauses for d, or returns ctx's error as soon as it ends.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -1,13 +1,18 @@
 package attack
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/textproto"
 	"os"
 	"strings"
 	"sync"
@@ -19,205 +24,782 @@ import (
 
 // Runner executes the attack.
 type Runner struct {
-	cfg    *config.Config
-	client *http.Client
-}
-
-// StatsCollector maintains real-time metrics.
-type StatsCollector struct {
-	sent     int64
-	success  int64
-	fail     int64
-	failMap  sync.Map
-	totalLat int64
-	twoXX    int64
-	threeXX  int64
-	fourXX   int64
-	fiveXX   int64
+	cfg     *config.Config
+	client  *http.Client
+	dialer  *dialer
+	idle    *idleTracker
+	capture *bodyCapture
+	abort   *abortGuard
+	ui      bool // full-terminal dashboard instead of the progress line
+	meta    *MetaRecord
+
+	checkpoints Checkpointer // summaries into the progress log, see SetCheckpoints
+
+	abortAfter time.Duration  // load.client_abort.after, parsed
+	retry      *retryPolicy   // nil without load.retries
+	auth       *authenticator // nil without an auth section
+
+	discarded atomic.Int64 // scheduled requests dropped by the stop policy in the last RunSink
+	sent      atomic.Int64 // results the live stats counted in the last RunSink
+
+	// live reload plumbing, see Reload and Extend
+	mu          sync.Mutex
+	rateCh      chan int
+	progressCh  chan time.Duration
+	extendCh    chan time.Duration
+	annotations chan Annotation
+	planned     time.Duration // length of the running plan, extensions included; 0 when idle
 }
 
 // NewRunner creates a new attack runner from config.
 func NewRunner(cfg *config.Config) (*Runner, error) {
 	timeout, _ := time.ParseDuration(cfg.Load.Timeout)
+	idleTimeout, _ := time.ParseDuration(cfg.Load.IdleTimeout)
+	tlsTimeout, _ := time.ParseDuration(cfg.Load.TLSTimeout)
+	headerTimeout, _ := time.ParseDuration(cfg.Load.ResponseHeaderTimeout)
+
+	dialer, err := newDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	transport := &http.Transport{
-		DisableKeepAlives: cfg.Load.DisableKeepAlive,
-		TLSClientConfig:   &tls.Config{InsecureSkipVerify: cfg.Load.InsecureTLS},
+		DialContext:            dialer.DialContext,
+		ForceAttemptHTTP2:      cfg.Load.HTTP2,
+		DisableKeepAlives:      cfg.Load.DisableKeepAlive,
+		IdleConnTimeout:        idleTimeout,
+		TLSHandshakeTimeout:    tlsTimeout,
+		ResponseHeaderTimeout:  headerTimeout,
+		TLSClientConfig:        tlsConfig,
+		Proxy:                  newProxyFunc(cfg.Proxy),
+		OnProxyConnectResponse: onProxyConnect,
+		DisableCompression:     cfg.Load.Compression == "disable",
 	}
 
 	client := &http.Client{
-		Timeout:   timeout,
-		Transport: transport,
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: checkRedirect(cfg.Load.FollowsRedirects(), cfg.Load.MaxRedirects),
 	}
 
-	return &Runner{cfg: cfg, client: client}, nil
+	abortAfter, _ := time.ParseDuration(cfg.Load.ClientAbort.After)
+
+	// token requests go to their own host: same proxy and trust, none of
+	// the target's dialing or SNI
+	tokenTLS := tlsConfig.Clone()
+	tokenTLS.ServerName = ""
+	tokenClient := &http.Client{Transport: &http.Transport{Proxy: newProxyFunc(cfg.Proxy), TLSClientConfig: tokenTLS}}
+
+	return &Runner{
+		cfg:         cfg,
+		abortAfter:  abortAfter,
+		retry:       newRetryPolicy(cfg.Load.Retries),
+		auth:        newAuthenticator(cfg.Auth, tokenClient),
+		client:      client,
+		dialer:      dialer,
+		idle:        newIdleTracker(idleTimeout),
+		capture:     newBodyCapture(cfg.Output.Capture),
+		abort:       newAbortGuard(cfg.Abort),
+		rateCh:      make(chan int, 1),
+		progressCh:  make(chan time.Duration, 1),
+		extendCh:    make(chan time.Duration, 16),
+		annotations: make(chan Annotation, 16),
+	}, nil
 }
 
-// Run executes the full test and writes JSONL results.
-func (r *Runner) Run(ctx context.Context, outPath string) error {
-	rate := r.cfg.Load.Rate
-	duration, _ := time.ParseDuration(r.cfg.Load.Duration)
-	concurrency := r.cfg.Load.Concurrency
+// PinnedAddrs returns the addresses the target host was pinned to by
+// target.resolve, or nil when every connection resolves normally.
+func (r *Runner) PinnedAddrs() []string {
+	return r.dialer.Pinned()
+}
+
+// UseDashboard switches live progress from the single overwriting line to a
+// full-terminal dashboard. The caller decides whether the terminal supports it.
+func (r *Runner) UseDashboard(on bool) {
+	r.ui = on
+}
+
+// Discarded returns how many scheduled requests the stop policy dropped
+// in the last RunSink instead of sending them.
+func (r *Runner) Discarded() int64 {
+	return r.discarded.Load()
+}
 
-	req, err := r.makeRequest()
+// Sent returns how many results the live stats counted in the last RunSink,
+// whether or not they were written out.
+func (r *Runner) Sent() int64 {
+	return r.sent.Load()
+}
+
+// RunSink executes the full test, writing JSONL records to sink, live
+// progress to term and persistent progress lines to progress (either may be
+// nil for none). The sink is closed on every path.
+func (r *Runner) RunSink(ctx context.Context, sink ResultSink, term, progress io.Writer) error {
+	r.mu.Lock()
+	plan := r.cfg.Plan()
+	duration := config.PlanDuration(plan)
+	limit := r.cfg.Load.TotalRequests
+	searching := r.cfg.Load.Searching()
+	schedule, burst := r.cfg.Schedule(), r.cfg.Load.Burst
+	checkpointEvery, _ := time.ParseDuration(r.cfg.Output.CheckpointInterval)
+	var meta MetaRecord
+	if r.meta != nil {
+		meta = *r.meta
+	}
+	meta.complete(r.cfg)
+	concurrency := r.cfg.Load.Concurrency
+	progressEvery := progressInterval(r.cfg)
+	maxSize, _ := config.ParseSize(r.cfg.Output.MaxFileSize)
+	sizePolicy := r.cfg.Output.SizePolicy
+	stopGrace, _ := time.ParseDuration(r.cfg.Load.StopGrace)
+	gate := newStopGate(r.cfg.Load.StopPolicy, stopGrace)
+	r.planned = duration
+	r.mu.Unlock()
+
+	var req *http.Request
+	var steps []scenarioStep
+	var ws *wsTarget
+	var err error
+	switch {
+	case r.cfg.Target.UsesWebSocket():
+		ws, err = r.loadWebSocket()
+	case r.cfg.Scenario != nil:
+		steps, err = r.loadScenario()
+	default:
+		req, err = r.makeRequest("")
+	}
 	if err != nil {
+		sink.Close()
 		return fmt.Errorf("make request: %w", err)
 	}
+	// target.stages requests are built now too, so entering a stage reads
+	// no files
+	stageReqs := make(map[string]*http.Request, len(r.cfg.Target.Stages))
+	for name := range r.cfg.Target.Stages {
+		if stageReqs[name], err = r.makeRequest(name); err != nil {
+			sink.Close()
+			return fmt.Errorf("make request for stage %s: %w", name, err)
+		}
+	}
+	var iterations atomic.Int64
 
-	workCh := make(chan int, r.cfg.Load.QueueSize)
-	results := make(chan Result, concurrency*2)
-	stats := &StatsCollector{}
-	var wg sync.WaitGroup
+	if progress == nil {
+		progress = io.Discard
+	}
 
-	// Start workers
-	for i := 0; i < concurrency; i++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for range workCh {
-				res := r.doRequest(req)
-				select {
-				case results <- res:
-				case <-ctx.Done():
-					return
-				}
+	// the first OAuth2 token is fetched before any load; refreshes stop
+	// with the run
+	authCtx, stopAuth := context.WithCancel(ctx)
+	defer stopAuth()
+	if err := r.auth.start(authCtx, r.annotate); err != nil {
+		sink.Close()
+		return fmt.Errorf("auth: %w", err)
+	}
+
+	if r.cfg.Load.Baseline != nil {
+		b, err := r.calibrate(ctx)
+		if ctx.Err() != nil {
+			// interrupted before any load
+			return sink.Close()
+		}
+		if err != nil {
+			if term != nil {
+				fmt.Fprintf(term, "⚠️  Baseline skipped: %v\n", err)
+			}
+			r.annotate("baseline skipped: " + err.Error())
+		} else {
+			if term != nil {
+				fmt.Fprintf(term, "📏 Baseline: %s\n", b)
 			}
-		}(i)
+			fmt.Fprintf(progress, "Baseline: %s\n", b)
+			meta.Baseline = b
+		}
 	}
 
-	// Open results output file
-	outFile, err := os.Create(outPath)
+	exp, err := newExporter(r.cfg.Output)
 	if err != nil {
-		return fmt.Errorf("open output: %w", err)
+		sink.Close()
+		return fmt.Errorf("metrics export: %w", err)
 	}
-	defer outFile.Close()
 
-	// Open persistent progress log
-	progressFile, err := os.Create("progress.log")
-	if err != nil {
-		return fmt.Errorf("open progress log: %w", err)
+	// from here on the sink is closed (flushed) through the guard
+	guard := newSizeGuard(sink, maxSize, sizePolicy)
+	out := io.Writer(guard)
+	if r.checkpoints != nil {
+		out = recordTap{w: guard, to: r.checkpoints}
+	}
+
+	var dash *dashboard
+	if r.ui && term != nil {
+		target := r.cfg.Target.Method + " " + r.cfg.Target.URL
+		if r.cfg.Scenario != nil {
+			target = fmt.Sprintf("scenario (%d steps)", len(r.cfg.Scenario.Steps))
+		}
+		dash = newDashboard(term, target, duration)
+		term = nil // the progress log still gets every line
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workCh := make(chan token, r.cfg.Load.QueueSize)
+	results := make(chan Result, concurrency.Initial()*2)
+	stats := &StatsCollector{}
+	var wg sync.WaitGroup
+
+	// serve sends the request(s) for one dequeued token
+	serve := func(w *worker, t token) {
+		if !gate.admit() {
+			return
+		}
+		stats.Dispatch()
+		// only the first request of a token was scheduled; later scenario
+		// steps have no planned time to lag behind
+		emit := func(res Result, scheduled bool) {
+			if scheduled {
+				res.Scheduled = t.planned
+				res.SchedLag = res.Timestamp.Sub(t.planned)
+			}
+			if t.stage != "steady" && t.stage != "replay" {
+				res.Stage = t.stage
+			}
+			if t.step != nil {
+				res.StageRate = t.step.rate
+				t.step.observe(res, scheduled)
+			}
+			// the writer drains results until they are closed, so this never
+			// drops a finished request, even while shutting down
+			results <- res
+		}
+		if ws != nil {
+			emit(r.sendWebSocket(w, ws), true)
+			return
+		}
+		if steps == nil {
+			base := req
+			if sr, ok := stageReqs[t.stage]; ok {
+				base = sr
+			}
+			emit(r.send(ctx, w, base, nil), true)
+			return
+		}
+		for i, res := range r.runScenario(ctx, w, steps, iterations.Add(1)) {
+			emit(res, i == 0)
+		}
+	}
+
+	// Start workers
+	var pool *autoPool
+	if concurrency.Auto() {
+		pool = newAutoPool(r, workCh, &wg, stats, serve)
+	} else {
+		for _, w := range r.newWorkers(int(concurrency)) {
+			wg.Add(1)
+			go func(w *worker) {
+				defer wg.Done()
+				defer w.close()
+				for t := range workCh {
+					serve(w, t)
+				}
+			}(w)
+		}
+	}
+	var workers WorkersRecord
+	var searched *SearchRecord
+	var replayed *ReplayRecord
+	if len(schedule) > 0 {
+		replayed = newReplayRecord(r.cfg, time.Now())
 	}
-	defer progressFile.Close()
 
 	// Writer + live progress goroutine
+	writerDone := make(chan struct{})
 	go func() {
-		enc := json.NewEncoder(outFile)
-		ticker := time.NewTicker(time.Second)
+		defer close(writerDone)
+		enc := json.NewEncoder(out)
+		ticker := time.NewTicker(progressEvery)
 		defer ticker.Stop()
+		var checkpoints <-chan time.Time
+		if r.checkpoints != nil && progress != io.Discard && checkpointEvery > 0 {
+			t := time.NewTicker(checkpointEvery)
+			defer t.Stop()
+			checkpoints = t.C
+		}
 
 		start := time.Now()
+		meta.Timestamp = start
+		_ = enc.Encode(meta)
+		if replayed != nil {
+			_ = enc.Encode(replayed)
+		}
+		note := func(msg string) {
+			_ = enc.Encode(Annotation{Type: "annotation", Timestamp: time.Now(), Message: msg})
+			fmt.Fprintf(progress, "[%v] %s\n", time.Since(start).Round(time.Second), msg)
+			if dash != nil {
+				dash.event = msg
+			}
+		}
+		tick := func() {
+			_ = enc.Encode(printStats(stats, start, term, progress))
+			r.mu.Lock()
+			rate := r.cfg.Load.Rate
+			if r.planned > 0 {
+				duration = r.planned // moved out by Extend
+			}
+			r.mu.Unlock()
+			if dash != nil {
+				dash.duration = duration
+				dash.render(stats, rate)
+			}
+		}
+		// alert notes an event that needs attention and shows it on the
+		// terminal line too; the dashboard shows every note already
+		alert := func(icon, msg string) {
+			if term != nil {
+				fmt.Fprintf(term, "\n%s %s\n", icon, msg)
+			}
+			note(msg)
+		}
+		stopping := false
+		abortRun := func(te *ThresholdError) {
+			alert("🛑", te.Error())
+			cancel()
+		}
 		for {
 			select {
 			case res, ok := <-results:
 				if !ok {
-					printStats(stats, start, progressFile)
-					fmt.Fprintln(progressFile, "---- Test completed ----")
+					for len(r.annotations) > 0 {
+						note((<-r.annotations).Message)
+					}
+					if n := gate.discarded.Load(); n > 0 {
+						_ = enc.Encode(StopRecord{Type: "stop", Timestamp: time.Now(), Policy: gate.policy, Discarded: n})
+						note(fmt.Sprintf("stop policy %s discarded %d scheduled requests", gate.policy, n))
+					}
+					if searched != nil {
+						_ = enc.Encode(searched)
+					}
+					if rec := r.auth.record(); rec != nil {
+						_ = enc.Encode(rec)
+						if rec.Failed > 0 {
+							note(fmt.Sprintf("auth: %d token requests failed", rec.Failed))
+						}
+					}
+					if pool != nil {
+						workers.Timestamp = time.Now()
+						_ = enc.Encode(workers)
+						note(fmt.Sprintf("auto concurrency peaked at %d workers, %d busy at once", workers.Peak, workers.PeakBusy))
+					}
+					tick()
+					if dash != nil {
+						dash.close()
+					}
+					if msg := guard.summary(); msg != "" {
+						note(msg)
+					}
+					fmt.Fprintln(progress, "---- Test completed ----")
 					return
 				}
 				stats.Add(res)
-				_ = enc.Encode(res)
+				if r.checkpoints != nil {
+					r.checkpoints.Add(res)
+				}
+				if dash != nil {
+					dash.observe(res)
+				}
+				if exp != nil {
+					exp.observe(res)
+				}
+				if guard.keep(res) {
+					_ = enc.Encode(res)
+				}
+				if te := r.abort.observe(res); te != nil && !stopping {
+					stopping = true
+					abortRun(te)
+				}
+			case ann := <-r.annotations:
+				note(ann.Message)
+			case d := <-r.progressCh:
+				ticker.Reset(d)
+			case <-checkpoints:
+				fmt.Fprintf(progress, "[%v] ---- Checkpoint ----\n", time.Since(start).Round(time.Second))
+				r.checkpoints.ReportLevel(progress, 1)
 			case <-ticker.C:
-				printStats(stats, start, progressFile)
+				tick()
+				r.mu.Lock()
+				currentRate := r.cfg.Load.Rate
+				r.mu.Unlock()
+				elapsed := time.Since(start)
+				if te := r.abort.check(); te != nil && !stopping {
+					stopping = true
+					abortRun(te)
+				}
+				if msg := guard.check(elapsed, remaining(duration, elapsed), currentRate); msg != "" {
+					alert("⚠️ ", msg)
+					if guard.aborted {
+						cancel()
+					}
+				}
 			}
 		}
 	}()
 
-	// Fixed-rate scheduler
-	interval := time.Second / time.Duration(rate)
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	stop := time.After(duration)
-	count := 0
-loop:
-	for {
-		select {
-		case <-stop:
-			break loop
-		case <-ticker.C:
-			select {
-			case workCh <- count:
-				count++
-			case <-ctx.Done():
-				break loop
-			}
-		}
+	// Paced scheduler
+	switch {
+	case searching:
+		searched = r.search(ctx, workCh, plan, term)
+	case replayed != nil:
+		r.replay(ctx, workCh, schedule, burst, replayed.Timestamp, limit)
+	default:
+		r.schedule(ctx, workCh, plan, limit, nil)
+	}
+	r.mu.Lock()
+	r.planned = 0
+	for len(r.extendCh) > 0 {
+		<-r.extendCh // arrived after the deadline; too late to apply
+	}
+	r.mu.Unlock()
+	gate.stop()
+	if pool != nil {
+		// no worker may start once the queue is closed and wg.Wait begins
+		workers = pool.halt()
 	}
 	close(workCh)
 	wg.Wait()
 	close(results)
+	<-writerDone
+	r.discarded.Store(gate.discarded.Load())
+	r.sent.Store(stats.sent.Load())
+	if exp != nil {
+		if n := exp.close(); n > 0 {
+			out.Write(annotationLine(fmt.Sprintf("metrics export fell behind and dropped %d results", n)))
+		}
+	}
+
+	if err := guard.Close(); err != nil {
+		return fmt.Errorf("close output: %w", err)
+	}
+	if r.abort.tripped != nil {
+		return r.abort.tripped
+	}
+	if guard.aborted {
+		return ErrOutputSizeLimit
+	}
 	return nil
 }
 
-// makeRequest builds the base HTTP request from config.
-func (r *Runner) makeRequest() (*http.Request, error) {
-	body := strings.NewReader("")
-	if r.cfg.Target.BodyFile != "" {
-		data, err := os.ReadFile(r.cfg.Target.BodyFile)
+// makeRequest builds the base HTTP request from config, with the
+// target.stages override for stage applied ("" for none). The payload is
+// read once and kept in memory, see newRequest.
+func (r *Runner) makeRequest(stage string) (*http.Request, error) {
+	override := r.cfg.Target.Stages[stage]
+	bodyFile := r.cfg.Target.BodyFile
+	if override.BodyFile != "" {
+		bodyFile = override.BodyFile
+	}
+	var payload []byte
+	if bodyFile != "" {
+		data, err := os.ReadFile(bodyFile)
 		if err != nil {
 			return nil, fmt.Errorf("read body file: %w", err)
 		}
-		body = strings.NewReader(string(data))
+		payload = data
 	}
 
-	req, err := http.NewRequest(r.cfg.Target.Method, r.cfg.Target.URL, body)
+	req, err := newRequest(r.cfg.Target.Method, r.cfg.Target.URL, payload)
 	if err != nil {
 		return nil, err
 	}
 	for k, v := range r.cfg.Target.Headers {
 		req.Header.Set(k, v)
 	}
+	for k, v := range override.Headers {
+		req.Header.Set(k, v)
+	}
 	return req, nil
 }
 
-// doRequest executes one traced HTTP request.
-func (r *Runner) doRequest(base *http.Request) Result {
+// doRequest executes one traced HTTP request. When keep is non-nil it also
+// receives the response headers and body, for callers that need more than
+// the Result (scenario extraction).
+func (r *Runner) doRequest(w *worker, base *http.Request, keep *kept) Result {
 	var res Result
 	var phases PhaseTimings
 	var reused bool
+	var redirects int
+	var remoteAddr string
+	var informational []int
+	var earlyAt time.Duration
+	var conn uint64
+	var delivered atomic.Bool // request written; WroteRequest runs on the transport's goroutine
+	// inPhase is the phase currently in progress, for timeout attribution;
+	// trace hooks may fire on the transport's dial goroutine.
+	var inPhase atomic.Value
+	inPhase.Store("connect")
 
 	start := time.Now()
-	req := base.Clone(context.Background())
+	var missingCert atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// a client_abort request cancels its own context at the trigger point
+	var aborted atomic.Bool
+	abort := func() {
+		aborted.Store(true)
+		cancel()
+	}
+	var abortBytes int64
+	if ca := r.cfg.Load.ClientAbort; ca.Fraction > 0 && rand.Float64() < ca.Fraction {
+		if r.abortAfter > 0 {
+			defer time.AfterFunc(r.abortAfter, abort).Stop()
+		}
+		abortBytes = ca.AfterBytes
+	}
+	ctx = context.WithValue(ctx, redirectCountKey{}, &redirects)
+	var tunnel proxyTunnel
+	ctx = context.WithValue(ctx, proxyTunnelKey{}, &tunnel)
+	req := base.Clone(context.WithValue(ctx, missingCertKey{}, &missingCert))
+	if base.GetBody != nil {
+		// Clone shares the base's Body, which the first request would consume
+		req.Body, _ = base.GetBody()
+	}
+	// an explicit Accept-Encoding turns off the transport's own gzip
+	// handling, so the body reaches us as it crossed the network
+	decode := false
+	if f := r.cfg.Load.IdentityFraction; f > 0 && rand.Float64() < f {
+		req.Header.Set("Accept-Encoding", "identity")
+		res.Identity, decode = true, true
+	} else if offer := acceptEncoding(r.cfg.Load.Compression, req); offer != "" {
+		req.Header.Set("Accept-Encoding", offer)
+		decode = true
+	} else {
+		// the user's own Accept-Encoding may still get an encoding we decode
+		decode = r.cfg.Load.Compression != "auto" && r.cfg.Load.Compression != "disable"
+	}
+	if r.cfg.Output.Traceparent && req.Header.Get("traceparent") == "" {
+		res.TraceID = setTraceparent(req.Header)
+	}
+	if name := r.cfg.Target.RequestIDHeader; name != "" {
+		res.RequestID = newULID(time.Now())
+		req.Header.Set(name, res.RequestID)
+	}
+	token := r.auth.apply(req.Header)
 
 	trace := &httptrace.ClientTrace{
-		GotConn:      func(info httptrace.GotConnInfo) { reused = info.Reused },
-		DNSStart:     func(_ httptrace.DNSStartInfo) { phases.DNS = time.Since(start) },
-		DNSDone:      func(_ httptrace.DNSDoneInfo) { phases.DNS = time.Since(start) - phases.DNS },
-		ConnectStart: func(_, _ string) { phases.Connect = time.Since(start) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+			remoteAddr = info.Conn.RemoteAddr().String()
+			conn = connID(info.Conn)
+			tunnel.reached()
+			inPhase.Store("ttfb")
+		},
+		DNSStart: func(_ httptrace.DNSStartInfo) {
+			inPhase.Store("dns")
+			phases.DNS = time.Since(start)
+		},
+		DNSDone: func(_ httptrace.DNSDoneInfo) { phases.DNS = time.Since(start) - phases.DNS },
+		ConnectStart: func(_, _ string) {
+			inPhase.Store("connect")
+			phases.Connect = time.Since(start)
+		},
 		ConnectDone: func(net, addr string, err error) {
 			if err == nil {
 				phases.Connect = time.Since(start) - phases.Connect
+				tunnel.dialed = time.Now()
+				if tunnel.used.Load() {
+					inPhase.Store("proxy")
+				}
+			}
+		},
+		TLSHandshakeStart: func() {
+			if tunnel.opening() {
+				return // with an https proxy, part of proxy_connect
+			}
+			tunnel.reached()
+			inPhase.Store("tls")
+			phases.TLS = time.Since(start)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			if tunnel.opening() {
+				return
 			}
+			phases.TLS = time.Since(start) - phases.TLS
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				delivered.Store(true)
+			}
+		},
+		GotFirstResponseByte: func() {
+			delivered.Store(true)
+			phases.TTFB = time.Since(start)
+		},
+		Got1xxResponse: func(code int, _ textproto.MIMEHeader) error {
+			if len(informational) == 0 {
+				earlyAt = time.Since(start)
+			}
+			informational = append(informational, code)
+			return nil
 		},
-		TLSHandshakeStart:    func() { phases.TLS = time.Since(start) },
-		TLSHandshakeDone:     func(_ tls.ConnectionState, _ error) { phases.TLS = time.Since(start) - phases.TLS },
-		GotFirstResponseByte: func() { phases.TTFB = time.Since(start) },
 	}
 
 	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
 
-	resp, err := r.client.Do(req)
+	resp, err := w.client.Do(req)
 	total := time.Since(start)
+	if len(informational) > 0 {
+		// the first byte was the 1xx; the final response's headers arrived just now
+		phases.TTFB = total
+		res.Informational = informational
+		res.EarlyHintsAt = earlyAt
+	}
 	res.Timestamp = start
+	res.Method = base.Method
 	res.Phases = phases
 	res.Reused = reused
+	res.RemoteAddr = remoteAddr
+	res.Worker = w.id + 1
+	res.Conn = conn
+	res.Redirects = redirects
+	res.Undelivered = !delivered.Load()
 	res.Phases.Total = total
+	res.Phases.ProxyConnect = time.Duration(tunnel.took.Load())
+	if ttl, ok := r.dialer.TTL(); ok && phases.DNS > 0 {
+		res.DNSTTL = &ttl
+	}
+	afterGap := r.idle.observe(base.URL.Host, start, start.Add(total))
+	res.AfterIdle = afterGap && !reused && !r.cfg.Load.DisableKeepAlive
 
+	if err != nil && aborted.Load() {
+		res.Error, res.FailPhase = "client_abort", "client_abort"
+		return res
+	}
 	if err != nil {
 		res.Error = classifyError(err)
+		if missingCert.Load() && res.Error != "timeout" {
+			res.Error = "tls_client_auth"
+		}
+		switch {
+		case tunnel.status.Load() == http.StatusProxyAuthRequired:
+			res.Error = "proxy_auth"
+		case tunnel.failed() && res.Error != "timeout":
+			// refused, unresolvable or failing proxy: the origin was never tried
+			res.Error = "proxy"
+		}
 		res.FailPhase = res.Error
+		if res.Error == "timeout" {
+			res.FailPhase = inPhase.Load().(string)
+		}
+		// dial failures never reach GotConn; take the address from the error instead
+		var opErr *net.OpError
+		if res.RemoteAddr == "" && res.Error != "dns" && errors.As(err, &opErr) && opErr.Addr != nil {
+			res.RemoteAddr = opErr.Addr.String()
+		}
 		return res
 	}
 	res.Code = resp.StatusCode
-	io.Copy(io.Discard, resp.Body)
+	w.proto = resp.Proto
+	if name := r.cfg.Target.EchoRequestIDHeader; name != "" {
+		res.ServerRequestID = resp.Header.Get(name)
+	}
+	res.HeaderValues = r.capture.numericHeaders(resp.Header, start.Add(total))
+	skew, ok := r.capture.dateSkew(resp.Header, start.Add(total))
+	res.DateSkew, res.NoDate = skew, !ok
+	var wire *timedReader
+	var decoder *timedReader
+	var decoded io.Reader = resp.Body
+	if decode {
+		wire = &timedReader{r: resp.Body}
+		res.Encoding = resp.Header.Get("Content-Encoding")
+		var release func()
+		decoded, release, err = decodeBody(resp.Header, wire)
+		defer release()
+		if decoded != io.Reader(wire) {
+			decoder = &timedReader{r: decoded}
+			decoded = decoder
+		}
+	}
+	if abortBytes > 0 {
+		decoded = &abortingReader{r: decoded, left: abortBytes, abort: abort}
+	}
+	counted := &countingReader{r: decoded}
+	body := io.Reader(counted)
+	if keep != nil && err == nil {
+		keep.header = resp.Header
+		keep.body, err = io.ReadAll(io.LimitReader(counted, maxKeptBody))
+		body = io.MultiReader(bytes.NewReader(keep.body), counted)
+	}
+	if err == nil {
+		res.BodySample, err = r.capture.read(resp.StatusCode, body)
+	}
 	resp.Body.Close()
+	res.Bytes = counted.n
+	if wire != nil {
+		res.WireBytes = wire.n
+	}
+	if decoder != nil {
+		res.Decode = max(0, decoder.spent-wire.spent)
+	}
+	switch {
+	case err != nil && aborted.Load():
+		res.Error, res.FailPhase = "client_abort", "client_abort"
+	case err != nil:
+		res.Error = classifyError(err)
+		res.FailPhase = "body"
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		// a proxy refused to forward the request, the origin never saw it
+		res.Error, res.FailPhase = "proxy_auth", "proxy_auth"
+	case resp.StatusCode == http.StatusUnauthorized && r.auth.aroundRefresh(token, start, time.Now()):
+		// most likely the token changing hands, not bad credentials
+		res.Error, res.FailPhase = "auth", "auth"
+	}
 	return res
 }
 
+// abortingReader calls abort and stops reading once left bytes were read.
+type abortingReader struct {
+	r     io.Reader
+	left  int64
+	abort func()
+}
+
+func (a *abortingReader) Read(p []byte) (int, error) {
+	if a.left <= 0 {
+		a.abort()
+		return 0, context.Canceled
+	}
+	if int64(len(p)) > a.left {
+		p = p[:a.left]
+	}
+	n, err := a.r.Read(p)
+	a.left -= int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 // classifyError creates a taxonomy label for an error and phase tag.
 func classifyError(err error) string {
 	msg := err.Error()
 	switch {
+	case errors.Is(err, errRedirectLimit):
+		return "redirect_limit"
+	case errors.As(err, new(*net.DNSError)):
+		return "dns"
+	case isClientAuthError(err):
+		return "tls_client_auth"
 	case os.IsTimeout(err):
 		return "timeout"
 	case strings.Contains(msg, "no such host"):
@@ -233,94 +815,51 @@ func classifyError(err error) string {
 	}
 }
 
-// Add updates stats with a result.
-func (s *StatsCollector) Add(r Result) {
-	atomic.AddInt64(&s.sent, 1)
-	if r.Error != "" {
-		atomic.AddInt64(&s.fail, 1)
-		s.failMap.LoadOrStore(r.FailPhase, new(int64))
-		val, _ := s.failMap.Load(r.FailPhase)
-		ptr := val.(*int64)
-		atomic.AddInt64(ptr, 1)
-		return
-	}
-	atomic.AddInt64(&s.success, 1)
-	atomic.AddInt64(&s.totalLat, r.Phases.Total.Milliseconds())
-	// per-status-family counts
-	if r.Code > 0 {
-		switch r.Code / 100 {
-		case 2:
-			atomic.AddInt64(&s.twoXX, 1)
-		case 3:
-			atomic.AddInt64(&s.threeXX, 1)
-		case 4:
-			atomic.AddInt64(&s.fourXX, 1)
-		case 5:
-			atomic.AddInt64(&s.fiveXX, 1)
-		}
-	}
-}
-
-// Snapshot returns a snapshot of current stats safely.
-func (s *StatsCollector) Snapshot() (sent, success, fail int64, avgLat float64, fails map[string]int64, families map[string]int64) {
-	sent = atomic.LoadInt64(&s.sent)
-	success = atomic.LoadInt64(&s.success)
-	fail = atomic.LoadInt64(&s.fail)
-	totalLat := atomic.LoadInt64(&s.totalLat)
-	if success > 0 {
-		avgLat = float64(totalLat) / float64(success)
-	}
-	fails = make(map[string]int64)
-	s.failMap.Range(func(k, v any) bool {
-		fails[k.(string)] = atomic.LoadInt64(v.(*int64))
-		return true
-	})
-	families = map[string]int64{
-		"2xx": atomic.LoadInt64(&s.twoXX),
-		"3xx": atomic.LoadInt64(&s.threeXX),
-		"4xx": atomic.LoadInt64(&s.fourXX),
-		"5xx": atomic.LoadInt64(&s.fiveXX),
-	}
-	return
-}
-
-// printStats prints real-time progress to terminal and writes it to progress.log.
-func printStats(stats *StatsCollector, start time.Time, progressFile *os.File) {
-	sent, success, fail, avg, fails, fam := stats.Snapshot()
-	elapsed := time.Since(start).Round(time.Second)
+// printStats prints real-time progress to term (unless nil) and writes a
+// persistent line to progress (unless nil). It returns the readings.
+func printStats(stats *StatsCollector, start time.Time, term, progress io.Writer) ProgressRecord {
+	p := stats.Progress(start)
+	elapsed := time.Duration(p.Elapsed * float64(time.Second)).Round(time.Second)
 
 	// live terminal line (overwrites)
-	fmt.Printf("\r[%v] sent=%d ok=%d fail=%d avg=%.1fms",
-		elapsed, sent, success, fail, avg)
-
-	// append families
-	var famParts []string
-	if v := fam["2xx"]; v > 0 {
-		famParts = append(famParts, fmt.Sprintf("2xx=%d", v))
+	if term == nil {
+		term = io.Discard
 	}
-	if v := fam["3xx"]; v > 0 {
-		famParts = append(famParts, fmt.Sprintf("3xx=%d", v))
+	// an outage fails requests instantly; don't let them pass for delivered load
+	sentLabel := fmt.Sprintf("sent=%d", p.Sent)
+	if p.Delivered != p.Sent {
+		sentLabel += fmt.Sprintf(" delivered=%d", p.Delivered)
 	}
-	if v := fam["4xx"]; v > 0 {
-		famParts = append(famParts, fmt.Sprintf("4xx=%d", v))
+	// the live pool size under auto concurrency
+	var workers string
+	if p.Workers > 0 {
+		workers = fmt.Sprintf(" workers=%d", p.Workers)
 	}
-	if v := fam["5xx"]; v > 0 {
-		famParts = append(famParts, fmt.Sprintf("5xx=%d", v))
+	fmt.Fprintf(term, "\r[%v] %s rate=%.0f/s ok=%d fail=%d avg=%.1fms%s",
+		elapsed, sentLabel, p.Rate, p.OK, p.Failed, p.AvgMs, workers)
+
+	// append families
+	var famParts []string
+	for _, f := range []string{"2xx", "3xx", "4xx", "5xx"} {
+		if v := p.Families[f]; v > 0 {
+			famParts = append(famParts, fmt.Sprintf("%s=%d", f, v))
+		}
 	}
 	if len(famParts) > 0 {
-		fmt.Printf(" (%s)", strings.Join(famParts, " "))
+		fmt.Fprintf(term, " (%s)", strings.Join(famParts, " "))
 	}
 
 	// build fail breakdown
 	var failParts []string
-	for k, v := range fails {
+	for k, v := range p.Fails {
 		failParts = append(failParts, fmt.Sprintf("%s=%d", k, v))
 	}
 
 	// persistent log line
-	line := fmt.Sprintf("[%v] sent=%d ok=%d fail=%d avg=%.1fms",
-		elapsed, sent, success, fail, avg)
+	line := fmt.Sprintf("[%v] %s rate=%.0f/s ok=%d fail=%d avg=%.1fms%s",
+		elapsed, sentLabel, p.Rate, p.OK, p.Failed, p.AvgMs, workers)
 	if len(failParts) > 0 {
+		line += fmt.Sprintf(" fail_avg=%.1fms", p.FailAvgMs)
 		line += " (" + strings.Join(failParts, ", ") + ")"
 	}
 	if len(famParts) > 0 {
@@ -328,7 +867,8 @@ func printStats(stats *StatsCollector, start time.Time, progressFile *os.File) {
 	}
 	line += "\n"
 
-	if progressFile != nil {
-		progressFile.WriteString(line)
+	if progress != nil {
+		io.WriteString(progress, line)
 	}
+	return p
 }
@@ -0,0 +1,102 @@
+package attack
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"shard/internal/config"
+)
+
+// memSink collects a run's JSONL in memory.
+type memSink struct{ bytes.Buffer }
+
+func (*memSink) Close() error { return nil }
+
+// testConfig returns a short, low-rate run against url.
+func testConfig(url string) config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Target.URL = url
+	cfg.Load.Rate = 50
+	cfg.Load.Duration = "1s"
+	cfg.Load.Concurrency = 4
+	cfg.Load.Timeout = "5s"
+	return cfg
+}
+
+// runTest validates cfg, runs it to completion and returns its results.
+func runTest(t *testing.T, cfg config.Config) []Result {
+	t.Helper()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	runner, err := NewRunner(&cfg)
+	if err != nil {
+		t.Fatalf("runner: %v", err)
+	}
+	var sink memSink
+	if err := runner.RunSink(context.Background(), &sink, nil, nil); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var results []Result
+	sc := bufio.NewScanner(&sink)
+	for sc.Scan() {
+		if bytes.HasPrefix(sc.Bytes(), []byte(`{"type":`)) {
+			continue
+		}
+		var res Result
+		if err := json.Unmarshal(sc.Bytes(), &res); err != nil {
+			t.Fatalf("decode %s: %v", sc.Bytes(), err)
+		}
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		t.Fatal("the run sent no requests")
+	}
+	return results
+}
+
+func TestRunnerResendsFullBody(t *testing.T) {
+	body := strings.Repeat("shard-body-", 6000) // larger than one read buffer
+	path := filepath.Join(t.TempDir(), "body.txt")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var received, short atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		received.Add(1)
+		if string(got) != body {
+			short.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	cfg.Target.Method = http.MethodPost
+	cfg.Target.BodyFile = path
+	results := runTest(t, cfg)
+
+	if n := received.Load(); n != int64(len(results)) {
+		t.Fatalf("server received %d requests, the run recorded %d", n, len(results))
+	}
+	if n := short.Load(); n > 0 {
+		t.Fatalf("%d of %d POSTs arrived without the full body", n, len(results))
+	}
+	for _, res := range results {
+		if res.Code != http.StatusOK {
+			t.Fatalf("result %+v, want 200", res)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package attack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"shard/internal/config"
+)
+
+// maxKeptBody bounds how much of a response body is kept for extraction.
+const maxKeptBody = 1 << 20
+
+// kept receives a response's headers and (bounded) body from doRequest.
+type kept struct {
+	header http.Header
+	body   []byte
+}
+
+// scenarioStep is a config.Step with its body loaded and extractors parsed.
+type scenarioStep struct {
+	config.Step
+	payload string
+	extract map[string]config.Extractor
+}
+
+// loadScenario prepares the configured steps once per run.
+func (r *Runner) loadScenario() ([]scenarioStep, error) {
+	steps := make([]scenarioStep, len(r.cfg.Scenario.Steps))
+	for i, st := range r.cfg.Scenario.Steps {
+		s := scenarioStep{Step: st, payload: st.Body, extract: make(map[string]config.Extractor)}
+		if st.BodyFile != "" {
+			data, err := os.ReadFile(st.BodyFile)
+			if err != nil {
+				return nil, fmt.Errorf("step %s: read body file: %w", st.Name, err)
+			}
+			s.payload = string(data)
+		}
+		for name, spec := range st.Extract {
+			ex, err := config.ParseExtractor(spec)
+			if err != nil {
+				return nil, fmt.Errorf("step %s: %w", st.Name, err)
+			}
+			s.extract[name] = ex
+		}
+		steps[i] = s
+	}
+	return steps, nil
+}
+
+// runScenario executes one iteration and returns a Result per step it ran,
+// tagged with the step name and iteration. A step fails on a transport error,
+// a status >= 400, or a template/extraction error; the rest of the iteration
+// is then skipped. The last result carries the iteration's Outcome.
+func (r *Runner) runScenario(ctx context.Context, w *worker, steps []scenarioStep, iteration int64) []Result {
+	vars := make(map[string]string)
+	out := make([]Result, 0, len(steps))
+	for i, st := range steps {
+		var res Result
+		req, err := r.stepRequest(st, vars)
+		if err != nil {
+			res = Result{Timestamp: time.Now(), Method: st.Method, Error: "template", FailPhase: "template"}
+		} else {
+			var k kept
+			res = r.send(ctx, w, req, &k)
+			if res.Error == "" && res.Code < 400 {
+				if err := extractVars(st.extract, k, vars); err != nil {
+					res.Error, res.FailPhase = "extract", "extract"
+				}
+			}
+		}
+		res.Step, res.Iteration = st.Name, iteration
+
+		failed := res.Error != "" || res.Code >= 400
+		if failed {
+			res.Outcome = "failed"
+		} else if i == len(steps)-1 {
+			res.Outcome = "ok"
+		}
+		out = append(out, res)
+		if failed {
+			break
+		}
+	}
+	return out
+}
+
+// stepRequest builds a step's request with variables expanded; target.headers
+// apply first, step headers override them.
+func (r *Runner) stepRequest(st scenarioStep, vars map[string]string) (*http.Request, error) {
+	url, err := config.ExpandVars(st.URL, vars)
+	if err != nil {
+		return nil, err
+	}
+	body, err := config.ExpandVars(st.payload, vars)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newRequest(st.Method, url, []byte(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.cfg.Target.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range st.Headers {
+		if v, err = config.ExpandVars(v, vars); err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// newRequest builds a request whose payload can be re-read: GetBody hands
+// every clone (and every redirect) a fresh reader, and ContentLength avoids
+// chunked encoding.
+func newRequest(method, url string, payload []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 {
+		req.ContentLength = int64(len(payload))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(payload)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+	return req, nil
+}
+
+// extractVars sets every extracted variable from the response, failing on
+// the first source that doesn't match.
+func extractVars(extract map[string]config.Extractor, k kept, vars map[string]string) error {
+	var doc any
+	parsed := false
+	for name, ex := range extract {
+		switch ex.Kind {
+		case "header":
+			v := k.header.Get(ex.Field)
+			if v == "" {
+				return fmt.Errorf("%s: header %s missing", name, ex.Field)
+			}
+			vars[name] = v
+		case "regex":
+			m := ex.Re.FindSubmatch(k.body)
+			if m == nil {
+				return fmt.Errorf("%s: no match", name)
+			}
+			v := m[0] // whole match, or the first group when there is one
+			if len(m) > 1 {
+				v = m[1]
+			}
+			vars[name] = string(v)
+		case "json":
+			if !parsed {
+				if err := json.Unmarshal(k.body, &doc); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				parsed = true
+			}
+			v, err := jsonPath(doc, ex.Path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			vars[name] = v
+		}
+	}
+	return nil
+}
+
+// jsonPath walks object keys and array indexes; scalars are returned as
+// text, objects and arrays as JSON.
+func jsonPath(doc any, path []string) (string, error) {
+	cur := doc
+	for _, p := range path {
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[p]
+			if !ok {
+				return "", fmt.Errorf("key %q not found", p)
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("index %q out of range", p)
+			}
+			cur = node[i]
+		default:
+			return "", fmt.Errorf("cannot descend into %q", p)
+		}
+	}
+	switch v := cur.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", fmt.Errorf("value is null")
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,126 @@
+package attack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"shard/internal/config"
+)
+
+// token is one unit of scheduled work: when it was planned and in which
+// stage, and for a load.mode search, the step it belongs to.
+type token struct {
+	planned time.Time
+	stage   string
+	step    *searchStep
+}
+
+// schedule dispatches work tokens following the run plan until it ends or ctx
+// is cancelled. Every token's planned time is derived from the previous planned
+// time (never from the wall clock), so the schedule is absolute: when the loop
+// falls behind (slow wakeups, full queue) all overdue tokens are sent at once
+// in a batch and the achieved rate still tracks the configured one instead of
+// being capped by timer resolution. A live rate change applies to the steady
+// stage and takes effect from the next token; an extension (see Extend)
+// lengthens the plan and pushes out the deadline. A limit above 0
+// (load.total_requests) ends the schedule after that many tokens, even
+// before the deadline. Every token carries step; schedule returns how many
+// it sent.
+func (r *Runner) schedule(ctx context.Context, workCh chan<- token, plan []config.Stage, limit int, step *searchStep) int {
+	start := time.Now()
+	total := config.PlanDuration(plan)
+	deadline := start.Add(total)
+	stop := time.NewTimer(total)
+	defer stop.Stop()
+
+	var steadyOverride float64
+	// rateAt returns the stage and its target rate at offset off into the run.
+	rateAt := func(off time.Duration) (string, float64) {
+		var begin time.Duration
+		for _, s := range plan {
+			if off < begin+s.Duration {
+				if s.Name == "steady" && steadyOverride > 0 {
+					return s.Name, steadyOverride
+				}
+				frac := float64(off-begin) / float64(s.Duration)
+				return s.Name, s.FromRate + (s.ToRate-s.FromRate)*frac
+			}
+			begin += s.Duration
+		}
+		return "", 0
+	}
+
+	next := start
+	sent := 0
+	stage, _ := rateAt(0)
+	advance := func() {
+		_, rate := rateAt(next.Sub(start))
+		if rate <= 0 {
+			rate = 1
+		}
+		next = next.Add(time.Duration(float64(time.Second) / rate))
+		stage, _ = rateAt(next.Sub(start))
+	}
+
+	extend := func(by time.Duration) {
+		plan = config.ExtendPlan(plan, by)
+		deadline = deadline.Add(by)
+		stopTimer(stop)
+		stop.Reset(time.Until(deadline))
+	}
+
+	wait := time.NewTimer(time.Hour)
+	defer wait.Stop()
+
+	for next.Before(deadline) {
+		if d := time.Until(next); d > 0 {
+			wait.Reset(d)
+			select {
+			case <-ctx.Done():
+				return sent
+			case <-stop.C:
+				return sent
+			case newRate := <-r.rateCh:
+				stopTimer(wait)
+				steadyOverride = float64(newRate)
+				continue
+			case by := <-r.extendCh:
+				stopTimer(wait)
+				extend(by)
+				continue
+			case <-wait.C:
+			}
+		}
+
+		// send everything that is due now, catching up if we are behind
+		now := time.Now()
+		for !next.After(now) && next.Before(deadline) {
+			select {
+			case workCh <- token{planned: next, stage: stage, step: step}:
+				if sent++; sent == limit {
+					r.annotate(fmt.Sprintf("load.total_requests reached: %d requests scheduled in %s", sent, time.Since(start).Round(time.Second)))
+					return sent
+				}
+				advance()
+			case by := <-r.extendCh:
+				extend(by)
+			case <-ctx.Done():
+				return sent
+			case <-stop.C:
+				return sent
+			}
+		}
+	}
+	return sent
+}
+
+// stopTimer stops t and discards a pending fire, if any.
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package attack
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SchemaVersion is written as "v" on every result record. Version 1 files have
+// no "v" and encode durations as integer nanoseconds; version 2 encodes them
+// as float milliseconds.
+const SchemaVersion = 2
+
+// resultFields has Result's fields without its JSON methods.
+type resultFields Result
+
+type wirePhases struct {
+	DNS          float64 `json:"dns"`
+	Connect      float64 `json:"connect"`
+	ProxyConnect float64 `json:"proxy_connect,omitempty"`
+	TLS          float64 `json:"tls"`
+	TTFB         float64 `json:"ttfb"`
+	Total        float64 `json:"total"`
+}
+
+func toMillis(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// MarshalJSON writes a version 2 record.
+func (r Result) MarshalJSON() ([]byte, error) {
+	w := struct {
+		V int `json:"v"`
+		resultFields
+		SchedLag  float64    `json:"sched_lag"`
+		Phases    wirePhases `json:"phases"`
+		DateSkew  *float64   `json:"date_skew,omitempty"`
+		Early     float64    `json:"early_hints_at,omitempty"`
+		Decode    float64    `json:"decode,omitempty"`
+		PingRTT   *float64   `json:"ping_rtt,omitempty"`
+		Scheduled *time.Time `json:"scheduled,omitempty"`
+	}{
+		V:            SchemaVersion,
+		resultFields: resultFields(r),
+		SchedLag:     toMillis(r.SchedLag),
+		Phases: wirePhases{
+			DNS:          toMillis(r.Phases.DNS),
+			Connect:      toMillis(r.Phases.Connect),
+			ProxyConnect: toMillis(r.Phases.ProxyConnect),
+			TLS:          toMillis(r.Phases.TLS),
+			TTFB:         toMillis(r.Phases.TTFB),
+			Total:        toMillis(r.Phases.Total),
+		},
+		Early:  toMillis(r.EarlyHintsAt),
+		Decode: toMillis(r.Decode),
+	}
+	if r.DateSkew != nil {
+		ms := toMillis(*r.DateSkew)
+		w.DateSkew = &ms
+	}
+	if r.PingRTT != nil {
+		ms := toMillis(*r.PingRTT)
+		w.PingRTT = &ms
+	}
+	if !r.Scheduled.IsZero() {
+		w.Scheduled = &r.Scheduled
+	}
+	return json.Marshal(w)
+}
+
+// UnmarshalJSON reads both version 2 and legacy version 1 records.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	w := struct {
+		V int `json:"v"`
+		*resultFields
+		SchedLag float64    `json:"sched_lag"`
+		Phases   wirePhases `json:"phases"`
+		DateSkew *float64   `json:"date_skew,omitempty"`
+		Early    float64    `json:"early_hints_at,omitempty"`
+		Decode   float64    `json:"decode,omitempty"`
+		PingRTT  *float64   `json:"ping_rtt,omitempty"`
+	}{resultFields: (*resultFields)(r)}
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+
+	unit := float64(time.Millisecond)
+	if w.V < 2 {
+		unit = 1 // v1: nanoseconds
+	}
+	dur := func(v float64) time.Duration { return time.Duration(v * unit) }
+	r.SchedLag = dur(w.SchedLag)
+	r.Phases = PhaseTimings{
+		DNS:          dur(w.Phases.DNS),
+		Connect:      dur(w.Phases.Connect),
+		ProxyConnect: dur(w.Phases.ProxyConnect),
+		TLS:          dur(w.Phases.TLS),
+		TTFB:         dur(w.Phases.TTFB),
+		Total:        dur(w.Phases.Total),
+	}
+	r.EarlyHintsAt = dur(w.Early)
+	r.Decode = dur(w.Decode)
+	r.DateSkew = nil
+	if w.DateSkew != nil {
+		d := dur(*w.DateSkew)
+		r.DateSkew = &d
+	}
+	r.PingRTT = nil
+	if w.PingRTT != nil {
+		d := dur(*w.PingRTT)
+		r.PingRTT = &d
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package attack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"shard/internal/config"
+)
+
+// searchMinSent is the share of a step's requests Shard must manage to send
+// for the step to pass: a queue that stays full means the rate is not
+// sustained, whatever the latencies of the requests that did go out.
+const searchMinSent = 0.9
+
+// SearchRecord closes the results of a load.mode search: every step in the
+// order it ran, and the highest rate that passed.
+type SearchRecord struct {
+	Type      string       `json:"type"` // always "search"
+	Timestamp time.Time    `json:"ts"`
+	MaxRate   int          `json:"max_rate"` // 0 when no step passed
+	Stopped   string       `json:"stopped"`  // why the search ended
+	Steps     []SearchStep `json:"steps"`
+}
+
+// SearchStep is the verdict on one step. P99 is in milliseconds.
+type SearchStep struct {
+	Rate      int     `json:"rate"`
+	Requests  int     `json:"requests"`
+	ErrorRate float64 `json:"error_rate"`
+	P99       float64 `json:"p99"`
+	SentShare float64 `json:"sent_share"` // of the requests the rate called for
+	OK        bool    `json:"ok"`
+	Reason    string  `json:"reason,omitempty"` // why it failed
+}
+
+// searchStep tracks the requests of one step. Its tokens carry it, so
+// every result is tagged with the step that sent it.
+type searchStep struct {
+	rate    int
+	sent    int
+	done    atomic.Int64 // scheduled requests finished
+	results atomic.Int64
+	failed  atomic.Int64
+	latency latencyHistogram
+}
+
+func (s *searchStep) observe(res Result, scheduled bool) {
+	if scheduled {
+		defer s.done.Add(1)
+	}
+	if res.Error == "client_abort" {
+		return
+	}
+	s.results.Add(1)
+	if res.Error != "" {
+		s.failed.Add(1)
+	}
+	s.latency.Add(res.Phases.Total)
+}
+
+// settle waits until every request of the step has finished, for at most
+// limit.
+func (s *searchStep) settle(ctx context.Context, limit time.Duration) {
+	deadline := time.Now().Add(limit)
+	for s.done.Load() < int64(s.sent) && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func (s *searchStep) verdict(cfg *config.Search, d time.Duration) SearchStep {
+	v := SearchStep{Rate: s.rate, Requests: int(s.results.Load())}
+	if v.Requests > 0 {
+		v.ErrorRate = float64(s.failed.Load()) / float64(v.Requests)
+		v.P99 = s.latency.Quantile(0.99)
+	}
+	v.SentShare = float64(s.sent) / (float64(s.rate) * d.Seconds())
+	switch {
+	case v.Requests == 0:
+		v.Reason = "no results"
+	case v.ErrorRate > cfg.MaxErrorRate:
+		v.Reason = fmt.Sprintf("error rate %.2f%% > %.2f%%", v.ErrorRate*100, cfg.MaxErrorRate*100)
+	case cfg.MaxP99Ms > 0 && v.P99 > cfg.MaxP99Ms:
+		v.Reason = fmt.Sprintf("p99 %gms > %gms", v.P99, cfg.MaxP99Ms)
+	case v.SentShare < searchMinSent:
+		v.Reason = fmt.Sprintf("only %.0f%% of the requests could be sent", v.SentShare*100)
+	}
+	v.OK = v.Reason == ""
+	return v
+}
+
+// search runs a load.mode search: the plan's warmup, then flat steps at
+// rates growing by the search factor until one fails, then bisection
+// between the last passing and the first failing rate. A step's requests
+// all finish before the next step starts. load.max_run_time ends the
+// search before a step that would not fit.
+func (r *Runner) search(ctx context.Context, workCh chan<- token, plan []config.Stage, term io.Writer) *SearchRecord {
+	r.mu.Lock()
+	cfg := *r.cfg.Load.Search
+	timeout, _ := time.ParseDuration(r.cfg.Load.Timeout)
+	maxRun, _ := time.ParseDuration(r.cfg.Load.MaxRunTime)
+	r.mu.Unlock()
+	stepFor, _ := time.ParseDuration(cfg.StageDuration)
+	start := time.Now()
+
+	if len(plan) > 0 && plan[0].Name == "warmup" {
+		r.schedule(ctx, workCh, plan[:1], 0, nil)
+	}
+
+	rec := &SearchRecord{Type: "search"}
+	// try runs one step; ran is false when the search has to end first
+	try := func(rate int) (ok, ran bool) {
+		if maxRun > 0 && time.Since(start)+stepFor > maxRun {
+			rec.Stopped = "load.max_run_time reached"
+			return false, false
+		}
+		step := &searchStep{rate: rate}
+		stage := []config.Stage{{Name: "search", Duration: stepFor, FromRate: float64(rate), ToRate: float64(rate)}}
+		step.sent = r.schedule(ctx, workCh, stage, 0, step)
+		step.settle(ctx, timeout+time.Second)
+		if ctx.Err() != nil {
+			rec.Stopped = "interrupted"
+			return false, false
+		}
+		v := step.verdict(&cfg, stepFor)
+		rec.Steps = append(rec.Steps, v)
+		msg := fmt.Sprintf("search step %d/s: passed (p99 %gms, error rate %.2f%%)", rate, v.P99, v.ErrorRate*100)
+		if !v.OK {
+			msg = fmt.Sprintf("search step %d/s: failed, %s", rate, v.Reason)
+		}
+		r.annotate(msg)
+		if term != nil {
+			fmt.Fprintf(term, "\n🔎 %s\n", msg)
+		}
+		return v.OK, true
+	}
+
+	good, bad := 0, 0
+	for rate := cfg.StartRate; bad == 0; {
+		ok, ran := try(rate)
+		if !ran {
+			return r.endSearch(rec, good, term)
+		}
+		if !ok {
+			bad = rate
+			break
+		}
+		good = rate
+		if cfg.MaxRate > 0 && rate >= cfg.MaxRate {
+			rec.Stopped = "load.search.max_rate reached"
+			return r.endSearch(rec, good, term)
+		}
+		rate = int(math.Ceil(float64(rate) * cfg.Factor))
+		if cfg.MaxRate > 0 {
+			rate = min(rate, cfg.MaxRate)
+		}
+	}
+	for i := 0; i < cfg.Iterations && bad-good > 1; i++ {
+		mid := (good + bad) / 2
+		ok, ran := try(mid)
+		if !ran {
+			return r.endSearch(rec, good, term)
+		}
+		if ok {
+			good = mid
+		} else {
+			bad = mid
+		}
+	}
+	rec.Stopped = fmt.Sprintf("bisected to %d-%d/s", good, bad)
+	if bad-good <= 1 {
+		rec.Stopped = "converged"
+	}
+	return r.endSearch(rec, good, term)
+}
+
+// endSearch records the outcome of a search and announces it.
+func (r *Runner) endSearch(rec *SearchRecord, good int, term io.Writer) *SearchRecord {
+	rec.Timestamp = time.Now()
+	rec.MaxRate = good
+	msg := fmt.Sprintf("search found no sustainable rate (%s)", rec.Stopped)
+	for _, s := range rec.Steps {
+		if s.Rate == good && s.OK {
+			msg = fmt.Sprintf("max sustainable rate %d/s: p99 %gms, error rate %.2f%% (%s)", good, s.P99, s.ErrorRate*100, rec.Stopped)
+		}
+	}
+	r.annotate(msg)
+	if term != nil {
+		fmt.Fprintf(term, "\n🏁 %s\n", msg)
+	}
+	return rec
+}
@@ -0,0 +1,180 @@
+package attack
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"shard/internal/config"
+)
+
+// ResultSink receives encoded JSONL records. Close must flush everything
+// written so far; it is called on normal completion and on interrupt alike.
+type ResultSink interface {
+	io.Writer
+	Close() error
+}
+
+// OpenSink picks a sink for path: "-" is stdout, out.Codec selects gzip or
+// zstd compression, and a per-file size limit selects rotation.
+// segmentSize is in bytes; 0 disables rotation.
+func OpenSink(path string, out config.Output, segmentSize int64) (ResultSink, error) {
+	if path == "-" {
+		return &stdoutSink{w: bufio.NewWriter(os.Stdout)}, nil
+	}
+	codec := out.Codec(path)
+	if segmentSize > 0 {
+		return newRotatingSink(path, codec, segmentSize)
+	}
+	return openFileSink(path, codec)
+}
+
+// stdoutSink buffers records to stdout; closing flushes but leaves stdout open.
+type stdoutSink struct {
+	w *bufio.Writer
+}
+
+func (s *stdoutSink) Write(p []byte) (int, error) { return s.w.Write(p) }
+func (s *stdoutSink) Close() error                { return s.w.Flush() }
+
+// OpenProgress opens the progress log at path, truncating it unless
+// appendTo is set. An empty path disables the log: lines are discarded.
+func OpenProgress(path string, appendTo bool) (io.WriteCloser, error) {
+	if path == "" {
+		return nopCloser{io.Discard}, nil
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err == nil && appendTo {
+		// keep runs apart; each already ends with "---- Test completed ----"
+		fmt.Fprintf(f, "---- Test started %s ----\n", time.Now().Format(time.RFC3339))
+	}
+	return f, err
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// fileSink writes to a buffered file, optionally gzip- or zstd-compressed.
+type fileSink struct {
+	f  *os.File
+	bw *bufio.Writer
+	zw io.WriteCloser
+	w  io.Writer
+}
+
+func openFileSink(path, codec string) (*fileSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &fileSink{f: f, bw: bufio.NewWriterSize(f, 64*1024)}
+	s.w = s.bw
+	if s.zw, err = CompressWriter(s.bw, codec); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if s.zw != nil {
+		s.w = s.zw
+	}
+	return s, nil
+}
+
+// CompressWriter wraps w in the results codec ("gzip" or "zstd"). It returns
+// nil for plain output; closing the writer does not close w.
+func CompressWriter(w io.Writer, codec string) (io.WriteCloser, error) {
+	switch codec {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		// one encoder goroutine: results are written by a single goroutine anyway
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		return zw, nil
+	}
+	return nil, nil
+}
+
+func (s *fileSink) Write(p []byte) (int, error) { return s.w.Write(p) }
+
+func (s *fileSink) Close() error {
+	var errs []error
+	if s.zw != nil {
+		errs = append(errs, s.zw.Close())
+	}
+	errs = append(errs, s.bw.Flush(), s.f.Close())
+	return errors.Join(errs...)
+}
+
+// rotatingSink splits output into numbered segments of at most limit bytes
+// (uncompressed): results.jsonl becomes results-0001.jsonl, results-0002.jsonl, ...
+type rotatingSink struct {
+	path  string
+	codec string
+	limit int64
+
+	cur      *fileSink
+	size     int64
+	segments int
+}
+
+func newRotatingSink(path, codec string, limit int64) (*rotatingSink, error) {
+	s := &rotatingSink{path: path, codec: codec, limit: limit}
+	if err := s.next(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *rotatingSink) Write(p []byte) (int, error) {
+	if s.size > 0 && s.size+int64(len(p)) > s.limit {
+		if err := s.cur.Close(); err != nil {
+			return 0, err
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.cur.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *rotatingSink) next() error {
+	s.segments++
+	f, err := openFileSink(s.segmentPath(s.segments), s.codec)
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+	s.cur, s.size = f, 0
+	return nil
+}
+
+func (s *rotatingSink) segmentPath(n int) string {
+	return config.SegmentPath(s.path, s.codec, n)
+}
+
+// Segments returns how many files the sink has written so far.
+func (s *rotatingSink) Segments() int { return s.segments }
+
+// Pattern returns a glob matching every segment, for reporting.
+func (s *rotatingSink) Pattern() string {
+	first := s.segmentPath(1)
+	i := strings.LastIndex(first, "-0001")
+	return first[:i] + "-*" + first[i+len("-0001"):]
+}
+
+func (s *rotatingSink) Close() error { return s.cur.Close() }
@@ -0,0 +1,179 @@
+package attack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"shard/internal/config"
+)
+
+// sinkRecords returns n encoded result lines shaped like a real run's.
+func sinkRecords(t testing.TB, n int) [][]byte {
+	t.Helper()
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	lines := make([][]byte, n)
+	for i := range lines {
+		res := Result{
+			Timestamp:  start.Add(time.Duration(i) * time.Millisecond),
+			Method:     "GET",
+			Code:       200,
+			Reused:     i%50 != 0,
+			RemoteAddr: "10.0.0.7:443",
+			Worker:     i%16 + 1,
+			Conn:       uint64(i%16 + 1),
+			Bytes:      int64(512 + i%97),
+			Phases:     PhaseTimings{Total: time.Duration(8000+i%5000) * time.Microsecond},
+		}
+		if i%40 == 0 {
+			res.Code = 503
+		}
+		data, err := json.Marshal(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = append(data, '\n')
+	}
+	return lines
+}
+
+// readCodec decompresses path as codec wrote it.
+func readCodec(t *testing.T, path, codec string) []byte {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch codec {
+	case "gzip":
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	case "zstd":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return data
+}
+
+func TestSinkRoundTrip(t *testing.T) {
+	lines := sinkRecords(t, 5000)
+	want := bytes.Join(lines, nil)
+
+	for _, codec := range []string{"", "gzip", "zstd"} {
+		t.Run("codec="+codec, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results.jsonl")
+			sink, err := OpenSink(path, config.Output{Compression: codec}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, l := range lines {
+				if _, err := sink.Write(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readCodec(t, path, codec); !bytes.Equal(got, want) {
+				t.Fatalf("read back %d bytes, wrote %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestRotatingSinkRoundTrip(t *testing.T) {
+	lines := sinkRecords(t, 5000)
+	want := bytes.Join(lines, nil)
+
+	for _, codec := range []string{"gzip", "zstd"} {
+		t.Run(codec, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results.jsonl")
+			sink, err := OpenSink(path, config.Output{Compression: codec}, int64(len(want)/3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, l := range lines {
+				if _, err := sink.Write(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+			n := sink.(*rotatingSink).Segments()
+			if n < 3 {
+				t.Fatalf("%d segments, want at least 3", n)
+			}
+			var got []byte
+			for i := 1; i <= n; i++ {
+				seg := readCodec(t, config.SegmentPath(path, codec, i), codec)
+				if len(seg) == 0 || seg[len(seg)-1] != '\n' {
+					t.Fatalf("segment %d does not end on a whole record", i)
+				}
+				got = append(got, seg...)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("read back %d bytes over %d segments, wrote %d", len(got), n, len(want))
+			}
+		})
+	}
+}
+
+// BenchmarkSinkCodec compares the results codecs: throughput in uncompressed
+// bytes, and the compressed size as a share of it.
+func BenchmarkSinkCodec(b *testing.B) {
+	lines := sinkRecords(b, 10000)
+	var size int64
+	for _, l := range lines {
+		size += int64(len(l))
+	}
+	for _, codec := range []string{"", "gzip", "zstd"} {
+		name := codec
+		if name == "" {
+			name = "plain"
+		}
+		b.Run(name, func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "results.jsonl")
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sink, err := OpenSink(path, config.Output{Compression: codec}, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, l := range lines {
+					sink.Write(l)
+				}
+				if err := sink.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(fi.Size())/float64(size)*100, "%size")
+		})
+	}
+}
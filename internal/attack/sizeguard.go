@@ -0,0 +1,132 @@
+package attack
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"shard/internal/config"
+)
+
+const (
+	// projectAfter is how long rows are measured before output size is projected.
+	projectAfter = time.Minute
+	// sizeHeadroom is the fraction of the limit at which the guard acts.
+	sizeHeadroom = 0.9
+)
+
+// sizeGuard sits between the JSON encoder and the result sink and enforces
+// output.max_file_size. It counts every byte written and, depending on policy,
+// thins successful rows or asks the run to abort. The "rotate" policy is
+// carried out by a compressing rotatingSink; the guard only reports on it.
+type sizeGuard struct {
+	sink   ResultSink
+	limit  int64 // 0 disables the guard
+	policy string
+
+	total int64 // bytes across all segments
+	rows  int64
+
+	keepEvery int   // sampling: keep one in keepEvery successful rows
+	seen      int64 // successful rows offered while sampling
+	skipped   int64
+	aborted   bool
+}
+
+func newSizeGuard(sink ResultSink, limit int64, policy string) *sizeGuard {
+	return &sizeGuard{sink: sink, limit: limit, policy: policy, keepEvery: 1}
+}
+
+// Write implements io.Writer. Each call is one encoded record.
+func (g *sizeGuard) Write(p []byte) (int, error) {
+	n, err := g.sink.Write(p)
+	g.total += int64(n)
+	g.rows++
+	return n, err
+}
+
+// keep reports whether a result should be written under the current sampling rate.
+// Failures are always kept.
+func (g *sizeGuard) keep(res Result) bool {
+	if g.keepEvery <= 1 || res.Error != "" {
+		return true
+	}
+	g.seen++
+	if g.seen%int64(g.keepEvery) == 0 {
+		return true
+	}
+	g.skipped++
+	return false
+}
+
+// check projects the final output size and returns an annotation message when
+// the guard took action. elapsed and remaining are relative to the run's duration.
+func (g *sizeGuard) check(elapsed, remaining time.Duration, rate int) string {
+	if g.limit <= 0 || g.aborted || g.policy == "rotate" || g.rows == 0 {
+		return ""
+	}
+	budget := float64(g.limit)*sizeHeadroom - float64(g.total)
+	avgRow := float64(g.total) / float64(g.rows)
+	projectedRest := avgRow * float64(rate) * remaining.Seconds() / float64(g.keepEvery)
+	if elapsed < projectAfter && budget > 0 {
+		return ""
+	}
+	if projectedRest <= budget {
+		return ""
+	}
+
+	if g.policy == "abort" {
+		g.aborted = true
+		return fmt.Sprintf("output size guard: aborting, %s written and ~%s more projected exceeds max_file_size %s",
+			config.FormatSize(g.total), config.FormatSize(int64(projectedRest)), config.FormatSize(g.limit))
+	}
+
+	// sample: thin successful rows so the rest of the run fits in what is left
+	if budget <= 0 {
+		if g.keepEvery == math.MaxInt32 {
+			return ""
+		}
+		g.keepEvery = math.MaxInt32
+		return fmt.Sprintf("output size guard: SAMPLING stopped writing successful results, only failures are kept (%s written, limit %s)",
+			config.FormatSize(g.total), config.FormatSize(g.limit))
+	}
+	keep := int(math.Ceil(projectedRest * float64(g.keepEvery) / budget))
+	if keep <= g.keepEvery {
+		return ""
+	}
+	g.keepEvery = keep
+	return fmt.Sprintf("output size guard: SAMPLING successful results 1/%d from now on (%s written, limit %s)",
+		keep, config.FormatSize(g.total), config.FormatSize(g.limit))
+}
+
+// remaining is how much of a run planned to last duration is left after
+// elapsed. An unbounded run has nothing left to project, so its guard acts
+// on what was written alone.
+func remaining(duration, elapsed time.Duration) time.Duration {
+	if config.IsUnbounded(duration) {
+		return 0
+	}
+	return duration - elapsed
+}
+
+// summary describes what the guard did over the run, or "" if it never acted.
+func (g *sizeGuard) summary() string {
+	rot, rotating := g.sink.(*rotatingSink)
+	switch {
+	case g.policy == "rotate" && rotating && rot.Segments() > 1:
+		return fmt.Sprintf("output size guard: rotated into %d compressed segment(s) of %s, read them back with -in '%s'",
+			rot.Segments(), config.FormatSize(g.limit), rot.Pattern())
+	case g.skipped > 0 && g.keepEvery == math.MaxInt32:
+		return fmt.Sprintf("output size guard: %d successful result(s) were not written", g.skipped)
+	case g.skipped > 0:
+		return fmt.Sprintf("output size guard: %d successful result(s) were sampled out (final rate 1/%d)", g.skipped, g.keepEvery)
+	case g.aborted:
+		return "output size guard: run aborted at the size limit"
+	}
+	return ""
+}
+
+// Close flushes and closes the underlying sink.
+func (g *sizeGuard) Close() error {
+	return g.sink.Close()
+}
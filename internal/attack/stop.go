@@ -0,0 +1,43 @@
+package attack
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// StopRecord is written at the end of a run whose stop policy discarded
+// queued work, so reports can reconcile totals with rate × duration.
+type StopRecord struct {
+	Type      string    `json:"type"` // always "stop"
+	Timestamp time.Time `json:"ts"`
+	Policy    string    `json:"policy"`
+	Discarded int64     `json:"discarded"` // scheduled requests never sent
+}
+
+// stopGate applies load.stop_policy to tokens workers dequeue after the
+// scheduler has stopped.
+type stopGate struct {
+	policy    string
+	grace     time.Duration
+	stoppedAt atomic.Int64 // unix nanos; 0 while the scheduler runs
+	discarded atomic.Int64
+}
+
+func newStopGate(policy string, grace time.Duration) *stopGate {
+	return &stopGate{policy: policy, grace: grace}
+}
+
+func (g *stopGate) stop() { g.stoppedAt.Store(time.Now().UnixNano()) }
+
+// admit reports whether a dequeued token should still be sent.
+func (g *stopGate) admit() bool {
+	at := g.stoppedAt.Load()
+	if at == 0 || g.policy == "drain" {
+		return true
+	}
+	if g.policy == "deadline" && time.Now().Before(time.Unix(0, at).Add(g.grace)) {
+		return true
+	}
+	g.discarded.Add(1)
+	return false
+}
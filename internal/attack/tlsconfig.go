@@ -0,0 +1,75 @@
+package attack
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"shard/internal/config"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the client TLS config from the tls section. Unreadable
+// certificates fail here, before any load is sent.
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	t := cfg.TLS
+	tc := &tls.Config{
+		InsecureSkipVerify: cfg.Load.InsecureTLS,
+		ServerName:         t.ServerName,
+		MinVersion:         tlsVersions[t.MinVersion],
+	}
+	// an empty certificate tells the server we have none
+	clientCert := &tls.Certificate{}
+	if t.ClientCert != "" {
+		pair, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tls.client_cert %s / tls.client_key %s: %w", config.ErrInvalid, t.ClientCert, t.ClientKey, err)
+		}
+		clientCert = &pair
+	}
+	tc.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		if t.ClientCert == "" {
+			if missing, ok := info.Context().Value(missingCertKey{}).(*atomic.Bool); ok {
+				missing.Store(true)
+			}
+		}
+		return clientCert, nil
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tls.ca_file: %w", config.ErrInvalid, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: tls.ca_file %s: no PEM certificates found", config.ErrInvalid, t.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+	return tc, nil
+}
+
+// missingCertKey carries an *atomic.Bool in the request context that is set
+// when the server asks for a client certificate and none is configured.
+// Servers often just drop such connections, so the eventual error (e.g. a
+// broken pipe under HTTP/2) doesn't say why on its own.
+type missingCertKey struct{}
+
+// isClientAuthError reports whether the server rejected the client certificate,
+// going by the alert it sent ("remote error: tls: bad certificate", ...).
+// Under TLS 1.3 the alert arrives with the first read, so this can surface
+// after the handshake looked successful.
+func isClientAuthError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "remote error: tls:") &&
+		(strings.Contains(msg, "certificate") || strings.Contains(msg, "access denied"))
+}
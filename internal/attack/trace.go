@@ -5,15 +5,108 @@ import "time"
 type PhaseTimings struct {
 	DNS     time.Duration `json:"dns"`
 	Connect time.Duration `json:"connect"`
-	TLS     time.Duration `json:"tls"`
-	TTFB    time.Duration `json:"ttfb"`
-	Total   time.Duration `json:"total"`
+	// ProxyConnect is the CONNECT round trip that opens a tunnel through an
+	// HTTP proxy, after Connect reached the proxy and before TLS to the
+	// origin; zero when the request used no tunnel.
+	ProxyConnect time.Duration `json:"proxy_connect,omitempty"`
+	TLS          time.Duration `json:"tls"`
+	TTFB         time.Duration `json:"ttfb"`
+	Total        time.Duration `json:"total"`
 }
+
+// Result is one request outcome, one JSONL line per result; see schema.go for the encoding.
 type Result struct {
-	Timestamp time.Time    `json:"ts"`
-	Code      int          `json:"code"`
-	Error     string       `json:"error,omitempty"`
-	FailPhase string       `json:"fail_phase,omitempty"`
-	Reused    bool         `json:"reused"`
-	Phases    PhaseTimings `json:"phases"`
+	Timestamp  time.Time `json:"ts"`
+	Method     string    `json:"method,omitempty"`
+	Stage      string    `json:"stage,omitempty"`      // plan stage when not steady: warmup, ramp, cooldown, search
+	StageRate  int       `json:"stage_rate,omitempty"` // the rate of the search step that sent the request
+	Code       int       `json:"code"`
+	Error      string    `json:"error,omitempty"`
+	FailPhase  string    `json:"fail_phase,omitempty"`
+	Reused     bool      `json:"reused"`
+	AfterIdle  bool      `json:"after_idle,omitempty"`  // new connection after the pool idled out
+	RemoteAddr string    `json:"remote_addr,omitempty"` // backend dialed, even when the connect failed
+	Worker     int       `json:"worker,omitempty"`      // 1-based worker that sent the request
+	Conn       uint64    `json:"conn,omitempty"`        // 1-based id of the connection used, 0 if none
+	Redirects  int       `json:"redirects,omitempty"`
+	Attempts   int       `json:"attempts,omitempty"` // tries under load.retries, including the first
+	// Undelivered marks requests that never reached the server: nothing was
+	// written because resolving, connecting or the handshake failed.
+	Undelivered bool  `json:"undelivered,omitempty"`
+	Bytes       int64 `json:"bytes,omitempty"`      // response body bytes received, decoded
+	WireBytes   int64 `json:"wire_bytes,omitempty"` // body bytes as they crossed the network, when Shard decoded the body
+	// Encoding is the response's Content-Encoding when Shard decoded it, and
+	// Decode the client time that took, net of waiting for the network.
+	// Identity marks requests sent under load.identity_fraction.
+	Encoding string        `json:"encoding,omitempty"`
+	Decode   time.Duration `json:"decode,omitempty"`
+	Identity bool          `json:"identity,omitempty"`
+	// Scheduled is when the scheduler planned the request; Timestamp is when
+	// it was actually dispatched. Only set on scheduled requests, not on later
+	// scenario steps.
+	Scheduled  time.Time      `json:"scheduled,omitempty"`
+	SchedLag   time.Duration  `json:"sched_lag"` // how late the request started vs its planned dispatch time
+	Phases     PhaseTimings   `json:"phases"`
+	BodySample string         `json:"body_sample,omitempty"` // truncated response body, see output.capture
+	DateSkew   *time.Duration `json:"date_skew,omitempty"`   // server Date minus client clock, with output.capture.headers
+	NoDate     bool           `json:"no_date,omitempty"`     // Date header absent or unparseable
+	// DNSTTL is the TTL in seconds the custom resolver (target.resolve.resolver)
+	// answered with, on requests whose connection resolved the host.
+	DNSTTL *uint32 `json:"dns_ttl,omitempty"`
+	// HeaderValues holds output.capture.numeric_headers found on the response.
+	HeaderValues map[string]float64 `json:"header_values,omitempty"`
+	// Informational lists 1xx statuses (e.g. 103 Early Hints) received before the
+	// final response; TTFB then measures the final response, and EarlyHintsAt
+	// is when the first 1xx arrived.
+	Informational []int         `json:"informational,omitempty"`
+	EarlyHintsAt  time.Duration `json:"early_hints_at,omitempty"`
+	Agent         string        `json:"agent,omitempty"`    // agent that sent the request in a distributed run
+	TraceID       string        `json:"trace_id,omitempty"` // W3C trace ID sent in traceparent, see output.traceparent
+	// RequestID is the ID sent under target.request_id, ServerRequestID the
+	// one the server answered with in target.echo_request_id_header.
+	RequestID       string `json:"request_id,omitempty"`
+	ServerRequestID string `json:"server_request_id,omitempty"`
+
+	// WebSocket runs only: MsgIndex is the 1-based message of the body file
+	// sent, PingRTT the round trip of a ping answered since the connection's
+	// previous message, and CloseCode the close code of a connection the
+	// server ended (1006 when it just dropped), on the message that was in
+	// flight or else on the one that opened its replacement.
+	MsgIndex  int            `json:"msg_index,omitempty"`
+	PingRTT   *time.Duration `json:"ping_rtt,omitempty"`
+	CloseCode int            `json:"close_code,omitempty"`
+
+	// Scenario runs only: the step, its iteration, and on the iteration's last
+	// executed step whether the whole chain succeeded ("ok" or "failed").
+	Step      string `json:"step,omitempty"`
+	Iteration int64  `json:"iteration,omitempty"`
+	Outcome   string `json:"outcome,omitempty"`
+}
+
+// Annotation marks a notable event (e.g. a live config reload) in the results stream.
+// Type is always "annotation" and is encoded first so readers can skip the record cheaply.
+type Annotation struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"ts"`
+	Message   string    `json:"message"`
+}
+
+// ProgressRecord is one live progress reading, written to the results at
+// every output.progress_interval tick and once more at the end, so the
+// progress line can be followed back from the file. Counts are cumulative;
+// Rate is the dispatch rate since the previous reading.
+type ProgressRecord struct {
+	Type      string           `json:"type"` // always "progress"
+	Timestamp time.Time        `json:"ts"`
+	Elapsed   float64          `json:"elapsed"` // seconds since the run started
+	Sent      int64            `json:"sent"`
+	Delivered int64            `json:"delivered"`
+	OK        int64            `json:"ok"`
+	Failed    int64            `json:"failed"`
+	Rate      float64          `json:"rate"`
+	AvgMs     float64          `json:"avg_ms"`                // mean latency of successful requests
+	FailAvgMs float64          `json:"fail_avg_ms,omitempty"` // mean time failed requests took
+	Workers   int64            `json:"workers,omitempty"`     // live pool size under auto concurrency
+	Fails     map[string]int64 `json:"fails,omitempty"`       // failures by phase
+	Families  map[string]int64 `json:"families"`              // successful responses by status family
 }
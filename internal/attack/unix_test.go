@@ -0,0 +1,62 @@
+package attack
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUnixSocket(t *testing.T) {
+	for _, tls := range []bool{false, true} {
+		name := "http"
+		if tls {
+			name = "https"
+		}
+		t.Run(name, func(t *testing.T) {
+			sock := filepath.Join(t.TempDir(), "api.sock")
+			ln, err := net.Listen("unix", sock)
+			if err != nil {
+				t.Skipf("unix sockets unavailable: %v", err)
+			}
+			var served, misrouted atomic.Int64
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served.Add(1)
+				// the URL still supplies the Host header and path
+				if r.Host != "api.internal" || r.URL.Path != "/health" {
+					misrouted.Add(1)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			srv.Listener.Close()
+			srv.Listener = ln
+			if tls {
+				srv.StartTLS()
+			} else {
+				srv.Start()
+			}
+			defer srv.Close()
+
+			// api.internal does not resolve: only the socket can serve it
+			cfg := testConfig(name + "://api.internal/health")
+			cfg.Target.UnixSocket = sock
+			cfg.Target.UnixTLS = tls
+			cfg.Load.InsecureTLS = tls // httptest's certificate is self-signed
+			results := runTest(t, cfg)
+
+			if n := served.Load(); n != int64(len(results)) {
+				t.Fatalf("the socket served %d requests, the run recorded %d", n, len(results))
+			}
+			if n := misrouted.Load(); n > 0 {
+				t.Fatalf("%d requests arrived with the wrong Host or path", n)
+			}
+			for _, res := range results {
+				if res.Code != http.StatusOK {
+					t.Fatalf("result %+v, want 200", res)
+				}
+			}
+		})
+	}
+}
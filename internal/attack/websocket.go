@@ -0,0 +1,480 @@
+package attack
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocketMethod is the method of every result of a target.protocol
+// websocket run: one message, not an HTTP request.
+const WebSocketMethod = "WS"
+
+// maxWSMessage bounds a message read back from the server.
+const maxWSMessage = 16 << 20
+
+// wsCloseWait is how long a closing connection waits for the server to
+// answer its close frame.
+const wsCloseWait = time.Second
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsTarget is what a WebSocket run sends, prepared once: the upgrade URL and
+// headers, and the messages to cycle through.
+type wsTarget struct {
+	url      *url.URL // with an http or https scheme
+	header   http.Header
+	messages [][]byte
+	next     atomic.Uint64
+	echo     bool
+	ping     time.Duration
+}
+
+// loadWebSocket prepares a target.protocol websocket run: the messages are
+// the lines of target.body_file with message_per_line, otherwise the whole
+// file (or one empty message without a file).
+func (r *Runner) loadWebSocket() (*wsTarget, error) {
+	u, err := url.Parse(r.cfg.Target.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse target url: %w", err)
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	ws := r.cfg.Target.WebSocket
+	t := &wsTarget{url: u, header: make(http.Header), echo: ws.ExpectEcho, ping: ws.PingEvery()}
+	for k, v := range r.cfg.Target.Headers {
+		t.header.Set(k, v)
+	}
+	var payload []byte
+	if r.cfg.Target.BodyFile != "" {
+		if payload, err = os.ReadFile(r.cfg.Target.BodyFile); err != nil {
+			return nil, fmt.Errorf("read body file: %w", err)
+		}
+	}
+	if !ws.MessagePerLine {
+		t.messages = [][]byte{payload}
+		return t, nil
+	}
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		if line = bytes.TrimSuffix(line, []byte("\r")); len(line) > 0 {
+			t.messages = append(t.messages, line)
+		}
+	}
+	if len(t.messages) == 0 {
+		return nil, errors.New("target.websocket.message_per_line: the body file has no lines")
+	}
+	return t, nil
+}
+
+// wsConn is one upgraded connection. Its reader goroutine answers pings,
+// times pongs and hands data messages to the worker; it ends, closing done,
+// when either side closes the connection.
+type wsConn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	id     uint64
+	remote string
+	wait   time.Duration // load.timeout, for writes
+
+	wmu      sync.Mutex // frames come from the worker and from the reader
+	msgs     chan []byte
+	done     chan struct{}
+	closing  atomic.Bool // we sent the close frame
+	code     int         // the close code, 1006 for a dropped connection; read after done
+	pingAt   atomic.Int64
+	pingRTT  atomic.Int64 // of the latest pong not yet reported
+	lastPing time.Time
+}
+
+// dialWebSocket connects through the runner's dialer and TLS settings and
+// upgrades the connection. res receives the timings (connect is the dial
+// plus the upgrade round trip, tls the handshake) and, when the server
+// refuses the upgrade, its status.
+func (r *Runner) dialWebSocket(w *worker, t *wsTarget, res *Result) (*wsConn, error) {
+	timeout, _ := time.ParseDuration(r.cfg.Load.Timeout)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	port := t.url.Port()
+	if port == "" {
+		port = "80"
+		if t.url.Scheme == "https" {
+			port = "443"
+		}
+	}
+	start := time.Now()
+	conn, err := r.dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.url.Hostname(), port))
+	if err != nil {
+		res.FailPhase = "connect"
+		return nil, err
+	}
+	c := &wsConn{id: connID(conn), remote: conn.RemoteAddr().String(), wait: timeout, lastPing: time.Now()}
+	res.RemoteAddr, res.Conn = c.remote, c.id
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	var tlsTook time.Duration
+	if t.url.Scheme == "https" {
+		cfg := &tls.Config{}
+		if tr, ok := r.client.Transport.(*http.Transport); ok && tr.TLSClientConfig != nil {
+			cfg = tr.TLSClientConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = t.url.Hostname()
+		}
+		cfg.NextProtos = []string{"http/1.1"}
+		tlsStart := time.Now()
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			res.FailPhase = "tls"
+			return nil, err
+		}
+		tlsTook = time.Since(tlsStart)
+		conn = tc
+	}
+
+	key := make([]byte, 16)
+	for i := range key {
+		key[i] = byte(rand.Uint32())
+	}
+	req := &http.Request{Method: http.MethodGet, URL: t.url, Host: t.url.Host, Header: t.header.Clone()}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	r.auth.apply(req.Header)
+	if jar := w.client.Jar; jar != nil {
+		for _, ck := range jar.Cookies(t.url) {
+			req.AddCookie(ck)
+		}
+	}
+	c.br = bufio.NewReader(conn)
+	var resp *http.Response
+	if err = req.Write(conn); err == nil {
+		resp, err = http.ReadResponse(c.br, req)
+	}
+	if err != nil {
+		conn.Close()
+		res.FailPhase = "ws_upgrade"
+		return nil, err
+	}
+	resp.Body.Close()
+	res.Code = resp.StatusCode
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		res.FailPhase = "ws_upgrade"
+		return nil, fmt.Errorf("upgrade refused with status %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		conn.Close()
+		res.FailPhase = "ws_upgrade"
+		return nil, errors.New("upgrade answered with a wrong Sec-WebSocket-Accept")
+	}
+	if jar := w.client.Jar; jar != nil {
+		jar.SetCookies(t.url, resp.Cookies())
+	}
+	conn.SetDeadline(time.Time{})
+	res.Phases.TLS = tlsTook
+	res.Phases.Connect = time.Since(start) - tlsTook
+
+	c.conn = conn
+	c.msgs = make(chan []byte, 64)
+	c.done = make(chan struct{})
+	go c.readLoop()
+	return c, nil
+}
+
+// wsAccept is the Sec-WebSocket-Accept a server must answer key with.
+func wsAccept(key []byte) string {
+	h := sha1.New()
+	h.Write([]byte(base64.StdEncoding.EncodeToString(key)))
+	h.Write([]byte("258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// write sends one masked frame, as every client frame must be.
+func (c *wsConn) write(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|op)
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	var mask [4]byte
+	binary.LittleEndian.PutUint32(mask[:], rand.Uint32())
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if c.wait > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.wait))
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// readFrame reads one frame, unmasking it if the server masked it.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	fin, op = head[0]&0x80 != 0, head[0]&0x0F
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxWSMessage {
+		return fin, op, nil, fmt.Errorf("frame of %d bytes is over the %d byte limit", n, maxWSMessage)
+	}
+	var mask [4]byte
+	masked := head[1]&0x80 != 0
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+func (c *wsConn) readLoop() {
+	defer close(c.done)
+	c.code = 1006 // unless a close frame says otherwise
+	var msg []byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsText, wsBinary:
+			msg = payload
+		case wsContinuation:
+			if msg = append(msg, payload...); len(msg) > maxWSMessage {
+				return
+			}
+		case wsPing:
+			c.write(wsPong, payload)
+			continue
+		case wsPong:
+			if sent := c.pingAt.Swap(0); sent != 0 {
+				c.pingRTT.Store(time.Now().UnixNano() - sent)
+			}
+			continue
+		case wsClose:
+			c.code = 1005 // no status in the frame
+			if len(payload) >= 2 {
+				c.code = int(binary.BigEndian.Uint16(payload))
+			}
+			if !c.closing.Swap(true) {
+				c.write(wsClose, payload[:min(len(payload), 2)])
+			}
+			return
+		default:
+			continue
+		}
+		if fin {
+			select {
+			case c.msgs <- msg:
+			default: // nobody waits for it; an unsolicited message
+			}
+			msg = nil
+		}
+	}
+}
+
+// closed reports whether the connection has ended.
+func (c *wsConn) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// ping sends a ping when the interval has passed and none is in flight.
+func (c *wsConn) ping(every time.Duration) {
+	if every <= 0 || time.Since(c.lastPing) < every || c.pingAt.Load() != 0 {
+		return
+	}
+	c.lastPing = time.Now()
+	c.pingAt.Store(c.lastPing.UnixNano())
+	c.write(wsPing, nil)
+}
+
+// close ends the connection with a normal closure frame and waits briefly
+// for the server's answer.
+func (c *wsConn) close() {
+	if !c.closing.Swap(true) {
+		c.write(wsClose, binary.BigEndian.AppendUint16(nil, 1000))
+	}
+	select {
+	case <-c.done:
+	case <-time.After(wsCloseWait):
+	}
+	c.conn.Close()
+	<-c.done
+}
+
+// sendWebSocket sends the next message over the worker's connection,
+// opening one first when it has none. The first message on a connection
+// carries its connect and tls timings. With expect_echo the message is done
+// when the server's next message arrives, which also sets ttfb; otherwise
+// when it is written.
+func (r *Runner) sendWebSocket(w *worker, t *wsTarget) Result {
+	start := time.Now()
+	i := t.next.Add(1) - 1
+	res := Result{Timestamp: start, Method: WebSocketMethod, Worker: w.id + 1}
+	res.MsgIndex = int(i%uint64(len(t.messages))) + 1
+	msg := t.messages[i%uint64(len(t.messages))]
+
+	if w.ws != nil && w.ws.closed() {
+		// the server ended it between messages
+		res.CloseCode = w.ws.code
+		w.ws.conn.Close()
+		w.ws = nil
+	}
+	res.Reused = w.ws != nil
+	if w.ws == nil {
+		c, err := r.dialWebSocket(w, t, &res)
+		if err != nil {
+			res.Phases.Total = time.Since(start)
+			// a refused upgrade reached the server, a failed dial did not
+			res.Undelivered = res.FailPhase != "ws_upgrade"
+			switch res.Error = classifyError(err); {
+			case res.Error == "timeout":
+			case res.FailPhase == "ws_upgrade":
+				res.Error = "ws_upgrade"
+			default:
+				res.FailPhase = res.Error
+			}
+			return res
+		}
+		w.ws = c
+		w.proto = "websocket"
+	}
+	c := w.ws
+	res.Code, res.RemoteAddr, res.Conn = http.StatusSwitchingProtocols, c.remote, c.id
+	c.ping(t.ping)
+	if rtt := c.pingRTT.Swap(0); rtt > 0 {
+		d := time.Duration(rtt)
+		res.PingRTT = &d
+	}
+	// a late echo of an earlier, timed-out message must not pass for this one's
+	for len(c.msgs) > 0 {
+		<-c.msgs
+	}
+
+	op := byte(wsText)
+	if !utf8.Valid(msg) {
+		op = wsBinary
+	}
+	err := c.write(op, msg)
+	fail := func(class, phase string) Result {
+		res.Phases.Total = time.Since(start)
+		res.Error, res.FailPhase = class, phase
+		return res
+	}
+	if err != nil {
+		c.conn.Close()
+		<-c.done
+		res.CloseCode = c.code
+		w.ws = nil
+		res.Undelivered = true
+		return fail("ws_closed", "ws_closed")
+	}
+	if !t.echo {
+		res.Phases.Total = time.Since(start)
+		return res
+	}
+
+	timeout, _ := time.ParseDuration(r.cfg.Load.Timeout)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout - time.Since(start))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case echo := <-c.msgs:
+		res.Phases.TTFB = time.Since(start)
+		res.Bytes = int64(len(echo))
+	case <-c.done:
+		res.CloseCode = c.code
+		c.conn.Close()
+		w.ws = nil
+		return fail("ws_closed", "ws_closed")
+	case <-expired:
+		return fail("timeout", "ttfb")
+	}
+	res.Phases.Total = time.Since(start)
+	return res
+}
+
+// close ends the worker's WebSocket connection, if it has one.
+func (w *worker) close() {
+	if w.ws != nil {
+		w.ws.close()
+		w.ws = nil
+	}
+}
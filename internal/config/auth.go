@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Auth sets the Authorization header of every request (and WebSocket
+// handshake) with one of three schemes. Like every section it can be set
+// from the environment, e.g. SHARD_AUTH_BEARER_TOKEN, which keeps secrets
+// out of config files.
+type Auth struct {
+	Basic  BasicAuth         `json:"basic"`
+	Bearer BearerAuth        `json:"bearer"`
+	OAuth2 ClientCredentials `json:"oauth2_client_credentials"`
+}
+
+// BasicAuth is HTTP Basic authentication.
+type BasicAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// BearerAuth sends a static bearer token.
+type BearerAuth struct {
+	Token string `json:"token,omitempty"`
+}
+
+// ClientCredentials is the OAuth2 client credentials grant: the runner
+// fetches an access token from TokenURL before the run and refreshes it in
+// the background before it expires, RefreshBefore ahead of its expiry
+// (default a fifth of its lifetime). AuthStyle "header" (default) sends the
+// client id and secret as HTTP Basic, "body" as form fields.
+type ClientCredentials struct {
+	TokenURL      string   `json:"token_url,omitempty"`
+	ClientID      string   `json:"client_id,omitempty"`
+	ClientSecret  string   `json:"client_secret,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+	Audience      string   `json:"audience,omitempty"`
+	AuthStyle     string   `json:"auth_style,omitempty"`
+	RefreshBefore string   `json:"refresh_before,omitempty"`
+	Timeout       string   `json:"timeout,omitempty"` // per token request, default 10s
+}
+
+// Scheme names the configured scheme: "basic", "bearer",
+// "oauth2_client_credentials", or "" without one.
+func (a Auth) Scheme() string {
+	switch {
+	case a.Basic != BasicAuth{}:
+		return "basic"
+	case a.Bearer.Token != "":
+		return "bearer"
+	case a.OAuth2.set():
+		return "oauth2_client_credentials"
+	}
+	return ""
+}
+
+func (c ClientCredentials) set() bool {
+	return c.TokenURL != "" || c.ClientID != "" || c.ClientSecret != "" || len(c.Scopes) > 0 ||
+		c.Audience != "" || c.AuthStyle != "" || c.RefreshBefore != "" || c.Timeout != ""
+}
+
+// RefreshLead is auth.oauth2_client_credentials.refresh_before, 0 when unset.
+func (c ClientCredentials) RefreshLead() time.Duration {
+	d, _ := time.ParseDuration(c.RefreshBefore)
+	return d
+}
+
+// TokenTimeout bounds each token request.
+func (c ClientCredentials) TokenTimeout() time.Duration {
+	if d, err := time.ParseDuration(c.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
+func (c *Config) validateAuth() error {
+	a := c.Auth
+	n := 0
+	for _, set := range []bool{a.Basic != BasicAuth{}, a.Bearer.Token != "", a.OAuth2.set()} {
+		if set {
+			n++
+		}
+	}
+	switch {
+	case n == 0:
+		return nil
+	case n > 1:
+		return errors.New("auth: set only one of basic, bearer and oauth2_client_credentials")
+	case a.Basic.Password != "" && a.Basic.Username == "":
+		return errors.New("auth.basic.password needs auth.basic.username")
+	}
+	if where := c.authorizationHeader(); where != "" {
+		return fmt.Errorf("auth sets the Authorization header; drop it from %s", where)
+	}
+	if !a.OAuth2.set() {
+		return nil
+	}
+	o := a.OAuth2
+	if o.TokenURL == "" || o.ClientID == "" {
+		return errors.New("auth.oauth2_client_credentials needs token_url and client_id")
+	}
+	if u, err := url.Parse(o.TokenURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid auth.oauth2_client_credentials.token_url %q: want an http(s) URL", o.TokenURL)
+	}
+	switch o.AuthStyle {
+	case "", "header", "body":
+	default:
+		return fmt.Errorf("invalid auth.oauth2_client_credentials.auth_style %q (want header or body)", o.AuthStyle)
+	}
+	for _, f := range []struct{ name, value string }{{"refresh_before", o.RefreshBefore}, {"timeout", o.Timeout}} {
+		if f.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(f.value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid auth.oauth2_client_credentials.%s %q", f.name, f.value)
+		}
+	}
+	return nil
+}
+
+// authorizationHeader names a place the config sets Authorization itself.
+func (c *Config) authorizationHeader() string {
+	has := func(h map[string]string) bool {
+		for k := range h {
+			if strings.EqualFold(k, "Authorization") {
+				return true
+			}
+		}
+		return false
+	}
+	if has(c.Target.Headers) {
+		return "target.headers"
+	}
+	for name, o := range c.Target.Stages {
+		if has(o.Headers) {
+			return "target.stages." + name + ".headers"
+		}
+	}
+	if c.Scenario != nil {
+		for _, s := range c.Scenario.Steps {
+			if has(s.Headers) {
+				return "scenario step " + s.Name
+			}
+		}
+	}
+	return ""
+}
@@ -5,38 +5,530 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// ErrInvalid marks errors caused by the config itself: unreadable or
+// malformed files, failed validation, missing or clashing file dependencies.
+var ErrInvalid = errors.New("invalid config")
+
 type Target struct {
 	URL      string            `json:"url"`
 	Method   string            `json:"method"`
 	Headers  map[string]string `json:"headers"`
 	BodyFile string            `json:"body_file"`
+	Resolve  Resolve           `json:"resolve"`
+	Cookies  map[string]string `json:"cookies,omitempty"` // seeded into every cookie jar
+
+	// UnixSocket sends every connection to this socket; the URL still gives
+	// the scheme, Host header and path. An https URL also needs UnixTLS, to
+	// confirm the socket really speaks TLS.
+	UnixSocket string `json:"unix_socket,omitempty"`
+	UnixTLS    bool   `json:"unix_tls,omitempty"`
+
+	// RequestID sends a fresh ULID on every request, in RequestIDHeader
+	// (default X-Shard-Request-Id; setting it also turns the ID on), and
+	// records it as the result's request_id. EchoRequestIDHeader names a
+	// response header carrying the server's own ID, recorded as
+	// server_request_id. All are off by default.
+	RequestID           bool   `json:"request_id,omitempty"`
+	RequestIDHeader     string `json:"request_id_header,omitempty"`
+	EchoRequestIDHeader string `json:"echo_request_id_header,omitempty"`
+
+	// Stages changes the request while the run is in one plan stage, keyed
+	// by its name (warmup, ramp, steady, cooldown), e.g. a canary header
+	// for the steady stage only. Results carry their stage as usual.
+	Stages map[string]StageOverride `json:"stages,omitempty"`
+
+	// Protocol is "http" (the default) or "websocket", which sends messages
+	// over connections upgraded from the URL instead, see WebSocket.
+	Protocol  string     `json:"protocol,omitempty"`
+	WebSocket *WebSocket `json:"websocket,omitempty"`
+}
+
+// StageOverride is a target.stages entry: Headers are merged over
+// target.headers and BodyFile replaces target.body_file.
+type StageOverride struct {
+	Headers  map[string]string `json:"headers,omitempty"`
+	BodyFile string            `json:"body_file,omitempty"`
+}
+
+// DefaultRequestIDHeader carries target.request_id.
+const DefaultRequestIDHeader = "X-Shard-Request-Id"
+
+// Resolve controls how the target host is resolved. By default every new
+// connection resolves through the system resolver.
+type Resolve struct {
+	Once       bool   `json:"once,omitempty"`        // resolve at startup and pin the addresses
+	IPOverride string `json:"ip_override,omitempty"` // always dial this "ip:port"
+	Resolver   string `json:"resolver,omitempty"`    // DNS server "ip[:port]" instead of the system one
 }
 
 type LoadConfig struct {
-	Rate             int    `json:"rate"`
-	Duration         string `json:"duration"`
-	Concurrency      int    `json:"concurrency"`
-	QueueSize        int    `json:"queue_size"`
-	Timeout          string `json:"timeout"`
-	DisableKeepAlive bool   `json:"disable_keepalive"`
-	InsecureTLS      bool   `json:"insecure_tls"`
-	HTTP2            bool   `json:"http2"`
+	Rate int `json:"rate"`
+	// Duration is the length of the run; "0" or "infinite" runs until
+	// interrupted or until TotalRequests have been scheduled, whichever comes
+	// first. An unbounded run without TotalRequests needs RunForever, so a
+	// typo can't start one by accident.
+	Duration         string  `json:"duration"`
+	TotalRequests    int     `json:"total_requests,omitempty"`
+	RunForever       bool    `json:"run_forever,omitempty"`
+	Concurrency      Workers `json:"concurrency"` // a worker count or "auto", see Workers
+	QueueSize        int     `json:"queue_size"`
+	Timeout          string  `json:"timeout"`
+	DisableKeepAlive bool    `json:"disable_keepalive"`
+	InsecureTLS      bool    `json:"insecure_tls"`
+	HTTP2            bool    `json:"http2"`
+	FollowRedirects  *bool   `json:"follow_redirects,omitempty"`
+	MaxRedirects     int     `json:"max_redirects,omitempty"`
+	IdleTimeout      string  `json:"idle_timeout,omitempty"` // keep-alive idle limit, default 90s
+	// TCPNoDelay disables Nagle's algorithm on every connection (default
+	// true). Turning it off lets small writes wait for the peer's ACK.
+	TCPNoDelay *bool `json:"tcp_no_delay,omitempty"`
+
+	// Compression is the response encoding negotiated with the target:
+	// "auto" (default) offers gzip the way Go's transport would, "zstd"
+	// offers zstd and gzip, and "gzip", "br" or "identity" send exactly that
+	// Accept-Encoding. In all of these Shard decodes the body itself and
+	// records the wire size and decoding time. "disable" sends no
+	// Accept-Encoding at all and records only the body size. IdentityFraction
+	// of requests ask for an uncompressed body instead, to compare the two in
+	// one run.
+	Compression      string  `json:"compression,omitempty"`
+	IdentityFraction float64 `json:"identity_fraction,omitempty"`
+
+	// Per-phase limits inside the overall Timeout; empty means no separate limit
+	// (connect falls back to 30s).
+	ConnectTimeout        string `json:"connect_timeout,omitempty"`
+	TLSTimeout            string `json:"tls_timeout,omitempty"`
+	ResponseHeaderTimeout string `json:"response_header_timeout,omitempty"` // from request written to headers
+
+	// Run shape, see Plan: warmup at WarmupRate, then a linear ramp up to Rate,
+	// then steady load for the rest of Duration.
+	Warmup     string `json:"warmup,omitempty"`
+	WarmupRate int    `json:"warmup_rate,omitempty"` // default 10% of rate
+	Ramp       string `json:"ramp,omitempty"`
+	MaxRunTime string `json:"max_run_time,omitempty"`
+	AutoExtend bool   `json:"auto_extend,omitempty"` // extend duration instead of failing when warmup+ramp don't fit
+
+	// ScheduleFile replays a recorded traffic shape instead of a fixed rate:
+	// how many requests to dispatch in each window of the run, see
+	// ReadSchedule. A window's requests are spread evenly over it, or with
+	// Burst all sent at its start.
+	ScheduleFile string `json:"schedule_file,omitempty"`
+	Burst        bool   `json:"burst,omitempty"`
+
+	Cooldown Cooldown `json:"cooldown"`
+
+	ClientAbort ClientAbort `json:"client_abort,omitempty"`
+	Retries     Retries     `json:"retries,omitempty"`
+
+	// Cookies selects cookie handling: "off" (default), "shared" for one jar
+	// across the run, or "per_worker" for a jar per worker (N distinct users).
+	Cookies string `json:"cookies,omitempty"`
+
+	// What happens to queued work when the plan ends: "drain" runs it all
+	// (default), "cut" discards it, "deadline" runs only what can start
+	// within StopGrace after the stop.
+	StopPolicy string `json:"stop_policy,omitempty"`
+	StopGrace  string `json:"stop_grace,omitempty"` // default 1s, deadline policy only
+
+	// Baseline calibrates the network before the run, see Baseline.
+	Baseline *Baseline `json:"baseline,omitempty"`
+
+	// Mode is "fixed" (default) to run the plan, or "search" to look for the
+	// highest rate the target sustains, tuned by Search. A search runs the
+	// warmup, if any, then its own steps; Duration does not apply.
+	Mode   string  `json:"mode,omitempty"`
+	Search *Search `json:"search,omitempty"`
+}
+
+// Baseline sends Requests probes (default 10) one at a time, each on a new
+// connection, before any load. Their median phase timings are the cost of
+// the network path alone, which the report subtracts from the run's.
+// Method is HEAD (default) or OPTIONS, so probes do no real work.
+type Baseline struct {
+	Requests int    `json:"requests,omitempty"`
+	Method   string `json:"method,omitempty"`
+}
+
+func (b *Baseline) validate() error {
+	if b.Requests < 0 {
+		return errors.New("load.baseline.requests must be >= 0")
+	}
+	if b.Requests == 0 {
+		b.Requests = 10
+	}
+	b.Method = strings.ToUpper(b.Method)
+	switch b.Method {
+	case "":
+		b.Method = "HEAD"
+	case "HEAD", "OPTIONS":
+	default:
+		return fmt.Errorf("invalid load.baseline.method %q (want HEAD or OPTIONS)", b.Method)
+	}
+	return nil
+}
+
+// Cooldown is a low-rate trickle appended after the main profile to watch the
+// target recover. It is reported separately from the measured load.
+type Cooldown struct {
+	Duration string `json:"duration,omitempty"`
+	Rate     int    `json:"rate,omitempty"` // default 1/s
+}
+
+// ClientAbort deliberately gives up on a Fraction of requests, to see how the
+// target copes with clients that go away: the request is cancelled After a
+// delay from its start, or once AfterBytes of the response body arrived.
+// Aborted requests are recorded with the "client_abort" error.
+type ClientAbort struct {
+	Fraction   float64 `json:"fraction,omitempty"`
+	After      string  `json:"after,omitempty"`
+	AfterBytes int64   `json:"after_bytes,omitempty"`
+}
+
+func (a ClientAbort) validate() error {
+	if a.Fraction < 0 || a.Fraction > 1 {
+		return errors.New("load.client_abort.fraction must be between 0 and 1")
+	}
+	if a.Fraction == 0 {
+		return nil
+	}
+	if (a.After == "") == (a.AfterBytes == 0) {
+		return errors.New("load.client_abort needs exactly one of after or after_bytes")
+	}
+	if a.AfterBytes < 0 {
+		return errors.New("load.client_abort.after_bytes must be > 0")
+	}
+	if d, err := parseOptionalDuration(a.After); err != nil || (a.After != "" && d <= 0) {
+		return fmt.Errorf("invalid load.client_abort.after %q", a.After)
+	}
+	return nil
+}
+
+// Retries re-sends requests that failed for a transient reason, up to
+// MaxAttempts in total. RetryOn lists what counts as transient: failure
+// classes ("dns", "connect", "proxy", "tls", "timeout", "ttfb", "other"), status
+// families ("5xx") or exact statuses ("503"); default dns, connect and ttfb
+// (connections dropped before the response). Non-idempotent methods are
+// never retried unless RetryNonIdempotent is set.
+type Retries struct {
+	MaxAttempts        int      `json:"max_attempts,omitempty"`
+	RetryOn            []string `json:"retry_on,omitempty"`
+	Backoff            string   `json:"backoff,omitempty"`   // "fixed" or "exponential" (default, jittered)
+	Delay              string   `json:"delay,omitempty"`     // first backoff, default 100ms
+	MaxDelay           string   `json:"max_delay,omitempty"` // exponential cap, default 2s
+	RetryNonIdempotent bool     `json:"retry_non_idempotent,omitempty"`
+}
+
+// retryClasses are the failure classes retry_on accepts.
+var retryClasses = map[string]bool{"dns": true, "connect": true, "proxy": true, "tls": true, "timeout": true, "ttfb": true, "auth": true, "other": true}
+
+func (r *Retries) validate() error {
+	if r.MaxAttempts < 0 {
+		return errors.New("load.retries.max_attempts must be >= 0")
+	}
+	if r.MaxAttempts <= 1 {
+		return nil
+	}
+	if len(r.RetryOn) == 0 {
+		r.RetryOn = []string{"dns", "connect", "ttfb"}
+	}
+	for _, on := range r.RetryOn {
+		if !retryClasses[on] && !validStatusSpec(on) {
+			return fmt.Errorf("invalid load.retries.retry_on %q (want a failure class such as connect, a status family such as 5xx, or a status)", on)
+		}
+	}
+	switch r.Backoff {
+	case "":
+		r.Backoff = "exponential"
+	case "fixed", "exponential":
+	default:
+		return fmt.Errorf("invalid load.retries.backoff %q (want fixed or exponential)", r.Backoff)
+	}
+	if r.Delay == "" {
+		r.Delay = "100ms"
+	}
+	if r.MaxDelay == "" {
+		r.MaxDelay = "2s"
+	}
+	for _, d := range []struct{ name, value string }{{"delay", r.Delay}, {"max_delay", r.MaxDelay}} {
+		if v, err := time.ParseDuration(d.value); err != nil || v < 0 {
+			return fmt.Errorf("invalid load.retries.%s %q", d.name, d.value)
+		}
+	}
+	return nil
+}
+
+// validStatusSpec reports whether s is a status family ("5xx") or a status ("503").
+func validStatusSpec(s string) bool {
+	if len(s) != 3 || s[0] < '1' || s[0] > '5' {
+		return false
+	}
+	if s[1:] == "xx" {
+		return true
+	}
+	return s[1] >= '0' && s[1] <= '9' && s[2] >= '0' && s[2] <= '9'
+}
+
+// TLS configures the client side of TLS connections to the target;
+// load.insecure_tls still disables verification altogether.
+type TLS struct {
+	ClientCert string `json:"client_cert,omitempty"` // PEM client certificate for mTLS
+	ClientKey  string `json:"client_key,omitempty"`
+	CAFile     string `json:"ca_file,omitempty"`     // PEM bundle trusted instead of the system roots
+	ServerName string `json:"server_name,omitempty"` // SNI and verification name override
+	MinVersion string `json:"min_version,omitempty"` // "1.0" to "1.3"
+}
+
+func (t TLS) validate() error {
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return errors.New("tls.client_cert and tls.client_key must be set together")
+	}
+	switch t.MinVersion {
+	case "", "1.0", "1.1", "1.2", "1.3":
+	default:
+		return fmt.Errorf("invalid tls.min_version %q (want 1.0, 1.1, 1.2 or 1.3)", t.MinVersion)
+	}
+	return nil
+}
+
+// NoDelay reports whether connections are dialed with TCP_NODELAY (default true).
+func (l LoadConfig) NoDelay() bool {
+	return l.TCPNoDelay == nil || *l.TCPNoDelay
+}
+
+// FollowsRedirects reports whether redirects should be followed (default true).
+func (l LoadConfig) FollowsRedirects() bool {
+	return l.FollowRedirects == nil || *l.FollowRedirects
 }
 
 type Output struct {
-	JSONLPath string `json:"jsonl_path"`
+	JSONLPath        string `json:"jsonl_path"`
+	ProgressInterval string `json:"progress_interval,omitempty"`
+	// ProgressPath is where progress lines are mirrored; empty disables the
+	// log. A directory gets a timestamped progress-20240101-120000.log, and
+	// both paths may carry a {ts} placeholder. ProgressAppend keeps earlier
+	// runs' lines instead of truncating the file.
+	ProgressPath   string `json:"progress_path"`
+	ProgressAppend bool   `json:"progress_append,omitempty"`
+	// MaxFileSize caps the results file ("500MB", "2GB"); SizePolicy picks what
+	// happens when it is approached: "rotate", "sample" or "abort" (default).
+	MaxFileSize string `json:"max_file_size,omitempty"`
+	SizePolicy  string `json:"size_policy,omitempty"`
+	// Compression picks the results codec: "gzip", "zstd" or "none". Unset, a
+	// .gz or .zst path decides; Compress is the older spelling of "gzip".
+	// MaxFileSizeMB splits results into numbered segments (results-0001.jsonl,
+	// ...) of at most that size.
+	Compression   string `json:"compression,omitempty"`
+	Compress      bool   `json:"compress,omitempty"`
+	MaxFileSizeMB int    `json:"max_file_size_mb,omitempty"`
+
+	// CheckpointInterval is how often a run writes a full summary of the
+	// results so far into the progress log (default 5m).
+	CheckpointInterval string `json:"checkpoint_interval,omitempty"`
+
+	// OTLPEndpoint ("http://collector:4318") and StatsdAddr ("127.0.0.1:8125")
+	// export live request metrics alongside the results file. Traceparent
+	// sends a W3C traceparent header with a fresh trace ID on every request
+	// and records the ID in the results.
+	OTLPEndpoint string `json:"otlp_endpoint,omitempty"`
+	StatsdAddr   string `json:"statsd_addr,omitempty"`
+	Traceparent  bool   `json:"traceparent,omitempty"`
+
+	Capture Capture `json:"capture"`
+}
+
+func (o Output) validateExport() error {
+	if o.OTLPEndpoint != "" {
+		u, err := url.Parse(o.OTLPEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid output.otlp_endpoint %q (want http(s)://host:port)", o.OTLPEndpoint)
+		}
+	}
+	if o.StatsdAddr != "" {
+		if _, _, err := net.SplitHostPort(o.StatsdAddr); err != nil {
+			return fmt.Errorf("invalid output.statsd_addr %q: %v", o.StatsdAddr, err)
+		}
+	}
+	return nil
+}
+
+func (o Output) validateCompression() error {
+	switch o.Compression {
+	case "":
+		return nil
+	case "gzip", "zstd", "none":
+	default:
+		return fmt.Errorf("invalid output.compression %q (want gzip, zstd or none)", o.Compression)
+	}
+	if o.Compress && o.Compression != "gzip" {
+		return fmt.Errorf("output.compress conflicts with output.compression %q", o.Compression)
+	}
+	for codec, ext := range codecExt {
+		if strings.HasSuffix(o.JSONLPath, ext) && o.Compression != codec {
+			return fmt.Errorf("output.jsonl_path %q does not match output.compression %q", o.JSONLPath, o.Compression)
+		}
+	}
+	return nil
+}
+
+// Abort stops the run early when the target is clearly broken. Zero values
+// disable a threshold; warmup and cooldown traffic is never evaluated.
+type Abort struct {
+	ErrorRate           float64 `json:"error_rate,omitempty"`           // e.g. 0.05
+	ConsecutiveFailures int     `json:"consecutive_failures,omitempty"` // failures in a row
+	P99Ms               float64 `json:"p99_ms,omitempty"`
+	MinSamples          int     `json:"min_samples,omitempty"` // before error_rate/p99 apply, default 100
+
+	// CountClientAborts counts load.client_abort's deliberate aborts as
+	// failures; by default they are left out of every threshold.
+	CountClientAborts bool `json:"count_client_aborts,omitempty"`
+
+	// Methods scopes extra thresholds to one HTTP method's traffic, keyed by
+	// method (e.g. "POST"); the thresholds above still apply to everything.
+	Methods map[string]MethodAbort `json:"methods,omitempty"`
+}
+
+// MethodAbort holds the abort thresholds for a single HTTP method.
+type MethodAbort struct {
+	ErrorRate           float64 `json:"error_rate,omitempty"`
+	ConsecutiveFailures int     `json:"consecutive_failures,omitempty"`
+	P99Ms               float64 `json:"p99_ms,omitempty"`
+}
+
+// Capture controls which response bodies are kept in results for debugging.
+type Capture struct {
+	OnError     bool    `json:"on_error"`               // capture non-2xx bodies
+	SampleRate  float64 `json:"sample_rate,omitempty"`  // fraction of successful bodies to capture too
+	MaxBytes    int     `json:"max_bytes,omitempty"`    // per-body limit, default 1024
+	MaxCaptures int     `json:"max_captures,omitempty"` // per-run limit, default 10000
+	Headers     bool    `json:"headers,omitempty"`      // record response header metrics (Date skew)
+
+	// NumericHeaders are response headers parsed as numbers into each result,
+	// e.g. "X-RateLimit-Remaining"; Retry-After dates become seconds.
+	NumericHeaders []string `json:"numeric_headers,omitempty"`
+}
+
+// SegmentSize returns the rotation size in bytes, or 0 when results are not rotated.
+// An explicit max_file_size_mb wins; otherwise the "rotate" size policy rotates at max_file_size.
+func (o Output) SegmentSize() int64 {
+	if o.MaxFileSizeMB > 0 {
+		return int64(o.MaxFileSizeMB) << 20
+	}
+	if o.SizePolicy == "rotate" {
+		n, _ := ParseSize(o.MaxFileSize)
+		return n
+	}
+	return 0
+}
+
+// tsLayout is how {ts} and timestamped progress logs spell the run's start.
+const tsLayout = "20060102-150405"
+
+// ExpandTimestamp replaces every {ts} in path with t, e.g. 20240101-120000.
+func ExpandTimestamp(path string, t time.Time) string {
+	return strings.ReplaceAll(path, "{ts}", t.Format(tsLayout))
+}
+
+// Paths resolves the results and progress paths for a run started at now:
+// {ts} is expanded in both, and a progress path naming a directory (an
+// existing one, or any path ending in a separator) gets a timestamped file
+// inside it. progress is empty when the progress log is disabled.
+func (o Output) Paths(now time.Time) (results, progress string) {
+	results = ExpandTimestamp(o.JSONLPath, now)
+	if o.ProgressPath == "" {
+		return results, ""
+	}
+	progress = ExpandTimestamp(o.ProgressPath, now)
+	isDir := strings.HasSuffix(progress, "/") || strings.HasSuffix(progress, string(filepath.Separator))
+	if fi, err := os.Stat(progress); err == nil && fi.IsDir() {
+		isDir = true
+	}
+	if isDir {
+		progress = filepath.Join(progress, "progress-"+now.Format(tsLayout)+".log")
+	}
+	return results, progress
+}
+
+// Codec returns how results written to path are compressed: "gzip", "zstd"
+// or "" for plain JSONL. An explicit output.compression wins; otherwise the
+// path's .gz or .zst suffix decides, and compress or the rotate size policy
+// (which always compresses its segments) select gzip.
+func (o Output) Codec(path string) string {
+	switch {
+	case o.Compression == "none":
+		return ""
+	case o.Compression != "":
+		return o.Compression
+	case strings.HasSuffix(path, ".zst"):
+		return "zstd"
+	case strings.HasSuffix(path, ".gz"), o.Compress, o.SizePolicy == "rotate":
+		return "gzip"
+	}
+	return ""
+}
+
+// codecExt is the file suffix of each results codec.
+var codecExt = map[string]string{"gzip": ".gz", "zstd": ".zst"}
+
+// SegmentPath names the n-th rotation segment of path: results.jsonl becomes
+// results-0001.jsonl, or results-0001.jsonl.gz / .zst when compressed.
+func SegmentPath(path, codec string, n int) string {
+	base, ext := SplitExt(path)
+	if s := codecExt[codec]; s != "" && !strings.HasSuffix(ext, s) {
+		ext += s
+	}
+	return fmt.Sprintf("%s-%04d%s", base, n, ext)
+}
+
+// SplitExt splits "dir/results.jsonl.gz" into "dir/results" and ".jsonl.gz".
+func SplitExt(path string) (string, string) {
+	name := filepath.Base(path)
+	if i := strings.Index(name, "."); i > 0 {
+		return path[:len(path)-len(name)+i], name[i:]
+	}
+	return path, ".jsonl"
 }
 
 type Config struct {
 	Target Target     `json:"target"`
 	Load   LoadConfig `json:"load"`
+	TLS    TLS        `json:"tls"`
+	Proxy  Proxy      `json:"proxy"`
+	Auth   Auth       `json:"auth"`
 	Output Output     `json:"output"`
+	Abort  Abort      `json:"abort"`
+
+	Scenario *Scenario `json:"scenario,omitempty"` // replaces the single target request
+
+	// Cost holds named pricing profiles for the report's cost estimate.
+	Cost map[string]CostProfile `json:"cost,omitempty"`
+
+	// Thresholds are latency SLAs the report checks the results against.
+	Thresholds []Threshold `json:"thresholds,omitempty"`
+
+	// SLO lists service level objectives, see SLORule, that the report and
+	// shard check evaluate.
+	SLO []string `json:"slo,omitempty"`
+
+	notices  []string         // adjustments made by Validate, see Notices
+	schedule []ScheduleWindow // load.schedule_file, read by Validate
+}
+
+// Notices returns human-readable adjustments Validate made to the config.
+func (c *Config) Notices() []string {
+	return c.notices
 }
 
+// ReadConfig reads path on top of DefaultConfig, so fields missing from the
+// file keep their defaults.
 func ReadConfig(path string) (*Config, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -48,25 +540,42 @@ func ReadConfig(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
+	return ParseConfig(data)
+}
 
-	var cfg Config
+// ParseConfig reads a config from its JSON, over the defaults. Like
+// ReadConfig it does not validate. json merges objects into existing maps,
+// so default maps are only filled in when the JSON leaves them out: a config
+// with its own target.headers gets exactly those.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := DefaultConfig()
+	defaults := cfg.Target.Headers
+	cfg.Target.Headers = nil
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
+	if cfg.Target.Headers == nil {
+		cfg.Target.Headers = defaults
+	}
 	return &cfg, nil
 }
 
 func WriteDefaultConfig(path string) error {
-	def := DefaultConfig()
-	data, err := json.MarshalIndent(def, "", "  ")
+	return WriteConfig(path, DefaultConfig())
+}
+
+// WriteConfig writes cfg to path as indented JSON.
+func WriteConfig(path string, cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
-		return fmt.Errorf("marshal default config: %w", err)
+		return fmt.Errorf("marshal config: %w", err)
 	}
 	return os.WriteFile(path, data, 0644)
 }
 
 // DefaultConfig
 func DefaultConfig() Config {
+	follow, noDelay := true, true
 	return Config{
 		Target: Target{
 			URL:    "https://example.com",
@@ -85,9 +594,15 @@ func DefaultConfig() Config {
 			DisableKeepAlive: false,
 			InsecureTLS:      false,
 			HTTP2:            true,
+			FollowRedirects:  &follow,
+			MaxRedirects:     10,
+			IdleTimeout:      "90s",
+			TCPNoDelay:       &noDelay,
 		},
 		Output: Output{
-			JSONLPath: "logs.jsonl",
+			JSONLPath:        "logs.jsonl",
+			ProgressInterval: "1s",
+			ProgressPath:     "progress.log",
 		},
 	}
 }
@@ -97,21 +612,271 @@ func (c *Config) Validate() error {
 	if c.Target.URL == "" {
 		return errors.New("target.url is required")
 	}
+	if err := c.Target.Resolve.validate(); err != nil {
+		return err
+	}
+	if c.Target.RequestIDHeader != "" {
+		c.Target.RequestID = true
+	} else if c.Target.RequestID {
+		c.Target.RequestIDHeader = DefaultRequestIDHeader
+	}
+	if err := c.Target.validateUnix(); err != nil {
+		return err
+	}
+	if err := c.validateProtocol(); err != nil {
+		return err
+	}
+	if err := c.validateProxy(); err != nil {
+		return err
+	}
+	if err := c.validateAuth(); err != nil {
+		return err
+	}
+	if err := c.TLS.validate(); err != nil {
+		return err
+	}
+	if err := c.validateSchedule(); err != nil {
+		return err
+	}
+	if c.Scenario != nil {
+		if err := c.Scenario.validate(); err != nil {
+			return err
+		}
+	}
 	if c.Load.Rate <= 0 {
 		return errors.New("load.rate must be > 0")
 	}
-	if c.Load.Concurrency <= 0 {
-		return errors.New("load.concurrency must be > 0")
+	if time.Second/time.Duration(c.Load.Rate) <= 0 {
+		return fmt.Errorf("load.rate %d is too high: the request interval would be zero", c.Load.Rate)
+	}
+	if c.Load.Concurrency <= 0 && !c.Load.Concurrency.Auto() {
+		return errors.New("load.concurrency must be > 0 or \"auto\"")
 	}
-	// ensure a sensible queue size; default to 2x concurrency when unset or invalid
+	// ensure a sensible queue size; default to 2x (initial) concurrency when unset or invalid
 	if c.Load.QueueSize <= 0 {
-		c.Load.QueueSize = c.Load.Concurrency * 2
+		c.Load.QueueSize = c.Load.Concurrency.Initial() * 2
+	}
+	if c.Load.MaxRedirects < 0 {
+		return errors.New("load.max_redirects must be >= 0")
+	}
+	if c.Load.MaxRedirects == 0 {
+		c.Load.MaxRedirects = 10
+	}
+	if err := c.validateMode(); err != nil {
+		return err
 	}
-	if _, err := time.ParseDuration(c.Load.Duration); err != nil {
-		return fmt.Errorf("invalid load.duration: %v", err)
+	if !c.Load.Searching() {
+		if err := c.validateBound(); err != nil {
+			return err
+		}
+	}
+	if err := c.validatePlan(); err != nil {
+		return err
 	}
 	if _, err := time.ParseDuration(c.Load.Timeout); err != nil {
 		return fmt.Errorf("invalid load.timeout: %v", err)
 	}
+	for _, t := range []struct{ name, value string }{
+		{"load.connect_timeout", c.Load.ConnectTimeout},
+		{"load.tls_timeout", c.Load.TLSTimeout},
+		{"load.response_header_timeout", c.Load.ResponseHeaderTimeout},
+	} {
+		if d, err := parseOptionalDuration(t.value); err != nil || d < 0 {
+			return fmt.Errorf("invalid %s %q", t.name, t.value)
+		}
+	}
+	if c.Load.IdleTimeout == "" {
+		c.Load.IdleTimeout = "90s"
+	}
+	if d, err := time.ParseDuration(c.Load.IdleTimeout); err != nil || d <= 0 {
+		return fmt.Errorf("invalid load.idle_timeout %q", c.Load.IdleTimeout)
+	}
+	if c.Output.MaxFileSize != "" {
+		if _, err := ParseSize(c.Output.MaxFileSize); err != nil {
+			return fmt.Errorf("invalid output.max_file_size: %v", err)
+		}
+	}
+	if err := c.Output.validateCompression(); err != nil {
+		return err
+	}
+	if err := c.Output.validateExport(); err != nil {
+		return err
+	}
+	if c.Output.MaxFileSizeMB < 0 {
+		return errors.New("output.max_file_size_mb must be >= 0")
+	}
+	if c.Output.Capture.SampleRate < 0 || c.Output.Capture.SampleRate > 1 {
+		return errors.New("output.capture.sample_rate must be between 0 and 1")
+	}
+	if c.Output.Capture.MaxBytes <= 0 {
+		c.Output.Capture.MaxBytes = 1024
+	}
+	if c.Output.Capture.MaxCaptures <= 0 {
+		c.Output.Capture.MaxCaptures = 10000
+	}
+	if c.Abort.ErrorRate < 0 || c.Abort.ErrorRate > 1 {
+		return errors.New("abort.error_rate must be between 0 and 1")
+	}
+	if c.Abort.ConsecutiveFailures < 0 || c.Abort.P99Ms < 0 || c.Abort.MinSamples < 0 {
+		return errors.New("abort thresholds must be >= 0")
+	}
+	for method, m := range c.Abort.Methods {
+		if m.ErrorRate < 0 || m.ErrorRate > 1 || m.ConsecutiveFailures < 0 || m.P99Ms < 0 {
+			return fmt.Errorf("abort.methods.%s: thresholds must be >= 0 and error_rate <= 1", method)
+		}
+		if upper := strings.ToUpper(method); upper != method {
+			delete(c.Abort.Methods, method)
+			c.Abort.Methods[upper] = m
+		}
+	}
+	if c.Abort.MinSamples == 0 {
+		c.Abort.MinSamples = 100
+	}
+	if err := c.Load.ClientAbort.validate(); err != nil {
+		return err
+	}
+	if err := c.Load.Retries.validate(); err != nil {
+		return err
+	}
+	if c.Load.Baseline != nil {
+		if err := c.Load.Baseline.validate(); err != nil {
+			return err
+		}
+	}
+	switch c.Load.Cookies {
+	case "":
+		c.Load.Cookies = "off"
+	case "off", "shared", "per_worker":
+	default:
+		return fmt.Errorf("invalid load.cookies %q (want off, shared or per_worker)", c.Load.Cookies)
+	}
+	if len(c.Target.Cookies) > 0 && c.Load.Cookies == "off" {
+		return errors.New("target.cookies needs load.cookies set to shared or per_worker")
+	}
+	switch c.Load.Compression {
+	case "":
+		c.Load.Compression = "auto"
+	case "auto", "zstd", "gzip", "br", "identity", "disable":
+	default:
+		return fmt.Errorf("invalid load.compression %q (want auto, zstd, gzip, br, identity or disable)", c.Load.Compression)
+	}
+	if c.Load.IdentityFraction < 0 || c.Load.IdentityFraction > 1 {
+		return errors.New("load.identity_fraction must be between 0 and 1")
+	}
+	if c.Load.IdentityFraction > 0 && c.Load.Compression == "disable" {
+		return errors.New("load.identity_fraction needs compression negotiated, not load.compression disable")
+	}
+	switch c.Load.StopPolicy {
+	case "":
+		c.Load.StopPolicy = "drain"
+	case "drain", "cut", "deadline":
+	default:
+		return fmt.Errorf("invalid load.stop_policy %q (want drain, cut or deadline)", c.Load.StopPolicy)
+	}
+	if c.Load.StopGrace == "" {
+		c.Load.StopGrace = "1s"
+	}
+	if d, err := time.ParseDuration(c.Load.StopGrace); err != nil || d < 0 {
+		return fmt.Errorf("invalid load.stop_grace %q", c.Load.StopGrace)
+	}
+	switch c.Output.SizePolicy {
+	case "":
+		c.Output.SizePolicy = "abort"
+	case "rotate", "sample", "abort":
+	default:
+		return fmt.Errorf("invalid output.size_policy %q (want rotate, sample or abort)", c.Output.SizePolicy)
+	}
+	if _, err := c.CostProfiles(); err != nil {
+		return err
+	}
+	if _, err := c.SLAThresholds(); err != nil {
+		return err
+	}
+	if _, err := c.SLORules(); err != nil {
+		return err
+	}
+	if c.Output.CheckpointInterval == "" {
+		c.Output.CheckpointInterval = "5m"
+	}
+	if d, err := time.ParseDuration(c.Output.CheckpointInterval); err != nil || d <= 0 {
+		return fmt.Errorf("invalid output.checkpoint_interval %q", c.Output.CheckpointInterval)
+	}
+	if c.Output.ProgressInterval != "" {
+		d, err := time.ParseDuration(c.Output.ProgressInterval)
+		if err != nil {
+			return fmt.Errorf("invalid output.progress_interval: %v", err)
+		}
+		if d <= 0 {
+			return errors.New("output.progress_interval must be > 0")
+		}
+	}
+	return nil
+}
+
+// ParseSize parses a byte size such as "1048576", "512KB", "500MB" or "2GB" (powers of 1024).
+func ParseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	str := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(str, u.suffix) {
+			str, mult = strings.TrimSpace(strings.TrimSuffix(str, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// FormatSize prints a byte size in the units ParseSize reads, e.g. "1.50 GB".
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %cB", float64(n)/float64(div), "KMG"[exp])
+}
+
+func (t Target) validateUnix() error {
+	if t.UnixSocket == "" {
+		if t.UnixTLS {
+			return errors.New("target.unix_tls needs target.unix_socket")
+		}
+		return nil
+	}
+	if t.Resolve.Once || t.Resolve.IPOverride != "" || t.Resolve.Resolver != "" {
+		return errors.New("target.unix_socket can't be combined with target.resolve")
+	}
+	if strings.HasPrefix(strings.ToLower(t.URL), "https://") && !t.UnixTLS {
+		return errors.New("target.unix_socket with an https URL needs target.unix_tls: true (TLS over the socket)")
+	}
+	return nil
+}
+
+func (r *Resolve) validate() error {
+	if r.Once && r.IPOverride != "" {
+		return errors.New("target.resolve: once and ip_override are mutually exclusive")
+	}
+	if r.IPOverride != "" {
+		if _, _, err := net.SplitHostPort(r.IPOverride); err != nil {
+			return fmt.Errorf("invalid target.resolve.ip_override: %v", err)
+		}
+	}
+	if r.Resolver != "" {
+		if _, _, err := net.SplitHostPort(r.Resolver); err != nil {
+			r.Resolver = net.JoinHostPort(r.Resolver, "53")
+		}
+	}
 	return nil
 }
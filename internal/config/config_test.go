@@ -0,0 +1,29 @@
+package config
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseConfigHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want map[string]string
+	}{
+		{"own headers", `{"target":{"headers":{"Accept":"application/json"}}}`, map[string]string{"Accept": "application/json"}},
+		{"no headers", `{"target":{"headers":{}}}`, map[string]string{}},
+		{"left out", `{"target":{"url":"http://localhost"}}`, DefaultConfig().Target.Headers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseConfig([]byte(tt.json))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(cfg.Target.Headers, tt.want) {
+				t.Fatalf("headers %v, want %v", cfg.Target.Headers, tt.want)
+			}
+		})
+	}
+}
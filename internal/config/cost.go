@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CostProfile prices a run for one provider. The report multiplies the
+// measured request count and response bytes into an estimate.
+type CostProfile struct {
+	PerMillionRequests float64 `json:"per_million_requests,omitempty"`
+	PerGBEgress        float64 `json:"per_gb_egress,omitempty"` // GB = 10^9 bytes
+	Currency           string  `json:"currency,omitempty"`      // default "USD"
+}
+
+// CostProfiles returns the validated pricing profiles with defaults applied,
+// or nil when the config has none.
+func (c *Config) CostProfiles() (map[string]CostProfile, error) {
+	if len(c.Cost) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(c.Cost))
+	for name := range c.Cost {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make(map[string]CostProfile, len(c.Cost))
+	for _, name := range names {
+		p := c.Cost[name]
+		if p.PerMillionRequests < 0 || p.PerGBEgress < 0 {
+			return nil, fmt.Errorf("cost.%s: prices must be >= 0", name)
+		}
+		if p.PerMillionRequests == 0 && p.PerGBEgress == 0 {
+			return nil, fmt.Errorf("cost.%s: set per_million_requests and/or per_gb_egress", name)
+		}
+		if p.Currency == "" {
+			p.Currency = "USD"
+		}
+		out[name] = p
+	}
+	return out, nil
+}
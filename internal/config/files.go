@@ -0,0 +1,58 @@
+package config
+
+import "sort"
+
+// RunFiles calls fn for every file the run itself reads from disk (body
+// files, TLS material and the schedule file), in a fixed order, and
+// replaces each path with the one fn returns. field names the setting,
+// e.g. "target.stages.steady.body_file". Output paths are not included.
+func (c *Config) RunFiles(fn func(field, path string) (string, error)) error {
+	visit := func(field string, path *string) error {
+		if *path == "" {
+			return nil
+		}
+		p, err := fn(field, *path)
+		if err != nil {
+			return err
+		}
+		*path = p
+		return nil
+	}
+	if err := visit("target.body_file", &c.Target.BodyFile); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(c.Target.Stages))
+	for name := range c.Target.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		o := c.Target.Stages[name]
+		if err := visit("target.stages."+name+".body_file", &o.BodyFile); err != nil {
+			return err
+		}
+		c.Target.Stages[name] = o
+	}
+	if c.Scenario != nil {
+		for i := range c.Scenario.Steps {
+			s := &c.Scenario.Steps[i]
+			if err := visit("scenario.steps."+s.Name+".body_file", &s.BodyFile); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range []struct {
+		field string
+		path  *string
+	}{
+		{"tls.client_cert", &c.TLS.ClientCert},
+		{"tls.client_key", &c.TLS.ClientKey},
+		{"tls.ca_file", &c.TLS.CAFile},
+		{"load.schedule_file", &c.Load.ScheduleFile},
+	} {
+		if err := visit(f.field, f.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ImportedRequest is a request taken from a HAR file or a curl command, ready
+// to become the config's target.
+type ImportedRequest struct {
+	Method   string
+	URL      string
+	Headers  map[string]string
+	Body     []byte
+	Insecure bool // curl -k
+}
+
+// droppedHeaders are never copied into a target: hop-by-hop headers, and
+// ones the client computes itself.
+var droppedHeaders = map[string]bool{
+	"Connection": true, "Keep-Alive": true, "Proxy-Authenticate": true,
+	"Proxy-Authorization": true, "Proxy-Connection": true, "Te": true, "Trailer": true,
+	"Transfer-Encoding": true, "Upgrade": true, "Host": true, "Content-Length": true,
+}
+
+// addHeader applies the import filter: pseudo-headers (":authority"),
+// hop-by-hop headers and, unless keepCookies, Cookie are dropped.
+func (r *ImportedRequest) addHeader(name, value string, keepCookies bool) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.HasPrefix(name, ":") {
+		return
+	}
+	key := http.CanonicalHeaderKey(name)
+	if droppedHeaders[key] || (key == "Cookie" && !keepCookies) {
+		return
+	}
+	if r.Headers == nil {
+		r.Headers = make(map[string]string)
+	}
+	if prev, ok := r.Headers[key]; ok {
+		sep := ", "
+		if key == "Cookie" {
+			sep = "; "
+		}
+		value = prev + sep + value
+	}
+	r.Headers[key] = strings.TrimSpace(value)
+}
+
+func (r *ImportedRequest) validate() error {
+	u, err := url.Parse(r.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid URL %q", r.URL)
+	}
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+	r.Method = strings.ToUpper(r.Method)
+	return nil
+}
+
+// Apply makes r the config's target; the body, if any, must have been
+// written to bodyFile.
+func (r *ImportedRequest) Apply(c *Config, bodyFile string) {
+	c.Target.URL = r.URL
+	c.Target.Method = r.Method
+	c.Target.Headers = r.Headers
+	if len(r.Body) > 0 {
+		c.Target.BodyFile = bodyFile
+	}
+	if r.Insecure {
+		c.Load.InsecureTLS = true
+	}
+}
+
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"` // non-standard, set by some exporters
+					Params   []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"params"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// FromHAR reads entry n (0-based) of a HAR export. It also returns how many
+// entries the file has, so callers can say what was left out.
+func FromHAR(data []byte, n int, keepCookies bool) (*ImportedRequest, int, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, 0, fmt.Errorf("parse HAR: %w", err)
+	}
+	entries := har.Log.Entries
+	if len(entries) == 0 {
+		return nil, 0, errors.New("HAR has no entries")
+	}
+	if n < 0 || n >= len(entries) {
+		return nil, len(entries), fmt.Errorf("HAR has %d entries, no entry %d", len(entries), n+1)
+	}
+	e := entries[n].Request
+	fail := func(format string, args ...any) error {
+		return fmt.Errorf("HAR entry %d (%s %s): %s", n+1, e.Method, e.URL, fmt.Sprintf(format, args...))
+	}
+
+	req := &ImportedRequest{Method: e.Method, URL: e.URL}
+	if err := req.validate(); err != nil {
+		return nil, len(entries), fail("%v", err)
+	}
+	for _, h := range e.Headers {
+		req.addHeader(h.Name, h.Value, keepCookies)
+	}
+	if pd := e.PostData; pd != nil {
+		switch {
+		case pd.Encoding == "base64":
+			body, err := base64.StdEncoding.DecodeString(pd.Text)
+			if err != nil {
+				return nil, len(entries), fail("bad base64 postData: %v", err)
+			}
+			req.Body = body
+		case pd.Text != "":
+			req.Body = []byte(pd.Text)
+		case len(pd.Params) > 0:
+			form := url.Values{}
+			for _, p := range pd.Params {
+				form.Add(p.Name, p.Value)
+			}
+			req.Body = []byte(form.Encode())
+		}
+		if _, ok := req.Headers["Content-Type"]; !ok && pd.MimeType != "" && len(req.Body) > 0 {
+			req.addHeader("Content-Type", pd.MimeType, keepCookies)
+		}
+	}
+	return req, len(entries), nil
+}
+
+// FromCurl parses a curl command line as copied from browser devtools
+// ("Copy as cURL"). Data given as @file is read from disk.
+func FromCurl(command string, keepCookies bool) (*ImportedRequest, error) {
+	args, err := shellSplit(command)
+	if err != nil {
+		return nil, fmt.Errorf("parse curl command: %w", err)
+	}
+	if len(args) > 0 && (args[0] == "curl" || strings.HasSuffix(args[0], "/curl")) {
+		args = args[1:]
+	}
+
+	req := &ImportedRequest{}
+	var data []string
+	var user string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if req.URL != "" {
+				return nil, fmt.Errorf("curl: unexpected argument %q", arg)
+			}
+			req.URL = arg
+			continue
+		}
+		name, value, inline := strings.Cut(arg, "=")
+		if !strings.HasPrefix(arg, "--") {
+			// short options take their value attached ("-XPOST") or as the next argument
+			name, value, inline = arg[:2], arg[2:], len(arg) > 2
+		}
+		next := func() (string, error) {
+			if inline {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("curl: %s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+		switch {
+		case curlValueOptions[name]:
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			switch name {
+			case "-X", "--request":
+				req.Method = v
+			case "-H", "--header":
+				k, hv, ok := strings.Cut(v, ":")
+				if !ok {
+					return nil, fmt.Errorf("curl: bad header %q, want \"K: V\"", v)
+				}
+				req.addHeader(k, hv, keepCookies)
+			case "-b", "--cookie":
+				req.addHeader("Cookie", v, keepCookies)
+			case "-u", "--user":
+				user = v
+			case "-A", "--user-agent":
+				req.addHeader("User-Agent", v, keepCookies)
+			case "-e", "--referer":
+				req.addHeader("Referer", v, keepCookies)
+			case "--url":
+				req.URL = v
+			default:
+				if strings.HasPrefix(v, "@") && name != "--data-raw" {
+					b, err := os.ReadFile(v[1:])
+					if err != nil {
+						return nil, fmt.Errorf("curl %s: %w", name, err)
+					}
+					v = string(b)
+					if name != "--data-binary" {
+						v = strings.NewReplacer("\r", "", "\n", "").Replace(v)
+					}
+				}
+				data = append(data, v)
+			}
+		case name == "-k" || name == "--insecure":
+			req.Insecure = true
+		case curlIgnored[name]:
+		default:
+			return nil, fmt.Errorf("curl: unsupported option %s", name)
+		}
+		if inline && !strings.HasPrefix(arg, "--") && !curlValueOptions[name] {
+			// grouped short flags ("-sSk"): handle the rest next
+			args = append(args[:i+1], append([]string{"-" + value}, args[i+1:]...)...)
+		}
+	}
+	if req.URL == "" {
+		return nil, errors.New("curl: no URL")
+	}
+	if len(data) > 0 {
+		req.Body = []byte(strings.Join(data, "&"))
+		if req.Method == "" {
+			req.Method = http.MethodPost
+		}
+		if _, ok := req.Headers["Content-Type"]; !ok {
+			req.addHeader("Content-Type", "application/x-www-form-urlencoded", keepCookies)
+		}
+	}
+	if user != "" {
+		req.addHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user)), keepCookies)
+	}
+	if err := req.validate(); err != nil {
+		return nil, fmt.Errorf("curl: %w", err)
+	}
+	return req, nil
+}
+
+// curlValueOptions are the curl options FromCurl understands that take a value.
+var curlValueOptions = map[string]bool{
+	"-X": true, "--request": true, "-H": true, "--header": true, "-d": true, "--data": true,
+	"--data-raw": true, "--data-binary": true, "--data-ascii": true, "--data-urlencode": true,
+	"-b": true, "--cookie": true, "-u": true, "--user": true, "-A": true, "--user-agent": true,
+	"-e": true, "--referer": true, "--url": true,
+}
+
+// curlIgnored are output and transport niceties with no bearing on the target.
+var curlIgnored = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-L": true, "--location": true,
+	"-i": true, "--include": true, "-v": true, "--verbose": true, "--compressed": true,
+	"-g": true, "--globoff": true, "--http1.1": true, "--http2": true,
+}
+
+// shellSplit splits a POSIX shell command line: single and double quotes,
+// bash $'...' strings, backslash escapes and line continuations.
+func shellSplit(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			i++
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				cur.WriteByte(s[i])
+			}
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			j := i + 2
+			for ; j < len(s) && s[j] != '\''; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+					switch s[j] {
+					case 'n':
+						cur.WriteByte('\n')
+					case 't':
+						cur.WriteByte('\t')
+					case 'r':
+						cur.WriteByte('\r')
+					default:
+						cur.WriteByte(s[j])
+					}
+					continue
+				}
+				cur.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, errors.New("unterminated $'...' string")
+			}
+			i = j
+			inWord = true
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) && strings.IndexByte("\"\\$`\n", s[j+1]) >= 0 {
+					j++
+					if s[j] == '\n' {
+						continue
+					}
+				}
+				cur.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			i = j
+			inWord = true
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
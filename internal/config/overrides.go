@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix prefixes every environment override, e.g. SHARD_LOAD_RATE.
+const EnvPrefix = "SHARD"
+
+// ApplyEnv overrides scalar config fields from environment variables named
+// after their JSON path: load.rate becomes SHARD_LOAD_RATE, target.url
+// becomes SHARD_TARGET_URL. Maps and slices are not overridable this way.
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix, getenv)
+}
+
+func applyEnv(v reflect.Value, prefix string, getenv func(string) string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnv(fv, key, getenv); err != nil {
+				return err
+			}
+			continue
+		}
+		raw := getenv(key)
+		if raw == "" {
+			continue
+		}
+		if err := setScalar(fv, raw); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// setScalar parses raw into a string, bool, int, float or *bool field, or a
+// field that parses itself (encoding.TextUnmarshaler, e.g. Workers).
+func setScalar(fv reflect.Value, raw string) error {
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+	if fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Bool {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(&b))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
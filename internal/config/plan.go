@@ -0,0 +1,307 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Unbounded is the length of the steady stage of a run without an end (see
+// LoadConfig.Duration). It is long enough never to be reached and short
+// enough that adding the other stages or an extension can't overflow.
+const Unbounded = time.Duration(1 << 62)
+
+// IsUnbounded reports whether a stage or plan length is Unbounded.
+func IsUnbounded(d time.Duration) bool { return d >= Unbounded }
+
+// Unbounded reports whether the run has no planned end: duration "0" or
+// "infinite".
+func (l LoadConfig) Unbounded() bool {
+	if l.Duration == "infinite" {
+		return true
+	}
+	d, err := time.ParseDuration(l.Duration)
+	return err == nil && d == 0
+}
+
+// DefaultWarmupRate is the load.warmup_rate Validate fills in when none is
+// set: a tenth of load.rate, at least 1/s.
+func (l LoadConfig) DefaultWarmupRate() int { return max(1, l.Rate/10) }
+
+// soakSegmentMB is the rotation size given to unbounded runs that set none.
+const soakSegmentMB = 1024
+
+// Stage is one segment of the planned run. The rate moves linearly from
+// FromRate to ToRate over the stage; both are equal for flat stages.
+type Stage struct {
+	Name     string
+	Duration time.Duration
+	FromRate float64
+	ToRate   float64
+}
+
+// Plan returns the stages of the run in order: warmup, ramp, steady and
+// cooldown, skipping any with zero length. A load.mode search has the
+// warmup and then one "search" stage of unknown length, from the start
+// rate; a load.schedule_file replay one "replay" stage at its average rate.
+// Call it on a validated config.
+func (c *Config) Plan() []Stage {
+	if len(c.schedule) > 0 {
+		length := ScheduleLength(c.schedule)
+		n := 0
+		for _, w := range c.schedule {
+			n += w.Count
+		}
+		avg := float64(n) / length.Seconds()
+		return []Stage{{Name: "replay", Duration: length, FromRate: avg, ToRate: avg}}
+	}
+	total, _ := time.ParseDuration(c.Load.Duration)
+	if c.Load.Unbounded() {
+		total = Unbounded
+	}
+	warmup, _ := parseOptionalDuration(c.Load.Warmup)
+	ramp, _ := parseOptionalDuration(c.Load.Ramp)
+	rate := float64(c.Load.Rate)
+	low := float64(c.Load.WarmupRate)
+
+	var stages []Stage
+	if warmup > 0 {
+		stages = append(stages, Stage{Name: "warmup", Duration: warmup, FromRate: low, ToRate: low})
+	}
+	if c.Load.Searching() && c.Load.Search != nil {
+		start := float64(c.Load.Search.StartRate)
+		return append(stages, Stage{Name: "search", Duration: Unbounded, FromRate: start, ToRate: start})
+	}
+	if ramp > 0 {
+		stages = append(stages, Stage{Name: "ramp", Duration: ramp, FromRate: low, ToRate: rate})
+	}
+	if steady := total - warmup - ramp; IsUnbounded(total) {
+		stages = append(stages, Stage{Name: "steady", Duration: Unbounded, FromRate: rate, ToRate: rate})
+	} else if steady > 0 {
+		stages = append(stages, Stage{Name: "steady", Duration: steady, FromRate: rate, ToRate: rate})
+	}
+	if cooldown, _ := parseOptionalDuration(c.Load.Cooldown.Duration); cooldown > 0 {
+		trickle := float64(c.Load.Cooldown.Rate)
+		stages = append(stages, Stage{Name: "cooldown", Duration: cooldown, FromRate: trickle, ToRate: trickle})
+	}
+	return stages
+}
+
+// PlanDuration is the total length of all stages, Unbounded when one of
+// them is.
+func PlanDuration(stages []Stage) time.Duration {
+	var d time.Duration
+	for _, s := range stages {
+		if IsUnbounded(s.Duration) {
+			return Unbounded
+		}
+		d += s.Duration
+	}
+	return d
+}
+
+// ExtendPlan returns a copy of stages running by longer. The steady stage
+// grows; without one, a steady stage at the last rate reached is inserted
+// before cooldown.
+func ExtendPlan(stages []Stage, by time.Duration) []Stage {
+	out := append([]Stage(nil), stages...)
+	at := len(out)
+	for i, s := range out {
+		if s.Name == "steady" {
+			out[i].Duration += by
+			return out
+		}
+		if s.Name == "cooldown" {
+			at = i
+		}
+	}
+	var rate float64
+	if at > 0 {
+		rate = out[at-1].ToRate
+	}
+	extra := Stage{Name: "steady", Duration: by, FromRate: rate, ToRate: rate}
+	return append(out[:at], append([]Stage{extra}, out[at:]...)...)
+}
+
+// PlanRequests estimates how many requests the stages schedule in total,
+// +Inf for an unbounded plan.
+func PlanRequests(stages []Stage) float64 {
+	var n float64
+	for _, s := range stages {
+		if IsUnbounded(s.Duration) {
+			return math.Inf(1)
+		}
+		n += (s.FromRate + s.ToRate) / 2 * s.Duration.Seconds()
+	}
+	return n
+}
+
+// validateBound checks that the run ends: load.duration, load.total_requests
+// or both bound it, unless load.run_forever says it should not. Unbounded
+// runs rotate their results unless output sets a size.
+func (c *Config) validateBound() error {
+	if c.Load.TotalRequests < 0 {
+		return errors.New("load.total_requests must be >= 0")
+	}
+	if !c.Load.Unbounded() {
+		if d, err := time.ParseDuration(c.Load.Duration); err != nil || d < 0 {
+			return fmt.Errorf("invalid load.duration %q (want e.g. 30s, or 0/infinite for no end)", c.Load.Duration)
+		}
+		return nil
+	}
+	if c.Load.TotalRequests == 0 && !c.Load.RunForever {
+		return fmt.Errorf("load.duration %q never ends: set load.total_requests, or load.run_forever to run until interrupted", c.Load.Duration)
+	}
+	if c.Output.MaxFileSize == "" && c.Output.MaxFileSizeMB == 0 && c.Output.JSONLPath != "-" {
+		c.Output.MaxFileSizeMB = soakSegmentMB
+		c.notices = append(c.notices, fmt.Sprintf("load.duration %q: results rotate into %dMB segments (set output.max_file_size_mb to change)",
+			c.Load.Duration, soakSegmentMB))
+	}
+	return nil
+}
+
+// validatePlan cross-checks duration against warmup and ramp, and the whole
+// plan against max_run_time.
+func (c *Config) validatePlan() error {
+	warmup, err := parseOptionalDuration(c.Load.Warmup)
+	if err != nil || warmup < 0 {
+		return fmt.Errorf("invalid load.warmup %q", c.Load.Warmup)
+	}
+	ramp, err := parseOptionalDuration(c.Load.Ramp)
+	if err != nil || ramp < 0 {
+		return fmt.Errorf("invalid load.ramp %q", c.Load.Ramp)
+	}
+	maxRun, err := parseOptionalDuration(c.Load.MaxRunTime)
+	if err != nil || maxRun < 0 {
+		return fmt.Errorf("invalid load.max_run_time %q", c.Load.MaxRunTime)
+	}
+	cooldown, err := parseOptionalDuration(c.Load.Cooldown.Duration)
+	if err != nil || cooldown < 0 {
+		return fmt.Errorf("invalid load.cooldown.duration %q", c.Load.Cooldown.Duration)
+	}
+	if c.Load.Cooldown.Rate < 0 {
+		return errors.New("load.cooldown.rate must be >= 0")
+	}
+	if c.Load.Cooldown.Rate == 0 {
+		c.Load.Cooldown.Rate = 1
+	}
+	if cooldown > 0 && c.Load.Unbounded() {
+		return fmt.Errorf("load.cooldown needs a bounded load.duration, not %q", c.Load.Duration)
+	}
+	if c.Load.WarmupRate < 0 {
+		return errors.New("load.warmup_rate must be >= 0")
+	}
+	if c.Load.WarmupRate == 0 {
+		c.Load.WarmupRate = c.Load.DefaultWarmupRate()
+	}
+	if c.Load.Searching() {
+		// steps are sized by the search, and max_run_time ends it early
+		return c.validateStageOverrides()
+	}
+
+	duration, _ := time.ParseDuration(c.Load.Duration)
+	if lead := warmup + ramp; lead > 0 && duration < lead && !c.Load.Unbounded() {
+		if !c.Load.AutoExtend {
+			return fmt.Errorf("load.duration %s does not cover warmup %s + ramp %s; "+
+				"increase load.duration or set load.auto_extend to treat it as the steady-state time",
+				duration, warmup, ramp)
+		}
+		extended := lead + duration
+		c.notices = append(c.notices, fmt.Sprintf("load.duration extended from %s to %s to cover warmup %s + ramp %s",
+			duration, extended, warmup, ramp))
+		c.Load.Duration = extended.String()
+	}
+
+	if maxRun > 0 && c.Load.Unbounded() {
+		return fmt.Errorf("load.duration %q has no end to fit in load.max_run_time %s", c.Load.Duration, maxRun)
+	}
+	if total := PlanDuration(c.Plan()); maxRun > 0 && total > maxRun {
+		return fmt.Errorf("planned run of %s exceeds load.max_run_time %s", total, maxRun)
+	}
+	return c.validateStageOverrides()
+}
+
+// validateStageOverrides checks that every target.stages entry names a
+// stage of the plan and changes something. Scenario steps build their own
+// requests, so scenario runs take no overrides.
+func (c *Config) validateStageOverrides() error {
+	if len(c.Target.Stages) == 0 {
+		return nil
+	}
+	if c.Scenario != nil {
+		return errors.New("target.stages does not apply to scenario runs")
+	}
+	var planned []string
+	for _, s := range c.Plan() {
+		planned = append(planned, s.Name)
+	}
+	names := make([]string, 0, len(c.Target.Stages))
+	for name := range c.Target.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !slices.Contains(planned, name) {
+			return fmt.Errorf("target.stages.%s: the plan has no %s stage (it has %s)", name, name, strings.Join(planned, ", "))
+		}
+		if o := c.Target.Stages[name]; len(o.Headers) == 0 && o.BodyFile == "" {
+			return fmt.Errorf("target.stages.%s overrides nothing; set headers or body_file", name)
+		}
+	}
+	return nil
+}
+
+// FormatPlan renders a one-line description and a proportional bar, e.g.
+//
+//	warmup 30s @5/s → ramp 1m0s 5→50/s → steady 45s @50/s (total 2m15s)
+//	[#####=========----------]
+func FormatPlan(stages []Stage, width int) string {
+	var parts []string
+	for _, s := range stages {
+		if s.Name == "search" {
+			parts = append(parts, fmt.Sprintf("search from %g/s", s.FromRate))
+		} else if s.Name == "replay" {
+			parts = append(parts, fmt.Sprintf("replay %s averaging %.4g/s", s.Duration, s.ToRate))
+		} else if IsUnbounded(s.Duration) {
+			parts = append(parts, fmt.Sprintf("%s until stopped @%g/s", s.Name, s.ToRate))
+		} else if s.FromRate == s.ToRate {
+			parts = append(parts, fmt.Sprintf("%s %s @%g/s", s.Name, s.Duration, s.ToRate))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %s %g→%g/s", s.Name, s.Duration, s.FromRate, s.ToRate))
+		}
+	}
+	total := PlanDuration(stages)
+	if IsUnbounded(total) {
+		if stages[len(stages)-1].Name == "search" {
+			return strings.Join(parts, " → ") + " (until the search settles)"
+		}
+		return strings.Join(parts, " → ") + " (no end)"
+	}
+	line := strings.Join(parts, " → ") + fmt.Sprintf(" (total %s)", total)
+	if total <= 0 || len(stages) < 2 {
+		return line
+	}
+
+	marks := map[string]string{"warmup": "~", "ramp": "/", "steady": "=", "cooldown": "."}
+	var bar strings.Builder
+	for _, s := range stages {
+		n := max(1, int(float64(width)*float64(s.Duration)/float64(total)))
+		mark, ok := marks[s.Name]
+		if !ok {
+			mark = "#"
+		}
+		bar.WriteString(strings.Repeat(mark, n))
+	}
+	return line + "\n[" + bar.String() + "]"
+}
+
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileCheck describes one file the run depends on, as found during Prepare.
+type FileCheck struct {
+	Field string // config field that references the file, e.g. "target.body_file"
+	Path  string
+	Size  int64
+	Rows  int
+}
+
+// fileDeps lists every (field, path) pair the config reads from disk, as
+// RunFiles visits them.
+func (c *Config) fileDeps() [][2]string {
+	var deps [][2]string
+	c.RunFiles(func(field, path string) (string, error) {
+		deps = append(deps, [2]string{field, path})
+		return path, nil
+	})
+	return deps
+}
+
+// outputTargets lists every (field, path) pair the run creates. A rotated
+// output is represented by its first segment.
+func (c *Config) outputTargets() [][2]string {
+	var targets [][2]string
+	results, progress := c.Output.Paths(time.Now())
+	if p := results; p != "" && p != "-" {
+		if c.Output.SegmentSize() > 0 {
+			targets = append(targets, [2]string{"output.jsonl_path (segment)", SegmentPath(p, c.Output.Codec(p), 1)})
+		} else {
+			targets = append(targets, [2]string{"output.jsonl_path", p})
+		}
+	}
+	if progress != "" {
+		targets = append(targets, [2]string{"output.progress_path", progress})
+	}
+	return targets
+}
+
+// Prepare checks that every file the config depends on exists, is readable and
+// non-empty, and that every output is distinct and writable, before any output
+// is created. All problems are reported together.
+func (c *Config) Prepare() ([]FileCheck, error) {
+	var checks []FileCheck
+	var errs []error
+	for _, dep := range c.fileDeps() {
+		fc, err := checkFile(dep[0], dep[1])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		checks = append(checks, fc)
+	}
+
+	// every path, read or written, must be distinct from the outputs
+	seen := make(map[string]string)
+	for _, dep := range c.fileDeps() {
+		seen[absPath(dep[1])] = dep[0]
+	}
+	for _, t := range c.outputTargets() {
+		abs := absPath(t[1])
+		if other, ok := seen[abs]; ok {
+			errs = append(errs, fmt.Errorf("%s: %s is also used by %s", t[0], t[1], other))
+			continue
+		}
+		seen[abs] = t[0]
+		if err := checkWritable(t[0], t[1]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return checks, errors.Join(errs...)
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// checkWritable verifies path can be created in its directory with a
+// create-and-delete probe, and that an existing file there can be overwritten.
+func checkWritable(field, path string) error {
+	dir := filepath.Dir(path)
+	fi, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: directory %s does not exist", field, dir)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s: %s is not a directory", field, dir)
+	}
+	probe, err := os.CreateTemp(dir, ".shard-probe-*")
+	if err != nil {
+		return fmt.Errorf("%s: directory %s is not writable: %w", field, dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if fi, err := os.Stat(path); err == nil {
+		if fi.IsDir() {
+			return fmt.Errorf("%s: %s is a directory", field, path)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		f.Close()
+	}
+	return nil
+}
+
+func checkFile(field, path string) (FileCheck, error) {
+	fc := FileCheck{Field: field, Path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		return fc, fmt.Errorf("%s: %w", field, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fc, fmt.Errorf("%s: %w", field, err)
+	}
+	if fi.IsDir() {
+		return fc, fmt.Errorf("%s: %s is a directory", field, path)
+	}
+	fc.Size = fi.Size()
+	if fc.Size == 0 {
+		return fc, fmt.Errorf("%s: %s is empty", field, path)
+	}
+
+	rows, err := countRows(f)
+	if err != nil {
+		return fc, fmt.Errorf("%s: read %s: %w", field, path, err)
+	}
+	fc.Rows = rows
+	return fc, nil
+}
+
+// countRows counts newline-terminated rows, plus a trailing unterminated one.
+func countRows(r io.Reader) (int, error) {
+	buf := make([]byte, 64*1024)
+	rows := 0
+	last := byte('\n')
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			rows += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+	}
+	if last != '\n' {
+		rows++
+	}
+	return rows, nil
+}
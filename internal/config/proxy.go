@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Proxy routes requests through a forward proxy. URL names it (http://,
+// https:// or socks5://host:port); FromEnv takes it per request from
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY instead, as Go's
+// http.ProxyFromEnvironment does. Username and Password authenticate to
+// either. Without a proxy section the environment is ignored.
+type Proxy struct {
+	URL      string `json:"url,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	FromEnv  bool   `json:"from_env,omitempty"`
+}
+
+// Enabled reports whether requests go through a proxy.
+func (p Proxy) Enabled() bool { return p.URL != "" || p.FromEnv }
+
+// proxySchemes are the proxy URL schemes Go's transport can speak.
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+func (c *Config) validateProxy() error {
+	p := c.Proxy
+	switch {
+	case p.URL != "" && p.FromEnv:
+		return errors.New("proxy.url and proxy.from_env are mutually exclusive")
+	case !p.Enabled() && (p.Username != "" || p.Password != ""):
+		return errors.New("proxy.username and proxy.password need proxy.url or proxy.from_env")
+	case p.Password != "" && p.Username == "":
+		return errors.New("proxy.password needs proxy.username")
+	case p.Enabled() && c.Target.UnixSocket != "":
+		return errors.New("proxy can't be combined with target.unix_socket")
+	case p.Enabled() && (c.Target.Resolve.Once || c.Target.Resolve.IPOverride != ""):
+		return errors.New("proxy can't be combined with target.resolve once or ip_override: the proxy resolves the target")
+	}
+	if p.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy.url: %v", err)
+	}
+	if !proxySchemes[u.Scheme] || u.Hostname() == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return fmt.Errorf("invalid proxy.url %q: want http://, https:// or socks5://host:port", p.URL)
+	}
+	if u.User != nil && p.Username != "" {
+		return errors.New("proxy credentials are set both in proxy.url and in proxy.username")
+	}
+	return nil
+}
+
+// Credentials returns proxy.username and proxy.password as URL userinfo,
+// nil when unset.
+func (p Proxy) Credentials() *url.Userinfo {
+	switch {
+	case p.Username == "":
+		return nil
+	case p.Password == "":
+		return url.User(p.Username)
+	}
+	return url.UserPassword(p.Username, p.Password)
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"maps"
+	"net/url"
+	"strings"
+)
+
+// redactedValue replaces secrets in a Redacted config.
+const redactedValue = "REDACTED"
+
+// secretHeaderWords mark header names whose values are left out of
+// Redacted configs.
+var secretHeaderWords = []string{"auth", "token", "secret", "key", "cookie", "password", "session"}
+
+// Redacted returns a copy of c fit for writing into results files: values of
+// headers that usually carry credentials, seeded cookies and passwords in
+// URLs and the proxy and auth settings are replaced. c itself is not
+// changed.
+func (c Config) Redacted() Config {
+	c.Target.URL = redactURL(c.Target.URL)
+	c.Proxy.URL = redactURL(c.Proxy.URL)
+	redactSecret(&c.Proxy.Password)
+	redactSecret(&c.Auth.Basic.Password)
+	redactSecret(&c.Auth.Bearer.Token)
+	redactSecret(&c.Auth.OAuth2.ClientSecret)
+	c.Auth.OAuth2.TokenURL = redactURL(c.Auth.OAuth2.TokenURL)
+	c.Target.Headers = redactHeaders(c.Target.Headers)
+	if len(c.Target.Cookies) > 0 {
+		cookies := make(map[string]string, len(c.Target.Cookies))
+		for k := range c.Target.Cookies {
+			cookies[k] = redactedValue
+		}
+		c.Target.Cookies = cookies
+	}
+	if len(c.Target.Stages) > 0 {
+		stages := make(map[string]StageOverride, len(c.Target.Stages))
+		for name, o := range c.Target.Stages {
+			o.Headers = redactHeaders(o.Headers)
+			stages[name] = o
+		}
+		c.Target.Stages = stages
+	}
+	if c.Scenario != nil {
+		sc := *c.Scenario
+		sc.Steps = append([]Step(nil), sc.Steps...)
+		for i := range sc.Steps {
+			sc.Steps[i].URL = redactURL(sc.Steps[i].URL)
+			sc.Steps[i].Headers = redactHeaders(sc.Steps[i].Headers)
+		}
+		c.Scenario = &sc
+	}
+	c.notices = nil
+	return c
+}
+
+// redactSecret replaces a set secret.
+func redactSecret(s *string) {
+	if *s != "" {
+		*s = redactedValue
+	}
+}
+
+func redactHeaders(h map[string]string) map[string]string {
+	if h == nil {
+		return nil
+	}
+	out := maps.Clone(h)
+	for k := range out {
+		name := strings.ToLower(k)
+		for _, word := range secretHeaderWords {
+			if strings.Contains(name, word) {
+				out[k] = redactedValue
+				break
+			}
+		}
+	}
+	return out
+}
+
+// redactURL hides a password in the URL's userinfo; unparseable URLs are
+// kept as they are.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Scenario replaces the single target with an ordered chain of steps. Each
+// scheduled request runs the whole chain once on one worker; values
+// extracted from a step's response are available to later steps as
+// {{vars.name}} in their URL, headers and body. target.headers apply to
+// every step, and target.resolve to the target.url host.
+type Scenario struct {
+	Steps []Step `json:"steps"`
+}
+
+// Step is one request of a scenario.
+type Step struct {
+	Name     string            `json:"name"`
+	Method   string            `json:"method,omitempty"` // default GET
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	BodyFile string            `json:"body_file,omitempty"`
+	// Extract maps a variable name to where its value comes from in this
+	// step's response: "json:data.token", "regex:id=(\\d+)" or "header:Location".
+	Extract map[string]string `json:"extract,omitempty"`
+}
+
+// Extractor is a parsed Step.Extract source.
+type Extractor struct {
+	Kind  string // "json", "regex" or "header"
+	Path  []string
+	Re    *regexp.Regexp
+	Field string
+}
+
+// ParseExtractor parses an extract source such as "json:$.data.token".
+func ParseExtractor(spec string) (Extractor, error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return Extractor{}, fmt.Errorf("extract %q: want json:<path>, regex:<pattern> or header:<name>", spec)
+	}
+	switch kind {
+	case "json":
+		path := strings.TrimPrefix(strings.TrimPrefix(arg, "$"), ".")
+		return Extractor{Kind: kind, Path: strings.Split(path, ".")}, nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return Extractor{}, fmt.Errorf("extract %q: %w", spec, err)
+		}
+		return Extractor{Kind: kind, Re: re}, nil
+	case "header":
+		return Extractor{Kind: kind, Field: arg}, nil
+	default:
+		return Extractor{}, fmt.Errorf("extract %q: unknown source %q (want json, regex or header)", spec, kind)
+	}
+}
+
+var varRef = regexp.MustCompile(`\{\{\s*vars\.([A-Za-z0-9_]+)\s*\}\}`)
+
+// VarRefs returns the variable names referenced as {{vars.name}} in s.
+func VarRefs(s string) []string {
+	var names []string
+	for _, m := range varRef.FindAllStringSubmatch(s, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// ExpandVars replaces every {{vars.name}} in s. It fails on a variable that
+// has not been extracted yet.
+func ExpandVars(s string, vars map[string]string) (string, error) {
+	var missing string
+	out := varRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varRef.FindStringSubmatch(ref)[1]
+		v, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("variable %q is not set", missing)
+	}
+	return out, nil
+}
+
+func (s *Scenario) validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("scenario.steps must not be empty")
+	}
+	seen := make(map[string]bool)
+	defined := make(map[string]bool)
+	for i := range s.Steps {
+		st := &s.Steps[i]
+		if st.Name == "" {
+			return fmt.Errorf("scenario.steps[%d]: name is required", i)
+		}
+		if seen[st.Name] {
+			return fmt.Errorf("scenario.steps[%d]: duplicate name %q", i, st.Name)
+		}
+		seen[st.Name] = true
+		if st.URL == "" {
+			return fmt.Errorf("scenario step %q: url is required", st.Name)
+		}
+		if st.Method == "" {
+			st.Method = "GET"
+		}
+		st.Method = strings.ToUpper(st.Method)
+		if st.Body != "" && st.BodyFile != "" {
+			return fmt.Errorf("scenario step %q: set body or body_file, not both", st.Name)
+		}
+
+		// variables must be extracted by an earlier step
+		refs := VarRefs(st.URL + st.Body)
+		for _, v := range st.Headers {
+			refs = append(refs, VarRefs(v)...)
+		}
+		for _, name := range refs {
+			if !defined[name] {
+				return fmt.Errorf("scenario step %q: {{vars.%s}} is not extracted by an earlier step", st.Name, name)
+			}
+		}
+		if st.BodyFile != "" {
+			// an unreadable file is reported by Prepare
+			data, err := os.ReadFile(st.BodyFile)
+			if err == nil {
+				for _, name := range VarRefs(string(data)) {
+					if !defined[name] {
+						return fmt.Errorf("scenario step %q: {{vars.%s}} in body_file %s is not extracted by an earlier step", st.Name, name, st.BodyFile)
+					}
+				}
+			}
+		}
+		for name, spec := range st.Extract {
+			if _, err := ParseExtractor(spec); err != nil {
+				return fmt.Errorf("scenario step %q: %w", st.Name, err)
+			}
+			defined[name] = true
+		}
+	}
+	return nil
+}
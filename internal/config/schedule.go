@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleWindow is one row of load.schedule_file: Count requests
+// dispatched in the window from Offset into the run, which lasts Length
+// (until the next row). Target names the target the row was recorded for;
+// Shard runs one target per config, so it only has to be consistent.
+type ScheduleWindow struct {
+	Offset time.Duration
+	Length time.Duration
+	Target string
+	Count  int
+}
+
+// defaultWindow is the length of the last window of a one-row schedule.
+const defaultWindow = time.Second
+
+// Schedule returns the windows of load.schedule_file as Validate read
+// them, nil without one.
+func (c *Config) Schedule() []ScheduleWindow { return c.schedule }
+
+// ReadSchedule parses a schedule file: CSV rows of offset,count or
+// offset,target,count (an "offset" header row and # comments are skipped),
+// or JSONL objects {"offset": ..., "target": ..., "count": ...}. .csv
+// files are CSV, .jsonl, .ndjson and .json files JSONL, and anything else
+// is sniffed. Offsets are seconds from the start of the run, sub-second
+// ones as decimals, or Go durations ("1m30s"); they must increase. Every
+// window lasts until the next offset and the last one as long as the one
+// before it: end a schedule with a row of count 0 to set its length.
+func ReadSchedule(path string) ([]ScheduleWindow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rows []ScheduleWindow
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".csv":
+		rows, err = readScheduleCSV(data)
+	case ext == ".jsonl" || ext == ".ndjson" || ext == ".json":
+		rows, err = readScheduleJSONL(data)
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		rows, err = readScheduleJSONL(data)
+	default:
+		rows, err = readScheduleCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scheduleWindows(rows)
+}
+
+func readScheduleCSV(data []byte) ([]ScheduleWindow, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var rows []ScheduleWindow
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if len(rows) == 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "offset") {
+			continue // header
+		}
+		var w ScheduleWindow
+		switch len(rec) {
+		case 2:
+		case 3:
+			w.Target = strings.TrimSpace(rec[1])
+		default:
+			return nil, fmt.Errorf("line %d: want offset,count or offset,target,count, got %d fields", line, len(rec))
+		}
+		if w.Offset, err = parseOffset(rec[0]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if w.Count, err = strconv.Atoi(strings.TrimSpace(rec[len(rec)-1])); err != nil {
+			return nil, fmt.Errorf("line %d: invalid count %q", line, rec[len(rec)-1])
+		}
+		rows = append(rows, w)
+	}
+}
+
+func readScheduleJSONL(data []byte) ([]ScheduleWindow, error) {
+	var rows []ScheduleWindow
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var rec struct {
+			Offset json.RawMessage `json:"offset"`
+			Target string          `json:"target"`
+			Count  *int            `json:"count"`
+		}
+		if err := json.Unmarshal(text, &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Offset == nil || rec.Count == nil {
+			return nil, fmt.Errorf("line %d: want offset and count", line)
+		}
+		w := ScheduleWindow{Target: rec.Target, Count: *rec.Count}
+		var err error
+		var s string
+		if json.Unmarshal(rec.Offset, &s) == nil {
+			w.Offset, err = parseOffset(s)
+		} else {
+			w.Offset, err = parseOffset(string(rec.Offset))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, w)
+	}
+	return rows, sc.Err()
+}
+
+// parseOffset reads seconds ("1.25") or a Go duration ("1m30s").
+func parseOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		if secs < 0 || math.IsInf(secs, 0) || math.IsNaN(secs) || secs > maxOffsetSeconds {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid offset %q (want seconds, e.g. 1.5, or a duration, e.g. 1m30s)", s)
+	}
+	return d, nil
+}
+
+// maxOffsetSeconds caps offsets well below Unbounded.
+const maxOffsetSeconds = 100 * 365 * 24 * 3600
+
+// scheduleWindows checks the rows and sets every window's length.
+func scheduleWindows(rows []ScheduleWindow) ([]ScheduleWindow, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("no rows")
+	}
+	total := 0
+	targets := map[string]bool{}
+	for i, w := range rows {
+		if w.Count < 0 {
+			return nil, fmt.Errorf("row %d: count must be >= 0", i+1)
+		}
+		if i > 0 && w.Offset <= rows[i-1].Offset {
+			return nil, fmt.Errorf("row %d: offset %s does not come after %s", i+1, w.Offset, rows[i-1].Offset)
+		}
+		if w.Target != "" {
+			targets[w.Target] = true
+		}
+		total += w.Count
+	}
+	if total == 0 {
+		return nil, errors.New("no requests: every count is 0")
+	}
+	if len(targets) > 1 {
+		return nil, fmt.Errorf("rows name %d targets; a config runs one, so split the schedule per target", len(targets))
+	}
+	for i := range rows {
+		switch {
+		case i+1 < len(rows):
+			rows[i].Length = rows[i+1].Offset - rows[i].Offset
+		case i > 0:
+			rows[i].Length = rows[i-1].Length
+		default:
+			rows[i].Length = defaultWindow
+		}
+	}
+	// a closing row of count 0 only marks where the schedule ends
+	if last := rows[len(rows)-1]; last.Count == 0 && len(rows) > 1 {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+// ScheduleLength is how long a schedule runs.
+func ScheduleLength(windows []ScheduleWindow) time.Duration {
+	if len(windows) == 0 {
+		return 0
+	}
+	last := windows[len(windows)-1]
+	return last.Offset + last.Length
+}
+
+// validateSchedule reads load.schedule_file, which then sets the shape of
+// the run: load.rate becomes its peak rate (used to size the pool) and
+// load.duration its length.
+func (c *Config) validateSchedule() error {
+	c.schedule = nil
+	if c.Load.ScheduleFile == "" {
+		if c.Load.Burst {
+			return errors.New("load.burst needs load.schedule_file")
+		}
+		return nil
+	}
+	switch {
+	case c.Load.Searching():
+		return errors.New("load.schedule_file can't be combined with load.mode search")
+	case c.Load.Warmup != "" || c.Load.Ramp != "" || c.Load.Cooldown.Duration != "":
+		return errors.New("load.schedule_file sets the whole run; drop load.warmup, load.ramp and load.cooldown")
+	}
+	windows, err := ReadSchedule(c.Load.ScheduleFile)
+	if err != nil {
+		return fmt.Errorf("load.schedule_file %s: %w", c.Load.ScheduleFile, err)
+	}
+	peak := 0.0
+	for _, w := range windows {
+		peak = math.Max(peak, float64(w.Count)/w.Length.Seconds())
+	}
+	c.schedule = windows
+	c.Load.Rate = max(1, int(math.Ceil(peak)))
+	c.Load.Duration = ScheduleLength(windows).String()
+	return nil
+}
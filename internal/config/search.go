@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ModeSearch is the load.mode that looks for the highest rate the target
+// sustains instead of running a fixed plan.
+const ModeSearch = "search"
+
+// Search tunes load.mode "search". Steps of StageDuration (default 30s)
+// start at StartRate (default load.rate) and multiply by Factor (default 2)
+// for as long as they pass. A step fails when its error rate exceeds
+// MaxErrorRate (default 0.01), its p99 exceeds MaxP99Ms (no limit by
+// default), or Shard could not send at least 90% of its requests. The search
+// then bisects between the last passing and the first failing rate for
+// Iterations rounds (default 5). MaxRate, when set, ends the growth there.
+type Search struct {
+	StageDuration string  `json:"stage_duration,omitempty"`
+	StartRate     int     `json:"start_rate,omitempty"`
+	Factor        float64 `json:"factor,omitempty"`
+	MaxRate       int     `json:"max_rate,omitempty"`
+	MaxErrorRate  float64 `json:"max_error_rate,omitempty"`
+	MaxP99Ms      float64 `json:"max_p99_ms,omitempty"`
+	Iterations    int     `json:"iterations,omitempty"`
+}
+
+// Searching reports whether the run is a load.mode "search".
+func (l LoadConfig) Searching() bool { return l.Mode == ModeSearch }
+
+// validateMode checks load.mode and, for a search, fills in load.search.
+// A search sets its own rates and ends by itself, so the settings that
+// shape or bound a fixed plan are refused.
+func (c *Config) validateMode() error {
+	switch c.Load.Mode {
+	case "", "fixed":
+		return nil
+	case ModeSearch:
+	default:
+		return fmt.Errorf("invalid load.mode %q (want fixed or search)", c.Load.Mode)
+	}
+	switch {
+	case c.Load.TotalRequests > 0:
+		return errors.New("load.total_requests does not apply to load.mode search")
+	case c.Load.RunForever:
+		return errors.New("load.run_forever does not apply to load.mode search")
+	case c.Load.Ramp != "":
+		return errors.New("load.ramp does not apply to load.mode search; every step runs at a flat rate")
+	case c.Load.Cooldown.Duration != "":
+		return errors.New("load.cooldown does not apply to load.mode search")
+	}
+	if c.Load.Search == nil {
+		c.Load.Search = &Search{}
+	}
+	s := c.Load.Search
+	if s.StageDuration == "" {
+		s.StageDuration = "30s"
+	}
+	if d, err := time.ParseDuration(s.StageDuration); err != nil || d <= 0 {
+		return fmt.Errorf("invalid load.search.stage_duration %q", s.StageDuration)
+	}
+	if s.StartRate < 0 {
+		return errors.New("load.search.start_rate must be > 0")
+	}
+	if s.StartRate == 0 {
+		s.StartRate = c.Load.Rate
+	}
+	if s.Factor == 0 {
+		s.Factor = 2
+	}
+	if s.Factor <= 1 {
+		return fmt.Errorf("load.search.factor must be > 1, got %g", s.Factor)
+	}
+	if s.MaxRate != 0 && s.MaxRate < s.StartRate {
+		return fmt.Errorf("load.search.max_rate %d is below the start rate %d", s.MaxRate, s.StartRate)
+	}
+	if s.MaxErrorRate < 0 || s.MaxErrorRate >= 1 {
+		return errors.New("load.search.max_error_rate must be between 0 and 1")
+	}
+	if s.MaxErrorRate == 0 {
+		s.MaxErrorRate = 0.01
+	}
+	if s.MaxP99Ms < 0 {
+		return errors.New("load.search.max_p99_ms must be >= 0")
+	}
+	if s.Iterations < 0 {
+		return errors.New("load.search.iterations must be >= 0")
+	}
+	if s.Iterations == 0 {
+		s.Iterations = 5
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SLORule is one service level objective, written "<metric> <op> <value>",
+// e.g. "p99_ms <= 250" or "availability >= 99.9". Metrics are
+//
+//   - pN, avg, min, max (optionally with _ms): total latency in ms; any
+//     percentile, e.g. p99.9
+//   - <phase>.pN, <phase>.avg, ...: the same for one phase of the report's
+//     phase table, e.g. ttfb.p95
+//   - error_rate: failed requests as a fraction, 0 to 1
+//   - availability: requests without an error or 5xx, in percent
+//   - throughput: requests per second; requests: how many were measured
+//
+// and ops <, <=, > and >=.
+type SLORule struct {
+	Rule       string  // as written
+	Metric     string  // "latency", "error_rate", "availability", "throughput" or "requests"
+	Phase      string  // latency only
+	Stat       string  // latency only: "p", "avg", "min" or "max"
+	Percentile float64 // stat "p" only
+	Op         string
+	Value      float64
+}
+
+// sloPhases are the phases latency rules can name, as in the report.
+var sloPhases = map[string]bool{"dns": true, "connect": true, "proxy_connect": true, "tls": true, "ttfb": true, "total": true}
+
+// SLORules parses the slo section, nil when the config has none.
+func (c *Config) SLORules() ([]SLORule, error) {
+	return ParseSLO(c.SLO)
+}
+
+// ParseSLO parses SLO rules; errors name the rule by its index.
+func ParseSLO(rules []string) ([]SLORule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	out := make([]SLORule, len(rules))
+	for i, s := range rules {
+		r, err := parseSLORule(s)
+		if err != nil {
+			return nil, fmt.Errorf("slo[%d] %q: %w", i, s, err)
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+// ReadSLOFile reads the rules of an SLO file: an object with an slo list, as
+// in a config file.
+func ReadSLOFile(path string) ([]SLORule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		SLO []string `json:"slo"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(file.SLO) == 0 {
+		return nil, fmt.Errorf("%s: no slo rules", path)
+	}
+	return ParseSLO(file.SLO)
+}
+
+func parseSLORule(s string) (SLORule, error) {
+	r := SLORule{Rule: strings.Join(strings.Fields(s), " ")}
+	// the two-character ops first, so "<=" is not read as "<"
+	i, op := -1, ""
+	for _, o := range []string{"<=", ">=", "<", ">"} {
+		if i = strings.Index(s, o); i >= 0 {
+			op = o
+			break
+		}
+	}
+	if i < 0 {
+		return r, errors.New(`want "<metric> <op> <value>" with op <, <=, > or >=`)
+	}
+	r.Op = op
+	metric := strings.TrimSpace(s[:i])
+	v, err := strconv.ParseFloat(strings.TrimSpace(s[i+len(op):]), 64)
+	if err != nil {
+		return r, fmt.Errorf("invalid value %q", strings.TrimSpace(s[i+len(op):]))
+	}
+	r.Value = v
+	switch metric {
+	case "error_rate", "availability", "throughput", "requests":
+		r.Metric = metric
+		return r, nil
+	}
+	r.Metric, r.Phase = "latency", "total"
+	stat := metric
+	if phase, rest, ok := strings.Cut(metric, "."); ok && sloPhases[phase] {
+		r.Phase, stat = phase, rest
+	}
+	stat = strings.TrimSuffix(stat, "_ms")
+	switch {
+	case stat == "avg" || stat == "min" || stat == "max":
+		r.Stat = stat
+	case strings.HasPrefix(stat, "p"):
+		p, err := strconv.ParseFloat(stat[1:], 64)
+		if err != nil || p <= 0 || p > 100 {
+			return r, fmt.Errorf("invalid percentile %q: want p followed by (0, 100]", stat)
+		}
+		r.Stat, r.Percentile = "p", p
+	default:
+		return r, fmt.Errorf("unknown metric %q (want pN, avg, min, max, <phase>.pN with phase dns, connect, proxy_connect, tls, ttfb or total, error_rate, availability, throughput or requests)", metric)
+	}
+	if v < 0 {
+		return r, errors.New("latency bounds must be >= 0")
+	}
+	return r, nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Suite is a sequence of runs that `shard suite` executes one after another.
+// A run's rate can be derived from a metric of an earlier run's summary,
+// e.g. a soak at 80% of the throughput a capacity run reached.
+type Suite struct {
+	// Dir receives every run's results, progress log and summary JSON as
+	// <name>.jsonl, <name>.progress.log and <name>.summary.json. Relative
+	// paths, like each run's config, are taken from the suite file's
+	// directory; default is that directory itself.
+	Dir  string     `json:"dir,omitempty"`
+	Runs []SuiteRun `json:"runs"`
+}
+
+// SuiteRun is one run of a suite: a regular config file plus overrides.
+type SuiteRun struct {
+	Name     string   `json:"name"`
+	Config   string   `json:"config"`
+	Rate     *RateRef `json:"rate,omitempty"` // overrides load.rate
+	Duration string   `json:"duration,omitempty"`
+}
+
+// RateRef is a suite run's rate: a number, or a reference to an earlier
+// run's summary metric such as "80% of runs.capacity.throughput". Without a
+// percentage the metric is used as is.
+type RateRef struct {
+	Fixed   int
+	Percent float64
+	Run     string
+	Metric  string // dotted path into the summary JSON
+	expr    string
+}
+
+var rateRefPattern = regexp.MustCompile(`^(?:([0-9]+(?:\.[0-9]+)?)%\s+of\s+)?runs\.([A-Za-z0-9_-]+)\.([A-Za-z0-9_.]+)$`)
+
+// ParseRateRef parses a rate reference expression.
+func ParseRateRef(s string) (RateRef, error) {
+	m := rateRefPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return RateRef{}, fmt.Errorf("rate %q: want a number or \"<pct>%% of runs.<name>.<metric>\"", s)
+	}
+	ref := RateRef{Percent: 100, Run: m[2], Metric: m[3], expr: s}
+	if m[1] != "" {
+		ref.Percent, _ = strconv.ParseFloat(m[1], 64)
+	}
+	if ref.Percent <= 0 {
+		return RateRef{}, fmt.Errorf("rate %q: percentage must be > 0", s)
+	}
+	return ref, nil
+}
+
+// Derived reports whether the rate comes from an earlier run.
+func (r RateRef) Derived() bool { return r.Run != "" }
+
+func (r RateRef) String() string {
+	if r.Derived() {
+		return r.expr
+	}
+	return strconv.Itoa(r.Fixed)
+}
+
+func (r RateRef) MarshalJSON() ([]byte, error) {
+	if r.Derived() {
+		return json.Marshal(r.expr)
+	}
+	return json.Marshal(r.Fixed)
+}
+
+func (r *RateRef) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		ref, err := ParseRateRef(s)
+		if err != nil {
+			return err
+		}
+		*r = ref
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("rate must be a number or a reference to an earlier run: %w", err)
+	}
+	*r = RateRef{Fixed: n}
+	return nil
+}
+
+var suiteRunName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ReadSuite reads and validates a suite file, resolving Dir and every
+// run's config path against the file's directory.
+func ReadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("open suite: %w", err)
+	}
+	var s Suite
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse suite: %w", err)
+	}
+	base := filepath.Dir(path)
+	s.Dir = resolveFrom(base, s.Dir)
+	for i := range s.Runs {
+		if s.Runs[i].Config != "" {
+			s.Runs[i].Config = resolveFrom(base, s.Runs[i].Config)
+		}
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func resolveFrom(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// validate checks the suite's shape; references must name an earlier run.
+// Whether a metric exists is up to the caller, which knows the summary.
+func (s *Suite) validate() error {
+	if len(s.Runs) == 0 {
+		return errors.New("suite has no runs")
+	}
+	seen := map[string]bool{}
+	for _, run := range s.Runs {
+		if !suiteRunName.MatchString(run.Name) {
+			return fmt.Errorf("suite run name %q: use letters, digits, - and _", run.Name)
+		}
+		if seen[run.Name] {
+			return fmt.Errorf("suite run %q appears twice", run.Name)
+		}
+		if run.Config == "" {
+			return fmt.Errorf("suite run %q: config is required", run.Name)
+		}
+		if run.Duration != "" {
+			if _, err := parseOptionalDuration(run.Duration); err != nil {
+				return fmt.Errorf("suite run %q: invalid duration %q", run.Name, run.Duration)
+			}
+		}
+		if r := run.Rate; r != nil {
+			if !r.Derived() && r.Fixed <= 0 {
+				return fmt.Errorf("suite run %q: rate must be > 0", run.Name)
+			}
+			if r.Derived() && !seen[r.Run] {
+				return fmt.Errorf("suite run %q: rate %q refers to %q, which does not run before it", run.Name, r, r.Run)
+			}
+		}
+		seen[run.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import "fmt"
+
+// Threshold is a latency SLA on the total time of measured requests, e.g.
+// p95 <= 300ms. Mode "overall" (default) checks the percentile over the whole
+// run; "per-bucket" checks it in every time bucket of the report's timeline
+// and passes when at least MinPass of them hold (default 1: every bucket), so
+// a two-minute breakdown can't hide in a good overall figure.
+type Threshold struct {
+	Percentile float64 `json:"percentile"` // e.g. 95
+	MaxMs      float64 `json:"max_ms"`
+	Mode       string  `json:"mode,omitempty"`
+	MinPass    float64 `json:"min_pass,omitempty"` // fraction of buckets, per-bucket mode only
+}
+
+// SLAThresholds returns the validated thresholds with defaults applied, or
+// nil when the config has none.
+func (c *Config) SLAThresholds() ([]Threshold, error) {
+	if len(c.Thresholds) == 0 {
+		return nil, nil
+	}
+	out := make([]Threshold, len(c.Thresholds))
+	for i, t := range c.Thresholds {
+		if t.Percentile <= 0 || t.Percentile > 100 {
+			return nil, fmt.Errorf("thresholds[%d]: percentile must be in (0, 100]", i)
+		}
+		if t.MaxMs <= 0 {
+			return nil, fmt.Errorf("thresholds[%d]: max_ms must be > 0", i)
+		}
+		switch t.Mode {
+		case "":
+			t.Mode = "overall"
+		case "overall", "per-bucket":
+		default:
+			return nil, fmt.Errorf("thresholds[%d]: invalid mode %q (want overall or per-bucket)", i, t.Mode)
+		}
+		if t.MinPass < 0 || t.MinPass > 1 {
+			return nil, fmt.Errorf("thresholds[%d]: min_pass must be between 0 and 1", i)
+		}
+		if t.Mode == "overall" && t.MinPass != 0 {
+			return nil, fmt.Errorf("thresholds[%d]: min_pass needs mode per-bucket", i)
+		}
+		if t.Mode == "per-bucket" && t.MinPass == 0 {
+			t.MinPass = 1
+		}
+		out[i] = t
+	}
+	return out, nil
+}
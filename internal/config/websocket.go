@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ProtocolWebSocket is the target.protocol that sends WebSocket messages
+// over long-lived connections instead of HTTP requests.
+const ProtocolWebSocket = "websocket"
+
+// WebSocket tunes target.protocol "websocket". Every worker holds one
+// connection and sends one message per scheduled request: the lines of
+// target.body_file in turn with MessagePerLine, otherwise the whole file.
+// ExpectEcho waits for the server's reply to each message and times the
+// round trip; without it a message is done once written. PingInterval pings
+// every connection that often and records the pong's round trip.
+type WebSocket struct {
+	ExpectEcho     bool   `json:"expect_echo,omitempty"`
+	MessagePerLine bool   `json:"message_per_line,omitempty"`
+	PingInterval   string `json:"ping_interval,omitempty"`
+}
+
+// UsesWebSocket reports whether the target is a WebSocket endpoint.
+func (t Target) UsesWebSocket() bool { return t.Protocol == ProtocolWebSocket }
+
+// validateProtocol checks target.protocol and target.websocket. Settings
+// that only mean something per HTTP request are refused for WebSocket.
+func (c *Config) validateProtocol() error {
+	switch c.Target.Protocol {
+	case "", "http":
+		if c.Target.WebSocket != nil {
+			return errors.New("target.websocket needs target.protocol websocket")
+		}
+		return nil
+	case ProtocolWebSocket:
+	default:
+		return fmt.Errorf("invalid target.protocol %q (want http or websocket)", c.Target.Protocol)
+	}
+	u, err := url.Parse(c.Target.URL)
+	if err != nil {
+		return fmt.Errorf("invalid target.url: %v", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss", "http", "https":
+	default:
+		return fmt.Errorf("target.url %q: a WebSocket target needs a ws:// or wss:// URL", c.Target.URL)
+	}
+	switch {
+	case c.Scenario != nil:
+		return errors.New("scenario does not apply to target.protocol websocket")
+	case len(c.Target.Stages) > 0:
+		return errors.New("target.stages does not apply to target.protocol websocket")
+	case c.Target.RequestID || c.Target.EchoRequestIDHeader != "":
+		return errors.New("target.request_id does not apply to target.protocol websocket")
+	case c.Load.Retries.MaxAttempts > 1:
+		return errors.New("load.retries does not apply to target.protocol websocket")
+	case c.Load.ClientAbort.Fraction > 0:
+		return errors.New("load.client_abort does not apply to target.protocol websocket")
+	case c.Load.IdentityFraction > 0:
+		return errors.New("load.identity_fraction does not apply to target.protocol websocket")
+	case c.Load.Baseline != nil:
+		return errors.New("load.baseline does not apply to target.protocol websocket")
+	case c.Proxy.Enabled():
+		return errors.New("proxy is not supported with target.protocol websocket")
+	case c.Load.DisableKeepAlive:
+		return errors.New("load.disable_keepalive does not apply to target.protocol websocket; connections stay open by design")
+	}
+	if c.Target.WebSocket == nil {
+		c.Target.WebSocket = &WebSocket{}
+	}
+	if d, err := parseOptionalDuration(c.Target.WebSocket.PingInterval); err != nil || d < 0 {
+		return fmt.Errorf("invalid target.websocket.ping_interval %q", c.Target.WebSocket.PingInterval)
+	}
+	return nil
+}
+
+// PingEvery returns target.websocket.ping_interval, 0 for no pings.
+func (w *WebSocket) PingEvery() time.Duration {
+	if w == nil {
+		return 0
+	}
+	d, _ := parseOptionalDuration(w.PingInterval)
+	return d
+}
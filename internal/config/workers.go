@@ -0,0 +1,79 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Workers is load.concurrency: a fixed worker count, or "auto" to let the
+// runner size the pool to the load. In auto mode the pool starts at
+// AutoWorkersMin, grows while work queues up and shrinks back when workers
+// sit idle, never beyond AutoWorkersMax.
+type Workers int
+
+// AutoWorkers is the "auto" setting.
+const AutoWorkers Workers = -1
+
+const (
+	AutoWorkersMin = 8
+	AutoWorkersMax = 10000
+)
+
+// Auto reports whether the pool is sized automatically.
+func (w Workers) Auto() bool { return w == AutoWorkers }
+
+// Initial is the pool size a run starts with.
+func (w Workers) Initial() int {
+	if w.Auto() {
+		return AutoWorkersMin
+	}
+	return int(w)
+}
+
+func (w Workers) String() string {
+	if w.Auto() {
+		return "auto"
+	}
+	return strconv.Itoa(int(w))
+}
+
+// MarshalText and UnmarshalText also serve the -concurrency flag and
+// SHARD_LOAD_CONCURRENCY.
+func (w Workers) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+func (w *Workers) UnmarshalText(b []byte) error {
+	if string(b) == "auto" {
+		*w = AutoWorkers
+		return nil
+	}
+	n, err := strconv.Atoi(string(b))
+	if err != nil {
+		return fmt.Errorf("concurrency must be a worker count or \"auto\", got %q", b)
+	}
+	*w = Workers(n)
+	return nil
+}
+
+// MarshalJSON keeps counts as JSON numbers.
+func (w Workers) MarshalJSON() ([]byte, error) {
+	if w.Auto() {
+		return []byte(`"auto"`), nil
+	}
+	return []byte(strconv.Itoa(int(w))), nil
+}
+
+func (w *Workers) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		return w.UnmarshalText([]byte(s))
+	}
+	var n int
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("concurrency must be a worker count or \"auto\": %w", err)
+	}
+	*w = Workers(n)
+	return nil
+}
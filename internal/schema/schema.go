@@ -0,0 +1,258 @@
+// Package schema describes Shard's machine outputs as JSON Schema documents
+// and checks documents against them. The schemas are generated from the Go
+// types the outputs are encoded from, so they follow every field change.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+	"shard/internal/stats"
+)
+
+// Schema is a JSON Schema document or subschema.
+type Schema = map[string]any
+
+// Dialect is the JSON Schema version the documents use.
+const Dialect = "https://json-schema.org/draft/2020-12/schema"
+
+// kind is one machine output Shard writes.
+type kind struct {
+	name   string
+	title  string
+	of     any
+	record bool // a typed JSONL record, told apart by its "type" field
+}
+
+// kinds lists every output: rows and typed records of results files, and
+// the summary `report -format json` and suites write.
+var kinds = []kind{
+	{"result", "Shard result: one request, one line of a results file", attack.Result{}, false},
+	{"meta", "Shard meta record: the first line of a results file", attack.MetaRecord{}, true},
+	{"annotation", "Shard annotation: an event during the run", attack.Annotation{}, true},
+	{"progress", "Shard progress record: the live counters at a progress tick", attack.ProgressRecord{}, true},
+	{"stop", "Shard stop record: scheduled requests the stop policy discarded", attack.StopRecord{}, true},
+	{"workers", "Shard workers record: the auto concurrency pool's peak", attack.WorkersRecord{}, true},
+	{"search", "Shard search record: the outcome of a load.mode search", attack.SearchRecord{}, true},
+	{"replay", "Shard replay record: the schedule a load.schedule_file replay followed", attack.ReplayRecord{}, true},
+	{"auth", "Shard auth record: the OAuth2 tokens a run fetched and refreshed", attack.AuthRecord{}, true},
+	{"prune", "Shard prune record: how a results file was thinned", stats.PruneRecord{}, true},
+	{"summary", "Shard summary: report -format json", stats.Summary{}, false},
+}
+
+// Kinds returns the name of every output with a schema, in a stable order.
+func Kinds() []string {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = k.name
+	}
+	return names
+}
+
+// Document returns the schema of the named output. Every document carries
+// x-shard-schema-version, the attack.SchemaVersion that results and meta
+// records are written with.
+func Document(name string) (Schema, error) {
+	for _, k := range kinds {
+		if k.name != name {
+			continue
+		}
+		g := generator{defs: map[string]Schema{}}
+		t := reflect.TypeOf(k.of)
+		root := g.object(t)
+		if k.record {
+			root["properties"].(Schema)["type"] = Schema{"const": k.name}
+		}
+		if t == reflect.TypeOf(attack.Result{}) {
+			// written by Result.MarshalJSON
+			root["properties"].(Schema)["v"] = Schema{"const": attack.SchemaVersion}
+			root["required"] = append(root["required"].([]string), "v")
+		}
+		doc := Schema{
+			"$schema":                Dialect,
+			"title":                  k.title,
+			"x-shard-schema-version": attack.SchemaVersion,
+		}
+		for key, v := range root {
+			doc[key] = v
+		}
+		if len(g.defs) > 0 {
+			doc["$defs"] = g.defs
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("no schema for %q (want one of %s)", name, strings.Join(Kinds(), ", "))
+}
+
+// generator builds schemas for Go types the way encoding/json encodes
+// them. Named structs other than the root go into defs, once each.
+type generator struct {
+	defs map[string]Schema
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	workersType  = reflect.TypeOf(config.Workers(0))
+	marshaler    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshal  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *generator) of(t reflect.Type) Schema {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case durationType:
+		// results files carry durations as float milliseconds
+		return Schema{"type": "number", "description": "milliseconds"}
+	case workersType:
+		return Schema{"type": []string{"integer", "string"}, "description": `a worker count or "auto"`}
+	}
+	if t.Kind() != reflect.Pointer && t.Implements(marshaler) {
+		return Schema{"description": "custom encoding"}
+	}
+	if t.Implements(textMarshal) && !t.Implements(marshaler) {
+		return Schema{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Pointer:
+		return g.of(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": g.of(t.Elem())}
+	case reflect.Map:
+		// integer keys are encoded as strings too
+		return Schema{"type": "object", "additionalProperties": g.of(t.Elem())}
+	case reflect.Struct:
+		name := defName(t)
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = Schema{} // placeholder, in case the type refers to itself
+			g.defs[name] = g.object(t)
+		}
+		return Schema{"$ref": "#/$defs/" + name}
+	}
+	return Schema{}
+}
+
+// nullable widens s to also allow null, for pointers, slices and maps that
+// are encoded even when nil.
+func nullable(s Schema) Schema {
+	if ref, ok := s["$ref"]; ok {
+		return Schema{"anyOf": []Schema{{"$ref": ref}, {"type": "null"}}}
+	}
+	switch typ := s["type"].(type) {
+	case string:
+		s["type"] = []string{typ, "null"}
+	case []string:
+		s["type"] = append(typ, "null")
+	}
+	return s
+}
+
+// object is the schema of a struct: its encoded fields, those without
+// omitempty required, and nothing else.
+func (g *generator) object(t reflect.Type) Schema {
+	props := Schema{}
+	var required []string
+	for _, f := range fields(t) {
+		s := g.of(f.typ)
+		switch f.typ.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			if !f.omitempty {
+				s = nullable(s)
+			}
+		}
+		props[f.name] = s
+		if !f.omitempty {
+			required = append(required, f.name)
+		}
+	}
+	sort.Strings(required)
+	return Schema{"type": "object", "properties": props, "required": required, "additionalProperties": false}
+}
+
+type field struct {
+	name      string
+	typ       reflect.Type
+	omitempty bool
+}
+
+// fields lists the JSON fields of struct t, with embedded structs
+// flattened and shallower fields winning, as encoding/json does.
+func fields(t reflect.Type) []field {
+	var out []field
+	depth := map[string]int{} // of the field in out
+	var walk func(t reflect.Type, d int)
+	walk = func(t reflect.Type, d int) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if et := f.Type; f.Anonymous && name == "" {
+				if et.Kind() == reflect.Pointer {
+					et = et.Elem()
+				}
+				if et.Kind() == reflect.Struct {
+					walk(et, d+1)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fd := field{name: name, typ: f.Type, omitempty: strings.Contains(opts, "omitempty")}
+			if prev, ok := depth[name]; ok {
+				if prev <= d {
+					continue
+				}
+				for j := range out {
+					if out[j].name == name {
+						out[j] = fd
+					}
+				}
+			} else {
+				out = append(out, fd)
+			}
+			depth[name] = d
+		}
+	}
+	walk(t, 0)
+	return out
+}
+
+// defName names a struct's definition after its package and type, e.g.
+// "attack.Baseline", since config and attack both have a Baseline.
+func defName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return pkg + "." + t.Name()
+}
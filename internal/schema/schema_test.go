@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+	"shard/internal/stats"
+)
+
+type memSink struct{ bytes.Buffer }
+
+func (*memSink) Close() error { return nil }
+
+// runOutputs runs a short attack against an httptest server and returns its
+// results file, line by line, and its summary as report -format json
+// writes it.
+func runOutputs(t *testing.T) ([][]byte, []byte) {
+	t.Helper()
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1)%5 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Target.URL = srv.URL
+	cfg.Load.Rate, cfg.Load.Duration, cfg.Load.Concurrency = 20, "1500ms", 1
+	cfg.Output.ProgressInterval = "500ms"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	runner, err := attack.NewRunner(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// queued before the run, the reload's annotation opens its events
+	next := cfg
+	next.Load.Rate = 25
+	if _, err := runner.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	var sink memSink
+	if err := runner.RunSink(context.Background(), &sink, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	agg := stats.New()
+	var lines [][]byte
+	sc := bufio.NewScanner(&sink)
+	for sc.Scan() {
+		line := bytes.Clone(sc.Bytes())
+		lines = append(lines, line)
+		if err := agg.AddRecord(line); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(line, []byte(`{"type":`)) {
+			var res attack.Result
+			if err := json.Unmarshal(line, &res); err != nil {
+				t.Fatal(err)
+			}
+			agg.Add(res)
+		}
+	}
+	summary, err := json.Marshal(agg.Summary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lines, summary
+}
+
+func TestOutputsMatchSchemas(t *testing.T) {
+	lines, summary := runOutputs(t)
+
+	seen := make(map[string]int)
+	var meta attack.MetaRecord
+	for i, line := range lines {
+		var rec struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		kind := rec.Type
+		if kind == "" {
+			kind = "result"
+		}
+		if kind == "meta" {
+			if err := json.Unmarshal(line, &meta); err != nil {
+				t.Fatal(err)
+			}
+		}
+		doc, err := Document(kind)
+		if err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if err := Validate(doc, line); err != nil {
+			t.Fatalf("line %d (%s) %s: %v", i+1, kind, line, err)
+		}
+		seen[kind]++
+	}
+	for _, kind := range []string{"meta", "result", "annotation", "progress"} {
+		if seen[kind] == 0 {
+			t.Errorf("the run wrote no %s record to check, got %v", kind, seen)
+		}
+	}
+
+	doc, err := Document("summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(doc, summary); err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+
+	// every document carries the version the meta record was written with
+	for _, kind := range Kinds() {
+		doc, err := Document(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := doc["x-shard-schema-version"]; v != meta.Schema {
+			t.Fatalf("%s schema has version %v, the meta record %d", kind, v, meta.Schema)
+		}
+	}
+}
+
+// The schemas are strict: a field the types do not declare is rejected.
+func TestValidateRejectsUndeclaredField(t *testing.T) {
+	doc, err := Document("annotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(doc, []byte(`{"type":"annotation","ts":"2026-01-02T03:04:05Z","message":"hi"}`)); err != nil {
+		t.Fatalf("valid annotation rejected: %v", err)
+	}
+	if err := Validate(doc, []byte(`{"type":"annotation","ts":"2026-01-02T03:04:05Z","message":"hi","extra":1}`)); err == nil {
+		t.Fatal("an undeclared field passed")
+	}
+}
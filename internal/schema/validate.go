@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Validate checks one JSON document against doc, a schema from Document.
+// It understands the keywords the generated schemas use: type, const,
+// properties, required, additionalProperties, items, anyOf and local $ref.
+// Formats and descriptions are not checked.
+func Validate(doc Schema, data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	c := checker{defs: map[string]Schema{}}
+	if defs, ok := doc["$defs"].(map[string]Schema); ok {
+		c.defs = defs
+	}
+	return c.check(doc, v, "$")
+}
+
+type checker struct {
+	defs map[string]Schema
+}
+
+func (c checker) check(s Schema, v any, path string) error {
+	if ref, ok := s["$ref"].(string); ok {
+		def, ok := c.defs[strings.TrimPrefix(ref, "#/$defs/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown $ref %q", path, ref)
+		}
+		return c.check(def, v, path)
+	}
+	if alts, ok := s["anyOf"].([]Schema); ok {
+		var errs []string
+		for _, alt := range alts {
+			err := c.check(alt, v, path)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("%s: matches none of: %s", path, strings.Join(errs, "; "))
+	}
+	if want, ok := s["const"]; ok && !sameValue(want, v) {
+		return fmt.Errorf("%s: got %v, want %v", path, v, want)
+	}
+	if err := checkType(s["type"], v, path); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(Schema)
+		required, _ := s["required"].([]string)
+		for _, name := range required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sub, ok := props[name].(Schema)
+			if !ok {
+				switch extra := s["additionalProperties"].(type) {
+				case bool:
+					if !extra {
+						return fmt.Errorf("%s: unexpected field %q", path, name)
+					}
+					continue
+				case Schema:
+					sub = extra
+				default:
+					continue
+				}
+			}
+			if err := c.check(sub, v[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if items, ok := s["items"].(Schema); ok {
+			for i, item := range v {
+				if err := c.check(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkType checks v against a type keyword, a name or a list of names.
+func checkType(typ any, v any, path string) error {
+	var names []string
+	switch typ := typ.(type) {
+	case nil:
+		return nil
+	case string:
+		names = []string{typ}
+	case []string:
+		names = typ
+	}
+	for _, name := range names {
+		if isType(name, v) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: got %s, want %s", path, typeName(v), strings.Join(names, " or "))
+}
+
+func isType(name string, v any) bool {
+	switch name {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+// sameValue compares a const from a generated schema with a decoded value,
+// whose numbers are all float64.
+func sameValue(want, v any) bool {
+	if n, ok := want.(int); ok {
+		f, ok := v.(float64)
+		return ok && f == float64(n)
+	}
+	return want == v
+}
@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+
+	"shard/internal/attack"
+)
+
+// ClientAbortSummary covers the requests load.client_abort cancelled on
+// purpose, which are left out of every other figure, and how the target's
+// connections behaved afterwards: a pool that keeps handing out connections
+// broken by an abort shows up as failed follow-up requests.
+type ClientAbortSummary struct {
+	Aborted int `json:"aborted"`
+	// Conns is how many distinct connections had a request aborted on them,
+	// ConnsReused how many of those later carried another request.
+	Conns       int `json:"connections"`
+	ConnsReused int `json:"connections_reused"`
+	// The same worker's next request after an abort: sent on a pooled
+	// connection, on a newly dialed one, and how many of those failed.
+	NextReused int `json:"next_reused"`
+	NextNew    int `json:"next_new"`
+	NextFailed int `json:"next_failed"`
+}
+
+// abortTracking follows aborted requests' workers and connections.
+type abortTracking struct {
+	ClientAbortSummary
+	pending map[string]bool // workers whose next request follows an abort
+	conns   map[string]bool // aborted connections not seen again yet
+}
+
+func (a *Aggregator) addClientAbort(r attack.Result) {
+	t := &a.aborts
+	worker := groupID(r.Agent, uint64(r.Worker))
+	conn := groupID(r.Agent, r.Conn)
+	if r.Error == "client_abort" {
+		if t.pending == nil {
+			t.pending, t.conns = make(map[string]bool), make(map[string]bool)
+		}
+		t.Aborted++
+		if r.Worker > 0 {
+			t.pending[worker] = true
+		}
+		if r.Conn > 0 && !t.conns[conn] {
+			t.Conns++
+			t.conns[conn] = true
+		}
+		return
+	}
+	if t.Aborted == 0 {
+		return
+	}
+	if r.Conn > 0 && t.conns[conn] {
+		t.ConnsReused++
+		delete(t.conns, conn)
+	}
+	if t.pending[worker] {
+		delete(t.pending, worker)
+		if r.Reused {
+			t.NextReused++
+		} else {
+			t.NextNew++
+		}
+		if r.Error != "" {
+			t.NextFailed++
+		}
+	}
+}
+
+func (a *Aggregator) clientAbortSummary() *ClientAbortSummary {
+	if a.aborts.Aborted == 0 {
+		return nil
+	}
+	s := a.aborts.ClientAbortSummary
+	return &s
+}
+
+func printClientAborts(w io.Writer, c *ClientAbortSummary) {
+	fmt.Fprintf(w, "\nClient aborts (%d, excluded from the figures above):\n", c.Aborted)
+	fmt.Fprintf(w, "  connections   : %d aborted on, %d later reused\n", c.Conns, c.ConnsReused)
+	fmt.Fprintf(w, "  next request  : %d on a pooled connection, %d on a new one, %d failed\n",
+		c.NextReused, c.NextNew, c.NextFailed)
+	if c.NextFailed > 0 && c.NextReused > 0 {
+		fmt.Fprintln(w, "  ⚠️  requests after an abort fail: the pool may be handing out broken connections")
+	}
+}
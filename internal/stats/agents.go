@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// AgentSummary is one agent's share of a distributed run.
+type AgentSummary struct {
+	Requests   int     `json:"requests"`
+	Failed     int     `json:"failed"`
+	Throughput float64 `json:"throughput"` // achieved requests per second
+}
+
+type agentStats struct {
+	count, failed int
+	first, last   time.Time
+}
+
+func (a *Aggregator) addAgent(r attack.Result) {
+	if r.Agent == "" {
+		return
+	}
+	as := a.agents[r.Agent]
+	if as == nil {
+		as = &agentStats{first: r.Timestamp}
+		a.agents[r.Agent] = as
+	}
+	as.count++
+	if r.Error != "" {
+		as.failed++
+	}
+	if r.Timestamp.Before(as.first) {
+		as.first = r.Timestamp
+	}
+	if r.Timestamp.After(as.last) {
+		as.last = r.Timestamp
+	}
+}
+
+func (a *Aggregator) agentSummaries() map[string]AgentSummary {
+	if len(a.agents) == 0 {
+		return nil
+	}
+	out := make(map[string]AgentSummary, len(a.agents))
+	for name, as := range a.agents {
+		s := AgentSummary{Requests: as.count, Failed: as.failed}
+		if span := as.last.Sub(as.first).Seconds(); span > 0 {
+			s.Throughput = float64(as.count) / span
+		}
+		out[name] = s
+	}
+	return out
+}
+
+func printAgents(w io.Writer, agents map[string]AgentSummary) {
+	names := make([]string, 0, len(agents))
+	for n := range agents {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "\nAgents:")
+	for _, n := range names {
+		s := agents[n]
+		fmt.Fprintf(w, "  %-21s requests=%-7d failed=%-6d achieved=%.1f req/s\n", n, s.Requests, s.Failed, s.Throughput)
+	}
+}
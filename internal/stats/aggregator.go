@@ -2,33 +2,248 @@ package stats
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+
 	"shard/internal/attack"
+	"shard/internal/config"
 )
 
 // PhaseNames for consistent iteration
-var PhaseNames = []string{"dns", "connect", "tls", "ttfb", "total"}
+var PhaseNames = []string{"dns", "connect", "proxy_connect", "tls", "ttfb", "total"}
 
 type phaseStats struct {
-	Count int
-	Sum   float64
-	Min   float64
-	Max   float64
+	Count  int
+	Sum    float64
+	Min    float64
+	Max    float64
+	digest digest // bounded, see digest.go
 }
 
 type Aggregator struct {
 	count        int
+	failed       int
+	warmup       int
+	warmupLat    *phaseStats // pre-load baseline for cooldown recovery
+	cooldown     cooldownTracking
+	stages       map[string]*stageStats
 	status       map[int]int
 	errors       map[string]int
 	stats        map[string]*phaseStats
+	afterIdle    map[string]*phaseStats // reconnects after an idle gap, kept out of stats
 	failByPhase  map[string]int
 	statusFamily map[string]int
+	redirects    map[int]int
+	schedLag     *phaseStats
+	dateSkew     *phaseStats // see skew.go
+	noDate       int
+	unsent       int // scheduled but discarded by the stop policy
+	peakWorkers  int // auto concurrency peaks, summed over agents
+	peakBusy     int
+	agents       map[string]*agentStats
+	methods      map[string]*methodStats
+	hinted       int // requests that got a 1xx first, see hints.go
+
+	delivered int
+	results   int           // every result added, including warmup and cooldown
+	prunes    []PruneRecord // see prune.go
+
+	byStatus   map[string]*statusLatStats         // see statuslat.go
+	headerSecs map[string]map[int64]*headerSecond // numeric headers per second, see headers.go
+
+	histBounds []time.Duration // latency histogram, see histogram.go
+	histCounts []int
+
+	ackLat     digest // successful totals, see delayedack.go
+	tcpNoDelay *bool
+
+	runInfo runInfoTracking // meta records, see runinfo.go
+	search  searchTracking  // load.mode search steps, see search.go
+	replay  replayTracking  // load.schedule_file windows, see replay.go
+
+	baseline  *attack.Baseline // see baseline.go
+	baselines int
+
+	bytes       int64                         // response body bytes of measured traffic
+	compression compressionStats              // see compression.go
+	pricing     map[string]config.CostProfile // see cost.go
+
+	malformed int  // lines LoadJSONL could not parse
+	strict    bool // fail on them instead, see SetStrict
+
+	filter   *Filter // see filter.go
+	filtered int     // results it dropped
+
+	thresholds []config.Threshold // see sla.go
+	slaSecs    map[int64]*digest  // per-second totals, per-bucket thresholds only
+	slo        sloTracking        // see slo.go
+
+	rate rateTracking // see rate.go
+
+	raw bool // plain numbers in the text report, see human.go
+
+	// failure clustering, see clusters.go
+	byWorker map[string]*groupCounts
+	byConn   map[string]*groupCounts
+	connRest groupCounts // connections beyond clusterMaxConns
+
+	aborts  abortTracking // see aborts.go
+	retries RetrySummary  // see retries.go
+	auth    authTracking  // see auth.go
+
+	// DNS lookups per second, see dns.go
+	dnsSecs    map[int64]*dnsSecond
+	dnsByTotal map[int64]float64 // DNS ms by total latency digest bucket
+	dnsTTLMax  *uint32
+
+	// scenario runs, see scenario.go
+	steps            map[string]*stepStats
+	stepOrder        []string
+	iterations       int
+	iterationsFailed int
+
+	hintCodes map[int]int
+	hintGap   *phaseStats
+
+	webSocket webSocketStats // see websocket.go
+
+	// per-address timeline, see timeline.go
+	perAddr map[string]map[int64]*addrCounts
+	first   time.Time
+	last    time.Time
+	bucket  time.Duration
+
+	notes []string
+
+	errorBodies map[string]int // distinct non-2xx body samples, bounded by maxErrorBodies
+}
+
+// maxErrorBodies bounds how many distinct error body samples are tracked.
+const maxErrorBodies = 1000
+
+// BodyCount is a distinct captured body and how often it was seen.
+type BodyCount struct {
+	Body  string `json:"body"`
+	Count int    `json:"count"`
+}
+
+// PhaseSummary holds the computed timings of one phase, in milliseconds.
+type PhaseSummary struct {
+	Count int     `json:"count"`
+	Avg   float64 `json:"avg"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Total float64 `json:"total"`
+}
+
+// Summary is the computed view of everything the aggregator has seen.
+type Summary struct {
+	// Run describes the run from its meta record; files from before meta
+	// records leave it out.
+	Run *RunInfo `json:"run,omitempty"`
+	// Filter states the report filters in effect, when any.
+	Filter *FilterSummary `json:"filter,omitempty"`
+	// Pruned says how the run's data was thinned, when it was.
+	Pruned          *PruneInfo `json:"pruned,omitempty"`
+	Requests        int        `json:"requests"`
+	DurationSeconds float64    `json:"duration_seconds"`
+	Throughput      float64    `json:"throughput"` // requests per second
+	// Delivered counts requests that reached the server; the rest failed
+	// before anything was written (DNS, connect, TLS).
+	Delivered           int                     `json:"delivered"`
+	DeliveredThroughput float64                 `json:"delivered_throughput"` // delivered requests per second
+	BytesReceived       int64                   `json:"bytes_received"`
+	Warmup              int                     `json:"warmup_excluded,omitempty"`
+	Unsent              int                     `json:"unsent,omitempty"`       // scheduled, discarded by the stop policy
+	PeakWorkers         int                     `json:"peak_workers,omitempty"` // load.concurrency "auto" only
+	PeakBusyWorkers     int                     `json:"peak_busy_workers,omitempty"`
+	Malformed           int                     `json:"malformed_lines,omitempty"` // input lines skipped as unparseable
+	Failed              int                     `json:"failed"`
+	ErrorRate           float64                 `json:"error_rate"`
+	StatusCodes         map[int]int             `json:"status_codes"`
+	StatusFamily        map[string]int          `json:"status_family"`
+	Errors              map[string]int          `json:"errors"`
+	FailByPhase         map[string]int          `json:"fail_by_phase"`
+	Phases              map[string]PhaseSummary `json:"phases"`
+	// Baseline is the load.baseline calibration and the phases less it.
+	Baseline *BaselineSummary `json:"baseline,omitempty"`
+	// Redirects maps hop count to the number of requests that followed that many redirects.
+	Redirects         map[int]int `json:"redirects"`
+	RedirectLimitHits int         `json:"redirect_limit_hits"`
+	// ByStatus splits latency per status code, or per error class for
+	// requests that got no response; it is not part of Phases' breakdown.
+	ByStatus map[string]StatusLatency `json:"by_status,omitempty"`
+	// Headers summarizes the captured numeric response headers over time,
+	// using the timeline's buckets.
+	Headers map[string]HeaderSeries `json:"headers,omitempty"`
+	// Histogram is the total latency distribution of successful requests.
+	Histogram *LatencyHistogram `json:"histogram,omitempty"`
+	// DelayedACK flags a latency spike just above a delayed-ACK timeout.
+	DelayedACK *DelayedACKSummary `json:"delayed_ack,omitempty"`
+	// SchedLag is the self-inflicted delay between planned and actual dispatch.
+	SchedLag PhaseSummary `json:"sched_lag"`
+	// Rate compares the offered and achieved request rates over time.
+	Rate *RateSummary `json:"rate,omitempty"`
+	// Timeline breaks traffic down per remote address over time.
+	Timeline AddressTimeline `json:"timeline"`
+	// AfterIdle holds phase timings of requests that paid a reconnect after the
+	// pool idled out; they are excluded from Phases so quiet periods don't skew them.
+	AfterIdle map[string]PhaseSummary `json:"after_idle,omitempty"`
+	// ErrorBodies lists the most frequent captured non-2xx bodies.
+	ErrorBodies []BodyCount `json:"error_bodies,omitempty"`
+	// Cooldown is the post-load recovery curve, excluded from everything above.
+	Cooldown *CooldownSummary `json:"cooldown,omitempty"`
+	// Search is the outcome of a load.mode search, step by step.
+	Search *SearchSummary `json:"search,omitempty"`
+	// Replay compares a load.schedule_file replay's windows with what was sent.
+	Replay *ReplaySummary `json:"replay,omitempty"`
+	// Stages breaks every result down by plan stage, including warmup and cooldown.
+	Stages map[string]StageSummary `json:"stages,omitempty"`
+	// DateSkew is the server Date header vs the client clock, when recorded.
+	DateSkew *SkewSummary `json:"date_skew,omitempty"`
+	// DNS follows lookup latency over time and flags periodic spikes.
+	DNS *DNSSummary `json:"dns,omitempty"`
+	// Scenario breaks a scenario run down per step.
+	Scenario *ScenarioSummary `json:"scenario,omitempty"`
+	// EarlyHints covers requests that received 1xx responses before the final one.
+	EarlyHints *EarlyHintsSummary `json:"early_hints,omitempty"`
+	// WebSocket covers the connections of a target.protocol websocket run.
+	WebSocket *WebSocketSummary `json:"websocket,omitempty"`
+	// Methods breaks the measured traffic down per HTTP method.
+	Methods map[string]MethodSummary `json:"methods,omitempty"`
+	// Clusters flags workers and connections failing far more than the rest.
+	Clusters *ClusterSummary `json:"failure_clusters,omitempty"`
+	// Retries counts requests sent more than once under load.retries.
+	Retries *RetrySummary `json:"retries,omitempty"`
+	// Auth covers the OAuth2 token of the run and its 401s.
+	Auth *AuthSummary `json:"auth,omitempty"`
+	// Compression covers bodies Shard decoded: wire vs decoded size and decoding time.
+	Compression *CompressionSummary `json:"compression,omitempty"`
+	// ClientAborts covers requests cancelled on purpose by load.client_abort.
+	ClientAborts *ClientAbortSummary `json:"client_aborts,omitempty"`
+	// Agents breaks a distributed run down per agent, including achieved rates.
+	Agents map[string]AgentSummary `json:"agents,omitempty"`
+	// Cost prices the measured traffic per configured pricing profile.
+	Cost map[string]CostEstimate `json:"cost,omitempty"`
+	// Thresholds holds the verdict on each configured latency threshold.
+	Thresholds []ThresholdResult `json:"thresholds,omitempty"`
+	// SLO holds the verdict on each configured SLO rule.
+	SLO []SLOResult `json:"slo,omitempty"`
+	// Notes are the annotation messages recorded during the run.
+	Notes []string `json:"notes,omitempty"`
 }
 
 func New() *Aggregator {
@@ -36,17 +251,72 @@ func New() *Aggregator {
 		status:       make(map[int]int),
 		errors:       make(map[string]int),
 		stats:        make(map[string]*phaseStats),
+		afterIdle:    make(map[string]*phaseStats),
 		failByPhase:  make(map[string]int),
 		statusFamily: make(map[string]int),
+		redirects:    make(map[int]int),
+		schedLag:     &phaseStats{Min: 1e9},
+		dateSkew:     &phaseStats{Min: 1e9, Max: -1e9},
+		perAddr:      make(map[string]map[int64]*addrCounts),
+		errorBodies:  make(map[string]int),
+		warmupLat:    &phaseStats{Min: 1e9},
+		stages:       make(map[string]*stageStats),
+		agents:       make(map[string]*agentStats),
+		methods:      make(map[string]*methodStats),
+		hintCodes:    make(map[int]int),
+		steps:        make(map[string]*stepStats),
+		hintGap:      &phaseStats{Min: 1e9},
+		byWorker:     make(map[string]*groupCounts),
+		byStatus:     make(map[string]*statusLatStats),
+		headerSecs:   make(map[string]map[int64]*headerSecond),
+		byConn:       make(map[string]*groupCounts),
 	}
+	a.SetLatencyBuckets(DefaultLatencyBuckets)
 	for _, p := range PhaseNames {
 		a.stats[p] = &phaseStats{Min: 1e9} // initialize with large min
+		a.afterIdle[p] = &phaseStats{Min: 1e9}
 	}
 	return a
 }
 
 func (a *Aggregator) Add(r attack.Result) {
+	a.results++
+	a.addStage(r)
+	a.addAgent(r)
+	a.addRate(r)
+	// warmup traffic only primes the target and is not part of the measurement
+	switch r.Stage {
+	case "warmup":
+		a.warmup++
+		if r.Error == "" {
+			a.warmupLat.add(r.Phases.Total)
+		}
+		return
+	case "cooldown":
+		a.addCooldown(r)
+		return
+	}
+	// deliberate aborts would only skew the error rate and latencies
+	a.addClientAbort(r)
+	if r.Error == "client_abort" {
+		return
+	}
 	a.count++
+	if !r.Undelivered {
+		a.delivered++
+	}
+	a.bytes += r.Bytes
+	a.addMethod(r)
+	a.addSearch(r)
+	a.addReplay(r)
+	a.addHints(r)
+	a.addWebSocket(r)
+	a.addStep(r)
+	a.addCluster(r)
+	a.addRetries(r)
+	a.addAuth(r)
+	a.addSLO(r)
+	a.addCompression(r)
 
 	// --- handle status code ---
 	if r.Code > 0 {
@@ -60,46 +330,110 @@ func (a *Aggregator) Add(r attack.Result) {
 
 	// --- handle errors and failure phase ---
 	if r.Error != "" {
+		a.failed++
 		a.errors[r.Error]++
 	}
 	if r.FailPhase != "" {
 		a.failByPhase[r.FailPhase]++
 	}
+	a.redirects[r.Redirects]++
+	if r.BodySample != "" && (r.Code < 200 || r.Code >= 300) {
+		if _, ok := a.errorBodies[r.BodySample]; ok || len(a.errorBodies) < maxErrorBodies {
+			a.errorBodies[r.BodySample]++
+		}
+	}
+	a.addTimeline(r.Timestamp, r.RemoteAddr, r.Error != "")
 
 	// --- handle timings ---
-	update := func(phase string, d time.Duration) {
-		ms := float64(d.Milliseconds())
-		ps := a.stats[phase]
-		ps.Count++
-		ps.Sum += ms
-		if ms < ps.Min {
-			ps.Min = ms
-		}
-		if ms > ps.Max {
-			ps.Max = ms
-		}
+	phases := a.stats
+	if r.AfterIdle {
+		phases = a.afterIdle
+	}
+	phases["dns"].add(r.Phases.DNS)
+	phases["connect"].add(r.Phases.Connect)
+	// only tunnelled requests have the phase, so the others don't dilute it
+	if r.Phases.ProxyConnect > 0 {
+		phases["proxy_connect"].add(r.Phases.ProxyConnect)
 	}
-	update("dns", r.Phases.DNS)
-	update("connect", r.Phases.Connect)
-	update("tls", r.Phases.TLS)
-	update("ttfb", r.Phases.TTFB)
-	update("total", r.Phases.Total)
+	phases["tls"].add(r.Phases.TLS)
+	phases["ttfb"].add(r.Phases.TTFB)
+	phases["total"].add(r.Phases.Total)
+	a.addSLA(r)
+	a.schedLag.add(r.SchedLag)
+	a.addHistogram(r)
+	a.addDelayedACK(r)
+	a.addStatusLatency(r)
+	a.addHeaders(r)
+	a.addSkew(r)
+	a.addDNS(r)
 }
 
+func (ps *phaseStats) add(d time.Duration) {
+	ms := toMs(d)
+	ps.Count++
+	ps.Sum += ms
+	ps.digest.add(ms)
+	if ms < ps.Min {
+		ps.Min = ms
+	}
+	if ms > ps.Max {
+		ps.Max = ms
+	}
+}
+
+func (ps *phaseStats) summary() PhaseSummary {
+	if ps.Count == 0 {
+		return PhaseSummary{}
+	}
+	p := ps.digest.quantiles(50, 95, 99)
+	return PhaseSummary{
+		Count: ps.Count,
+		Avg:   ps.Sum / float64(ps.Count),
+		Min:   ps.Min,
+		Max:   ps.Max,
+		P50:   p[0],
+		P95:   p[1],
+		P99:   p[2],
+		Total: ps.Sum,
+	}
+}
+
+// SetStrict makes LoadJSONL fail on the first line it cannot parse instead
+// of counting it as malformed and moving on.
+func (a *Aggregator) SetStrict(strict bool) {
+	a.strict = strict
+}
+
+// LoadJSONL reads a results file, transparently decompressing gzip or zstd
+// input; "-" reads standard input. Lines are aggregated as they are read, in
+// whatever order they come, so files of any length load in bounded memory.
+// Lines that do not parse, such as one cut short when a run was killed
+// mid-write, are counted in Summary.Malformed.
 func (a *Aggregator) LoadJSONL(path string) error {
-	f, err := os.Open(path)
+	var r *jsonlReader
+	var err error
+	if path == "-" {
+		r, err = newJSONLReader(os.Stdin)
+	} else {
+		r, err = openJSONL(path)
+	}
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer r.Close()
+	return a.load(r)
+}
 
-	r := bufio.NewReader(f)
-	for {
+// load aggregates every line of r.
+func (a *Aggregator) load(r *jsonlReader) error {
+	for n := 1; ; n++ {
 		line, err := r.ReadBytes('\n')
-		if len(line) > 0 {
-			var res attack.Result
-			if e := json.Unmarshal(line, &res); e == nil {
-				a.Add(res)
+		if len(bytes.TrimSpace(line)) > 0 {
+			if e := a.addLine(line); e != nil {
+				if a.strict {
+					return fmt.Errorf("line %d: %w", n, e)
+				}
+				a.malformed++
 			}
 		}
 		if err == io.EOF {
@@ -112,49 +446,454 @@ func (a *Aggregator) LoadJSONL(path string) error {
 	return nil
 }
 
+func (a *Aggregator) addLine(line []byte) error {
+	if isRecordType(line) {
+		return a.addRecord(line)
+	}
+	var res attack.Result
+	if err := json.Unmarshal(line, &res); err != nil {
+		return err
+	}
+	if a.filter != nil && !a.filter.match(res) {
+		a.filtered++
+		return nil
+	}
+	a.Add(res)
+	return nil
+}
+
+// jsonlReader reads a results file, decompressing it transparently. The
+// codec is detected from the magic bytes, not the file name.
+type jsonlReader struct {
+	*bufio.Reader
+	codec   string // "gzip", "zstd" or "" for plain JSONL
+	closers []io.Closer
+}
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+func openJSONL(path string) (*jsonlReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return newJSONLReader(f)
+}
+
+func newJSONLReader(f io.ReadCloser) (*jsonlReader, error) {
+	jr := &jsonlReader{Reader: bufio.NewReader(f), closers: []io.Closer{f}}
+	magic, _ := jr.Peek(4)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		zr, err := gzip.NewReader(jr.Reader)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		jr.Reader, jr.codec = bufio.NewReader(zr), "gzip"
+		jr.closers = append(jr.closers, zr)
+	case bytes.Equal(magic, zstdMagic):
+		zr, err := zstd.NewReader(jr.Reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		jr.Reader, jr.codec = bufio.NewReader(zr), "zstd"
+		jr.closers = append(jr.closers, zr.IOReadCloser())
+	}
+	return jr, nil
+}
+
+func (r *jsonlReader) Close() error {
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		r.closers[i].Close()
+	}
+	return nil
+}
+
+// isRecordType reports whether a JSONL line is a typed non-result record
+// (e.g. an annotation). Such records always encode "type" as their first field.
+func isRecordType(line []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(line), []byte(`{"type":`))
+}
+
+// AddRecord takes a typed record line of a run as it is written, next to
+// the results passed to Add, so a live aggregator summarises the run as
+// LoadJSONL would. Other lines are ignored.
+func (a *Aggregator) AddRecord(line []byte) error {
+	if !isRecordType(line) {
+		return nil
+	}
+	return a.addRecord(line)
+}
+
+// addRecord consumes a typed non-result record. Types it does not know are
+// skipped, so newer files still load.
+func (a *Aggregator) addRecord(line []byte) error {
+	var rec struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return err
+	}
+	switch rec.Type {
+	case "annotation":
+		var ann attack.Annotation
+		if json.Unmarshal(line, &ann) == nil {
+			a.notes = append(a.notes, ann.Message)
+		}
+	case "stop":
+		var stop attack.StopRecord
+		if json.Unmarshal(line, &stop) == nil {
+			a.unsent += int(stop.Discarded)
+		}
+	case "workers":
+		var rec attack.WorkersRecord
+		if json.Unmarshal(line, &rec) == nil {
+			a.peakWorkers += rec.Peak
+			a.peakBusy += rec.PeakBusy
+		}
+	case "prune":
+		a.addPrune(line)
+	case "search":
+		a.addSearchRecord(line)
+	case "replay":
+		a.addReplayRecord(line)
+	case "auth":
+		a.addAuthRecord(line)
+	case "meta":
+		var meta attack.MetaRecord
+		if json.Unmarshal(line, &meta) == nil {
+			for _, d := range meta.Derived {
+				a.notes = append(a.notes, fmt.Sprintf("%s = %g, from %s (%g)", d.Field, d.Value, d.Expr, d.Source))
+			}
+			a.addRunInfo(meta)
+			a.noteNoDelay(meta.TCPNoDelay)
+			a.addBaseline(meta.Baseline)
+		}
+	}
+	return nil
+}
+
+// Summary computes the current statistics without printing them.
+func (a *Aggregator) Summary() Summary {
+	s := Summary{
+		Run:               a.runInfoSummary(),
+		Filter:            a.filterSummary(),
+		Requests:          a.count,
+		Delivered:         a.delivered,
+		BytesReceived:     a.bytes,
+		Warmup:            a.warmup,
+		Unsent:            a.unsent,
+		PeakWorkers:       a.peakWorkers,
+		PeakBusyWorkers:   a.peakBusy,
+		Malformed:         a.malformed,
+		Failed:            a.failed,
+		StatusCodes:       copyMap(a.status),
+		StatusFamily:      copyMap(a.statusFamily),
+		Errors:            copyMap(a.errors),
+		FailByPhase:       copyMap(a.failByPhase),
+		Phases:            make(map[string]PhaseSummary, len(PhaseNames)),
+		Redirects:         copyMap(a.redirects),
+		RedirectLimitHits: a.errors["redirect_limit"],
+	}
+	if a.count > 0 {
+		s.ErrorRate = float64(a.failed) / float64(a.count)
+	}
+	for _, name := range PhaseNames {
+		if ps := a.stats[name]; ps.Count > 0 {
+			s.Phases[name] = ps.summary()
+		}
+		if ps := a.afterIdle[name]; ps.Count > 0 {
+			if s.AfterIdle == nil {
+				s.AfterIdle = make(map[string]PhaseSummary)
+			}
+			s.AfterIdle[name] = ps.summary()
+		}
+	}
+	s.SchedLag = a.schedLag.summary()
+	s.Rate = a.rateSummary()
+	s.Baseline = a.baselineSummary(s.Phases)
+	s.Histogram = a.histogram()
+	s.Search = a.searchSummary()
+	s.Replay = a.replaySummary()
+	s.DelayedACK = a.delayedACKSummary()
+	s.ByStatus = a.statusLatencies()
+	s.Headers = a.headerSeries()
+	s.Timeline = a.timeline()
+	s.Stages = a.stageSummaries()
+	if span := a.last.Sub(a.first).Seconds(); span > 0 {
+		s.DurationSeconds = span
+		s.Throughput = float64(a.count) / span
+		s.DeliveredThroughput = float64(a.delivered) / span
+	}
+	s.Notes = append([]string(nil), a.notes...)
+	s.ErrorBodies = topBodies(a.errorBodies, 10)
+	s.Cooldown = a.cooldownSummary()
+	s.DateSkew = a.skewSummary()
+	s.DNS = a.dnsSummary()
+	s.Agents = a.agentSummaries()
+	s.Methods = a.methodSummaries()
+	s.EarlyHints = a.hintsSummary()
+	s.WebSocket = a.webSocketSummary()
+	s.Scenario = a.scenarioSummary()
+	s.Clusters = a.clusterSummary()
+	s.ClientAborts = a.clientAbortSummary()
+	s.Retries = a.retrySummary()
+	s.Auth = a.authSummary()
+	s.Compression = a.compressionSummary()
+	s.Cost = a.costEstimates(s)
+	s.Thresholds = a.thresholdResults()
+	s.SLO = a.sloResults(s)
+	return a.prunedSummary(s)
+}
+
 // Report prints raw math statistics per phase
 func (a *Aggregator) Report(w io.Writer) {
-	fmt.Fprintf(w, "\n=== Summary (%d requests) ===\n", a.count)
+	a.ReportLevel(w, 1)
+}
+
+// ReportLevel prints the report at a verbosity level: 0 is the headline only,
+// 1 adds the standard tables, 2 adds per-stage and per-address breakdowns.
+func (a *Aggregator) ReportLevel(w io.Writer, level int) {
+	s := a.Summary()
+	f := numFormat{raw: a.raw}
+	if s.Run != nil {
+		printRunInfo(w, s.Run)
+	}
+	if s.Filter != nil {
+		printFilter(w, s.Filter)
+		if s.Filter.Matched == 0 {
+			fmt.Fprintln(w, "\n0 records matched the filters.")
+			return
+		}
+	}
+	fmt.Fprintf(w, "\n=== Summary (%s requests) ===\n", f.count(s.Requests))
+	if s.Warmup > 0 {
+		fmt.Fprintf(w, "  (%s warmup requests excluded)\n", f.count(s.Warmup))
+	}
+	if s.Unsent > 0 {
+		fmt.Fprintf(w, "  (%s scheduled requests discarded at stop, never sent)\n", f.count(s.Unsent))
+	}
+	if s.PeakWorkers > 0 {
+		fmt.Fprintf(w, "  (auto concurrency peaked at %d workers, %d busy at once; set load.concurrency to pin the pool)\n",
+			s.PeakWorkers, s.PeakBusyWorkers)
+	}
+	if s.Malformed > 0 {
+		fmt.Fprintf(w, "  ⚠️  %s malformed input lines skipped (report -strict refuses them)\n", f.count(s.Malformed))
+	}
+	printHeadline(w, s, f)
+	if s.Rate != nil {
+		printRateWarning(w, s.Rate)
+	}
+	if len(s.Thresholds) > 0 {
+		printThresholds(w, s.Thresholds)
+	}
+	if len(s.SLO) > 0 {
+		WriteSLO(w, s.SLO)
+	}
+	if s.Search != nil {
+		printSearch(w, s.Search, f)
+	}
+	if level < 1 {
+		return
+	}
+	if s.Replay != nil {
+		printReplay(w, s.Replay, f, level)
+	}
+
+	if len(s.Notes) > 0 {
+		fmt.Fprintln(w, "\nNotes:")
+		for _, n := range s.Notes {
+			fmt.Fprintf(w, "  • %s\n", n)
+		}
+	}
 
 	fmt.Fprintln(w, "\nStatus families:")
 	// print in order 2xx..5xx if present
 	for _, fam := range []string{"2xx", "3xx", "4xx", "5xx"} {
-		if v, ok := a.statusFamily[fam]; ok {
-			fmt.Fprintf(w, "  %-3s : %d\n", fam, v)
+		if v, ok := s.StatusFamily[fam]; ok {
+			fmt.Fprintf(w, "  %-3s : %s\n", fam, f.count(v))
 		}
 	}
 
 	fmt.Fprintln(w, "\nStatus codes:")
-	for _, code := range sortedKeysInt(a.status) {
-		fmt.Fprintf(w, "  %3d : %d\n", code, a.status[code])
+	for _, code := range sortedKeysInt(s.StatusCodes) {
+		fmt.Fprintf(w, "  %3d : %s\n", code, f.count(s.StatusCodes[code]))
 	}
 
 	fmt.Fprintln(w, "\nErrors:")
-	for _, key := range sortedKeysStr(a.errors) {
-		fmt.Fprintf(w, "  %-10s : %d\n", key, a.errors[key])
+	for _, key := range sortedKeysStr(s.Errors) {
+		fmt.Fprintf(w, "  %-10s : %s\n", key, f.count(s.Errors[key]))
 	}
-	if len(a.errors) == 0 {
+	if len(s.Errors) == 0 {
 		fmt.Fprintln(w, "  none")
 	}
 
+	if len(s.ErrorBodies) > 0 {
+		fmt.Fprintln(w, "\nTop error bodies:")
+		for i, b := range s.ErrorBodies {
+			if i == 5 {
+				break
+			}
+			fmt.Fprintf(w, "  %6s × %s\n", f.count(b.Count), oneLine(b.Body, 100))
+		}
+	}
+
 	fmt.Fprintln(w, "\nFailures by phase:")
-	for _, key := range sortedKeysStr(a.failByPhase) {
-		fmt.Fprintf(w, "  %-10s : %d\n", key, a.failByPhase[key])
+	for _, key := range sortedKeysStr(s.FailByPhase) {
+		fmt.Fprintf(w, "  %-10s : %s\n", key, f.count(s.FailByPhase[key]))
 	}
-	if len(a.failByPhase) == 0 {
+	if len(s.FailByPhase) == 0 {
 		fmt.Fprintln(w, "  none")
 	}
 
-	fmt.Fprintln(w, "\nPhase timings (ms):")
-	fmt.Fprintf(w, "  %-8s %-10s %-10s %-10s %-10s\n", "Phase", "Avg", "Min", "Max", "Total")
+	hasRedirects := s.RedirectLimitHits > 0
+	for hops := range s.Redirects {
+		hasRedirects = hasRedirects || hops > 0
+	}
+	if hasRedirects {
+		fmt.Fprintln(w, "\nRedirects (hops : requests):")
+		for _, hops := range sortedKeysInt(s.Redirects) {
+			fmt.Fprintf(w, "  %3d : %s\n", hops, f.count(s.Redirects[hops]))
+		}
+		if s.RedirectLimitHits > 0 {
+			fmt.Fprintf(w, "  ⚠️  max redirects hit by %d request(s)\n", s.RedirectLimitHits)
+		}
+	}
+
+	if f.raw {
+		fmt.Fprintln(w, "\nPhase timings (ms):")
+	} else {
+		fmt.Fprintln(w, "\nPhase timings:")
+	}
+	if s.Baseline != nil {
+		printBaseline(w, s.Baseline, f)
+	}
+	nameW := 8
+	if _, ok := s.Phases["proxy_connect"]; ok {
+		nameW = len("proxy_connect")
+	}
+	fmt.Fprintf(w, "  %-*s %-10s %-10s %-10s %-10s %-10s %-10s %-10s",
+		nameW, "Phase", "Avg", "Min", "Max", "P50", "P95", "P99", "Total")
+	if s.Baseline != nil {
+		fmt.Fprintf(w, " %-10s", "Adj P50")
+	}
+	fmt.Fprintln(w)
 	for _, name := range PhaseNames {
-		s := a.stats[name]
-		if s.Count == 0 {
+		p, ok := s.Phases[name]
+		if !ok {
 			continue
 		}
-		avg := s.Sum / float64(s.Count)
-		fmt.Fprintf(w, "  %-8s %-10.2f %-10.2f %-10.2f %-10.2f\n",
-			name, avg, s.Min, s.Max, s.Sum)
+		fmt.Fprintf(w, "  %-*s %-10s %-10s %-10s %-10s %-10s %-10s %-10s",
+			nameW, name, f.cell(p.Avg), f.cell(p.Min), f.cell(p.Max), f.cell(p.P50), f.cell(p.P95), f.cell(p.P99), f.cell(p.Total))
+		if adj, ok := s.Baseline.adjusted(name); ok {
+			fmt.Fprintf(w, " %-10s", f.cell(adj))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if idle, ok := s.AfterIdle["total"]; ok {
+		fmt.Fprintf(w, "\nReconnects after idle gap (%s, excluded from the table above):\n", f.count(idle.Count))
+		for _, name := range []string{"connect", "tls", "total"} {
+			p := s.AfterIdle[name]
+			fmt.Fprintf(w, "  %-8s avg=%s p95=%s max=%s\n", name, f.cell(p.Avg), f.cell(p.P95), f.cell(p.Max))
+		}
+	}
+
+	// a single outcome would just repeat the phase table
+	if len(s.ByStatus) > 1 {
+		printStatusLatency(w, s.ByStatus)
+	}
+
+	if s.Histogram != nil {
+		printHistogram(w, s.Histogram, f)
+	}
+	if s.DelayedACK != nil {
+		printDelayedACK(w, s.DelayedACK, f)
+	}
+
+	if lag := s.SchedLag; lag.Count > 0 {
+		if f.raw {
+			fmt.Fprintln(w, "\nScheduler lag (ms, planned vs actual dispatch):")
+		} else {
+			fmt.Fprintln(w, "\nScheduler lag (planned vs actual dispatch):")
+		}
+		fmt.Fprintf(w, "  avg=%s p95=%s p99=%s max=%s\n", f.cell(lag.Avg), f.cell(lag.P95), f.cell(lag.P99), f.cell(lag.Max))
+	}
+	if s.Rate != nil {
+		printRate(w, s.Rate, f)
+	}
+
+	if s.Scenario != nil {
+		printScenario(w, s.Scenario)
+	}
+
+	if s.EarlyHints != nil {
+		printHints(w, s.EarlyHints, s.Requests)
+	}
+
+	if s.WebSocket != nil {
+		printWebSocket(w, s.WebSocket)
+	}
+
+	if s.DateSkew != nil {
+		printSkew(w, s.DateSkew)
+	}
+
+	// lookups without spikes are already covered by the phase table
+	if s.DNS != nil && (s.DNS.Spikes > 0 || level >= 2) {
+		printDNS(w, s.DNS)
+	}
+
+	if len(s.Headers) > 0 {
+		printHeaders(w, s.Headers)
+	}
+
+	// a single-method run would just repeat the headline
+	if len(s.Methods) > 1 || (level >= 2 && len(s.Methods) > 0) {
+		printMethods(w, s.Methods)
+	}
+
+	if s.Clusters != nil {
+		printClusters(w, s.Clusters)
+	}
+
+	if s.Retries != nil {
+		printRetries(w, s.Retries, s.Requests)
+	}
+
+	if s.Auth != nil {
+		printAuth(w, s.Auth)
+	}
+
+	// uncompressed responses alone are already counted in the headline
+	if c := s.Compression; c != nil && (c.Ratio > 0 || c.Identity != nil || level >= 2) {
+		printCompression(w, c, f)
+	}
+
+	if s.ClientAborts != nil {
+		printClientAborts(w, s.ClientAborts)
+	}
+
+	if len(s.Agents) > 0 {
+		printAgents(w, s.Agents)
+	}
+
+	if s.Cooldown != nil {
+		printCooldown(w, s.Cooldown)
+	}
+
+	if len(s.Cost) > 0 {
+		printCost(w, s, f)
+	}
+
+	if level < 2 {
+		return
+	}
+	printStages(w, s.Stages)
+	if len(s.Timeline.Addresses) > 0 {
+		printTimeline(w, s.Timeline)
 	}
 }
 
@@ -176,3 +915,53 @@ func sortedKeysStr(m map[string]int) []string {
 	sort.Strings(keys)
 	return keys
 }
+
+// topBodies returns the n most frequent bodies, ties broken alphabetically.
+func topBodies(m map[string]int, n int) []BodyCount {
+	out := make([]BodyCount, 0, len(m))
+	for b, c := range m {
+		out = append(out, BodyCount{Body: b, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Body < out[j].Body
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// oneLine collapses whitespace and truncates s for single-line display.
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) > max {
+		s = string([]rune(s)[:max]) + "…"
+	}
+	return s
+}
+
+func copyMap[K comparable](m map[K]int) map[K]int {
+	out := make(map[K]int, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// percentile uses nearest-rank on an already sorted slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,135 @@
+package stats
+
+import (
+	"io"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// genResults streams n result lines as a run writes them, without holding
+// them in memory or on disk. Latencies are log-normal around 20ms at
+// microsecond resolution, so the digests see as many distinct values as a
+// real run's.
+type genResults struct {
+	n, i  int
+	start time.Time
+	rnd   *rand.Rand
+	buf   []byte
+	off   int
+}
+
+func newGenResults(n int) *genResults {
+	return &genResults{
+		n:     n,
+		start: time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC),
+		rnd:   rand.New(rand.NewPCG(1, 2)),
+	}
+}
+
+func (g *genResults) Read(p []byte) (int, error) {
+	if g.off == len(g.buf) {
+		g.buf, g.off = g.buf[:0], 0
+		for len(g.buf) < 64<<10 && g.i < g.n {
+			g.buf = g.line(g.buf)
+			g.i++
+		}
+		if len(g.buf) == 0 {
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, g.buf[g.off:])
+	g.off += n
+	return n, nil
+}
+
+// line appends result g.i, encoded as attack.Result encodes it.
+func (g *genResults) line(b []byte) []byte {
+	total := math.Round(20*math.Exp(0.6*g.rnd.NormFloat64())*1000) / 1000
+	code := 200
+	if g.i%200 == 0 {
+		code = 503
+	}
+	b = append(b, `{"v":2,"ts":"`...)
+	b = g.start.Add(time.Duration(g.i)*100*time.Microsecond).AppendFormat(b, time.RFC3339Nano)
+	b = append(b, `","method":"GET","code":`...)
+	b = strconv.AppendInt(b, int64(code), 10)
+	b = append(b, `,"reused":`...)
+	b = strconv.AppendBool(b, g.i%50 != 0)
+	b = append(b, `,"remote_addr":"10.0.0.7:443","worker":`...)
+	b = strconv.AppendInt(b, int64(g.i%64+1), 10)
+	b = append(b, `,"conn":`...)
+	b = strconv.AppendInt(b, int64(g.i%64+1), 10)
+	b = append(b, `,"bytes":`...)
+	b = strconv.AppendInt(b, int64(512+g.i%97), 10)
+	b = append(b, `,"sched_lag":0,"phases":{"dns":0,"connect":0,"tls":0,"ttfb":`...)
+	b = strconv.AppendFloat(b, math.Round(total*900)/1000, 'f', -1, 64)
+	b = append(b, `,"total":`...)
+	b = strconv.AppendFloat(b, total, 'f', -1, 64)
+	return append(b, "}}\n"...)
+}
+
+// heapPeak samples the heap left live by each GC until stop is called and
+// returns the highest reading. Garbage awaiting collection is not counted.
+func heapPeak() (stop func() uint64) {
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	done := make(chan struct{})
+	var peak uint64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tick := time.NewTicker(5 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			metrics.Read(sample)
+			peak = max(peak, sample[0].Value.Uint64())
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		wg.Wait()
+		return peak
+	}
+}
+
+// BenchmarkLoadJSONL10M holds LoadJSONL to the README's figures: 10M lines
+// load at about 100K lines/s, and the live heap stays under 20 MB throughout.
+func BenchmarkLoadJSONL10M(b *testing.B) {
+	const lines = 10_000_000
+	const heapLimit = 20 << 20
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		stop := heapPeak()
+		a := New()
+		r, err := newJSONLReader(io.NopCloser(newGenResults(lines)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		start := time.Now()
+		if err := a.load(r); err != nil {
+			b.Fatal(err)
+		}
+		elapsed := time.Since(start)
+		s := a.Summary()
+		peak := stop()
+		if s.Requests != lines {
+			b.Fatalf("aggregated %d requests, want %d", s.Requests, lines)
+		}
+		b.ReportMetric(lines/elapsed.Seconds(), "lines/s")
+		b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+		if peak > heapLimit {
+			b.Fatalf("heap peaked at %.1f MB loading %d lines, want under %d MB", float64(peak)/(1<<20), lines, heapLimit>>20)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// AuthSummary covers how a run with OAuth2 client credentials kept its
+// token: tokens fetched and refreshed, from the closing auth record, and
+// the 401s it got, split into those around a token change (the auth error)
+// and the rest.
+type AuthSummary struct {
+	Scheme        string  `json:"scheme"`
+	Fetched       int64   `json:"fetched"`
+	Refreshed     int64   `json:"refreshed"`
+	Failed        int64   `json:"failed"`   // token requests
+	Lifetime      float64 `json:"lifetime"` // seconds, 0 for tokens that do not expire
+	AroundRefresh int     `json:"around_refresh"`
+	Unauthorized  int     `json:"unauthorized"` // other 401s
+}
+
+type authTracking struct {
+	record        *attack.AuthRecord // summed over agents
+	around, other int
+}
+
+func (a *Aggregator) addAuthRecord(line []byte) {
+	var rec attack.AuthRecord
+	if json.Unmarshal(line, &rec) != nil {
+		return
+	}
+	if t := a.auth.record; t != nil {
+		t.Fetched += rec.Fetched
+		t.Refreshed += rec.Refreshed
+		t.Failed += rec.Failed
+		return
+	}
+	a.auth.record = &rec
+}
+
+func (a *Aggregator) addAuth(r attack.Result) {
+	switch {
+	case r.Error == "auth":
+		a.auth.around++
+	case r.Code == 401 && r.Error == "":
+		a.auth.other++
+	}
+}
+
+func (a *Aggregator) authSummary() *AuthSummary {
+	t := a.auth
+	if t.record == nil && t.around == 0 {
+		return nil
+	}
+	s := &AuthSummary{AroundRefresh: t.around, Unauthorized: t.other}
+	if rec := t.record; rec != nil {
+		s.Scheme, s.Fetched, s.Refreshed, s.Failed, s.Lifetime = rec.Scheme, rec.Fetched, rec.Refreshed, rec.Failed, rec.Lifetime
+	}
+	return s
+}
+
+func printAuth(w io.Writer, s *AuthSummary) {
+	fmt.Fprintf(w, "\nAuth: %d token(s) fetched, %d by a refresh during the run", s.Fetched, s.Refreshed)
+	if s.Lifetime > 0 {
+		fmt.Fprintf(w, " (issued for %s)", time.Duration(s.Lifetime*float64(time.Second)).Round(time.Millisecond))
+	}
+	fmt.Fprintln(w)
+	if s.Failed > 0 {
+		fmt.Fprintf(w, "  ⚠️  %d token request(s) failed; see the annotations\n", s.Failed)
+	}
+	if s.AroundRefresh > 0 || s.Unauthorized > 0 {
+		fmt.Fprintf(w, "  401s: %d around a token refresh (auth errors), %d otherwise\n", s.AroundRefresh, s.Unauthorized)
+	}
+}
@@ -0,0 +1,64 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+
+	"shard/internal/attack"
+)
+
+// BaselineSummary is the load.baseline calibration recorded with the run,
+// and the run's phase medians with it subtracted: what is left once the
+// network path's own cost is taken out.
+type BaselineSummary struct {
+	Method   string             `json:"method"`
+	Requests int                `json:"requests"`
+	Medians  map[string]float64 `json:"medians"` // ms, per phase
+	// Adjusted is each phase's p50 less its baseline median, floored at 0.
+	Adjusted map[string]float64 `json:"adjusted_p50"`
+}
+
+// addBaseline keeps the first calibration found; agents of a distributed
+// run each record their own, and the report shows one.
+func (a *Aggregator) addBaseline(b *attack.Baseline) {
+	if b == nil || a.baseline != nil {
+		return
+	}
+	a.baseline = b
+	if a.baselines++; a.baselines == 2 {
+		a.notes = append(a.notes, "several baselines were recorded (one per agent); the report uses the first")
+	}
+}
+
+func (a *Aggregator) baselineSummary(phases map[string]PhaseSummary) *BaselineSummary {
+	b := a.baseline
+	if b == nil {
+		return nil
+	}
+	s := &BaselineSummary{Method: b.Method, Requests: b.Requests, Medians: b.Medians, Adjusted: make(map[string]float64)}
+	for name, p := range phases {
+		if base, ok := b.Medians[name]; ok {
+			s.Adjusted[name] = max(0, p.P50-base)
+		}
+	}
+	return s
+}
+
+// adjusted returns a phase's adjusted p50; b may be nil.
+func (b *BaselineSummary) adjusted(phase string) (float64, bool) {
+	if b == nil {
+		return 0, false
+	}
+	v, ok := b.Adjusted[phase]
+	return v, ok
+}
+
+func printBaseline(w io.Writer, b *BaselineSummary, f numFormat) {
+	fmt.Fprintf(w, "  baseline: %d %s probes, median", b.Requests, b.Method)
+	for _, name := range PhaseNames {
+		if v, ok := b.Medians[name]; ok {
+			fmt.Fprintf(w, " %s=%s", name, f.ms(v))
+		}
+	}
+	fmt.Fprintln(w, " (Adj P50 below subtracts it)")
+}
@@ -0,0 +1,74 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultBenchMetrics is what -format benchfmt reports unless told otherwise.
+var DefaultBenchMetrics = []string{"total_p50", "total_p95", "total_p99", "error_rate", "throughput"}
+
+// benchStats are the per-phase statistics a latency metric can name.
+var benchStats = map[string]func(PhaseSummary) float64{
+	"avg": func(p PhaseSummary) float64 { return p.Avg },
+	"min": func(p PhaseSummary) float64 { return p.Min },
+	"max": func(p PhaseSummary) float64 { return p.Max },
+	"p50": func(p PhaseSummary) float64 { return p.P50 },
+	"p95": func(p PhaseSummary) float64 { return p.P95 },
+	"p99": func(p PhaseSummary) float64 { return p.P99 },
+}
+
+// benchValue resolves a metric name to its value and unit. Names are stable
+// because benchstat matches on them:
+//
+//	<phase>_<stat>  phase is dns, connect, tls, ttfb, total or sched_lag;
+//	                stat is avg, min, max, p50, p95 or p99; unit ns/op
+//	error_rate      failed / requests; unit errors/op
+//	throughput      measured requests per second; unit req/s
+func benchValue(s Summary, metric string) (float64, string, error) {
+	switch metric {
+	case "error_rate":
+		return s.ErrorRate, "errors/op", nil
+	case "throughput":
+		return s.Throughput, "req/s", nil
+	}
+	i := strings.LastIndex(metric, "_")
+	if i < 0 {
+		return 0, "", fmt.Errorf("unknown metric %q", metric)
+	}
+	phase, stat := metric[:i], metric[i+1:]
+	get, ok := benchStats[stat]
+	if !ok {
+		return 0, "", fmt.Errorf("unknown metric %q: statistic must be avg, min, max, p50, p95 or p99", metric)
+	}
+	var ps PhaseSummary
+	if phase == "sched_lag" {
+		ps = s.SchedLag
+	} else if !contains(PhaseNames, phase) {
+		return 0, "", fmt.Errorf("unknown metric %q: phase must be one of %s or sched_lag", metric, strings.Join(PhaseNames, ", "))
+	} else {
+		ps = s.Phases[phase]
+	}
+	return math.Round(get(ps) * 1e6), "ns/op", nil // ms -> ns
+}
+
+// WriteBenchfmt writes s as Go benchmark lines, one per metric, e.g.
+// "BenchmarkShard/total_p95 1 243000000 ns/op", for comparison with benchstat.
+func WriteBenchfmt(w io.Writer, s Summary, metrics []string) error {
+	if len(metrics) == 0 {
+		metrics = DefaultBenchMetrics
+	}
+	var b strings.Builder
+	for _, m := range metrics {
+		v, unit, err := benchValue(s, strings.TrimSpace(m))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "BenchmarkShard/%s 1 %s %s\n", strings.TrimSpace(m), strconv.FormatFloat(v, 'f', -1, 64), unit)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
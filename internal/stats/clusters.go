@@ -0,0 +1,170 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"shard/internal/attack"
+)
+
+// A group is flagged as a failure cluster when it has at least
+// clusterMinRequests requests, clusterMinFailures failures, and a failure
+// rate clusterRatio times that of all other traffic.
+const (
+	clusterMinRequests = 10
+	clusterMinFailures = 3
+	clusterRatio       = 10
+)
+
+// clusterMaxConns bounds the connections tracked one by one: a run that dials
+// a connection per request would otherwise keep a group per request. Traffic
+// on later connections still counts towards the rest rate.
+const clusterMaxConns = 100000
+
+// ClusterSummary is the failure clustering analysis: failure rates per
+// worker and per connection, and the groups that stand out.
+type ClusterSummary struct {
+	Workers int `json:"workers"`
+	Conns   int `json:"connections"`
+	// Untracked is requests on connections beyond clusterMaxConns.
+	Untracked int              `json:"untracked_requests,omitempty"`
+	Outliers  []FailureCluster `json:"outliers,omitempty"`
+}
+
+// FailureCluster is a worker or connection failing far more than the rest.
+type FailureCluster struct {
+	Kind      string  `json:"kind"` // "worker" or "connection"
+	ID        string  `json:"id"`
+	Requests  int     `json:"requests"`
+	Failed    int     `json:"failed"`
+	Rate      float64 `json:"rate"`
+	RestRate  float64 `json:"rest_rate"` // failure rate of all other traffic
+	TopError  string  `json:"top_error,omitempty"`
+	TopErrorN int     `json:"top_error_count,omitempty"`
+}
+
+type groupCounts struct {
+	requests int
+	failed   int
+	errors   map[string]int
+}
+
+func (g *groupCounts) add(r attack.Result) {
+	g.requests++
+	if r.Error == "" {
+		return
+	}
+	g.failed++
+	if g.errors == nil {
+		g.errors = make(map[string]int)
+	}
+	g.errors[r.Error]++
+}
+
+// groupID scopes worker and connection ids to their agent, since every
+// agent numbers its own from 1.
+func groupID(agent string, id uint64) string {
+	if agent == "" {
+		return strconv.FormatUint(id, 10)
+	}
+	return agent + "/" + strconv.FormatUint(id, 10)
+}
+
+func (a *Aggregator) addCluster(r attack.Result) {
+	if r.Worker > 0 {
+		id := groupID(r.Agent, uint64(r.Worker))
+		if a.byWorker[id] == nil {
+			a.byWorker[id] = &groupCounts{}
+		}
+		a.byWorker[id].add(r)
+	}
+	// requests that never got a connection can't be pinned to one
+	if r.Conn > 0 {
+		id := groupID(r.Agent, r.Conn)
+		g := a.byConn[id]
+		switch {
+		case g != nil:
+		case len(a.byConn) >= clusterMaxConns:
+			g = &a.connRest
+		default:
+			g = &groupCounts{}
+			a.byConn[id] = g
+		}
+		g.add(r)
+	}
+}
+
+func (a *Aggregator) clusterSummary() *ClusterSummary {
+	if a.failed == 0 || (len(a.byWorker) == 0 && len(a.byConn) == 0) {
+		return nil
+	}
+	s := &ClusterSummary{Workers: len(a.byWorker), Conns: len(a.byConn), Untracked: a.connRest.requests}
+	s.Outliers = append(outliers("worker", a.byWorker, nil), outliers("connection", a.byConn, &a.connRest)...)
+	return s
+}
+
+// outliers compares every group against the rest of the groups of its kind,
+// plus untracked traffic of that kind if any.
+func outliers(kind string, groups map[string]*groupCounts, untracked *groupCounts) []FailureCluster {
+	var total, failed int
+	if untracked != nil {
+		total, failed = untracked.requests, untracked.failed
+	}
+	for _, g := range groups {
+		total += g.requests
+		failed += g.failed
+	}
+	var out []FailureCluster
+	for id, g := range groups {
+		if g.requests < clusterMinRequests || g.failed < clusterMinFailures {
+			continue
+		}
+		rate := float64(g.failed) / float64(g.requests)
+		rest := 0.0
+		if n := total - g.requests; n > 0 {
+			rest = float64(failed-g.failed) / float64(n)
+		}
+		if rate < rest*clusterRatio || total == g.requests {
+			continue
+		}
+		c := FailureCluster{Kind: kind, ID: id, Requests: g.requests, Failed: g.failed, Rate: rate, RestRate: rest}
+		for e, n := range g.errors {
+			if n > c.TopErrorN || (n == c.TopErrorN && e < c.TopError) {
+				c.TopError, c.TopErrorN = e, n
+			}
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rate != out[j].Rate {
+			return out[i].Rate > out[j].Rate
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func printClusters(w io.Writer, s *ClusterSummary) {
+	fmt.Fprintf(w, "\nFailure clustering (%d workers, %d connections):\n", s.Workers, s.Conns)
+	if s.Untracked > 0 {
+		fmt.Fprintf(w, "  %d requests on later connections are only counted towards the rest\n", s.Untracked)
+	}
+	if len(s.Outliers) == 0 {
+		fmt.Fprintln(w, "  failures are spread evenly, no worker or connection stands out")
+		return
+	}
+	for i, c := range s.Outliers {
+		if i == 10 {
+			fmt.Fprintf(w, "  … and %d more\n", len(s.Outliers)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ⚠️  %s %s: %d/%d failed (%.1f%%) vs %.1f%% elsewhere",
+			c.Kind, c.ID, c.Failed, c.Requests, c.Rate*100, c.RestRate*100)
+		if c.TopError != "" {
+			fmt.Fprintf(w, ", mostly %s (%d)", c.TopError, c.TopErrorN)
+		}
+		fmt.Fprintln(w)
+	}
+}
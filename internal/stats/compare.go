@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrRegression is returned when a comparison finds metrics that regressed
+// past the caller's failure threshold.
+var ErrRegression = errors.New("regression threshold exceeded")
+
+// Delta is the change of a single metric between a baseline (A) and a candidate (B).
+type Delta struct {
+	Metric string
+	A      float64
+	B      float64
+	Abs    float64
+	Pct    float64 // relative change in percent, NaN when A is zero
+	// HigherIsWorse marks metrics where an increase is a regression.
+	HigherIsWorse bool
+}
+
+// Regression reports whether B is worse than A by more than threshold percent.
+// A zero baseline has no relative change, so the error rate is then judged in
+// absolute percentage points; other metrics never regress from zero.
+func (d Delta) Regression(threshold float64) bool {
+	if !d.HigherIsWorse || d.Abs <= 0 {
+		return false
+	}
+	if math.IsNaN(d.Pct) {
+		return d.Metric == "error_rate" && d.Abs*100 > threshold
+	}
+	return d.Pct > threshold
+}
+
+// Improvement reports whether B is better than A by more than threshold percent.
+func (d Delta) Improvement(threshold float64) bool {
+	if !d.HigherIsWorse || d.Abs >= 0 || math.IsNaN(d.Pct) {
+		return false
+	}
+	return -d.Pct > threshold
+}
+
+// Compare diffs two summaries: request counts, error rate and per-phase avg/p95.
+func Compare(a, b Summary) []Delta {
+	deltas := []Delta{
+		newDelta("requests", float64(a.Requests), float64(b.Requests), false),
+		newDelta("error_rate", a.ErrorRate, b.ErrorRate, true),
+	}
+	for _, name := range PhaseNames {
+		pa, okA := a.Phases[name]
+		pb, okB := b.Phases[name]
+		if !okA && !okB {
+			continue
+		}
+		deltas = append(deltas,
+			newDelta(name+".avg", pa.Avg, pb.Avg, true),
+			newDelta(name+".p95", pa.P95, pb.P95, true),
+		)
+	}
+	return deltas
+}
+
+// FamilyShare returns the fraction of requests per status family.
+func (s Summary) FamilyShare(fam string) float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.StatusFamily[fam]) / float64(s.Requests)
+}
+
+func newDelta(metric string, a, b float64, higherIsWorse bool) Delta {
+	d := Delta{Metric: metric, A: a, B: b, Abs: b - a, HigherIsWorse: higherIsWorse}
+	if a == 0 {
+		d.Pct = math.NaN()
+	} else {
+		d.Pct = (b - a) / a * 100
+	}
+	return d
+}
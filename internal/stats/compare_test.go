@@ -0,0 +1,29 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRegressionZeroBaselineErrorRate(t *testing.T) {
+	a := Summary{Requests: 1000}
+	b := Summary{Requests: 1000, ErrorRate: 0.002}
+	var d Delta
+	for _, x := range Compare(a, b) {
+		if x.Metric == "error_rate" {
+			d = x
+		}
+	}
+	if !math.IsNaN(d.Pct) {
+		t.Fatalf("Pct = %v, want NaN for a zero baseline", d.Pct)
+	}
+	if d.Regression(math.Inf(1)) {
+		t.Error("regression without a fail threshold")
+	}
+	if d.Regression(1) {
+		t.Error("0.2pp counted as a regression past 1")
+	}
+	if !d.Regression(0.1) {
+		t.Error("0.2pp not counted as a regression past 0.1")
+	}
+}
@@ -0,0 +1,179 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"shard/internal/attack"
+)
+
+// CompressionSummary covers the response bodies Shard decoded itself: their
+// size on the wire and decoded, and the client time decoding took. Times are
+// in milliseconds.
+type CompressionSummary struct {
+	Responses int            `json:"responses"`
+	Encodings map[string]int `json:"encodings"` // by Content-Encoding, "identity" when none
+	WireBytes int64          `json:"wire_bytes"`
+	Bytes     int64          `json:"bytes"` // the same bodies decoded
+	// Ratio is decoded over wire bytes of the compressed responses only,
+	// EffectiveRatio the same over every response, uncompressed ones too:
+	// how much less the run moved than it would have without compression.
+	Ratio          float64 `json:"ratio,omitempty"`
+	EffectiveRatio float64 `json:"effective_ratio,omitempty"`
+	DecodeMs       float64 `json:"decode_ms"` // summed over all responses
+	DecodeAvgMs    float64 `json:"decode_avg_ms"`
+	DecodeMaxMs    float64 `json:"decode_max_ms"`
+	// DecodeShare is decoding time over the latency of compressed responses.
+	DecodeShare float64 `json:"decode_share"`
+	// With load.identity_fraction, the requests that asked for an
+	// uncompressed body and the rest, side by side.
+	Identity   *EncodingGroup `json:"identity,omitempty"`
+	Negotiated *EncodingGroup `json:"negotiated,omitempty"`
+}
+
+// EncodingGroup is the traffic of one side of an identity comparison.
+type EncodingGroup struct {
+	Requests     int          `json:"requests"`
+	AvgWireBytes float64      `json:"avg_wire_bytes"`
+	TTFB         PhaseSummary `json:"ttfb"`
+	Total        PhaseSummary `json:"total"`
+}
+
+type compressionStats struct {
+	responses            int
+	encodings            map[string]int
+	wire, bytes          int64
+	compWire, compBytes  int64   // compressed responses only
+	compLatency          float64 // ms
+	decode, decodeMax    float64 // ms
+	compressed           int
+	identity, negotiated encodingGroupStats
+}
+
+type encodingGroupStats struct {
+	wire        int64
+	ttfb, total phaseStats
+}
+
+func (g *encodingGroupStats) add(r attack.Result) {
+	g.wire += r.WireBytes
+	g.ttfb.add(r.Phases.TTFB)
+	g.total.add(r.Phases.Total)
+}
+
+func (g *encodingGroupStats) summary() *EncodingGroup {
+	if g.total.Count == 0 {
+		return nil
+	}
+	return &EncodingGroup{
+		Requests:     g.total.Count,
+		AvgWireBytes: float64(g.wire) / float64(g.total.Count),
+		TTFB:         g.ttfb.summary(),
+		Total:        g.total.summary(),
+	}
+}
+
+func (a *Aggregator) addCompression(r attack.Result) {
+	if r.Code == 0 || (r.WireBytes == 0 && r.Encoding == "" && !r.Identity) {
+		return
+	}
+	c := &a.compression
+	if c.encodings == nil {
+		c.encodings = make(map[string]int)
+		c.identity.ttfb.Min, c.identity.total.Min = 1e9, 1e9
+		c.negotiated.ttfb.Min, c.negotiated.total.Min = 1e9, 1e9
+	}
+	c.responses++
+	c.wire += r.WireBytes
+	c.bytes += r.Bytes
+	if r.Encoding == "" {
+		c.encodings["identity"]++
+	} else {
+		c.encodings[r.Encoding]++
+		c.compressed++
+		c.compWire += r.WireBytes
+		c.compBytes += r.Bytes
+		c.compLatency += toMs(r.Phases.Total)
+	}
+	ms := toMs(r.Decode)
+	c.decode += ms
+	c.decodeMax = math.Max(c.decodeMax, ms)
+	if r.Identity {
+		c.identity.add(r)
+	} else {
+		c.negotiated.add(r)
+	}
+}
+
+func (a *Aggregator) compressionSummary() *CompressionSummary {
+	c := &a.compression
+	if c.responses == 0 {
+		return nil
+	}
+	s := &CompressionSummary{
+		Responses:   c.responses,
+		Encodings:   copyMap(c.encodings),
+		WireBytes:   c.wire,
+		Bytes:       c.bytes,
+		DecodeMs:    c.decode,
+		DecodeMaxMs: c.decodeMax,
+	}
+	if c.compWire > 0 {
+		s.Ratio = float64(c.compBytes) / float64(c.compWire)
+	}
+	if c.wire > 0 {
+		s.EffectiveRatio = float64(c.bytes) / float64(c.wire)
+	}
+	if c.compressed > 0 {
+		s.DecodeAvgMs = c.decode / float64(c.compressed)
+	}
+	if c.compLatency > 0 {
+		s.DecodeShare = c.decode / c.compLatency
+	}
+	// a comparison needs both sides
+	if c.identity.total.Count > 0 {
+		s.Identity, s.Negotiated = c.identity.summary(), c.negotiated.summary()
+	}
+	return s
+}
+
+func printCompression(w io.Writer, s *CompressionSummary, f numFormat) {
+	encodings := make([]string, 0, len(s.Encodings))
+	for e := range s.Encodings {
+		encodings = append(encodings, e)
+	}
+	sort.Strings(encodings)
+	fmt.Fprintf(w, "\nCompression: %s responses decoded by Shard", f.count(s.Responses))
+	for _, e := range encodings {
+		fmt.Fprintf(w, " %s=%s", e, f.count(s.Encodings[e]))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  size     : %s on the wire, %s decoded", f.bytes(s.WireBytes), f.bytes(s.Bytes))
+	if s.EffectiveRatio > 0 {
+		fmt.Fprintf(w, ", effective ratio %.2fx", s.EffectiveRatio)
+	}
+	if s.Ratio > 0 {
+		fmt.Fprintf(w, " (compressed bodies %.2fx)", s.Ratio)
+	}
+	fmt.Fprintln(w)
+	if s.DecodeMs > 0 {
+		fmt.Fprintf(w, "  decoding : %s client time, avg %.3fms max %s per compressed response (%.2f%% of their latency)\n",
+			f.ms(s.DecodeMs), s.DecodeAvgMs, f.ms(s.DecodeMaxMs), s.DecodeShare*100)
+	}
+	if s.Identity == nil {
+		return
+	}
+	for _, g := range []struct {
+		name string
+		g    *EncodingGroup
+	}{{"identity", s.Identity}, {"negotiated", s.Negotiated}} {
+		if g.g == nil {
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s: %s requests, avg %s on the wire, ttfb p50=%s p95=%s, total p50=%s p95=%s\n",
+			g.name, f.count(g.g.Requests), f.bytes(int64(g.g.AvgWireBytes)),
+			f.ms(g.g.TTFB.P50), f.ms(g.g.TTFB.P95), f.ms(g.g.Total.P50), f.ms(g.g.Total.P95))
+	}
+}
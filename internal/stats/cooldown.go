@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// recoveryTolerance is how close to the baseline p95 latency has to return.
+const recoveryTolerance = 1.10
+
+// CooldownBucket is one slice of the cooldown recovery curve.
+type CooldownBucket struct {
+	OffsetSeconds int     `json:"offset_seconds"`
+	Count         int     `json:"count"`
+	P95           float64 `json:"p95"`
+}
+
+// CooldownSummary describes how the target recovered after the main load.
+type CooldownSummary struct {
+	Requests      int              `json:"requests"`
+	BucketSeconds int              `json:"bucket_seconds"`
+	Buckets       []CooldownBucket `json:"buckets"`
+	// BaselineP95 is the pre-load latency recovery is measured against: the
+	// load.baseline probes' total p95, or without them the warmup p95.
+	BaselineP95    float64 `json:"baseline_p95,omitempty"`
+	BaselineSource string  `json:"baseline_source,omitempty"` // "baseline" or "warmup"
+	// RecoveredAfter is seconds into cooldown until p95 was back within 10% of
+	// the baseline; -1 if it never recovered or no baseline was available.
+	RecoveredAfter int `json:"recovered_after_seconds"`
+}
+
+// cooldownTracking counts cooldown latencies per second from the first
+// cooldown result read, so buckets are exact for files in time order.
+type cooldownTracking struct {
+	secs          map[int64]*digest
+	anchor, first time.Time
+}
+
+// addCooldown keeps cooldown results out of the main stats.
+func (a *Aggregator) addCooldown(r attack.Result) {
+	if r.Error != "" {
+		return
+	}
+	c := &a.cooldown
+	if c.secs == nil {
+		c.secs = make(map[int64]*digest)
+		c.anchor, c.first = r.Timestamp, r.Timestamp
+	}
+	c.first = minTime(c.first, r.Timestamp)
+	i := floorDiv(r.Timestamp.Sub(c.anchor), time.Second)
+	if c.secs[i] == nil {
+		c.secs[i] = &digest{}
+	}
+	c.secs[i].add(toMs(r.Phases.Total))
+}
+
+func (a *Aggregator) cooldownSummary() *CooldownSummary {
+	c := &a.cooldown
+	if len(c.secs) == 0 {
+		return nil
+	}
+	secs := make([]int64, 0, len(c.secs))
+	requests := 0
+	for i, d := range c.secs {
+		secs = append(secs, i)
+		requests += int(d.n)
+	}
+	sort.Slice(secs, func(i, j int) bool { return secs[i] < secs[j] })
+
+	at := func(sec int64) time.Time { return c.anchor.Add(time.Duration(sec) * time.Second) }
+	span := at(secs[len(secs)-1]).Sub(c.first) + time.Second
+	width := a.bucket
+	if width <= 0 {
+		width = time.Duration(math.Ceil(span.Seconds()/maxTimelineBuckets)) * time.Second
+	}
+
+	cs := &CooldownSummary{Requests: requests, BucketSeconds: int(width / time.Second), RecoveredAfter: -1}
+	switch {
+	case a.baseline != nil && a.baseline.TotalP95 > 0:
+		cs.BaselineP95, cs.BaselineSource = a.baseline.TotalP95, "baseline"
+	case a.warmupLat.Count > 0:
+		cs.BaselineP95, cs.BaselineSource = a.warmupLat.summary().P95, "warmup"
+	}
+
+	var bucket digest
+	idx := 0
+	flush := func() {
+		if bucket.n == 0 {
+			return
+		}
+		b := CooldownBucket{OffsetSeconds: idx * cs.BucketSeconds, Count: int(bucket.n), P95: bucket.quantile(95)}
+		cs.Buckets = append(cs.Buckets, b)
+		if cs.RecoveredAfter < 0 && cs.BaselineP95 > 0 && b.P95 <= cs.BaselineP95*recoveryTolerance {
+			cs.RecoveredAfter = b.OffsetSeconds
+		}
+		bucket = digest{}
+	}
+	for _, sec := range secs {
+		i := int(max(0, floorDiv(at(sec).Sub(c.first), width)))
+		if i != idx {
+			flush()
+			idx = i
+		}
+		bucket.merge(c.secs[sec])
+	}
+	flush()
+	return cs
+}
+
+// printCooldown renders the recovery curve as p95 per bucket with a bar.
+func printCooldown(w io.Writer, cs *CooldownSummary) {
+	fmt.Fprintf(w, "\nCooldown recovery (%d requests, p95 per %ds):\n", cs.Requests, cs.BucketSeconds)
+	peak := 0.0
+	for _, b := range cs.Buckets {
+		peak = math.Max(peak, b.P95)
+	}
+	for _, b := range cs.Buckets {
+		bar := 0
+		if peak > 0 {
+			bar = int(b.P95 / peak * 30)
+		}
+		fmt.Fprintf(w, "  %-7s %8.2fms %s\n", fmt.Sprintf("+%ds", b.OffsetSeconds), b.P95, bars(bar))
+	}
+	switch {
+	case cs.BaselineP95 == 0:
+		fmt.Fprintln(w, "  no baseline: configure load.baseline or load.warmup to measure recovery time")
+	case cs.RecoveredAfter >= 0:
+		fmt.Fprintf(w, "  recovered to within 10%% of %s p95 %.2fms after %ds\n", cs.BaselineSource, cs.BaselineP95, cs.RecoveredAfter)
+	default:
+		fmt.Fprintf(w, "  ⚠️  did not recover to within 10%% of %s p95 %.2fms\n", cs.BaselineSource, cs.BaselineP95)
+	}
+}
+
+func bars(n int) string {
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = '█'
+	}
+	return string(b)
+}
@@ -0,0 +1,67 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"shard/internal/config"
+)
+
+// CostEstimate prices the measured traffic with one pricing profile.
+type CostEstimate struct {
+	Currency string  `json:"currency"`
+	Requests float64 `json:"requests"` // request charges
+	Egress   float64 `json:"egress"`   // response bytes charges
+	Total    float64 `json:"total"`
+	PerHour  float64 `json:"per_hour"` // the same load sustained for an hour
+}
+
+// SetPricing enables cost estimates for the given profiles, see config.CostProfile.
+func (a *Aggregator) SetPricing(profiles map[string]config.CostProfile) {
+	a.pricing = profiles
+}
+
+func (a *Aggregator) costEstimates(s Summary) map[string]CostEstimate {
+	if len(a.pricing) == 0 {
+		return nil
+	}
+	out := make(map[string]CostEstimate, len(a.pricing))
+	for name, p := range a.pricing {
+		e := CostEstimate{
+			Currency: p.Currency,
+			Requests: float64(s.Requests) / 1e6 * p.PerMillionRequests,
+			Egress:   float64(s.BytesReceived) / 1e9 * p.PerGBEgress,
+		}
+		e.Total = e.Requests + e.Egress
+		if s.DurationSeconds > 0 {
+			e.PerHour = e.Total * 3600 / s.DurationSeconds
+		}
+		out[name] = e
+	}
+	return out
+}
+
+func printCost(w io.Writer, s Summary, f numFormat) {
+	names := make([]string, 0, len(s.Cost))
+	for name := range s.Cost {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// pricing is per decimal GB, unlike the 1024-based sizes elsewhere in the report
+	fmt.Fprintf(w, "\nEstimated cost (%s requests, %.3f GB egress, 1 GB = 10⁹ bytes):\n", f.count(s.Requests), float64(s.BytesReceived)/1e9)
+	fmt.Fprintf(w, "  %-12s %12s %12s %12s %14s\n", "Profile", "Requests", "Egress", "Total", "Per hour")
+	for _, name := range names {
+		e := s.Cost[name]
+		fmt.Fprintf(w, "  %-12s %12s %12s %12s %14s\n", name,
+			money(e.Requests, e.Currency), money(e.Egress, e.Currency), money(e.Total, e.Currency), money(e.PerHour, e.Currency))
+	}
+}
+
+// money keeps sub-cent amounts visible; short runs rarely cost a whole cent.
+func money(v float64, currency string) string {
+	if v != 0 && v < 0.01 {
+		return fmt.Sprintf("%.6f %s", v, currency)
+	}
+	return fmt.Sprintf("%.2f %s", v, currency)
+}
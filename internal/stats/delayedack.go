@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+
+	"shard/internal/attack"
+)
+
+// delayedACKFloors are the classic delayed-ACK timeouts in ms: Linux's
+// minimum, and the 200ms of Windows and older stacks (Linux's maximum). A
+// small write held back by Nagle's algorithm until the peer's delayed ACK
+// adds one of them to the request.
+var delayedACKFloors = []float64{40, 200}
+
+const (
+	// delayedACKMinShare and delayedACKMinRequests keep a few slow requests
+	// from counting as a spike.
+	delayedACKMinShare    = 0.05
+	delayedACKMinRequests = 20
+	// delayedACKDensity is how much denser, per ms, latencies must be just
+	// above a floor than in the half below it.
+	delayedACKDensity = 5
+)
+
+// DelayedACKSummary flags a spike of successful requests just above a
+// delayed-ACK timeout: the latency floor Nagle's algorithm and delayed ACKs
+// produce together on small request/response pairs.
+type DelayedACKSummary struct {
+	FloorMs  float64 `json:"floor_ms"`
+	Requests int     `json:"requests"` // successful requests from the floor to 1.25× it
+	Share    float64 `json:"share"`    // of all successful requests
+	// TCPNoDelay is load.tcp_no_delay as the results file recorded it; it is
+	// left out for files from before it was.
+	TCPNoDelay *bool `json:"tcp_no_delay,omitempty"`
+}
+
+func (a *Aggregator) addDelayedACK(r attack.Result) {
+	if r.Error == "" {
+		a.ackLat.add(toMs(r.Phases.Total))
+	}
+}
+
+// noteNoDelay records the tcp_no_delay setting of a meta record; one
+// record with it off is enough for the whole report to say so.
+func (a *Aggregator) noteNoDelay(on *bool) {
+	if on == nil || (a.tcpNoDelay != nil && !*a.tcpNoDelay) {
+		return
+	}
+	a.tcpNoDelay = on
+	if !*on {
+		a.notes = append(a.notes, "load.tcp_no_delay was off: Nagle's algorithm could hold small writes")
+	}
+}
+
+// delayedACKSummary returns the floor with the biggest spike, or nil when
+// no floor has one.
+func (a *Aggregator) delayedACKSummary() *DelayedACKSummary {
+	d := &a.ackLat
+	if d.n < delayedACKMinRequests {
+		return nil
+	}
+	var best *DelayedACKSummary
+	for _, floor := range delayedACKFloors {
+		top := floor * 1.25
+		band := d.countFrom(floor) - d.countFrom(top)
+		below := d.countFrom(floor/2) - d.countFrom(floor)
+		share := float64(band) / float64(d.n)
+		if band < delayedACKMinRequests || share < delayedACKMinShare {
+			continue
+		}
+		if float64(band)/(top-floor) < delayedACKDensity*float64(below)/(floor/2) {
+			continue
+		}
+		if best == nil || share > best.Share {
+			best = &DelayedACKSummary{FloorMs: floor, Requests: band, Share: share, TCPNoDelay: a.tcpNoDelay}
+		}
+	}
+	return best
+}
+
+func printDelayedACK(w io.Writer, s *DelayedACKSummary, f numFormat) {
+	fmt.Fprintf(w, "\n⚠️  Delayed-ACK suspect: %.1f%% of successful requests (%s) took %g–%gms, far more than just below\n",
+		s.Share*100, f.count(s.Requests), s.FloorMs, s.FloorMs*1.25)
+	switch {
+	case s.TCPNoDelay != nil && !*s.TCPNoDelay:
+		fmt.Fprintln(w, "  hint: load.tcp_no_delay was off, so Nagle held small writes until the target's delayed ACK; turn it back on")
+	case s.TCPNoDelay != nil:
+		fmt.Fprintln(w, "  hint: Shard dialed with TCP_NODELAY; check that the target sets it too and writes each response in one piece")
+	default:
+		fmt.Fprintln(w, "  hint: a small write waited for a delayed ACK; check TCP_NODELAY on both ends (load.tcp_no_delay for Shard)")
+	}
+}
@@ -0,0 +1,158 @@
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// digestBits sets the digest's resolution: samples are kept exactly below
+// 2^digestBits thousandths of a unit (65.5ms in microseconds for
+// millisecond samples), and beyond that in 32768 buckets per power of two, a
+// relative error under 0.004%. However many samples arrive, a digest of
+// latencies up to an hour holds at most about half a million buckets, and
+// typically a few thousand.
+const digestBits = 16
+
+// digestScale is how many buckets a unit spans below 2^digestBits: samples
+// keep three decimals, so millisecond latencies keep their microseconds.
+const digestScale = 1000
+
+// digest is a bounded-memory latency distribution: sample counts per bucket
+// instead of the samples themselves. Samples are in milliseconds unless
+// noted, rounded to thousandths. Percentiles come out as the nearest rank,
+// exact for anything under 65.536 units.
+type digest struct {
+	counts map[int64]int64 // bucket (its lowest value, in thousandths) -> samples
+	n      int64
+}
+
+// digestKey returns the bucket a sample falls in.
+func digestKey(v float64) int64 {
+	return digestBucket(int64(math.Round(v * digestScale)))
+}
+
+// keyValue returns the sample value a bucket stands for.
+func keyValue(k int64) float64 {
+	return float64(k) / digestScale
+}
+
+// digestBucket returns the bucket of a value.
+func digestBucket(v int64) int64 {
+	if v < 0 {
+		return -digestBucket(-v)
+	}
+	if v < 1<<digestBits {
+		return v
+	}
+	shift := bits.Len64(uint64(v)) - digestBits
+	return v >> shift << shift
+}
+
+func (d *digest) add(v float64) {
+	if d.counts == nil {
+		d.counts = make(map[int64]int64)
+	}
+	d.counts[digestKey(v)]++
+	d.n++
+}
+
+func (d *digest) merge(o *digest) {
+	if o == nil || o.n == 0 {
+		return
+	}
+	if d.counts == nil {
+		d.counts = make(map[int64]int64, len(o.counts))
+	}
+	for k, c := range o.counts {
+		d.counts[k] += c
+	}
+	d.n += o.n
+}
+
+// MarshalJSON encodes the digest as [bucket, count] pairs in bucket order,
+// as prune records carry it.
+func (d digest) MarshalJSON() ([]byte, error) {
+	pairs := make([][2]int64, 0, len(d.counts))
+	for _, k := range d.keys() {
+		pairs = append(pairs, [2]int64{k, d.counts[k]})
+	}
+	return json.Marshal(pairs)
+}
+
+func (d *digest) UnmarshalJSON(data []byte) error {
+	var pairs [][2]int64
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return err
+	}
+	*d = digest{counts: make(map[int64]int64, len(pairs))}
+	for _, p := range pairs {
+		d.counts[p[0]] += p[1]
+		d.n += p[1]
+	}
+	return nil
+}
+
+func (d *digest) keys() []int64 {
+	keys := make([]int64, 0, len(d.counts))
+	for k := range d.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// quantile is percentile's nearest rank over the digest.
+func (d *digest) quantile(p float64) float64 {
+	return d.quantiles(p)[0]
+}
+
+// quantiles returns several nearest-rank percentiles with one sort.
+func (d *digest) quantiles(ps ...float64) []float64 {
+	out := make([]float64, len(ps))
+	if d == nil || d.n == 0 {
+		return out
+	}
+	keys := d.keys()
+	for i, p := range ps {
+		rank := int64(ceilRank(p, d.n))
+		var seen int64
+		for _, k := range keys {
+			seen += d.counts[k]
+			if seen > rank {
+				out[i] = keyValue(k)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// ceilRank is the 0-based nearest rank of percentile p among n samples.
+func ceilRank(p float64, n int64) int64 {
+	rank := int64(math.Ceil(p/100*float64(n))) - 1
+	return max(0, min(rank, n-1))
+}
+
+// countFrom returns how many samples are at or above v.
+func (d *digest) countFrom(v float64) int {
+	var n int64
+	for k, c := range d.counts {
+		if keyValue(k) >= v {
+			n += c
+		}
+	}
+	return int(n)
+}
+
+// sumFrom returns the sum of the samples at or above v.
+func (d *digest) sumFrom(v float64) float64 {
+	var sum float64
+	for k, c := range d.counts {
+		if keyValue(k) >= v {
+			sum += keyValue(k) * float64(c)
+		}
+	}
+	return sum
+}
@@ -0,0 +1,263 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// A lookup is a spike when it takes dnsSpikeRatio times the median lookup
+// and at least dnsSpikeMin longer. Spikes less than dnsEpisodeGap apart
+// belong to the same episode: every connection dialed while the resolver
+// refreshes its cache pays for it.
+const (
+	dnsSpikeRatio = 4
+	dnsSpikeMin   = 2 * time.Millisecond
+	dnsEpisodeGap = time.Second
+)
+
+// DNSSummary looks at the DNS phase over time: lookups much slower than
+// usual, whether they recur periodically in step with the record's TTL (a
+// resolver re-fetching an expired record), and how much of the latency
+// tail they account for. Times are in milliseconds.
+type DNSSummary struct {
+	BucketSeconds int         `json:"bucket_seconds"`
+	Lookups       int         `json:"lookups"` // requests whose connection resolved the host
+	MedianMs      float64     `json:"median_ms"`
+	Spikes        int         `json:"spikes"`
+	Episodes      []float64   `json:"episodes,omitempty"` // seconds from the run start
+	PeriodSec     float64     `json:"period_s,omitempty"` // typical gap between episodes, when regular
+	TTLMax        *uint32     `json:"ttl_max,omitempty"`  // longest TTL answered, i.e. for a fresh record
+	Aligned       bool        `json:"aligned_with_ttl"`   // the period matches TTLMax
+	FreshSpikes   int         `json:"fresh_spikes"`       // spikes answered with a fresh TTL
+	P99Ms         float64     `json:"p99_ms"`
+	P99Share      float64     `json:"p99_dns_share"` // share of time at or above p99 spent in DNS
+	Buckets       []DNSBucket `json:"buckets"`
+}
+
+// DNSBucket is one time bucket of lookups, bucketed like the address timeline.
+type DNSBucket struct {
+	Lookups int     `json:"lookups"`
+	AvgMs   float64 `json:"avg_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// dnsSecond holds one second of lookups in bounded memory: digests of
+// microseconds per answered TTL (a second's lookups share one or two), so
+// the spike threshold can be applied once the median is known, and the
+// lookups that could be the first and last spike of the second.
+type dnsSecond struct {
+	byTTL       map[int64]*digest // -1 when no TTL was answered
+	first, last []dnsPoint
+	n           int
+	sum, max    float64
+}
+
+type dnsPoint struct {
+	at time.Time
+	us float64 // as bucketed in the digests
+}
+
+// frontier adds p to the points no other point outdoes by being both at
+// least as slow and earlier (or later, for the last spikes): whatever the
+// threshold, the first spike of the second is among them.
+func frontier(pts []dnsPoint, p dnsPoint, earlier func(a, b time.Time) bool) []dnsPoint {
+	for _, q := range pts {
+		if !earlier(p.at, q.at) && q.us >= p.us {
+			return pts
+		}
+	}
+	kept := pts[:0]
+	for _, q := range pts {
+		if earlier(q.at, p.at) || q.us > p.us {
+			kept = append(kept, q)
+		}
+	}
+	return append(kept, p)
+}
+
+// spikeSpan returns the first and last lookup of the second at or above
+// threshold µs.
+func (sec *dnsSecond) spikeSpan(threshold float64) (first, last time.Time) {
+	for _, p := range sec.first {
+		if p.us >= threshold && (first.IsZero() || p.at.Before(first)) {
+			first = p.at
+		}
+	}
+	for _, p := range sec.last {
+		if p.us >= threshold && p.at.After(last) {
+			last = p.at
+		}
+	}
+	return first, last
+}
+
+func (a *Aggregator) addDNS(r attack.Result) {
+	if r.Phases.DNS <= 0 || r.Timestamp.IsZero() {
+		return
+	}
+	if a.dnsSecs == nil {
+		a.dnsSecs = make(map[int64]*dnsSecond)
+		a.dnsByTotal = make(map[int64]float64)
+	}
+	sec := a.dnsSecs[r.Timestamp.Unix()]
+	if sec == nil {
+		sec = &dnsSecond{byTTL: make(map[int64]*digest)}
+		a.dnsSecs[r.Timestamp.Unix()] = sec
+	}
+	dns := toMs(r.Phases.DNS)
+	ttl := int64(-1)
+	if r.DNSTTL != nil {
+		ttl = int64(*r.DNSTTL)
+		if a.dnsTTLMax == nil || *r.DNSTTL > *a.dnsTTLMax {
+			v := *r.DNSTTL
+			a.dnsTTLMax = &v
+		}
+	}
+	if sec.byTTL[ttl] == nil {
+		sec.byTTL[ttl] = &digest{}
+	}
+	us := int64(dns * 1000)
+	sec.byTTL[ttl].add(float64(us))
+	p := dnsPoint{at: r.Timestamp, us: keyValue(digestKey(float64(us)))}
+	sec.first = frontier(sec.first, p, time.Time.Before)
+	sec.last = frontier(sec.last, p, time.Time.After)
+	sec.n++
+	sec.sum += dns
+	sec.max = math.Max(sec.max, dns)
+	// keyed like the total digest
+	a.dnsByTotal[digestKey(toMs(r.Phases.Total))] += dns
+}
+
+func (a *Aggregator) dnsSummary() *DNSSummary {
+	if len(a.dnsSecs) == 0 {
+		return nil
+	}
+	secs := make([]int64, 0, len(a.dnsSecs))
+	var all digest
+	for t, sec := range a.dnsSecs {
+		secs = append(secs, t)
+		for _, d := range sec.byTTL {
+			all.merge(d)
+		}
+	}
+	sort.Slice(secs, func(i, j int) bool { return secs[i] < secs[j] })
+	s := &DNSSummary{Lookups: int(all.n), MedianMs: all.quantile(50) / 1000, TTLMax: a.dnsTTLMax}
+
+	threshold := math.Max(s.MedianMs*dnsSpikeRatio, s.MedianMs+toMs(dnsSpikeMin)) * 1000 // µs
+	var last time.Time
+	for _, t := range secs {
+		sec := a.dnsSecs[t]
+		spikes := 0
+		for ttl, d := range sec.byTTL {
+			n := d.countFrom(threshold)
+			spikes += n
+			if s.TTLMax != nil && ttl >= 0 && ttl+1 >= int64(*s.TTLMax) {
+				s.FreshSpikes += n
+			}
+		}
+		if spikes == 0 {
+			continue
+		}
+		s.Spikes += spikes
+		// spikes within a second are less than dnsEpisodeGap apart
+		first, end := sec.spikeSpan(threshold)
+		if last.IsZero() || first.Sub(last) >= dnsEpisodeGap {
+			s.Episodes = append(s.Episodes, first.Sub(a.first).Seconds())
+		}
+		last = end
+	}
+	s.PeriodSec = regularPeriod(s.Episodes)
+	if s.PeriodSec > 0 && s.TTLMax != nil {
+		ttl := float64(*s.TTLMax)
+		s.Aligned = math.Abs(s.PeriodSec-ttl) <= math.Max(1, ttl/4)
+	}
+	s.P99Ms, s.P99Share = a.dnsTailShare()
+	s.Buckets, s.BucketSeconds = a.dnsBuckets()
+	return s
+}
+
+// regularPeriod returns the median gap between episodes when at least three
+// gaps exist and most lie within 25% of it; 0 otherwise.
+func regularPeriod(episodes []float64) float64 {
+	if len(episodes) < 4 {
+		return 0
+	}
+	gaps := make([]float64, len(episodes)-1)
+	for i := range gaps {
+		gaps[i] = episodes[i+1] - episodes[i]
+	}
+	sort.Float64s(gaps)
+	med := percentile(gaps, 50)
+	near := 0
+	for _, g := range gaps {
+		if math.Abs(g-med) <= med/4 {
+			near++
+		}
+	}
+	if near*4 < len(gaps)*3 {
+		return 0
+	}
+	return med
+}
+
+// dnsTailShare returns the p99 of total latency over all measured requests
+// and the share of the time spent at or above it that went into DNS.
+func (a *Aggregator) dnsTailShare() (p99, share float64) {
+	var all digest
+	all.merge(&a.stats["total"].digest)
+	all.merge(&a.afterIdle["total"].digest)
+	p99 = all.quantile(99)
+	tail := all.sumFrom(p99)
+	var dns float64
+	for k, v := range a.dnsByTotal {
+		if keyValue(k) >= p99 {
+			dns += v
+		}
+	}
+	if tail > 0 {
+		share = math.Min(dns/tail, 1)
+	}
+	return p99, share
+}
+
+func (a *Aggregator) dnsBuckets() ([]DNSBucket, int) {
+	start, width, n := a.buckets()
+	buckets := make([]DNSBucket, n)
+	for t, sec := range a.dnsSecs {
+		i := int((t - start) / width)
+		if i < 0 || i >= n {
+			continue
+		}
+		b := &buckets[i]
+		b.Lookups += sec.n
+		b.AvgMs += sec.sum
+		b.MaxMs = math.Max(b.MaxMs, sec.max)
+	}
+	for i := range buckets {
+		if buckets[i].Lookups > 0 {
+			buckets[i].AvgMs /= float64(buckets[i].Lookups)
+		}
+	}
+	return buckets, int(width)
+}
+
+func printDNS(w io.Writer, s *DNSSummary) {
+	fmt.Fprintf(w, "\nDNS lookups: %d, median %.2fms, %d spike(s) in %d episode(s)\n",
+		s.Lookups, s.MedianMs, s.Spikes, len(s.Episodes))
+	if s.TTLMax != nil {
+		fmt.Fprintf(w, "  resolver TTL : up to %ds, %d spike(s) on a freshly fetched record\n", *s.TTLMax, s.FreshSpikes)
+	}
+	if s.PeriodSec > 0 {
+		fmt.Fprintf(w, "  spikes recur every ~%.0fs", s.PeriodSec)
+		if s.Aligned {
+			fmt.Fprint(w, ", in step with the TTL: the resolver re-fetches the expired record")
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "  tail         : %.0f%% of the time at or above p99 (%.0fms) is DNS\n", s.P99Share*100, s.P99Ms)
+}
@@ -0,0 +1,214 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// Filter selects the results a report covers, see SetFilter. Its conditions
+// are ANDed. Typed records (meta, annotations, ...) are always read.
+type Filter struct {
+	Since, Until time.Time // zero for no bound
+	// SinceLast and UntilLast are bounds relative to the latest timestamp in
+	// the inputs ("last 2m"); Anchor turns them into Since and Until.
+	SinceLast, UntilLast time.Duration
+	Statuses             map[string]bool // "503" and families such as "5xx"
+	ErrorsOnly           bool            // failed requests and statuses >= 400
+	ErrorClasses         map[string]bool // Result.Error values, e.g. "connect"
+}
+
+// FilterSummary states the report -since/-until/-status/-errors-only/
+// -error-class filters in effect and what they left out.
+type FilterSummary struct {
+	Active   string `json:"active"`
+	Matched  int    `json:"matched"`  // results the report covers
+	Excluded int    `json:"excluded"` // results the filters dropped
+}
+
+// ParseFilter builds a filter from report flags; it is nil when none is
+// set. since and until take RFC 3339 times or "last <duration>", status a
+// list of statuses and families (500,503 or 5xx), classes a list of error
+// classes.
+func ParseFilter(since, until, status, classes string, errorsOnly bool) (*Filter, error) {
+	if since == "" && until == "" && status == "" && classes == "" && !errorsOnly {
+		return nil, nil
+	}
+	f := &Filter{ErrorsOnly: errorsOnly}
+	var err error
+	if f.Since, f.SinceLast, err = parseFilterTime(since); err != nil {
+		return nil, fmt.Errorf("-since: %w", err)
+	}
+	if f.Until, f.UntilLast, err = parseFilterTime(until); err != nil {
+		return nil, fmt.Errorf("-until: %w", err)
+	}
+	if status != "" {
+		f.Statuses = make(map[string]bool)
+		for _, s := range strings.Split(status, ",") {
+			s = strings.ToLower(strings.TrimSpace(s))
+			if !statusSpec(s) {
+				return nil, fmt.Errorf("-status: %q is not a status (503) or a status family (5xx)", s)
+			}
+			f.Statuses[s] = true
+		}
+	}
+	if classes != "" {
+		f.ErrorClasses = make(map[string]bool)
+		for _, c := range strings.Split(classes, ",") {
+			if c = strings.TrimSpace(c); c == "" {
+				return nil, errors.New("-error-class: empty class")
+			}
+			f.ErrorClasses[c] = true
+		}
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Until.After(f.Since) {
+		return nil, errors.New("-until must be after -since")
+	}
+	return f, nil
+}
+
+// parseFilterTime reads an RFC 3339 time or "last <duration>".
+func parseFilterTime(s string) (time.Time, time.Duration, error) {
+	if s == "" {
+		return time.Time{}, 0, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "last"); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return time.Time{}, 0, fmt.Errorf("%q: want \"last\" and a positive duration, e.g. \"last 2m\"", s)
+		}
+		return time.Time{}, d, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("%q: want an RFC 3339 time or \"last <duration>\"", s)
+	}
+	return t, 0, nil
+}
+
+// statusSpec reports whether s is a status ("503") or a family ("5xx").
+func statusSpec(s string) bool {
+	if len(s) != 3 || s[0] < '1' || s[0] > '5' {
+		return false
+	}
+	if s[1:] == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// Relative reports whether the filter has a "last" bound, which needs
+// Anchor before loading.
+func (f *Filter) Relative() bool {
+	return f.SinceLast > 0 || f.UntilLast > 0
+}
+
+// Anchor resolves the "last" bounds against latest, the latest timestamp
+// in the inputs.
+func (f *Filter) Anchor(latest time.Time) {
+	if f.SinceLast > 0 {
+		f.Since = latest.Add(-f.SinceLast)
+	}
+	if f.UntilLast > 0 {
+		f.Until = latest.Add(-f.UntilLast)
+	}
+}
+
+func (f *Filter) match(r attack.Result) bool {
+	switch {
+	case !f.Since.IsZero() && r.Timestamp.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && r.Timestamp.After(f.Until):
+		return false
+	case f.ErrorsOnly && r.Error == "" && r.Code < 400:
+		return false
+	case f.ErrorClasses != nil && !f.ErrorClasses[r.Error]:
+		return false
+	}
+	if f.Statuses != nil {
+		code := strconv.Itoa(r.Code)
+		if r.Code == 0 || !(f.Statuses[code] || f.Statuses[code[:1]+"xx"]) {
+			return false
+		}
+	}
+	return true
+}
+
+// describe lists the conditions, in the order the flags are documented.
+func (f *Filter) describe() string {
+	var parts []string
+	bound := func(name string, t time.Time, last time.Duration) {
+		switch {
+		case last > 0:
+			parts = append(parts, fmt.Sprintf("%s %s (last %s)", name, t.UTC().Format(time.RFC3339), last))
+		case !t.IsZero():
+			parts = append(parts, name+" "+t.UTC().Format(time.RFC3339))
+		}
+	}
+	bound("since", f.Since, f.SinceLast)
+	bound("until", f.Until, f.UntilLast)
+	if f.Statuses != nil {
+		parts = append(parts, "status "+strings.Join(sortedSet(f.Statuses), ","))
+	}
+	if f.ErrorsOnly {
+		parts = append(parts, "errors only")
+	}
+	if f.ErrorClasses != nil {
+		parts = append(parts, "error class "+strings.Join(sortedSet(f.ErrorClasses), ","))
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// SetFilter has LoadJSONL drop the results f does not match before
+// aggregating them.
+func (a *Aggregator) SetFilter(f *Filter) {
+	a.filter = f
+}
+
+func (a *Aggregator) filterSummary() *FilterSummary {
+	if a.filter == nil {
+		return nil
+	}
+	return &FilterSummary{Active: a.filter.describe(), Matched: a.results, Excluded: a.filtered}
+}
+
+func printFilter(w io.Writer, f *FilterSummary) {
+	fmt.Fprintln(w, "\n=== Filter ===")
+	fmt.Fprintf(w, "  active  : %s\n", f.Active)
+	fmt.Fprintf(w, "  matched : %d records, %d excluded\n", f.Matched, f.Excluded)
+}
+
+// LatestTimestamp returns the latest timestamp of any result in the results
+// file at path, for anchoring "last" filter bounds. Typed records are
+// skipped, since some are written after the run, e.g. by prune. Lines that
+// do not parse are skipped, as LoadJSONL counts them.
+func LatestTimestamp(path string) (time.Time, error) {
+	r, err := openJSONL(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer r.Close()
+	var latest time.Time
+	for {
+		line, err := r.ReadBytes('\n')
+		var rec struct {
+			Timestamp time.Time `json:"ts"`
+		}
+		if !isRecordType(line) && json.Unmarshal(line, &rec) == nil && rec.Timestamp.After(latest) {
+			latest = rec.Timestamp
+		}
+		if err == io.EOF {
+			return latest, nil
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+}
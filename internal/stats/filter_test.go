@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shard/internal/attack"
+)
+
+func TestLatestTimestampSkipsPruneRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	enc := json.NewEncoder(f)
+	for i := 0; i < 100; i++ {
+		res := attack.Result{Timestamp: start.Add(time.Duration(i) * time.Second), Code: 200}
+		if i%33 == 0 {
+			res.Code = 503 // kept by prune, the last one included
+		}
+		res.Phases.Total = 5 * time.Millisecond
+		if err := enc.Encode(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+	if _, err := PruneFile(path, PruneOptions{Slow: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := LatestTimestamp(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(99 * time.Second); !latest.Equal(want) {
+		t.Fatalf("LatestTimestamp = %v, want the last result's %v", latest, want)
+	}
+
+	filter, err := ParseFilter("last 30s", "", "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter.Anchor(latest)
+	a := New()
+	a.SetFilter(filter)
+	if err := a.LoadJSONL(path); err != nil {
+		t.Fatal(err)
+	}
+	if s := a.Summary(); s.Filter.Matched == 0 {
+		t.Fatal(`"last 30s" matched nothing in the pruned file`)
+	}
+}
@@ -0,0 +1,140 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"shard/internal/attack"
+)
+
+// HeaderBucket is one time bucket of a numeric header's values.
+type HeaderBucket struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Avg   float64 `json:"avg"`
+}
+
+// HeaderSeries summarizes one numeric response header over the run (see
+// output.capture.numeric_headers), bucketed like the address timeline.
+type HeaderSeries struct {
+	BucketSeconds int `json:"bucket_seconds"`
+
+	Count   int            `json:"count"`
+	Min     float64        `json:"min"`
+	Avg     float64        `json:"avg"`
+	Max     float64        `json:"max"`
+	Buckets []HeaderBucket `json:"buckets"`
+	// ZeroAt is the offset in seconds from the run start at which the value
+	// first reached zero (quota exhausted), or -1 if it never did.
+	ZeroAt int64 `json:"zero_at"`
+}
+
+type headerSecond struct {
+	count         int
+	min, max, sum float64
+}
+
+func (a *Aggregator) addHeaders(r attack.Result) {
+	if r.Timestamp.IsZero() {
+		return
+	}
+	for name, v := range r.HeaderValues {
+		secs := a.headerSecs[name]
+		if secs == nil {
+			secs = make(map[int64]*headerSecond)
+			a.headerSecs[name] = secs
+		}
+		sec := secs[r.Timestamp.Unix()]
+		if sec == nil {
+			sec = &headerSecond{min: v, max: v}
+			secs[r.Timestamp.Unix()] = sec
+		}
+		sec.count++
+		sec.sum += v
+		sec.min = math.Min(sec.min, v)
+		sec.max = math.Max(sec.max, v)
+	}
+}
+
+func (a *Aggregator) headerSeries() map[string]HeaderSeries {
+	if len(a.headerSecs) == 0 {
+		return nil
+	}
+	start, width, n := a.buckets()
+	out := make(map[string]HeaderSeries, len(a.headerSecs))
+	for name, secs := range a.headerSecs {
+		s := HeaderSeries{BucketSeconds: int(width), Min: math.Inf(1), Max: math.Inf(-1), ZeroAt: -1, Buckets: make([]HeaderBucket, n)}
+		sums := make([]float64, n)
+		var sum float64
+		for sec, hs := range secs {
+			i := int((sec - start) / width)
+			if i < 0 || i >= n {
+				continue
+			}
+			b := &s.Buckets[i]
+			if b.Count == 0 || hs.min < b.Min {
+				b.Min = hs.min
+			}
+			b.Count += hs.count
+			sums[i] += hs.sum
+			s.Count += hs.count
+			sum += hs.sum
+			s.Min = math.Min(s.Min, hs.min)
+			s.Max = math.Max(s.Max, hs.max)
+			if hs.min <= 0 && (s.ZeroAt < 0 || sec-start < s.ZeroAt) {
+				s.ZeroAt = sec - start
+			}
+		}
+		for i := range s.Buckets {
+			if s.Buckets[i].Count > 0 {
+				s.Buckets[i].Avg = sums[i] / float64(s.Buckets[i].Count)
+			}
+		}
+		if s.Count == 0 {
+			continue
+		}
+		s.Avg = sum / float64(s.Count)
+		out[name] = s
+	}
+	return out
+}
+
+func printHeaders(w io.Writer, series map[string]HeaderSeries) {
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "\nNumeric headers (min/avg per bucket; ! marks zero):")
+	for _, name := range names {
+		s := series[name]
+		bucketSeconds := s.BucketSeconds
+		fmt.Fprintf(w, "  %s: n=%d min=%s avg=%s max=%s\n", name, s.Count, num(s.Min), num(s.Avg), num(s.Max))
+		fmt.Fprint(w, "   ")
+		for i, b := range s.Buckets {
+			cell := "-"
+			if b.Count > 0 {
+				mark := ""
+				if b.Min <= 0 {
+					mark = "!"
+				}
+				cell = fmt.Sprintf("%s%s/%s", num(b.Min), mark, num(b.Avg))
+			}
+			fmt.Fprintf(w, " %s %s", fmt.Sprintf("+%ds", i*bucketSeconds), cell)
+		}
+		fmt.Fprintln(w)
+		if s.ZeroAt >= 0 {
+			fmt.Fprintf(w, "  ⚠️  %s reached zero at +%ds\n", name, s.ZeroAt)
+		}
+	}
+}
+
+// num prints whole values without decimals and others with two.
+func num(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
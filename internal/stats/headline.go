@@ -0,0 +1,131 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"shard/internal/attack"
+)
+
+// StageOrder is the order plan stages are reported in.
+var StageOrder = []string{"warmup", "ramp", "steady", "search", "cooldown"}
+
+// StageSummary is the outcome of one plan stage.
+type StageSummary struct {
+	Requests  int          `json:"requests"`
+	Failed    int          `json:"failed"`
+	ErrorRate float64      `json:"error_rate"`
+	Total     PhaseSummary `json:"total"`
+}
+
+type stageStats struct {
+	requests int
+	failed   int
+	total    *phaseStats
+}
+
+func (a *Aggregator) addStage(r attack.Result) {
+	name := r.Stage
+	if name == "" {
+		name = "steady"
+	}
+	st := a.stages[name]
+	if st == nil {
+		st = &stageStats{total: &phaseStats{Min: 1e9}}
+		a.stages[name] = st
+	}
+	st.requests++
+	if r.Error != "" {
+		st.failed++
+		return
+	}
+	st.total.add(r.Phases.Total)
+}
+
+func (a *Aggregator) stageSummaries() map[string]StageSummary {
+	out := make(map[string]StageSummary, len(a.stages))
+	for name, st := range a.stages {
+		ss := StageSummary{Requests: st.requests, Failed: st.failed, Total: st.total.summary()}
+		if st.requests > 0 {
+			ss.ErrorRate = float64(st.failed) / float64(st.requests)
+		}
+		out[name] = ss
+	}
+	return out
+}
+
+// Verdict grades the run by the share of requests that failed or got a 5xx:
+// "healthy" below 1%, "degraded" below 5%, "failing" otherwise.
+func (s Summary) Verdict() string {
+	if s.Requests == 0 {
+		return "no data"
+	}
+	bad := float64(s.Failed+s.StatusFamily["5xx"]) / float64(s.Requests)
+	switch {
+	case bad < 0.01:
+		return "healthy"
+	case bad < 0.05:
+		return "degraded"
+	default:
+		return "failing"
+	}
+}
+
+// printHeadline prints the five-line overview shown at every verbosity level.
+func printHeadline(w io.Writer, s Summary, f numFormat) {
+	total := s.Phases["total"]
+	if s.Delivered != s.Requests {
+		fmt.Fprintf(w, "  requests   : %s attempted, %s delivered to the server\n", f.count(s.Requests), f.count(s.Delivered))
+	} else {
+		fmt.Fprintf(w, "  requests   : %s\n", f.count(s.Requests))
+	}
+	fmt.Fprintf(w, "  error rate : %.2f%% (%s failed, %s 5xx)\n", s.ErrorRate*100, f.count(s.Failed), f.count(s.StatusFamily["5xx"]))
+	fmt.Fprintf(w, "  latency    : p50=%s p95=%s p99=%s\n", f.ms(total.P50), f.ms(total.P95), f.ms(total.P99))
+	var received string
+	if s.BytesReceived > 0 {
+		received = ", " + f.bytes(s.BytesReceived) + " received"
+	}
+	if s.Delivered != s.Requests {
+		fmt.Fprintf(w, "  throughput : %s req/s delivered (%s attempted) over %s%s\n",
+			f.rate(s.DeliveredThroughput), f.rate(s.Throughput), f.seconds(s.DurationSeconds), received)
+	} else {
+		fmt.Fprintf(w, "  throughput : %s req/s over %s%s\n", f.rate(s.Throughput), f.seconds(s.DurationSeconds), received)
+	}
+	fmt.Fprintf(w, "  verdict    : %s\n", s.Verdict())
+}
+
+func printStages(w io.Writer, stages map[string]StageSummary) {
+	if len(stages) == 0 {
+		return
+	}
+	names := append([]string(nil), StageOrder...)
+	var extra []string
+	for name := range stages {
+		if !contains(StageOrder, name) {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	names = append(names, extra...)
+
+	fmt.Fprintln(w, "\nPer-stage:")
+	fmt.Fprintf(w, "  %-10s %-9s %-8s %-10s %-10s\n", "Stage", "Requests", "Err%", "Avg", "P95")
+	for _, name := range names {
+		st, ok := stages[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s %-9d %-8.2f %-10.2f %-10.2f\n",
+			name, st.Requests, st.ErrorRate*100, st.Total.Avg, st.Total.P95)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
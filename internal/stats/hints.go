@@ -0,0 +1,50 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+
+	"shard/internal/attack"
+)
+
+// EarlyHintsSummary describes requests that received 1xx responses (typically
+// 103 Early Hints) before the final one.
+type EarlyHintsSummary struct {
+	Requests int         `json:"requests"`
+	Codes    map[int]int `json:"codes"`
+	// Gap is the time from the first 1xx to the final response's headers, in ms.
+	Gap PhaseSummary `json:"gap"`
+}
+
+func (a *Aggregator) addHints(r attack.Result) {
+	if len(r.Informational) == 0 {
+		return
+	}
+	a.hinted++
+	for _, c := range r.Informational {
+		a.hintCodes[c]++
+	}
+	a.hintGap.add(r.Phases.TTFB - r.EarlyHintsAt)
+}
+
+func (a *Aggregator) hintsSummary() *EarlyHintsSummary {
+	if a.hinted == 0 {
+		return nil
+	}
+	return &EarlyHintsSummary{Requests: a.hinted, Codes: copyMap(a.hintCodes), Gap: a.hintGap.summary()}
+}
+
+func printHints(w io.Writer, h *EarlyHintsSummary, total int) {
+	pct := 0.0
+	if total > 0 {
+		pct = float64(h.Requests) / float64(total) * 100
+	}
+	fmt.Fprintf(w, "\nInformational responses: %d requests (%.1f%%)", h.Requests, pct)
+	for _, c := range sortedKeysInt(h.Codes) {
+		fmt.Fprintf(w, " %d=%d", c, h.Codes[c])
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  gap to final response (ms): avg=%.2f p50=%.2f p95=%.2f max=%.2f\n",
+		h.Gap.Avg, h.Gap.P50, h.Gap.P95, h.Gap.Max)
+	fmt.Fprintln(w, "  (ttfb is measured to the final response)")
+}
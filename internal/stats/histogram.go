@@ -0,0 +1,153 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the report's latency
+// histogram, log-scaled from 1ms to 10s.
+var DefaultLatencyBuckets = []time.Duration{
+	1 * time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	1 * time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+// HistogramBucket counts the successful requests with total latency in
+// (From, To] milliseconds; the last bucket has no upper bound (To = 0).
+type HistogramBucket struct {
+	From    float64 `json:"from"`
+	To      float64 `json:"to,omitempty"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// LatencyHistogram is the total latency distribution of successful requests.
+type LatencyHistogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	Failed  int               `json:"failed_excluded"`
+}
+
+// SetLatencyBuckets replaces the histogram's bucket upper bounds, which must
+// be increasing. It has to be called before results are added.
+func (a *Aggregator) SetLatencyBuckets(bounds []time.Duration) error {
+	for i, b := range bounds {
+		if b <= 0 || (i > 0 && b <= bounds[i-1]) {
+			return fmt.Errorf("latency buckets must be positive and increasing, got %v", bounds)
+		}
+	}
+	a.histBounds = bounds
+	a.histCounts = make([]int, len(bounds)+1)
+	return nil
+}
+
+func (a *Aggregator) addHistogram(r attack.Result) {
+	if r.Error != "" {
+		return
+	}
+	i := 0
+	for i < len(a.histBounds) && r.Phases.Total > a.histBounds[i] {
+		i++
+	}
+	a.histCounts[i]++
+}
+
+// histogram trims leading and trailing empty buckets.
+func (a *Aggregator) histogram() *LatencyHistogram {
+	first, last := -1, -1
+	total := 0
+	for i, n := range a.histCounts {
+		if n == 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+		total += n
+	}
+	if total == 0 {
+		return nil
+	}
+	h := &LatencyHistogram{Failed: a.failed}
+	for i := first; i <= last; i++ {
+		b := HistogramBucket{Count: a.histCounts[i], Percent: float64(a.histCounts[i]) / float64(total) * 100}
+		if i > 0 {
+			b.From = toMs(a.histBounds[i-1])
+		}
+		if i < len(a.histBounds) {
+			b.To = toMs(a.histBounds[i])
+		}
+		h.Buckets = append(h.Buckets, b)
+	}
+	return h
+}
+
+func toMs(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// ParseBuckets parses comma-separated bucket bounds such as "5ms,50ms,1s";
+// bare numbers are milliseconds.
+func ParseBuckets(s string) ([]time.Duration, error) {
+	var out []time.Duration
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f)
+		if err != nil {
+			var ms float64
+			if _, scanErr := fmt.Sscanf(f, "%g", &ms); scanErr != nil {
+				return nil, fmt.Errorf("bad bucket %q: %v", f, err)
+			}
+			d = time.Duration(ms * float64(time.Millisecond))
+		}
+		out = append(out, d)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no buckets in %q", s)
+	}
+	return out, nil
+}
+
+const histBarWidth = 40
+
+func printHistogram(w io.Writer, h *LatencyHistogram, f numFormat) {
+	max := 0
+	for _, b := range h.Buckets {
+		if b.Count > max {
+			max = b.Count
+		}
+	}
+	fmt.Fprintln(w, "\nLatency histogram (total, successful requests):")
+	for _, b := range h.Buckets {
+		var label string
+		switch {
+		case b.To == 0:
+			label = fmt.Sprintf("> %s", fmtMs(b.From))
+		default:
+			label = fmt.Sprintf("%s – %s", fmtMs(b.From), fmtMs(b.To))
+		}
+		bar := strings.Repeat("■", b.Count*histBarWidth/max)
+		if bar == "" && b.Count > 0 {
+			bar = "▏"
+		}
+		fmt.Fprintf(w, "  %17s %9s %6.2f%% %s\n", label, f.count(b.Count), b.Percent, bar)
+	}
+	if h.Failed > 0 {
+		fmt.Fprintf(w, "  (%s failed requests not shown)\n", f.count(h.Failed))
+	}
+}
+
+// fmtMs prints a bucket bound in the largest unit that keeps it whole-ish.
+func fmtMs(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%gs", ms/1000)
+	}
+	return fmt.Sprintf("%gms", ms)
+}
@@ -0,0 +1,92 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"shard/internal/config"
+)
+
+// numFormat renders numbers in the text report. By default large values are
+// humanized (12.8M requests, 3m03s, 1.20 GB); raw keeps every digit so two
+// reports diff cleanly. Abbreviations are fixed English, whatever the
+// machine's locale. The JSON summary never goes through it.
+type numFormat struct {
+	raw bool
+}
+
+// SetRawNumbers makes the text report print plain numbers instead of
+// humanized ones.
+func (a *Aggregator) SetRawNumbers(raw bool) {
+	a.raw = raw
+}
+
+// count abbreviates counts from 10000 up: 45.6K, 12.8M, 1.2B.
+func (f numFormat) count(n int) string {
+	if f.raw || n < 10000 {
+		return fmt.Sprintf("%d", n)
+	}
+	return abbrev(float64(n))
+}
+
+// rate is count for per-second values.
+func (f numFormat) rate(v float64) string {
+	if f.raw || v < 10000 {
+		return fmt.Sprintf("%.1f", v)
+	}
+	return abbrev(v)
+}
+
+func abbrev(v float64) string {
+	switch {
+	case v >= 999.95e6:
+		return fmt.Sprintf("%.1fB", v/1e9)
+	case v >= 999.95e3:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	}
+	return fmt.Sprintf("%.1fK", v/1e3)
+}
+
+// ms prints a latency with its unit, switching to seconds, minutes and hours
+// as it grows: 183456.23ms becomes 3m03s.
+func (f numFormat) ms(v float64) string {
+	if f.raw || v < 1000 {
+		return fmt.Sprintf("%.2fms", v)
+	}
+	return humanDuration(time.Duration(v * float64(time.Millisecond)))
+}
+
+// cell is ms for table columns headed "(ms)": raw values carry no unit.
+func (f numFormat) cell(v float64) string {
+	if f.raw {
+		return fmt.Sprintf("%.2f", v)
+	}
+	return f.ms(v)
+}
+
+// seconds prints a run length.
+func (f numFormat) seconds(v float64) string {
+	if f.raw || v < 60 {
+		return fmt.Sprintf("%.1fs", v)
+	}
+	return humanDuration(time.Duration(v * float64(time.Second)))
+}
+
+func (f numFormat) bytes(n int64) string {
+	if f.raw {
+		return fmt.Sprintf("%d B", n)
+	}
+	return config.FormatSize(n)
+}
+
+func humanDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	case d < time.Hour:
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%dm%02ds", int(d/time.Minute), int(d%time.Minute/time.Second))
+	}
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
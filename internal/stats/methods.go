@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"shard/internal/attack"
+)
+
+// MethodSummary is the measured traffic of one HTTP method.
+type MethodSummary struct {
+	Requests  int     `json:"requests"`
+	Failed    int     `json:"failed"`
+	ErrorRate float64 `json:"error_rate"`
+	P95       float64 `json:"p95"` // total latency, ms
+}
+
+type methodStats struct {
+	failed int
+	total  *phaseStats
+}
+
+func (a *Aggregator) addMethod(r attack.Result) {
+	if r.Method == "" {
+		return
+	}
+	ms := a.methods[r.Method]
+	if ms == nil {
+		ms = &methodStats{total: &phaseStats{Min: 1e9}}
+		a.methods[r.Method] = ms
+	}
+	if r.Error != "" {
+		ms.failed++
+	}
+	ms.total.add(r.Phases.Total)
+}
+
+func (a *Aggregator) methodSummaries() map[string]MethodSummary {
+	if len(a.methods) == 0 {
+		return nil
+	}
+	out := make(map[string]MethodSummary, len(a.methods))
+	for m, ms := range a.methods {
+		s := MethodSummary{Requests: ms.total.Count, Failed: ms.failed, P95: ms.total.summary().P95}
+		if s.Requests > 0 {
+			s.ErrorRate = float64(s.Failed) / float64(s.Requests)
+		}
+		out[m] = s
+	}
+	return out
+}
+
+func printMethods(w io.Writer, methods map[string]MethodSummary) {
+	names := make([]string, 0, len(methods))
+	for m := range methods {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "\nPer method:")
+	fmt.Fprintf(w, "  %-8s %10s %10s %8s %10s\n", "Method", "Requests", "Failed", "Err%", "P95 ms")
+	for _, m := range names {
+		s := methods[m]
+		fmt.Fprintf(w, "  %-8s %10d %10d %7.2f%% %10.2f\n", m, s.Requests, s.Failed, s.ErrorRate*100, s.P95)
+	}
+}
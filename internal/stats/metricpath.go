@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CheckMetricPath reports whether path, dotted JSON field names such as
+// "throughput" or "phases.total.p99", names a number the summary JSON can
+// contain. Map keys (phases, status codes) match any segment. It looks at
+// the Summary type only, so it can vet a reference before any run.
+func CheckMetricPath(path string) error {
+	t := reflect.TypeOf(Summary{})
+	var walked []string
+	for _, seg := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			f, ok := jsonField(t, seg)
+			if !ok {
+				return fmt.Errorf("summary has no field %q", strings.Join(append(walked, seg), "."))
+			}
+			t = f.Type
+		default:
+			return fmt.Errorf("summary field %q has no fields", strings.Join(walked, "."))
+		}
+		walked = append(walked, seg)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return nil
+	}
+	return fmt.Errorf("summary field %q is not a number", path)
+}
+
+// jsonField finds the struct field encoded under name.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// LookupMetric reads the number at path from a summary JSON document. A
+// path CheckMetricPath accepts can still be absent: optional sections are
+// left out of summaries that have nothing to put in them.
+func LookupMetric(summaryJSON []byte, path string) (float64, error) {
+	var v any
+	if err := json.Unmarshal(summaryJSON, &v); err != nil {
+		return 0, fmt.Errorf("parse summary: %w", err)
+	}
+	for _, seg := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return 0, fmt.Errorf("%s: not in the summary", path)
+		}
+		if v, ok = obj[seg]; !ok {
+			return 0, fmt.Errorf("%s: not in the summary", path)
+		}
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s: not a number in the summary", path)
+	}
+	return n, nil
+}
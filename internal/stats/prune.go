@@ -0,0 +1,311 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// ErrPruned is returned by PruneFile for a file that was already pruned.
+var ErrPruned = errors.New("already pruned")
+
+// PruneOptions selects the rows a pruned results file keeps besides failures
+// and typed records.
+type PruneOptions struct {
+	Slow       time.Duration // keep successes at least this slow
+	SampleRate float64       // fraction of the remaining successes to keep
+}
+
+// PruneRecord is appended to a pruned results file. It records how the file
+// was thinned and the summary of the full data, which reports use instead of
+// recomputing from the remaining rows. The files of one run, such as its
+// rotation segments, are pruned together: each gets a record with the same
+// Timestamp and its own counts, and the first one carries the snapshot of
+// them all.
+type PruneRecord struct {
+	Type       string    `json:"type"` // always "prune"
+	Timestamp  time.Time `json:"ts"`
+	SlowMs     float64   `json:"slow_ms"`
+	SampleRate float64   `json:"sample_rate"`
+	Total      int       `json:"total"` // results in this file before pruning
+	Kept       int       `json:"kept"`
+	Files      int       `json:"files,omitempty"` // files pruned together; 0 in older records means 1
+	Snapshot   *Summary  `json:"snapshot,omitempty"`
+	// Latency holds the full data's latency digests, so thresholds and SLOs
+	// are judged on every request rather than on the kept rows.
+	Latency *PruneLatency `json:"latency,omitempty"`
+}
+
+// PruneLatency is the latency distribution of a run before pruning.
+type PruneLatency struct {
+	Phases map[string]*digest `json:"phases"`
+	// Buckets holds total latency per default timeline bucket, for
+	// per-bucket thresholds.
+	Buckets       []digest `json:"buckets,omitempty"`
+	BucketSeconds int64    `json:"bucket_seconds,omitempty"`
+	Unavailable   int      `json:"unavailable"` // errors and 5xx, for availability SLOs
+}
+
+// PruneInfo describes how a run's data was thinned: in the summary of a
+// pruned run and in its run directory's summary file.
+type PruneInfo struct {
+	Timestamp  time.Time `json:"ts"`
+	SlowMs     float64   `json:"slow_ms"`
+	SampleRate float64   `json:"sample_rate"`
+	Files      int       `json:"files"`
+	Total      int       `json:"total"` // results before pruning
+	Kept       int       `json:"kept"`
+}
+
+// PruneSummary totals the records of files pruned together.
+func PruneSummary(recs []PruneRecord) PruneInfo {
+	var info PruneInfo
+	for _, r := range recs {
+		info.Timestamp, info.SlowMs, info.SampleRate = r.Timestamp, r.SlowMs, r.SampleRate
+		info.Total += r.Total
+		info.Kept += r.Kept
+	}
+	info.Files = len(recs)
+	return info
+}
+
+// PruneFile prunes a single results file, see PruneFiles.
+func PruneFile(path string, opt PruneOptions) (PruneRecord, error) {
+	recs, err := PruneFiles([]string{path}, opt)
+	if err != nil {
+		return PruneRecord{}, err
+	}
+	return recs[0], nil
+}
+
+// PruneFiles rewrites the results files of one run in place, keeping
+// failures (errors and non-2xx statuses), slow requests, typed records and a
+// sample of successes, and returns their records in order. The snapshot
+// covers all the files, so pass every segment of a rotated run at once.
+// Compressed files keep their codec and each file keeps its mode; no file
+// is replaced unless all of them were rewritten. Files that were already
+// pruned are refused with ErrPruned, since their snapshot could no longer be
+// taken from complete data.
+func PruneFiles(paths []string, opt PruneOptions) ([]PruneRecord, error) {
+	full := New()
+	full.slaSecs = make(map[int64]*digest) // for per-bucket thresholds on the pruned run
+	for _, path := range paths {
+		if err := full.LoadJSONL(path); err != nil {
+			return nil, err
+		}
+		if len(full.prunes) > 0 {
+			return nil, fmt.Errorf("%s: %w", path, ErrPruned)
+		}
+	}
+	snap := full.Summary()
+	now := time.Now()
+	recs := make([]PruneRecord, len(paths))
+	tmps := make([]string, 0, len(paths))
+	defer func() {
+		for _, tmp := range tmps {
+			os.Remove(tmp)
+		}
+	}()
+	for i, path := range paths {
+		recs[i] = PruneRecord{
+			Type:       "prune",
+			Timestamp:  now,
+			SlowMs:     toMs(opt.Slow),
+			SampleRate: opt.SampleRate,
+			Files:      len(paths),
+		}
+		if i == 0 {
+			recs[i].Snapshot = &snap
+			recs[i].Latency = full.pruneLatency()
+		}
+		tmp, err := pruneTo(path, opt, &recs[i])
+		if tmp != "" {
+			tmps = append(tmps, tmp)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for i, path := range paths {
+		if err := os.Rename(tmps[i], path); err != nil {
+			return nil, err
+		}
+	}
+	return recs, nil
+}
+
+// pruneTo writes the pruned rows of path and rec to a temporary file next to
+// it and returns the file's name.
+func pruneTo(path string, opt PruneOptions, rec *PruneRecord) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	in, err := openJSONL(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".prune-*")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	// CreateTemp makes the file 0600; keep the mode the results file had
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return tmp.Name(), err
+	}
+	var out io.Writer = tmp
+	zw, err := attack.CompressWriter(tmp, in.codec)
+	if err != nil {
+		return tmp.Name(), err
+	}
+	if zw != nil {
+		out = zw
+	}
+	bw := bufio.NewWriter(out)
+
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(line) > 0 && keepLine(line, opt, rec) {
+			if _, werr := bw.Write(line); werr != nil {
+				return tmp.Name(), werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return tmp.Name(), err
+		}
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return tmp.Name(), err
+	}
+	bw.Write(append(b, '\n'))
+	if err := bw.Flush(); err != nil {
+		return tmp.Name(), err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return tmp.Name(), err
+		}
+	}
+	return tmp.Name(), tmp.Close()
+}
+
+func keepLine(line []byte, opt PruneOptions, rec *PruneRecord) bool {
+	if isRecordType(line) {
+		return true
+	}
+	var res attack.Result
+	if json.Unmarshal(line, &res) != nil {
+		return false
+	}
+	rec.Total++
+	keep := res.Error != "" || failedStatus(res.Code) || res.Phases.Total >= opt.Slow || rand.Float64() < opt.SampleRate
+	if keep {
+		rec.Kept++
+	}
+	return keep
+}
+
+// failedStatus reports whether a response status counts as a failure for
+// pruning: anything outside 2xx, except a WebSocket upgrade's 101.
+func failedStatus(code int) bool {
+	if code == 0 || code == http.StatusSwitchingProtocols {
+		return false
+	}
+	return code < 200 || code >= 300
+}
+
+func (a *Aggregator) addPrune(line []byte) {
+	var rec PruneRecord
+	if json.Unmarshal(line, &rec) == nil {
+		a.prunes = append(a.prunes, rec)
+	}
+}
+
+// pruneLatency captures the digests a pruned run's thresholds and SLOs are
+// judged on.
+func (a *Aggregator) pruneLatency() *PruneLatency {
+	l := &PruneLatency{Phases: make(map[string]*digest), Unavailable: a.slo.unavailable}
+	for _, name := range PhaseNames {
+		if ps := a.stats[name]; ps != nil && ps.Count > 0 {
+			l.Phases[name] = &ps.digest
+		}
+	}
+	l.Buckets, l.BucketSeconds = a.slaBuckets()
+	return l
+}
+
+// pruneSet returns the record carrying the snapshot when the aggregator holds
+// exactly the rows of one set of files pruned together.
+func (a *Aggregator) pruneSet() (*PruneRecord, bool) {
+	var first *PruneRecord
+	kept := 0
+	for i := range a.prunes {
+		p := &a.prunes[i]
+		if p.Timestamp != a.prunes[0].Timestamp || max(p.Files, 1) != len(a.prunes) {
+			return nil, false
+		}
+		if p.Snapshot != nil {
+			first = p
+		}
+		kept += p.Kept
+	}
+	return first, first != nil && kept == a.results
+}
+
+// prunedSummary returns the pre-prune snapshot when the aggregator holds
+// exactly the rows of one pruned run; otherwise the figures computed from
+// thinned rows are kept and flagged. Thresholds and SLOs are judged on the
+// snapshot's digests.
+func (a *Aggregator) prunedSummary(s Summary) Summary {
+	if len(a.prunes) == 0 {
+		return s
+	}
+	if a.filter != nil {
+		// the snapshot covers every result, not the ones the filters kept
+		s.Notes = append(s.Notes, "⚠️  input is pruned data; filtered figures are computed from thinned rows and are not exact")
+		return s
+	}
+	first, ok := a.pruneSet()
+	if !ok {
+		s.Notes = append(s.Notes, "⚠️  input includes pruned data mixed with other results; figures are computed from thinned rows and are not exact")
+		return s
+	}
+	info := PruneSummary(a.prunes)
+	snap := *first.Snapshot
+	snap.Pruned = &info
+	snap.Notes = append(append([]string(nil), snap.Notes...), fmt.Sprintf(
+		"data pruned %s: kept %d of %d results (failures, ≥%gms, %g%% of other successes); figures are from the pre-prune snapshot",
+		info.Timestamp.Format(time.RFC3339), info.Kept, info.Total, info.SlowMs, info.SampleRate*100))
+	snap.Cost = a.costEstimates(snap)
+	lat := first.Latency
+	if lat == nil {
+		// pruned before the digests were recorded
+		snap.Thresholds, snap.SLO = s.Thresholds, s.SLO
+		if len(s.Thresholds) > 0 || len(s.SLO) > 0 {
+			snap.Notes = append(snap.Notes, "⚠️  thresholds and SLOs are judged on the thinned rows and are not exact")
+		}
+		return snap
+	}
+	if len(a.thresholds) > 0 {
+		snap.Thresholds = judgeThresholds(a.thresholds, lat.Phases["total"], lat.Buckets, lat.BucketSeconds)
+	}
+	if len(a.slo.rules) > 0 {
+		snap.SLO = judgeSLO(a.slo.rules, snap, func(phase string) *digest { return lat.Phases[phase] }, lat.Unavailable)
+	}
+	return snap
+}
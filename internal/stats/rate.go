@@ -0,0 +1,165 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// rateWindow is the width of the windows offered and achieved rates are
+// compared in; a window is behind when it achieved less than rateBehind of
+// what was offered.
+const (
+	rateWindow = 5 * time.Second
+	rateBehind = 0.95
+)
+
+// RateSummary compares the rate the scheduler offered with the rate requests
+// actually went out at. When workers saturate, requests queue up and start
+// late, so the server sees less load than planned and latencies understate
+// what users would see (coordinated omission).
+type RateSummary struct {
+	OfferedRate   float64      `json:"offered_rate"`  // scheduled requests per second
+	AchievedRate  float64      `json:"achieved_rate"` // the same requests by actual dispatch time
+	MaxLagMs      float64      `json:"max_sched_lag_ms"`
+	WindowSeconds int          `json:"window_seconds"`
+	Windows       []RateWindow `json:"windows"`
+	Scheduling    int          `json:"scheduling"` // windows with scheduled requests
+	Behind        int          `json:"behind"`     // of those, windows below 95% of offered
+}
+
+// RateWindow is one window of the run, by offset from the first schedule time.
+type RateWindow struct {
+	Offset   int     `json:"offset_s"`
+	Offered  float64 `json:"offered"`  // requests per second scheduled in the window
+	Achieved float64 `json:"achieved"` // requests per second dispatched in the window
+	Behind   bool    `json:"behind,omitempty"`
+}
+
+// rateTick is the resolution requests are counted at. Ticks start at the
+// first schedule time read, so windows are exact for files in schedule
+// order and off by less than a tick otherwise.
+const rateTick = 100 * time.Millisecond
+
+type rateTracking struct {
+	n                     int
+	firstSched, lastSched time.Time
+	firstSent, lastSent   time.Time
+	maxLag                time.Duration
+	anchor                time.Time     // tick 0
+	scheduled, dispatched map[int64]int // requests per tick
+}
+
+// addRate records every scheduled request, whatever its stage: falling
+// behind during warmup or cooldown is still falling behind.
+func (a *Aggregator) addRate(r attack.Result) {
+	if r.Scheduled.IsZero() || r.Timestamp.IsZero() {
+		return
+	}
+	t := &a.rate
+	if t.n == 0 {
+		t.firstSched, t.lastSched = r.Scheduled, r.Scheduled
+		t.firstSent, t.lastSent = r.Timestamp, r.Timestamp
+		t.anchor = r.Scheduled
+		t.scheduled, t.dispatched = make(map[int64]int), make(map[int64]int)
+	}
+	t.n++
+	t.firstSched, t.lastSched = minTime(t.firstSched, r.Scheduled), maxTime(t.lastSched, r.Scheduled)
+	t.firstSent, t.lastSent = minTime(t.firstSent, r.Timestamp), maxTime(t.lastSent, r.Timestamp)
+	t.maxLag = max(t.maxLag, r.Timestamp.Sub(r.Scheduled))
+	t.scheduled[floorDiv(r.Scheduled.Sub(t.anchor), rateTick)]++
+	t.dispatched[floorDiv(r.Timestamp.Sub(t.anchor), rateTick)]++
+}
+
+func (a *Aggregator) rateSummary() *RateSummary {
+	t := &a.rate
+	if t.n < 2 {
+		return nil
+	}
+	n := float64(t.n)
+	s := &RateSummary{MaxLagMs: toMs(t.maxLag), WindowSeconds: int(rateWindow / time.Second)}
+	if span := t.lastSched.Sub(t.firstSched).Seconds(); span > 0 {
+		s.OfferedRate = n / span
+	}
+	if span := t.lastSent.Sub(t.firstSent).Seconds(); span > 0 {
+		s.AchievedRate = n / span
+	}
+
+	windows := int(maxTime(t.lastSched, t.lastSent).Sub(t.firstSched)/rateWindow) + 1
+	offered := make([]int, windows)
+	achieved := make([]int, windows)
+	window := func(tick int64) int {
+		i := int(floorDiv(t.anchor.Add(time.Duration(tick)*rateTick).Sub(t.firstSched), rateWindow))
+		return max(0, min(i, windows-1))
+	}
+	for tick, c := range t.scheduled {
+		offered[window(tick)] += c
+	}
+	for tick, c := range t.dispatched {
+		if t.anchor.Add(time.Duration(tick+1) * rateTick).After(t.firstSched) {
+			achieved[window(tick)] += c
+		}
+	}
+	secs := rateWindow.Seconds()
+	for i := range offered {
+		w := RateWindow{Offset: i * s.WindowSeconds, Offered: float64(offered[i]) / secs, Achieved: float64(achieved[i]) / secs}
+		if offered[i] > 0 {
+			s.Scheduling++
+		}
+		// the last window is partial on both sides, so counts still compare
+		w.Behind = offered[i] > 0 && float64(achieved[i]) < float64(offered[i])*rateBehind
+		if w.Behind {
+			s.Behind++
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	return s
+}
+
+// floorDiv is d/unit rounded down, for times before an anchor.
+func floorDiv(d, unit time.Duration) int64 {
+	q := d / unit
+	if d%unit < 0 {
+		q--
+	}
+	return int64(q)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// printRateWarning is the prominent part, shown with the headline.
+func printRateWarning(w io.Writer, s *RateSummary) {
+	if s.Behind == 0 {
+		return
+	}
+	worst := s.Windows[0]
+	for _, win := range s.Windows {
+		if win.Behind && (!worst.Behind || win.Achieved/win.Offered < worst.Achieved/worst.Offered) {
+			worst = win
+		}
+	}
+	fmt.Fprintf(w, "\n⚠️  Coordinated omission: the run fell behind its schedule in %d of %d %ds windows\n",
+		s.Behind, s.Scheduling, s.WindowSeconds)
+	fmt.Fprintf(w, "   worst at +%ds: %.0f/s achieved of %.0f/s offered; the server saw less load than planned\n",
+		worst.Offset, worst.Achieved, worst.Offered)
+	fmt.Fprintln(w, "   and latencies understate what users would see. Add concurrency or lower the rate.")
+}
+
+func printRate(w io.Writer, s *RateSummary, f numFormat) {
+	fmt.Fprintf(w, "\nRate: offered %s/s, achieved %s/s, max scheduler lag %s\n",
+		f.rate(s.OfferedRate), f.rate(s.AchievedRate), f.ms(s.MaxLagMs))
+}
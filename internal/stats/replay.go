@@ -0,0 +1,218 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+)
+
+// replayShortShare is the share of its scheduled requests a window must
+// send in time not to count as short.
+const replayShortShare = 0.95
+
+// ReplaySummary sets a load.schedule_file replay against what was sent:
+// per window, the requests the schedule called for and those dispatched
+// while the window lasted. Requests dispatched after the schedule ended
+// (the run fell behind) are counted as Late.
+type ReplaySummary struct {
+	File      string               `json:"file"`
+	Burst     bool                 `json:"burst,omitempty"`
+	Length    float64              `json:"length"` // seconds
+	Scheduled int                  `json:"scheduled"`
+	Achieved  int                  `json:"achieved"`
+	Late      int                  `json:"late"`
+	Short     int                  `json:"short"` // windows under 95% of their schedule
+	Windows   []ReplayWindowResult `json:"windows"`
+}
+
+// ReplayWindowResult is one window of a replay. Offset is in seconds.
+type ReplayWindowResult struct {
+	Offset    float64 `json:"offset"`
+	Scheduled int     `json:"scheduled"`
+	Achieved  int     `json:"achieved"`
+}
+
+type replayTracking struct {
+	record   *attack.ReplayRecord
+	achieved []int
+	late     int
+}
+
+// addReplayRecord takes the schedule of a replay; results of the run come
+// after it.
+func (a *Aggregator) addReplayRecord(line []byte) {
+	var rec attack.ReplayRecord
+	if json.Unmarshal(line, &rec) == nil && len(rec.Windows) > 0 {
+		a.replay = replayTracking{record: &rec, achieved: make([]int, len(rec.Windows))}
+	}
+}
+
+// addReplay counts a scheduled request in the window it was dispatched in.
+// Later scenario steps were not scheduled and are left out.
+func (a *Aggregator) addReplay(r attack.Result) {
+	t := &a.replay
+	if t.record == nil || r.Scheduled.IsZero() {
+		return
+	}
+	off := r.Timestamp.Sub(t.record.Timestamp).Seconds()
+	if off >= t.record.Length {
+		t.late++
+		return
+	}
+	i := sort.Search(len(t.record.Windows), func(i int) bool { return t.record.Windows[i].Offset > off }) - 1
+	if i >= 0 {
+		t.achieved[i]++
+	}
+}
+
+func (a *Aggregator) replaySummary() *ReplaySummary {
+	t := &a.replay
+	if t.record == nil {
+		return nil
+	}
+	s := &ReplaySummary{File: t.record.File, Burst: t.record.Burst, Length: t.record.Length, Late: t.late,
+		Windows: make([]ReplayWindowResult, len(t.record.Windows))}
+	for i, w := range t.record.Windows {
+		s.Windows[i] = ReplayWindowResult{Offset: w.Offset, Scheduled: w.Count, Achieved: t.achieved[i]}
+		s.Scheduled += w.Count
+		s.Achieved += t.achieved[i]
+		if float64(t.achieved[i]) < replayShortShare*float64(w.Count) {
+			s.Short++
+		}
+	}
+	return s
+}
+
+// printReplay lists every window when there are few or the level asks for
+// detail, and otherwise only the short ones.
+func printReplay(w io.Writer, s *ReplaySummary, f numFormat, level int) {
+	mode := "spread over each window"
+	if s.Burst {
+		mode = "burst at each window start"
+	}
+	length := time.Duration(s.Length * float64(time.Second))
+	fmt.Fprintln(w, "\nReplay:")
+	fmt.Fprintf(w, "  schedule : %s, %d windows over %s, %s requests %s\n",
+		s.File, len(s.Windows), length, f.count(s.Scheduled), mode)
+	fmt.Fprintf(w, "  achieved : %s sent while it ran (%.1f%%)", f.count(s.Achieved), pct(s.Achieved, s.Scheduled))
+	if s.Late > 0 {
+		fmt.Fprintf(w, ", %s after it ended", f.count(s.Late))
+	}
+	if s.Short > 0 {
+		fmt.Fprintf(w, "; %d of %d windows short", s.Short, len(s.Windows))
+	}
+	fmt.Fprintln(w)
+	all := len(s.Windows) <= 20 || level >= 2
+	if !all && s.Short == 0 {
+		return
+	}
+	if !all {
+		fmt.Fprintf(w, "  %d windows short of their schedule by over 5%%:\n", s.Short)
+	}
+	fmt.Fprintf(w, "  %-10s %-10s %s\n", "Offset", "Scheduled", "Achieved")
+	shown := 0
+	for _, win := range s.Windows {
+		short := float64(win.Achieved) < replayShortShare*float64(win.Scheduled)
+		if !all && !short {
+			continue
+		}
+		if shown++; !all && shown > 10 {
+			fmt.Fprintf(w, "  … %d more (report -v 2 lists every window)\n", s.Short-10)
+			break
+		}
+		fmt.Fprintf(w, "  %-10s %-10s %s", fmt.Sprintf("%gs", win.Offset), f.count(win.Scheduled), f.count(win.Achieved))
+		if short {
+			fmt.Fprint(w, "  ⚠️  short")
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func pct(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(of)
+}
+
+// ScheduleFrom turns the dispatch times of the results in the files at
+// paths back into schedule rows for load.schedule_file, one per window of
+// the given length from the earliest result. Only scheduled requests
+// count, not later scenario steps. Empty stretches get a row of count 0
+// only where one must end the window before them, and a last one marks
+// where the schedule ends. The files are read twice: once for the start.
+func ScheduleFrom(paths []string, window time.Duration) ([]config.ScheduleWindow, error) {
+	scheduled := func(r attack.Result) bool { return r.Step == "" || !r.Scheduled.IsZero() }
+	var start time.Time
+	for _, path := range paths {
+		if err := scanResults(path, func(r attack.Result) {
+			if scheduled(r) && (start.IsZero() || r.Timestamp.Before(start)) {
+				start = r.Timestamp
+			}
+		}); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if start.IsZero() {
+		return nil, errors.New("no results")
+	}
+	counts := make(map[int64]int) // by window index
+	for _, path := range paths {
+		if err := scanResults(path, func(r attack.Result) {
+			if scheduled(r) {
+				counts[int64(r.Timestamp.Sub(start)/window)]++
+			}
+		}); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	keys := make([]int64, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	var rows []config.ScheduleWindow
+	row := func(k int64, n int) {
+		rows = append(rows, config.ScheduleWindow{Offset: time.Duration(k) * window, Count: n})
+	}
+	for i, k := range keys {
+		if i > 0 && keys[i-1] != k-1 {
+			row(keys[i-1]+1, 0)
+		}
+		row(k, counts[k])
+	}
+	row(keys[len(keys)-1]+1, 0)
+	return rows, nil
+}
+
+// scanResults calls fn for every result in the results file at path,
+// skipping typed records and lines that do not parse.
+func scanResults(path string, fn func(attack.Result)) error {
+	r, err := openJSONL(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 && !isRecordType(line) {
+			var res attack.Result
+			if json.Unmarshal(line, &res) == nil {
+				fn(res)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+
+	"shard/internal/attack"
+)
+
+// RetrySummary counts the requests load.retries sent more than once.
+type RetrySummary struct {
+	Retried       int `json:"retried"`
+	Recovered     int `json:"recovered"` // retried requests whose final attempt succeeded
+	ExtraAttempts int `json:"extra_attempts"`
+}
+
+func (a *Aggregator) addRetries(r attack.Result) {
+	if r.Attempts <= 1 {
+		return
+	}
+	a.retries.Retried++
+	a.retries.ExtraAttempts += r.Attempts - 1
+	if r.Error == "" && r.Code < 400 {
+		a.retries.Recovered++
+	}
+}
+
+func (a *Aggregator) retrySummary() *RetrySummary {
+	if a.retries.Retried == 0 {
+		return nil
+	}
+	s := a.retries
+	return &s
+}
+
+func printRetries(w io.Writer, s *RetrySummary, total int) {
+	pct := 0.0
+	if total > 0 {
+		pct = float64(s.Recovered) / float64(total) * 100
+	}
+	fmt.Fprintf(w, "\nRetries: %d request(s) retried, %d extra attempt(s)\n", s.Retried, s.ExtraAttempts)
+	fmt.Fprintf(w, "  %d (%.2f%% of all requests) succeeded only after retrying; their latency includes the retries\n",
+		s.Recovered, pct)
+}
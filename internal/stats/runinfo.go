@@ -0,0 +1,132 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+)
+
+// RunInfo describes the run the results came from, as its meta record tells
+// it. The first meta record read sets the target and labels; those of later
+// inputs, or a distributed run's agents, add their hosts and target URLs.
+type RunInfo struct {
+	Start    time.Time         `json:"start"` // earliest of all inputs
+	Version  string            `json:"version,omitempty"`
+	Hosts    []string          `json:"hosts,omitempty"` // every host that recorded a meta record
+	Method   string            `json:"method,omitempty"`
+	URLs     []string          `json:"urls,omitempty"` // target.url of every input, distinct and sorted
+	Rate     int               `json:"rate,omitempty"`
+	Duration string            `json:"duration,omitempty"`
+	Mode     string            `json:"mode,omitempty"` // load.mode, when not the default
+	Labels   map[string]string `json:"labels,omitempty"`
+	Suite    string            `json:"suite,omitempty"`
+	Run      string            `json:"run,omitempty"` // the run's name in the suite
+}
+
+type runInfoTracking struct {
+	info  *RunInfo
+	hosts map[string]bool
+	urls  map[string]bool
+}
+
+func (a *Aggregator) addRunInfo(m attack.MetaRecord) {
+	t := &a.runInfo
+	if t.info == nil {
+		t.info = &RunInfo{Start: m.Timestamp, Version: m.Version, Labels: m.Labels, Suite: m.Suite, Run: m.Run}
+		if c := m.Config; c != nil {
+			t.info.Method, t.info.Rate, t.info.Duration = c.Target.Method, c.Load.Rate, c.Load.Duration
+			if c.Load.Searching() && c.Load.Search != nil {
+				// steps set the rates and there is no fixed length
+				t.info.Mode, t.info.Rate, t.info.Duration = c.Load.Mode, c.Load.Search.StartRate, ""
+			}
+		}
+		t.hosts, t.urls = make(map[string]bool), make(map[string]bool)
+	}
+	if !m.Timestamp.IsZero() && m.Timestamp.Before(t.info.Start) {
+		t.info.Start = m.Timestamp
+	}
+	if m.Hostname != "" {
+		t.hosts[m.Hostname] = true
+	}
+	if c := m.Config; c != nil && c.Target.URL != "" {
+		t.urls[c.Target.URL] = true
+	}
+}
+
+func (a *Aggregator) runInfoSummary() *RunInfo {
+	t := &a.runInfo
+	if t.info == nil {
+		return nil
+	}
+	s := *t.info
+	s.Hosts, s.URLs = sortedSet(t.hosts), sortedSet(t.urls)
+	return &s
+}
+
+func sortedSet(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SameTarget reports whether two runs hit the same target URLs. Results
+// without a meta record say nothing about their target, so they match any.
+func SameTarget(a, b *RunInfo) bool {
+	if a == nil || b == nil || len(a.URLs) == 0 || len(b.URLs) == 0 {
+		return true
+	}
+	return strings.Join(a.URLs, " ") == strings.Join(b.URLs, " ")
+}
+
+func printRunInfo(w io.Writer, r *RunInfo) {
+	fmt.Fprintln(w, "\n=== Run ===")
+	target := strings.Join(r.URLs, ", ")
+	if target == "" {
+		target = "(not recorded)"
+	}
+	fmt.Fprintf(w, "  target : %s %s", r.Method, target)
+	switch {
+	case r.Mode == config.ModeSearch:
+		fmt.Fprintf(w, ", rate search from %d/s", r.Rate)
+	case r.Rate > 0:
+		fmt.Fprintf(w, " at %d/s", r.Rate)
+	}
+	if r.Duration != "" {
+		fmt.Fprintf(w, " for %s", r.Duration)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  started: %s", r.Start.UTC().Format("2006-01-02 15:04:05 MST"))
+	if len(r.Hosts) > 0 {
+		fmt.Fprintf(w, " on %s", strings.Join(r.Hosts, ", "))
+	}
+	if r.Version != "" {
+		fmt.Fprintf(w, " (shard %s)", r.Version)
+	}
+	fmt.Fprintln(w)
+	if len(r.Labels) > 0 {
+		keys := make([]string, 0, len(r.Labels))
+		for k := range r.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, "  labels :")
+		for _, k := range keys {
+			fmt.Fprintf(w, " %s=%s", k, r.Labels[k])
+		}
+		fmt.Fprintln(w)
+	}
+	if r.Run != "" {
+		fmt.Fprintf(w, "  suite  : run %q of %s\n", r.Run, r.Suite)
+	}
+}
@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+
+	"shard/internal/attack"
+)
+
+// ScenarioSummary covers a scenario run: whole iterations and each step.
+type ScenarioSummary struct {
+	Iterations int           `json:"iterations"`
+	Failed     int           `json:"failed"`
+	Steps      []StepSummary `json:"steps"` // in the order steps were first seen
+}
+
+// StepSummary is the latency and failure count of one scenario step.
+type StepSummary struct {
+	Name    string       `json:"name"`
+	Failed  int          `json:"failed"` // transport errors and statuses >= 400
+	Latency PhaseSummary `json:"latency"`
+}
+
+type stepStats struct {
+	failed int
+	total  *phaseStats
+}
+
+func (a *Aggregator) addStep(r attack.Result) {
+	if r.Step == "" {
+		return
+	}
+	st := a.steps[r.Step]
+	if st == nil {
+		st = &stepStats{total: &phaseStats{Min: 1e9}}
+		a.steps[r.Step] = st
+		a.stepOrder = append(a.stepOrder, r.Step)
+	}
+	st.total.add(r.Phases.Total)
+	if r.Error != "" || r.Code >= 400 {
+		st.failed++
+	}
+	switch r.Outcome {
+	case "ok":
+		a.iterations++
+	case "failed":
+		a.iterations++
+		a.iterationsFailed++
+	}
+}
+
+func (a *Aggregator) scenarioSummary() *ScenarioSummary {
+	if len(a.stepOrder) == 0 {
+		return nil
+	}
+	s := &ScenarioSummary{Iterations: a.iterations, Failed: a.iterationsFailed}
+	for _, name := range a.stepOrder {
+		st := a.steps[name]
+		s.Steps = append(s.Steps, StepSummary{Name: name, Failed: st.failed, Latency: st.total.summary()})
+	}
+	return s
+}
+
+func printScenario(w io.Writer, s *ScenarioSummary) {
+	rate := 0.0
+	if s.Iterations > 0 {
+		rate = float64(s.Failed) / float64(s.Iterations) * 100
+	}
+	fmt.Fprintf(w, "\nScenario: %d iterations, %d failed (%.2f%%)\n", s.Iterations, s.Failed, rate)
+	fmt.Fprintf(w, "  %-16s %8s %8s %10s %10s %10s %10s\n", "Step", "Count", "Failed", "Avg", "P50", "P95", "P99")
+	for _, st := range s.Steps {
+		l := st.Latency
+		fmt.Fprintf(w, "  %-16s %8d %8d %10.2f %10.2f %10.2f %10.2f\n", st.Name, l.Count, st.Failed, l.Avg, l.P50, l.P95, l.P99)
+	}
+}
@@ -0,0 +1,123 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"shard/internal/attack"
+)
+
+// SearchSummary is a load.mode search: the highest rate that passed, and
+// every step with the latencies of its results. Without the run's search
+// record (a run cut short) the steps are listed in the order seen, with no
+// verdict.
+type SearchSummary struct {
+	MaxRate int                 `json:"max_rate"`
+	Stopped string              `json:"stopped,omitempty"`
+	Steps   []SearchStepSummary `json:"steps"`
+}
+
+// SearchStepSummary is one search step.
+type SearchStepSummary struct {
+	Rate      int          `json:"rate"`
+	Requests  int          `json:"requests"`
+	ErrorRate float64      `json:"error_rate"`
+	Total     PhaseSummary `json:"total"`
+	OK        *bool        `json:"ok,omitempty"`
+	Reason    string       `json:"reason,omitempty"`
+}
+
+type searchTracking struct {
+	steps  map[int]*searchStepStats // by rate
+	order  []int
+	record *attack.SearchRecord
+}
+
+type searchStepStats struct {
+	count, failed int
+	total         phaseStats
+}
+
+func (a *Aggregator) addSearch(r attack.Result) {
+	if r.StageRate == 0 {
+		return
+	}
+	t := &a.search
+	if t.steps == nil {
+		t.steps = make(map[int]*searchStepStats)
+	}
+	st, ok := t.steps[r.StageRate]
+	if !ok {
+		st = &searchStepStats{total: phaseStats{Min: 1e9}}
+		t.steps[r.StageRate] = st
+		t.order = append(t.order, r.StageRate)
+	}
+	st.count++
+	if r.Error != "" {
+		st.failed++
+	}
+	st.total.add(r.Phases.Total)
+}
+
+func (a *Aggregator) addSearchRecord(line []byte) {
+	var rec attack.SearchRecord
+	if json.Unmarshal(line, &rec) == nil {
+		a.search.record = &rec
+	}
+}
+
+func (a *Aggregator) searchSummary() *SearchSummary {
+	t := &a.search
+	if t.record == nil && len(t.steps) == 0 {
+		return nil
+	}
+	s := &SearchSummary{}
+	step := func(rate int) SearchStepSummary {
+		v := SearchStepSummary{Rate: rate}
+		if st, ok := t.steps[rate]; ok {
+			v.Requests = st.count
+			v.ErrorRate = float64(st.failed) / float64(st.count)
+			v.Total = st.total.summary()
+		}
+		return v
+	}
+	if t.record == nil {
+		for _, rate := range t.order {
+			s.Steps = append(s.Steps, step(rate))
+		}
+		return s
+	}
+	s.MaxRate, s.Stopped = t.record.MaxRate, t.record.Stopped
+	for _, v := range t.record.Steps {
+		st := step(v.Rate)
+		ok := v.OK
+		st.OK, st.Reason = &ok, v.Reason
+		s.Steps = append(s.Steps, st)
+	}
+	return s
+}
+
+func printSearch(w io.Writer, s *SearchSummary, f numFormat) {
+	fmt.Fprintln(w, "\nRate search:")
+	switch {
+	case s.Stopped == "":
+		fmt.Fprintln(w, "  the search did not finish; steps so far:")
+	case s.MaxRate == 0:
+		fmt.Fprintf(w, "  no step passed (%s)\n", s.Stopped)
+	default:
+		fmt.Fprintf(w, "  max sustainable rate: %d/s (%s)\n", s.MaxRate, s.Stopped)
+	}
+	fmt.Fprintf(w, "  %-4s %-9s %-9s %-8s %-10s %-10s %-10s %s\n", "Step", "Rate", "Requests", "Err%", "P50", "P95", "P99", "Verdict")
+	for i, st := range s.Steps {
+		verdict := ""
+		if st.OK != nil {
+			verdict = "✅ passed"
+			if !*st.OK {
+				verdict = "❌ " + st.Reason
+			}
+		}
+		fmt.Fprintf(w, "  %-4d %-9s %-9s %-8.2f %-10s %-10s %-10s %s\n", i+1, fmt.Sprintf("%d/s", st.Rate),
+			f.count(st.Requests), st.ErrorRate*100, f.ms(st.Total.P50), f.ms(st.Total.P95), f.ms(st.Total.P99), verdict)
+	}
+}
@@ -0,0 +1,51 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"shard/internal/attack"
+)
+
+// skewWarn is the median Date skew (either direction) above which the report warns.
+const skewWarn = 2 * time.Second
+
+// SkewSummary is the distribution of server Date minus client clock, in
+// milliseconds; positive means the server clock is ahead.
+type SkewSummary struct {
+	PhaseSummary
+	// Missing counts responses without a parseable Date header.
+	Missing int `json:"missing"`
+}
+
+func (a *Aggregator) addSkew(r attack.Result) {
+	switch {
+	case r.DateSkew != nil:
+		a.dateSkew.add(*r.DateSkew)
+	case r.NoDate:
+		a.noDate++
+	}
+}
+
+func (a *Aggregator) skewSummary() *SkewSummary {
+	if a.dateSkew.Count == 0 && a.noDate == 0 {
+		return nil
+	}
+	return &SkewSummary{PhaseSummary: a.dateSkew.summary(), Missing: a.noDate}
+}
+
+func printSkew(w io.Writer, s *SkewSummary) {
+	fmt.Fprintln(w, "\nDate header skew (ms, server minus client, 1s resolution):")
+	if s.Count > 0 {
+		fmt.Fprintf(w, "  min=%.0f p50=%.0f p95=%.0f max=%.0f\n", s.Min, s.P50, s.P95, s.Max)
+	}
+	if s.Missing > 0 {
+		fmt.Fprintf(w, "  %d response(s) without a parseable Date header\n", s.Missing)
+	}
+	if s.Count > 0 && math.Abs(s.P50) > float64(skewWarn.Milliseconds()) {
+		fmt.Fprintf(w, "  ⚠️  median skew %.1fs exceeds %v: check clocks or queueing in front proxies\n",
+			s.P50/1000, skewWarn)
+	}
+}
@@ -0,0 +1,173 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+)
+
+// ErrThresholds is returned when results fail a configured latency threshold.
+var ErrThresholds = errors.New("latency thresholds failed")
+
+// ThresholdResult is the verdict on one config.Threshold. Observed values
+// use the same measured requests as the report's total latency.
+type ThresholdResult struct {
+	Rule       string  `json:"rule"` // e.g. "p95 <= 300ms per-bucket"
+	Percentile float64 `json:"percentile"`
+	MaxMs      float64 `json:"max_ms"`
+	Mode       string  `json:"mode"`
+	ObservedMs float64 `json:"observed_ms"` // over the whole run
+	Pass       bool    `json:"pass"`
+
+	// per-bucket mode only
+	MinPass       float64     `json:"min_pass,omitempty"`
+	PassShare     float64     `json:"pass_share,omitempty"` // of the buckets with requests
+	BucketSeconds int         `json:"bucket_seconds,omitempty"`
+	Buckets       []SLABucket `json:"buckets,omitempty"`
+}
+
+// SLABucket is one time bucket of a per-bucket threshold.
+type SLABucket struct {
+	Offset     int     `json:"offset_s"` // seconds from the run start
+	Requests   int     `json:"requests"`
+	ObservedMs float64 `json:"observed_ms"`
+	Pass       bool    `json:"pass"`
+	Skipped    bool    `json:"skipped,omitempty"` // no requests; not part of the verdict
+}
+
+// SetThresholds enables threshold verdicts, see config.Threshold. Call it
+// before adding results: per-second latencies are only kept for per-bucket
+// thresholds.
+func (a *Aggregator) SetThresholds(ts []config.Threshold) {
+	a.thresholds = ts
+	for _, t := range ts {
+		if t.Mode == "per-bucket" && a.slaSecs == nil {
+			a.slaSecs = make(map[int64]*digest)
+		}
+	}
+}
+
+func (a *Aggregator) addSLA(r attack.Result) {
+	if a.slaSecs == nil || r.AfterIdle || r.Timestamp.IsZero() {
+		return
+	}
+	sec := r.Timestamp.Unix()
+	d := a.slaSecs[sec]
+	if d == nil {
+		d = &digest{}
+		a.slaSecs[sec] = d
+	}
+	d.add(toMs(r.Phases.Total))
+}
+
+func (a *Aggregator) thresholdResults() []ThresholdResult {
+	if len(a.thresholds) == 0 {
+		return nil
+	}
+	perBucket, width := a.slaBuckets()
+	return judgeThresholds(a.thresholds, &a.stats["total"].digest, perBucket, width)
+}
+
+// slaBuckets regroups the per-second totals into the timeline's buckets.
+func (a *Aggregator) slaBuckets() ([]digest, int64) {
+	start, width, n := a.buckets()
+	if len(a.slaSecs) == 0 {
+		return nil, width
+	}
+	perBucket := make([]digest, n)
+	for sec, d := range a.slaSecs {
+		if i := int((sec - start) / width); i >= 0 && i < n {
+			perBucket[i].merge(d)
+		}
+	}
+	return perBucket, width
+}
+
+// judgeThresholds evaluates ts against the total latency of the whole run
+// and, for per-bucket thresholds, of each width-second bucket.
+func judgeThresholds(ts []config.Threshold, overall *digest, perBucket []digest, width int64) []ThresholdResult {
+	out := make([]ThresholdResult, 0, len(ts))
+	for _, t := range ts {
+		r := ThresholdResult{
+			Rule:       fmt.Sprintf("p%g <= %gms %s", t.Percentile, t.MaxMs, t.Mode),
+			Percentile: t.Percentile,
+			MaxMs:      t.MaxMs,
+			Mode:       t.Mode,
+			ObservedMs: overall.quantile(t.Percentile),
+		}
+		r.Pass = overall.n > 0 && r.ObservedMs <= t.MaxMs
+		if t.Mode == "per-bucket" {
+			r.MinPass = t.MinPass
+			r.BucketSeconds = int(width)
+			evaluated, passed := 0, 0
+			for i := range perBucket {
+				b := &perBucket[i]
+				sb := SLABucket{Offset: i * int(width), Requests: int(b.n)}
+				if b.n == 0 {
+					sb.Skipped = true
+				} else {
+					sb.ObservedMs = b.quantile(t.Percentile)
+					sb.Pass = sb.ObservedMs <= t.MaxMs
+					evaluated++
+					if sb.Pass {
+						passed++
+					}
+				}
+				r.Buckets = append(r.Buckets, sb)
+			}
+			if evaluated > 0 {
+				r.PassShare = float64(passed) / float64(evaluated)
+			}
+			r.Pass = evaluated > 0 && r.PassShare >= t.MinPass
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// ThresholdsFailed returns how many thresholds the summary failed.
+func (s Summary) ThresholdsFailed() int {
+	n := 0
+	for _, t := range s.Thresholds {
+		if !t.Pass {
+			n++
+		}
+	}
+	return n
+}
+
+func printThresholds(w io.Writer, ts []ThresholdResult) {
+	fmt.Fprintln(w, "\nThresholds:")
+	for _, t := range ts {
+		icon := "✅"
+		if !t.Pass {
+			icon = "❌"
+		}
+		if t.Mode != "per-bucket" {
+			fmt.Fprintf(w, "  %s %-32s observed %gms\n", icon, t.Rule, t.ObservedMs)
+			continue
+		}
+		passed, evaluated := 0, 0
+		var failing []string
+		for _, b := range t.Buckets {
+			if b.Skipped {
+				continue
+			}
+			evaluated++
+			if b.Pass {
+				passed++
+			} else {
+				failing = append(failing, fmt.Sprintf("+%ds (%gms)", b.Offset, b.ObservedMs))
+			}
+		}
+		fmt.Fprintf(w, "  %s %-32s %d/%d %ds buckets passed (%.0f%%, need %.0f%%), overall %gms\n",
+			icon, t.Rule, passed, evaluated, t.BucketSeconds, t.PassShare*100, t.MinPass*100, t.ObservedMs)
+		if len(failing) > 0 {
+			fmt.Fprintf(w, "       failing: %s\n", strings.Join(failing, ", "))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+)
+
+// ErrSLO is returned when results violate a configured SLO.
+var ErrSLO = errors.New("SLOs violated")
+
+// SLOResult is the verdict on one config.SLORule. Observed values come from
+// the same figures as the rest of the summary: a p99 rule sees the p99 of
+// the phase table.
+type SLOResult struct {
+	Rule      string  `json:"rule"` // e.g. "ttfb.p95_ms <= 100"
+	Observed  float64 `json:"observed"`
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+	Unit      string  `json:"unit,omitempty"` // "ms", "%" or "req/s"
+	Pass      bool    `json:"pass"`
+	NoData    bool    `json:"no_data,omitempty"` // nothing measured; fails
+}
+
+type sloTracking struct {
+	rules       []config.SLORule
+	unavailable int // measured requests with an error or a 5xx
+}
+
+// SetSLO enables SLO verdicts, see config.SLORule.
+func (a *Aggregator) SetSLO(rules []config.SLORule) {
+	a.slo.rules = rules
+}
+
+func (a *Aggregator) addSLO(r attack.Result) {
+	if r.Error != "" || r.Code >= 500 {
+		a.slo.unavailable++
+	}
+}
+
+// sloResults evaluates the rules against s, which holds everything but them.
+func (a *Aggregator) sloResults(s Summary) []SLOResult {
+	if len(a.slo.rules) == 0 {
+		return nil
+	}
+	return judgeSLO(a.slo.rules, s, func(phase string) *digest { return &a.stats[phase].digest }, a.slo.unavailable)
+}
+
+// judgeSLO evaluates rules against s, taking latency percentiles from the
+// phase digests and availability from the count of unavailable requests.
+func judgeSLO(rules []config.SLORule, s Summary, phase func(string) *digest, unavailable int) []SLOResult {
+	out := make([]SLOResult, 0, len(rules))
+	for _, rule := range rules {
+		r := SLOResult{Rule: rule.Rule, Op: rule.Op, Threshold: rule.Value}
+		switch rule.Metric {
+		case "latency":
+			r.Unit = "ms"
+			if r.NoData = s.Phases[rule.Phase].Count == 0; r.NoData {
+				break
+			}
+			switch rule.Stat {
+			case "p":
+				r.Observed = phase(rule.Phase).quantile(rule.Percentile)
+			case "avg":
+				r.Observed = s.Phases[rule.Phase].Avg
+			case "min":
+				r.Observed = s.Phases[rule.Phase].Min
+			case "max":
+				r.Observed = s.Phases[rule.Phase].Max
+			}
+		case "error_rate":
+			r.NoData, r.Observed = s.Requests == 0, s.ErrorRate
+		case "availability":
+			r.Unit = "%"
+			if r.NoData = s.Requests == 0; !r.NoData {
+				r.Observed = 100 * float64(s.Requests-unavailable) / float64(s.Requests)
+			}
+		case "throughput":
+			r.Unit = "req/s"
+			r.NoData, r.Observed = s.DurationSeconds == 0, s.Throughput
+		case "requests":
+			r.Observed = float64(s.Requests)
+		}
+		r.Pass = !r.NoData && sloHolds(r.Observed, rule.Op, rule.Value)
+		out = append(out, r)
+	}
+	return out
+}
+
+func sloHolds(v float64, op string, bound float64) bool {
+	switch op {
+	case "<":
+		return v < bound
+	case "<=":
+		return v <= bound
+	case ">":
+		return v > bound
+	case ">=":
+		return v >= bound
+	}
+	return false
+}
+
+// SLOFailed returns how many SLOs the summary failed.
+func (s Summary) SLOFailed() int {
+	n := 0
+	for _, r := range s.SLO {
+		if !r.Pass {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteSLO prints the SLO verdicts of a summary as a table.
+func WriteSLO(w io.Writer, rs []SLOResult) {
+	fmt.Fprintln(w, "\nSLOs:")
+	fmt.Fprintf(w, "  %-24s %-14s %-14s %s\n", "Rule", "Observed", "Threshold", "Result")
+	for _, r := range rs {
+		observed, verdict := sloValue(r.Observed, r.Unit), "✅ PASS"
+		switch {
+		case r.NoData:
+			observed, verdict = "no data", "❌ FAIL"
+		case !r.Pass:
+			verdict = "❌ FAIL"
+		}
+		metric, _, _ := strings.Cut(r.Rule, r.Op)
+		fmt.Fprintf(w, "  %-24s %-14s %-14s %s\n", strings.TrimSpace(metric), observed, r.Op+" "+sloValue(r.Threshold, r.Unit), verdict)
+	}
+}
+
+func sloValue(v float64, unit string) string {
+	switch unit {
+	case "ms":
+		return fmt.Sprintf("%gms", math.Round(v*100)/100)
+	case "%":
+		return fmt.Sprintf("%g%%", math.Round(v*1000)/1000)
+	case "req/s":
+		return fmt.Sprintf("%.1f/s", v)
+	}
+	return fmt.Sprintf("%g", math.Round(v*1e6)/1e6)
+}
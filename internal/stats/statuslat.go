@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"shard/internal/attack"
+)
+
+// StatusLatency holds the latency of the requests with one outcome: a status
+// code ("503") or, for transport failures, an error class ("error:timeout").
+type StatusLatency struct {
+	Total PhaseSummary `json:"total"`
+	TTFB  PhaseSummary `json:"ttfb"`
+}
+
+type statusLatStats struct {
+	total *phaseStats
+	ttfb  *phaseStats
+}
+
+func statusKey(r attack.Result) string {
+	if r.Code == 0 {
+		return "error:" + r.Error
+	}
+	return strconv.Itoa(r.Code)
+}
+
+func (a *Aggregator) addStatusLatency(r attack.Result) {
+	key := statusKey(r)
+	s := a.byStatus[key]
+	if s == nil {
+		s = &statusLatStats{total: &phaseStats{Min: 1e9}, ttfb: &phaseStats{Min: 1e9}}
+		a.byStatus[key] = s
+	}
+	s.total.add(r.Phases.Total)
+	s.ttfb.add(r.Phases.TTFB)
+}
+
+func (a *Aggregator) statusLatencies() map[string]StatusLatency {
+	if len(a.byStatus) == 0 {
+		return nil
+	}
+	out := make(map[string]StatusLatency, len(a.byStatus))
+	for k, s := range a.byStatus {
+		out[k] = StatusLatency{Total: s.total.summary(), TTFB: s.ttfb.summary()}
+	}
+	return out
+}
+
+// statusOrder sorts status codes numerically, then error classes.
+func statusOrder(m map[string]StatusLatency) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ei, ej := strings.HasPrefix(keys[i], "error:"), strings.HasPrefix(keys[j], "error:")
+		if ei != ej {
+			return ej
+		}
+		if !ei {
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func printStatusLatency(w io.Writer, m map[string]StatusLatency) {
+	fmt.Fprintln(w, "\nLatency by status (total ms):")
+	fmt.Fprintf(w, "  %-16s %8s %10s %10s %10s %10s\n", "Status", "n", "Avg", "P50", "P95", "Max")
+	for _, k := range statusOrder(m) {
+		t := m[k].Total
+		fmt.Fprintf(w, "  %-16s %8d %10.2f %10.2f %10.2f %10.2f\n", k, t.Count, t.Avg, t.P50, t.P95, t.Max)
+	}
+}
@@ -0,0 +1,171 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTimelineBuckets caps the number of columns when the bucket width is chosen automatically.
+const maxTimelineBuckets = 12
+
+type addrCounts struct {
+	requests int
+	failed   int
+}
+
+// AddressBucket is one time bucket of a single address' traffic.
+type AddressBucket struct {
+	Requests  int     `json:"requests"`
+	Share     float64 `json:"share"`      // fraction of the bucket's addressed traffic
+	ErrorRate float64 `json:"error_rate"` // fraction of this address' requests that failed
+}
+
+// AddressSeries is the per-bucket history of one remote address.
+type AddressSeries struct {
+	Addr     string          `json:"addr"`
+	Requests int             `json:"requests"`
+	Failed   int             `json:"failed"`
+	Buckets  []AddressBucket `json:"buckets"`
+	Joined   bool            `json:"joined"` // first seen after the first bucket
+	Left     bool            `json:"left"`   // last seen before the final bucket
+}
+
+// AddressTimeline shows how traffic and failures moved between backends over the run.
+type AddressTimeline struct {
+	Start         time.Time       `json:"start"`
+	BucketSeconds int             `json:"bucket_seconds"`
+	Buckets       int             `json:"buckets"`
+	Addresses     []AddressSeries `json:"addresses"`
+}
+
+// SetBucket fixes the timeline bucket width; zero picks one automatically.
+func (a *Aggregator) SetBucket(d time.Duration) {
+	a.bucket = d
+}
+
+// addTimeline records a result into the per-address, per-second counters.
+func (a *Aggregator) addTimeline(ts time.Time, addr string, failed bool) {
+	if ts.IsZero() {
+		return
+	}
+	if a.first.IsZero() || ts.Before(a.first) {
+		a.first = ts
+	}
+	if ts.After(a.last) {
+		a.last = ts
+	}
+	if addr == "" {
+		return
+	}
+	secs := a.perAddr[addr]
+	if secs == nil {
+		secs = make(map[int64]*addrCounts)
+		a.perAddr[addr] = secs
+	}
+	c := secs[ts.Unix()]
+	if c == nil {
+		c = &addrCounts{}
+		secs[ts.Unix()] = c
+	}
+	c.requests++
+	if failed {
+		c.failed++
+	}
+}
+
+// buckets returns the run's first second, the bucket width in seconds and
+// the number of buckets that cover the run.
+func (a *Aggregator) buckets() (start, width int64, n int) {
+	start = a.first.Unix()
+	span := a.last.Unix() - start + 1
+	width = int64(a.bucket / time.Second)
+	if width <= 0 {
+		width = int64(math.Ceil(float64(span) / maxTimelineBuckets))
+	}
+	return start, width, int((span + width - 1) / width)
+}
+
+// timeline regroups the per-second counters into buckets.
+func (a *Aggregator) timeline() AddressTimeline {
+	t := AddressTimeline{Start: a.first}
+	if len(a.perAddr) == 0 {
+		return t
+	}
+	start, width, n := a.buckets()
+	t.BucketSeconds = int(width)
+	t.Buckets = n
+
+	totals := make([]int, t.Buckets)
+	for addr, secs := range a.perAddr {
+		series := AddressSeries{Addr: addr, Buckets: make([]AddressBucket, t.Buckets)}
+		failed := make([]int, t.Buckets)
+		for sec, c := range secs {
+			i := int((sec - start) / width)
+			series.Buckets[i].Requests += c.requests
+			failed[i] += c.failed
+			totals[i] += c.requests
+			series.Requests += c.requests
+			series.Failed += c.failed
+		}
+		firstSeen, lastSeen := -1, -1
+		for i := range series.Buckets {
+			b := &series.Buckets[i]
+			if b.Requests == 0 {
+				continue
+			}
+			b.ErrorRate = float64(failed[i]) / float64(b.Requests)
+			if firstSeen < 0 {
+				firstSeen = i
+			}
+			lastSeen = i
+		}
+		series.Joined = firstSeen > 0
+		series.Left = lastSeen < t.Buckets-1
+		t.Addresses = append(t.Addresses, series)
+	}
+	for i := range t.Addresses {
+		for j := range t.Addresses[i].Buckets {
+			if totals[j] > 0 {
+				b := &t.Addresses[i].Buckets[j]
+				b.Share = float64(b.Requests) / float64(totals[j])
+			}
+		}
+	}
+	sort.Slice(t.Addresses, func(i, j int) bool { return t.Addresses[i].Addr < t.Addresses[j].Addr })
+	return t
+}
+
+// printTimeline renders the timeline as a compact matrix of share%/error% per bucket.
+func printTimeline(w io.Writer, t AddressTimeline) {
+	fmt.Fprintf(w, "\nPer-address timeline (%ds buckets, share%%/err%%):\n", t.BucketSeconds)
+	fmt.Fprintf(w, "  %-22s", "Address")
+	for i := 0; i < t.Buckets; i++ {
+		fmt.Fprintf(w, " %8s", fmt.Sprintf("+%ds", i*t.BucketSeconds))
+	}
+	fmt.Fprintln(w)
+	for _, s := range t.Addresses {
+		fmt.Fprintf(w, "  %-22s", s.Addr)
+		for _, b := range s.Buckets {
+			cell := "-"
+			if b.Requests > 0 {
+				cell = fmt.Sprintf("%.0f/%.0f", b.Share*100, b.ErrorRate*100)
+			}
+			fmt.Fprintf(w, " %8s", cell)
+		}
+		var notes []string
+		if s.Joined {
+			notes = append(notes, "joined mid-run")
+		}
+		if s.Left {
+			notes = append(notes, "left mid-run")
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(w, "  ⚠️  %s", strings.Join(notes, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
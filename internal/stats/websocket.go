@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"shard/internal/attack"
+)
+
+// WebSocketSummary covers the connections of a target.protocol websocket
+// run; its messages are the run's requests. PingRTT is in milliseconds.
+type WebSocketSummary struct {
+	Messages    int           `json:"messages"`
+	Connections int           `json:"connections"`      // opened during the run
+	Closes      map[int]int   `json:"closes,omitempty"` // connections the server ended, by close code
+	PingRTT     *PhaseSummary `json:"ping_rtt,omitempty"`
+}
+
+type webSocketStats struct {
+	messages, connections int
+	closes                map[int]int
+	ping                  phaseStats
+}
+
+func (a *Aggregator) addWebSocket(r attack.Result) {
+	if r.Method != attack.WebSocketMethod {
+		return
+	}
+	t := &a.webSocket
+	if t.closes == nil {
+		t.closes = make(map[int]int)
+		t.ping.Min = 1e9
+	}
+	t.messages++
+	if !r.Reused && r.Code == http.StatusSwitchingProtocols {
+		t.connections++
+	}
+	if r.CloseCode != 0 {
+		t.closes[r.CloseCode]++
+	}
+	if r.PingRTT != nil {
+		t.ping.add(*r.PingRTT)
+	}
+}
+
+func (a *Aggregator) webSocketSummary() *WebSocketSummary {
+	t := &a.webSocket
+	if t.messages == 0 {
+		return nil
+	}
+	s := &WebSocketSummary{Messages: t.messages, Connections: t.connections}
+	if len(t.closes) > 0 {
+		s.Closes = copyMap(t.closes)
+	}
+	if t.ping.Count > 0 {
+		ping := t.ping.summary()
+		s.PingRTT = &ping
+	}
+	return s
+}
+
+func printWebSocket(w io.Writer, s *WebSocketSummary) {
+	fmt.Fprintf(w, "\nWebSocket: %d messages over %d connections", s.Messages, s.Connections)
+	if s.Connections > 0 {
+		fmt.Fprintf(w, " (%.1f per connection)", float64(s.Messages)/float64(s.Connections))
+	}
+	fmt.Fprintln(w)
+	if len(s.Closes) > 0 {
+		fmt.Fprint(w, "  closed by the server:")
+		for _, code := range sortedKeysInt(s.Closes) {
+			fmt.Fprintf(w, " %d=%d", code, s.Closes[code])
+		}
+		if s.Closes[1006] > 0 {
+			fmt.Fprint(w, " (1006: dropped without a close frame)")
+		}
+		fmt.Fprintln(w)
+	}
+	if p := s.PingRTT; p != nil {
+		fmt.Fprintf(w, "  ping rtt (ms): n=%d avg=%.2f p50=%.2f p95=%.2f max=%.2f\n", p.Count, p.Avg, p.P50, p.P95, p.Max)
+	}
+}
@@ -0,0 +1,196 @@
+// Package shard runs Shard load tests from Go programs. It is the runner
+// and report summary behind the shard command, with every result handed to
+// a callback and the JSONL records to any writer instead of files, so a
+// test harness can run an attack and check its Summary in process.
+//
+//	cfg := shard.DefaultConfig()
+//	cfg.Target.URL = srv.URL
+//	cfg.Load.Rate, cfg.Load.Duration = 100, "5s"
+//	summary, err := shard.Run(ctx, cfg, shard.Options{
+//		ResultHandler: func(r shard.Result) { ... },
+//	})
+//
+// Config fields are those of a shard.json file; output paths in it are
+// ignored here, as the run writes to Options only.
+package shard
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"shard/internal/attack"
+	"shard/internal/config"
+	"shard/internal/stats"
+)
+
+type (
+	// Config is a run's configuration, as in a shard.json file.
+	Config = config.Config
+	// Result is one request of a run, as in a results line.
+	Result = attack.Result
+	// Summary is what the report says about a run, as report -format json
+	// prints it.
+	Summary = stats.Summary
+	// Aggregator summarises results, live or read back from results files.
+	Aggregator = stats.Aggregator
+	// Runner sends the load of one config; Attack.Runner returns it for
+	// live changes such as Reload and Extend while a run is going.
+	Runner = attack.Runner
+	// Meta is what the meta record opening the results says about a run,
+	// e.g. its labels. Run fills in the rest.
+	Meta = attack.MetaRecord
+	// ResultSink receives the JSONL records of a run and is closed with it.
+	ResultSink = attack.ResultSink
+	// ThresholdError is the error of a run its abort thresholds stopped.
+	ThresholdError = attack.ThresholdError
+)
+
+// ErrInvalid marks errors caused by the config itself.
+var ErrInvalid = config.ErrInvalid
+
+// ErrOutputSizeLimit is the error of a run the output size guard stopped.
+var ErrOutputSizeLimit = attack.ErrOutputSizeLimit
+
+// DefaultConfig returns the configuration shard init writes.
+func DefaultConfig() Config { return config.DefaultConfig() }
+
+// ParseConfig reads a config from the JSON of a shard.json file, over the
+// defaults. Run validates it.
+func ParseConfig(data []byte) (Config, error) {
+	cfg, err := config.ParseConfig(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	return *cfg, nil
+}
+
+// NewAggregator returns an aggregator that prices and checks results the
+// way cfg's cost profiles, latency thresholds and SLOs say.
+func NewAggregator(cfg *Config) (*Aggregator, error) {
+	agg := stats.New()
+	profiles, err := cfg.CostProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	agg.SetPricing(profiles)
+	thresholds, err := cfg.SLAThresholds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	agg.SetThresholds(thresholds)
+	rules, err := cfg.SLORules()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	agg.SetSLO(rules)
+	return agg, nil
+}
+
+// Options says where a run's output goes. Every field is optional: without
+// any, Run only returns the Summary.
+type Options struct {
+	// ResultHandler gets every result as the run writes it, in completion
+	// order, from a single goroutine. The run's output waits for it, so it
+	// should not block.
+	ResultHandler func(Result)
+	// Output receives the JSONL records shard attack writes to
+	// output.jsonl_path: the meta record, results and typed records. It is
+	// not closed.
+	Output io.Writer
+	// Sink replaces Output with a sink the run closes (flushes) when it
+	// ends, such as a results file.
+	Sink ResultSink
+	// Terminal gets the live progress line, or the dashboard with
+	// Dashboard set; Log the progress log lines and checkpoint summaries.
+	Terminal  io.Writer
+	Log       io.Writer
+	Dashboard bool
+	// Meta opens the results with these labels and derivations.
+	Meta Meta
+}
+
+// Attack is a validated config ready to run.
+type Attack struct {
+	cfg    *Config
+	runner *Runner
+	opts   Options
+	agg    *Aggregator // of the last Run
+}
+
+// New validates cfg, filling in its defaults, and prepares its run. The
+// error wraps ErrInvalid when the config is at fault.
+func New(cfg Config, opts Options) (*Attack, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	runner, err := attack.NewRunner(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("runner init: %w", err)
+	}
+	runner.SetMeta(opts.Meta)
+	runner.UseDashboard(opts.Dashboard && opts.Terminal != nil)
+	return &Attack{cfg: &cfg, runner: runner, opts: opts}, nil
+}
+
+// Runner returns the runner, for live changes to the run.
+func (a *Attack) Runner() *Runner { return a.runner }
+
+// Aggregator returns the aggregator behind the last Run's summary, e.g. to
+// print its report; nil before the first.
+func (a *Attack) Aggregator() *Aggregator { return a.agg }
+
+// Run sends the load until the plan ends or ctx is cancelled and returns
+// the summary of what was sent. A run stopped by its abort thresholds or
+// output size guard returns its summary so far together with the error.
+func (a *Attack) Run(ctx context.Context) (*Summary, error) {
+	agg, err := NewAggregator(a.cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.agg = agg
+	a.runner.SetCheckpoints(observer{Aggregator: agg, handle: a.opts.ResultHandler})
+	sink := a.opts.Sink
+	if sink == nil {
+		sink = writerSink{a.opts.Output}
+	}
+	err = a.runner.RunSink(ctx, sink, a.opts.Terminal, a.opts.Log)
+	summary := agg.Summary()
+	return &summary, err
+}
+
+// Run validates cfg and runs it, see New and Attack.Run.
+func Run(ctx context.Context, cfg Config, opts Options) (*Summary, error) {
+	a, err := New(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return a.Run(ctx)
+}
+
+// observer is the runner's checkpointer: the aggregator behind the summary,
+// then the caller's handler, see every result.
+type observer struct {
+	*Aggregator
+	handle func(Result)
+}
+
+func (o observer) Add(r Result) {
+	o.Aggregator.Add(r)
+	if o.handle != nil {
+		o.handle(r)
+	}
+}
+
+// writerSink is Options.Output as a sink: closing it leaves the writer
+// open, and no writer discards the records.
+type writerSink struct{ w io.Writer }
+
+func (s writerSink) Write(p []byte) (int, error) {
+	if s.w == nil {
+		return len(p), nil
+	}
+	return s.w.Write(p)
+}
+
+func (writerSink) Close() error { return nil }
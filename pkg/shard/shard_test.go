@@ -0,0 +1,120 @@
+package shard_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"shard/pkg/shard"
+)
+
+// captureStderr returns what fn writes to the process's stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stderr
+	os.Stderr = w
+	got := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		got <- data
+	}()
+	defer func() {
+		os.Stderr = orig
+	}()
+	fn()
+	w.Close()
+	return string(<-got)
+}
+
+// A program runs an attack in process: every result reaches the callback,
+// the summary comes back, and nothing is written outside Options.
+func TestRun(t *testing.T) {
+	var served atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+	}))
+	defer srv.Close()
+	// the default output paths would land in the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := shard.DefaultConfig()
+	cfg.Target.URL = srv.URL
+	cfg.Load.Rate, cfg.Load.Duration = 50, "5s"
+
+	var results []shard.Result
+	var summary *shard.Summary
+	stderr := captureStderr(t, func() {
+		summary, err = shard.Run(context.Background(), cfg, shard.Options{
+			ResultHandler: func(r shard.Result) { results = append(results, r) },
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := served.Load(); n < 200 || int64(len(results)) != n {
+		t.Fatalf("the server answered %d requests, the callback got %d results", n, len(results))
+	}
+	for _, r := range results {
+		if r.Code != http.StatusOK {
+			t.Fatalf("result %+v, want 200", r)
+		}
+	}
+	if summary.Requests != len(results) || summary.Failed != 0 {
+		t.Fatalf("summary has %d requests, %d failed; the callback got %d results", summary.Requests, summary.Failed, len(results))
+	}
+	if stderr != "" {
+		t.Fatalf("the run wrote to stderr: %q", stderr)
+	}
+	if entries, _ := os.ReadDir("."); len(entries) > 0 {
+		t.Fatalf("the run created %s in the working directory", entries[0].Name())
+	}
+}
+
+// An aborted run reports through Options and the error, not stderr.
+func TestRunAbortWritesToOptionsOnly(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close() // connections are refused
+
+	cfg := shard.DefaultConfig()
+	cfg.Target.URL = srv.URL
+	cfg.Load.Rate, cfg.Load.Duration = 50, "5s"
+	cfg.Abort.ConsecutiveFailures = 3
+
+	var out, log bytes.Buffer
+	var summary *shard.Summary
+	var err error
+	stderr := captureStderr(t, func() {
+		summary, err = shard.Run(context.Background(), cfg, shard.Options{Output: &out, Log: &log})
+	})
+	var threshold *shard.ThresholdError
+	if !errors.As(err, &threshold) {
+		t.Fatalf("run ended with %v, want the abort threshold", err)
+	}
+	if summary == nil || summary.Failed == 0 {
+		t.Fatalf("summary %+v, want the failures so far", summary)
+	}
+	if stderr != "" {
+		t.Fatalf("the run wrote to stderr: %q", stderr)
+	}
+	if !bytes.Contains(log.Bytes(), []byte("abort.consecutive_failures")) ||
+		!bytes.Contains(out.Bytes(), []byte(`{"type":"annotation"`)) {
+		t.Fatalf("the abort is missing from the log or the results:\n%s", log.String())
+	}
+}